@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxAdminAuthAttempts is the number of failed logins after which an admin
+// session is dropped.
+const maxAdminAuthAttempts = 3
+
+var (
+	startTime = time.Now() // Server start, reported as uptime
+	draining  atomic.Bool  // Set while new connections are refused
+)
+
+// adminAuthRequired reports whether console sessions must log in first,
+// which is the case as soon as credentials are configured.
+func adminAuthRequired() bool {
+	return config.AdminPassword != "" || len(config.AdminTokens) > 0
+}
+
+// isLoopbackAddr reports whether the host part of addr is a loopback address.
+func isLoopbackAddr(addr string) (bool, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false, err
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback()), nil
+}
+
+// startAdminConsole serves the admin console on addr in the background.
+func startAdminConsole(addr string) error {
+	ln, err := adminListener(addr)
+	if err != nil {
+		return err
+	}
+	go serveAdminConsole(ln)
+	return nil
+}
+
+// serveAdminConsole accepts console sessions on ln until it is closed.
+func serveAdminConsole(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Error accepting admin connection: %v", err)
+			continue
+		}
+		go handleAdminConnection(conn)
+	}
+}
+
+// adminListener listens on addr for console sessions. A loopback address
+// is enough for local operators; any other address exposes the console
+// remotely and therefore requires both TLS and credentials.
+func adminListener(addr string) (net.Listener, error) {
+	loopback, err := isLoopbackAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	useTLS := config.AdminTLSCert != "" || config.AdminTLSKey != ""
+	if !loopback && (!useTLS || !adminAuthRequired()) {
+		return nil, fmt.Errorf("admin console address %s is not a loopback address; remote access requires admin_tls_cert, admin_tls_key and admin_password or admin_tokens", addr)
+	}
+
+	var ln net.Listener
+	if useTLS {
+		cert, err := tls.LoadX509KeyPair(config.AdminTLSCert, config.AdminTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading admin TLS certificate: %w", err)
+		}
+		ln, err = tls.Listen("tcp", addr, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if ln, err = net.Listen("tcp", addr); err != nil {
+		return nil, err
+	}
+	log.Printf("Admin console listening on %s (tls: %t, auth: %t)", ln.Addr(), useTLS, adminAuthRequired())
+	return ln, nil
+}
+
+// handleAdminConnection runs an admin console session. The session acts
+// as an operator that is never registered as a chat participant.
+func handleAdminConnection(conn net.Conn) {
+	defer conn.Close()
+	admin := &client{conn: conn, name: "console", ip: remoteIP(conn), operator: true}
+	if adminAuthRequired() && failures.isBlocked(admin.ip, time.Now()) {
+		return
+	}
+	log.Printf("Admin console session opened from %s", conn.RemoteAddr())
+
+	admin.send("TCP-Chat admin console. Type 'help' for commands.")
+	authenticated := !adminAuthRequired()
+	if !authenticated {
+		admin.send("Authentication required: auth <password|token>")
+	}
+	attempts := 0
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			break
+		}
+		if !authenticated {
+			secret, ok := strings.CutPrefix(line, "auth ")
+			if ok && checkAdminCredentials(strings.TrimSpace(secret)) {
+				authenticated = true
+				log.Printf("Admin console session from %s authenticated", conn.RemoteAddr())
+				admin.send("Authenticated")
+				continue
+			}
+			attempts++
+			log.Printf("Failed admin console login from %s", conn.RemoteAddr())
+			delay, blocked := failures.record(admin.ip, failureAuth, time.Now())
+			if blocked || attempts >= maxAdminAuthAttempts {
+				admin.send("Too many failed attempts")
+				break
+			}
+			time.Sleep(delay)
+			admin.send("Authentication required: auth <password|token>")
+			continue
+		}
+		runAdminCommand(admin, line)
+	}
+	log.Printf("Admin console session closed from %s", conn.RemoteAddr())
+}
+
+// checkAdminCredentials reports whether secret matches the admin password
+// or one of the admin tokens, comparing in constant time.
+func checkAdminCredentials(secret string) bool {
+	if secret == "" {
+		return false
+	}
+	match := false
+	candidates := append([]string{config.AdminPassword}, config.AdminTokens...)
+	for _, candidate := range candidates {
+		if candidate != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(candidate)) == 1 {
+			match = true
+		}
+	}
+	return match
+}
+
+// runAdminCommand executes one console command on behalf of admin.
+func runAdminCommand(admin *client, line string) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "help":
+		admin.send("Commands: clients (or list), announce <message>, kick <name> [reason], ban <name|ip> [duration] [--ip], unban <name|ip>, banlist, mute <name> [duration], unmute <name>, purge <name>, modlog <name> [count], canned [list | add <name> <text> | remove <name>], maintenance [minutes [reason]|cancel], heatmap [#room], reload, stats, drain, undrain, quit")
+	case "clients", "list":
+		for _, line := range clientSummaries() {
+			admin.send(line)
+		}
+	case "kick":
+		if len(fields) < 2 {
+			admin.send("Usage: kick <name> [reason]")
+			return
+		}
+		target := findClientByName(fields[1])
+		if target == nil {
+			admin.send(fmt.Sprintf("User %s not found", fields[1]))
+			return
+		}
+		name, reason := target.currentName(), strings.Join(fields[2:], " ")
+		kickClient(target, reason)
+		log.Printf("%s kicked %s", admin.name, name)
+		audit.record(auditEntry{Action: "kick", Actor: admin.name, Target: name, Reason: reason})
+		admin.send(fmt.Sprintf("%s has been kicked", name))
+	case "announce":
+		if len(fields) < 2 {
+			admin.send("Usage: announce <message>")
+			return
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		broadcastMessage(formatSystemMessage(text), nil)
+		log.Printf("%s announced: %s", admin.name, text)
+		admin.send("Announcement sent")
+	case "ban", "unban", "banlist", "mute", "unmute", "purge", "modlog", "canned", "maintenance", "heatmap":
+		handleCommand(admin, "/"+line, time.Now())
+	case "reload":
+		if err := reloadProfanityFilter(); err != nil {
+			admin.send(fmt.Sprintf("Error reloading profanity filter: %v", err))
+			return
+		}
+		admin.send(fmt.Sprintf("Profanity filter reloaded: %d word(s)", profanity.size()))
+	case "stats":
+		for _, line := range serverStats() {
+			admin.send(line)
+		}
+	case "drain":
+		draining.Store(true)
+		log.Printf("Server draining, new connections are refused")
+		admin.send("Draining: new connections are refused, existing clients stay connected")
+	case "undrain":
+		draining.Store(false)
+		log.Printf("Server accepting connections again")
+		admin.send("Accepting new connections again")
+	default:
+		admin.send(fmt.Sprintf("Unknown command %q, type 'help' for commands", fields[0]))
+	}
+}
+
+// kickClient disconnects target, telling it why when a reason is given.
+func kickClient(target *client, reason string) {
+	if reason != "" {
+		target.send("You have been kicked: " + reason)
+	} else {
+		target.send("You have been kicked")
+	}
+	target.conn.Close()
+}
+
+// clientSummaries describes every registered client, sorted by name.
+func clientSummaries() []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var lines []string
+	for _, c := range clients {
+		line := fmt.Sprintf("%s %s %s", c.name, c.ip, c.room)
+		if c.operator {
+			line += " (operator)"
+		} else if c.moderator {
+			line += " (moderator)"
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		lines = []string{"No clients connected"}
+	}
+	return lines
+}
+
+// serverStats returns a few lines describing the server state.
+func serverStats() []string {
+	mutex.Lock()
+	registered, connections, roomCount := len(clients), connCount, len(rooms)
+	mutex.Unlock()
+
+	chat, _, _ := writeLatency.counters(classChat)
+	pm, _, _ := writeLatency.counters(classPM)
+	return []string{
+		fmt.Sprintf("Uptime: %s", time.Since(startTime).Round(time.Second)),
+		fmt.Sprintf("Connections: %d/%d (%d registered)", connections, maxConnections, registered),
+		fmt.Sprintf("Rooms: %d", roomCount),
+		fmt.Sprintf("Written: %d chat, %d private", chat, pm),
+		fmt.Sprintf("Draining: %t", draining.Load()),
+	}
+}
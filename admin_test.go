@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartAdminConsoleRequiresLoopback(t *testing.T) {
+	t.Parallel()
+	if err := startAdminConsole("0.0.0.0:0"); err == nil {
+		t.Error("Expected a non-loopback address to be refused")
+	}
+	if err := startAdminConsole("192.0.2.1:0"); err == nil {
+		t.Error("Expected a non-loopback address to be refused")
+	}
+}
+
+func TestStartAdminConsoleRemoteRequiresTLSAndAuth(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+
+	config.AdminPassword = "secret"
+	if err := startAdminConsole("192.0.2.1:0"); err == nil {
+		t.Error("Expected a remote address without TLS to be refused")
+	}
+
+	config.AdminPassword = ""
+	config.AdminTLSCert, config.AdminTLSKey = writeTestCertificate(t)
+	if err := startAdminConsole("192.0.2.1:0"); err == nil {
+		t.Error("Expected a remote address without credentials to be refused")
+	}
+}
+
+func TestCheckAdminCredentials(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.AdminPassword = "secret"
+	config.AdminTokens = []string{"token-1", ""}
+
+	tests := []struct {
+		secret string
+		want   bool
+	}{
+		{"secret", true},
+		{"token-1", true},
+		{"", false},
+		{"wrong", false},
+		{"secret ", false},
+	}
+	for _, tt := range tests {
+		if got := checkAdminCredentials(tt.secret); got != tt.want {
+			t.Errorf("checkAdminCredentials(%q) = %t, want %t", tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestAdminConsoleOverTLS(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.AdminTokens = []string{"token-1"}
+	config.AdminTLSCert, config.AdminTLSKey = writeTestCertificate(t)
+
+	ln, err := adminListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("adminListener: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			handleAdminConnection(conn)
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	expectLine := func(want string) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		line, err := reader.ReadString('\n')
+		if err != nil || !strings.Contains(line, want) {
+			t.Fatalf("Expected %q, got %q (%v)", want, line, err)
+		}
+	}
+
+	expectLine("admin console")
+	expectLine("Authentication required")
+	fmt.Fprintln(conn, "stats")
+	expectLine("Authentication required")
+	fmt.Fprintln(conn, "auth token-1")
+	expectLine("Authenticated")
+	fmt.Fprintln(conn, "stats")
+	expectLine("Uptime:")
+}
+
+// writeTestCertificate writes a self-signed localhost certificate and key
+// into a temporary directory and returns their paths.
+func writeTestCertificate(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "admin.crt"), filepath.Join(dir, "admin.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestRunAdminCommand(t *testing.T) {
+	conn := newMockConn()
+	admin := &client{conn: conn, name: "console", operator: true}
+
+	runAdminCommand(admin, "stats")
+	if !strings.Contains(conn.writeBuffer.String(), "Uptime:") {
+		t.Errorf("Expected stats output, got %q", conn.writeBuffer.String())
+	}
+
+	runAdminCommand(admin, "drain")
+	if !draining.Load() {
+		t.Error("Expected drain to refuse new connections")
+	}
+	runAdminCommand(admin, "undrain")
+	if draining.Load() {
+		t.Error("Expected undrain to accept connections again")
+	}
+
+	runAdminCommand(admin, "kick nobody-here")
+	if !strings.Contains(conn.writeBuffer.String(), "User nobody-here not found") {
+		t.Errorf("Expected missing user message, got %q", conn.writeBuffer.String())
+	}
+
+	runAdminCommand(admin, "banlist")
+	if strings.Contains(conn.writeBuffer.String(), "Permission denied") {
+		t.Error("Expected console to run operator commands")
+	}
+}
+
+func TestServeAdminConsoleStopsWhenClosed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		serveAdminConsole(ln)
+		close(done)
+	}()
+	ln.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the accept loop to return once the listener is closed")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errAuditDisabled = errors.New("The audit log is disabled on this server")
+
+// auditEntry is one line of the audit or event log.
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Actor    string    `json:"actor"`
+	Target   string    `json:"target,omitempty"`
+	Room     string    `json:"room,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Duration string    `json:"duration,omitempty"` // Length of timed bans and mutes
+	Text     string    `json:"text,omitempty"`     // Chat message of "message" events
+	Context  []string  `json:"context,omitempty"`  // Recent messages of the target
+}
+
+// auditLog appends entries to a JSON-lines file. The audit log keeps
+// moderation events; the event log, which is off by default, keeps the
+// chat activity itself.
+type auditLog struct {
+	mu   sync.Mutex
+	path string    // Empty for logs not backed by a file
+	w    io.Writer // Nil discards the entries
+}
+
+var (
+	audit  = &auditLog{} // Active audit log, replaced by the file on startup
+	events = &auditLog{} // Active event log, replaced by the file on startup
+)
+
+// openAuditLog opens the log at path for appending. An empty path yields a
+// log that discards its entries.
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return &auditLog{}, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{path: path, w: f}, nil
+}
+
+// record appends entry, stamping it with the current time if it has none.
+func (a *auditLog) record(entry auditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error encoding audit entry: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.w == nil {
+		return
+	}
+	if _, err := a.w.Write(append(data, '\n')); err != nil {
+		log.Printf("Error writing %s: %v", a.path, err)
+	}
+}
+
+// entriesAbout reads the entries whose target is name, ignoring case,
+// oldest first.
+func (a *auditLog) entriesAbout(name string) ([]auditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.path == "" {
+		return nil, errAuditDisabled
+	}
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var found []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry auditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && strings.EqualFold(entry.Target, name) {
+			found = append(found, entry)
+		}
+	}
+	return found, scanner.Err()
+}
+
+// purgeMessages rewrites the file without the chat messages of name and
+// returns how many were removed. Other entries about name are kept.
+func (a *auditLog) purgeMessages(name string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.path == "" {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return 0, err
+	}
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry auditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Action == "message" && entry.Actor == name {
+			removed++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0o600); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return 0, err
+	}
+	if closer, ok := a.w.(io.Closer); ok {
+		closer.Close()
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		a.w = nil
+		return removed, err
+	}
+	a.w = f
+	return removed, nil
+}
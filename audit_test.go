@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.record(auditEntry{Action: "report", Actor: "alice", Target: "bob", Reason: "spam"})
+	log.record(auditEntry{Action: "report", Actor: "carol", Target: "bob", Context: []string{"bob: buy now"}})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Actor != "alice" || entries[0].Time.IsZero() {
+		t.Errorf("Unexpected first entry %+v", entries[0])
+	}
+	if len(entries[1].Context) != 1 {
+		t.Errorf("Expected the context to be kept, got %+v", entries[1])
+	}
+
+	disabled, err := openAuditLog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled.record(auditEntry{Action: "report"})
+}
+
+func TestAuditLogPurgeMessages(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	log, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.record(auditEntry{Action: "join", Actor: "mallory"})
+	log.record(auditEntry{Action: "message", Actor: "mallory", Text: "secret"})
+	log.record(auditEntry{Action: "message", Actor: "bob", Text: "reply"})
+
+	removed, err := log.purgeMessages("mallory")
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 message purged, got %d (%v)", removed, err)
+	}
+	log.record(auditEntry{Action: "leave", Actor: "bob"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if strings.Contains(got, "secret") || !strings.Contains(got, "reply") || !strings.Contains(got, `"leave"`) {
+		t.Errorf("Unexpected log after purge:\n%s", got)
+	}
+}
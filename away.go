@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"unicode/utf8"
+)
+
+// maxAwayLength is the longest away message in characters.
+const maxAwayLength = 200
+
+// awayMessage returns the away message of c, empty while it is present.
+func (c *client) awayMessage() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return c.away
+}
+
+// handleAwayCommand processes "/away [message]", which marks the client
+// as away with message, or back without one. Senders of private messages
+// are told the away message; the messages are still delivered.
+func handleAwayCommand(c *client, call commandCall) {
+	reason := call.text
+	if utf8.RuneCountInString(reason) > maxAwayLength {
+		c.send(fmt.Sprintf("Away messages are limited to %d characters", maxAwayLength))
+		return
+	}
+
+	mutex.Lock()
+	was := c.away
+	c.away = reason
+	mutex.Unlock()
+
+	switch {
+	case reason != "":
+		log.Printf("%s is away: %s", c.name, reason)
+		c.send("You are marked as away: " + reason)
+		notifyPresence(c.name, "is away: "+reason)
+	case was != "":
+		log.Printf("%s is back", c.name)
+		c.send("You are no longer marked as away")
+		notifyPresence(c.name, "is back")
+	default:
+		c.send("You are not marked as away")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAwayCommand(t *testing.T) {
+	senderConn, awayConn := newMockConn(), newMockConn()
+	sender := &client{conn: senderConn, name: "away-sender", room: defaultRoom}
+	away := &client{conn: awayConn, name: "away-user", room: defaultRoom}
+	mutex.Lock()
+	clients[senderConn], clients[awayConn] = sender, away
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, senderConn)
+		delete(clients, awayConn)
+		mutex.Unlock()
+	}()
+
+	tests := []struct {
+		message string
+		want    string
+		away    string
+	}{
+		{"/away", "You are not marked as away", ""},
+		{"/away  idle ", "You are marked as away: idle", "idle"},
+		{"/away " + strings.Repeat("x", maxAwayLength+1), "Away messages are limited", "idle"},
+		{"/away", "You are no longer marked as away", ""},
+		{"/away out for lunch", "You are marked as away: out for lunch", "out for lunch"},
+	}
+	for _, tt := range tests {
+		awayConn.writeBuffer.Reset()
+		handleAwayCommand(away, callOf(tt.message))
+		if got := awayConn.writeBuffer.String(); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%q answered %q, want %q", tt.message, got, tt.want)
+		}
+		if got := away.awayMessage(); got != tt.away {
+			t.Errorf("After %q the away message is %q, want %q", tt.message, got, tt.away)
+		}
+	}
+
+	awayConn.writeBuffer.Reset()
+	handlePrivateMessage(sender, callOf("/msg away-user ping me"))
+	if got := awayConn.writeBuffer.String(); !strings.Contains(got, "[PM from away-sender]: ping me") {
+		t.Errorf("Expected the private message to be delivered, got %q", got)
+	}
+	if got := senderConn.writeBuffer.String(); !strings.Contains(got, "away-user is away: out for lunch\n") {
+		t.Errorf("Expected the sender to be told the away message, got %q", got)
+	}
+
+	senderConn.writeBuffer.Reset()
+	handleListCommand(sender)
+	if got := senderConn.writeBuffer.String(); !strings.Contains(got, "away-user (away)") {
+		t.Errorf("Expected /list to mark the user, got %q", got)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ban is a single entry of the ban list. Target is either a nickname or,
+// when IP is set, a remote address.
+type ban struct {
+	Target  string    `json:"target"`
+	IP      bool      `json:"ip"`
+	By      string    `json:"by"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitempty"` // Zero for permanent bans
+}
+
+// active reports whether the ban is still in force at now.
+func (entry ban) active(now time.Time) bool {
+	return entry.Expires.IsZero() || now.Before(entry.Expires)
+}
+
+// banList keeps the active bans and mirrors them to a JSON file. The
+// mutes are kept in a second list of the same kind.
+type banList struct {
+	mu      sync.Mutex
+	path    string          // Empty path keeps the list in memory only
+	kind    string          // What the entries are, "ban" when empty
+	expired func(entry ban) // Called when a timed entry runs out, may be nil
+	entries []ban
+}
+
+// what returns the kind of the entries, for job keys and log lines.
+func (b *banList) what() string {
+	if b.kind == "" {
+		return "ban"
+	}
+	return b.kind
+}
+
+// jobKey names the scheduler job that lifts a timed entry.
+func (b *banList) jobKey(entry ban) string {
+	if entry.IP {
+		return b.what() + ":ip:" + strings.ToLower(entry.Target)
+	}
+	return b.what() + ":name:" + strings.ToLower(entry.Target)
+}
+
+var bans = &banList{} // Active bans, replaced by the persisted list on startup
+
+// loadBanList reads the bans stored at path. A missing file yields an
+// empty list that will be created on the first change.
+func loadBanList(path string) (*banList, error) {
+	list := &banList{path: path}
+	if err := list.load(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// load reads the entries stored at b.path and schedules their expiry.
+func (b *banList) load() error {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &b.entries); err != nil {
+		return fmt.Errorf("parsing %s: %w", b.path, err)
+	}
+	for _, entry := range b.entries {
+		b.scheduleExpiry(entry)
+	}
+	return nil
+}
+
+// scheduleExpiry arranges for a timed ban to be lifted when it runs out.
+// Permanent bans cancel any expiry pending for the same target.
+func (b *banList) scheduleExpiry(entry ban) {
+	if entry.Expires.IsZero() {
+		jobs.cancel(b.jobKey(entry))
+		return
+	}
+	jobs.schedule(b.jobKey(entry), entry.Expires, func() {
+		if !b.expire(entry.Target, entry.IP) {
+			return
+		}
+		if b.expired != nil {
+			b.expired(entry)
+			return
+		}
+		log.Printf("Ban on %s expired", entry.Target)
+	})
+}
+
+// expire removes the ban on target if it has run out, reporting whether it
+// did. A ban that was replaced by a longer or permanent one is kept.
+func (b *banList) expire(target string, ip bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.find(target, ip)
+	if i < 0 || b.entries[i].active(time.Now()) {
+		return false
+	}
+	b.entries = append(b.entries[:i], b.entries[i+1:]...)
+	if err := b.save(); err != nil {
+		log.Printf("Error saving %s list: %v", b.what(), err)
+	}
+	return true
+}
+
+// save writes the list to disk. The caller must hold b.mu.
+func (b *banList) save() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.path, data, 0o600)
+}
+
+// find returns the index of the entry for target, or -1. The caller must
+// hold b.mu.
+func (b *banList) find(target string, ip bool) int {
+	for i, entry := range b.entries {
+		if entry.IP == ip && strings.EqualFold(entry.Target, target) {
+			return i
+		}
+	}
+	return -1
+}
+
+// add bans target for the given duration, or permanently when duration is
+// zero, replacing an existing entry for the same target.
+func (b *banList) add(target string, ip bool, by string, duration time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry := ban{Target: target, IP: ip, By: by, Created: time.Now()}
+	if duration > 0 {
+		entry.Expires = entry.Created.Add(duration)
+	}
+	if i := b.find(target, ip); i >= 0 {
+		b.entries[i] = entry
+	} else {
+		b.entries = append(b.entries, entry)
+	}
+	b.scheduleExpiry(entry)
+	return b.save()
+}
+
+// remove lifts every ban on target, whether nickname or address, and
+// reports whether anything was removed.
+func (b *banList) remove(target string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := b.entries[:0]
+	for _, entry := range b.entries {
+		if strings.EqualFold(entry.Target, target) {
+			jobs.cancel(b.jobKey(entry))
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	removed := len(kept) != len(b.entries)
+	b.entries = kept
+	if !removed {
+		return false, nil
+	}
+	return true, b.save()
+}
+
+// isBanned reports whether the nickname or the remote IP is banned.
+// Either may be empty when it is not known yet.
+func (b *banList) isBanned(name, ip string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if i := b.find(name, false); name != "" && i >= 0 && b.entries[i].active(now) {
+		return true
+	}
+	if i := b.find(ip, true); ip != "" && i >= 0 && b.entries[i].active(now) {
+		return true
+	}
+	return false
+}
+
+// list returns a copy of the current entries.
+func (b *banList) list() []ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]ban(nil), b.entries...)
+}
+
+// remoteIP extracts the IP part of the connection's remote address.
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	return addrHost(addr.String())
+}
+
+// addrHost returns the host part of a "host:port" address, or the address
+// itself when it has no port.
+func addrHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// handleBanCommand processes the operator commands /ban, /unban and
+// /banlist.
+func handleBanCommand(c *client, call commandCall) {
+	args := call.args
+	switch call.name {
+	case "/ban":
+		// /ban <name|ip> [duration] [--ip]
+		var duration time.Duration
+		withIP := false
+		valid := len(args) >= 1 && len(args) <= 3
+		for _, arg := range args[min(1, len(args)):] {
+			switch {
+			case arg == "--ip" && !withIP:
+				withIP = true
+			case duration == 0 && isDuration(arg):
+				duration, _ = parseDuration(arg)
+			default:
+				valid = false
+			}
+		}
+		if !valid {
+			c.send("Usage: /ban <name|ip> [duration] [--ip]")
+			return
+		}
+		banTarget(c, args[0], duration, withIP)
+	case "/unban":
+		if len(args) != 1 {
+			c.send("Usage: /unban <name|ip>")
+			return
+		}
+		removed, err := bans.remove(args[0])
+		if err != nil {
+			log.Printf("Error saving ban list: %v", err)
+		}
+		if !removed {
+			c.send(fmt.Sprintf("%s is not banned", args[0]))
+			return
+		}
+		log.Printf("%s unbanned %s", c.name, args[0])
+		audit.record(auditEntry{Action: "unban", Actor: c.name, Target: args[0]})
+		c.send(fmt.Sprintf("%s has been unbanned", args[0]))
+	case "/banlist":
+		entries := bans.list()
+		if len(entries) == 0 {
+			c.send("No active bans")
+			return
+		}
+		loc := c.locale()
+		for _, entry := range entries {
+			kind := "name"
+			if entry.IP {
+				kind = "ip"
+			}
+			line := fmt.Sprintf("%s (%s) banned by %s on %s", entry.Target, kind, entry.By, loc.time(entry.Created))
+			if !entry.Expires.IsZero() {
+				line += fmt.Sprintf(", expires %s", loc.time(entry.Expires))
+			}
+			c.send(line)
+		}
+	}
+}
+
+// banTarget bans a nickname or address, permanently when duration is zero,
+// and disconnects every matching client. With withIP set, the current
+// address of a connected target is banned as well.
+func banTarget(c *client, target string, duration time.Duration, withIP bool) {
+	isIP := net.ParseIP(target) != nil
+	var targets []*client
+	mutex.Lock()
+	for _, other := range clients {
+		if (isIP && other.ip == target) || (!isIP && strings.EqualFold(other.name, target)) {
+			targets = append(targets, other)
+		}
+	}
+	mutex.Unlock()
+
+	if err := bans.add(target, isIP, c.name, duration); err != nil {
+		log.Printf("Error saving ban list: %v", err)
+	}
+	if withIP && !isIP {
+		for _, other := range targets {
+			if other.ip == "" {
+				continue
+			}
+			if err := bans.add(other.ip, true, c.name, duration); err != nil {
+				log.Printf("Error saving ban list: %v", err)
+			}
+		}
+	}
+
+	entry := auditEntry{Action: "ban", Actor: c.name, Target: target}
+	if duration > 0 {
+		entry.Duration = duration.String()
+	}
+	audit.record(entry)
+	if duration > 0 {
+		log.Printf("%s banned %s for %v", c.name, target, duration)
+		c.send(fmt.Sprintf("%s has been banned for %v", target, duration))
+	} else {
+		log.Printf("%s banned %s", c.name, target)
+		c.send(fmt.Sprintf("%s has been banned", target))
+	}
+	for _, other := range targets {
+		other.send("You have been banned from this server.")
+		other.conn.Close()
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBanList(t *testing.T) {
+	t.Parallel()
+	t.Run("NameAndIPBans", func(t *testing.T) {
+		t.Parallel()
+		list := &banList{}
+		if err := list.add("Mallory", false, "op", 0); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := list.add("10.0.0.7", true, "op", 0); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !list.isBanned("mallory", "") {
+			t.Error("Expected name ban to ignore case")
+		}
+		if !list.isBanned("", "10.0.0.7") {
+			t.Error("Expected IP ban to match")
+		}
+		if list.isBanned("alice", "10.0.0.8") {
+			t.Error("Expected unrelated client not to be banned")
+		}
+	})
+
+	t.Run("Unban", func(t *testing.T) {
+		t.Parallel()
+		list := &banList{}
+		list.add("bob", false, "op", 0)
+		removed, err := list.remove("bob")
+		if err != nil || !removed {
+			t.Fatalf("Expected bob to be unbanned, got %v (%v)", removed, err)
+		}
+		if list.isBanned("bob", "") {
+			t.Error("Expected bob to be allowed after unban")
+		}
+		if removed, _ := list.remove("bob"); removed {
+			t.Error("Expected second unban to report nothing removed")
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "bans.json")
+		list, err := loadBanList(path)
+		if err != nil {
+			t.Fatalf("Unexpected error loading missing file: %v", err)
+		}
+		list.add("eve", false, "op", 0)
+
+		reloaded, err := loadBanList(path)
+		if err != nil {
+			t.Fatalf("Unexpected error reloading: %v", err)
+		}
+		if !reloaded.isBanned("eve", "") {
+			t.Error("Expected ban to survive a reload")
+		}
+	})
+}
+
+func TestBanCommandRequiresOperator(t *testing.T) {
+	t.Parallel()
+	conn := newMockConn()
+	c := &client{conn: conn, name: "alice"}
+
+	if !handleCommand(c, "/ban bob", time.Now()) {
+		t.Fatal("Expected /ban to be handled")
+	}
+	if !strings.Contains(conn.writeBuffer.String(), "Permission denied") {
+		t.Errorf("Expected permission error, got %q", conn.writeBuffer.String())
+	}
+	if handleCommand(c, "/banana", time.Now()) {
+		t.Error("Expected unrelated command not to be handled")
+	}
+}
+
+func TestTimedBan(t *testing.T) {
+	t.Parallel()
+	list := &banList{}
+	if err := list.add("tempuser", false, "op", 50*time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !list.isBanned("tempuser", "") {
+		t.Fatal("Expected timed ban to be active")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if list.isBanned("tempuser", "") {
+		t.Error("Expected timed ban to have expired")
+	}
+	if len(list.list()) != 0 {
+		t.Error("Expected expired ban to be removed by the scheduler")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBotHold = time.Hour          // Hold length when the API call names none
+	maxBotHold     = 7 * 24 * time.Hour // Longest hold a bot can ask for
+)
+
+// BotConfig registers a bot. A registered bot logs in by answering the
+// name prompt with "<name> <token>" and can hold its name while offline.
+type BotConfig struct {
+	Token string `json:"token"`
+}
+
+var (
+	errBotNameReserved = errors.New("Name is reserved for a registered bot")
+	errBotToken        = errors.New("Invalid bot token")
+)
+
+// botHolds records until when each bot name stays reserved while its bot
+// is disconnected, by lower-case name.
+var botHolds = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+// lookupBot returns the registered bot using name, ignoring case.
+func lookupBot(name string) (string, BotConfig, bool) {
+	for botName, bot := range config.Bots {
+		if strings.EqualFold(botName, name) {
+			return botName, bot, true
+		}
+	}
+	return "", BotConfig{}, false
+}
+
+// validBotToken reports whether token belongs to the registered bot name.
+func validBotToken(name, token string) bool {
+	_, bot, ok := lookupBot(name)
+	return ok && bot.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(bot.Token)) == 1
+}
+
+// authenticatedName returns the configured name of the identity called
+// name when logging in under it takes a credential. Registered bots with a
+// token are the only such identities; any other name can be taken by
+// whoever connects with it first.
+func authenticatedName(name string) (string, bool) {
+	botName, bot, ok := lookupBot(name)
+	return botName, ok && bot.Token != ""
+}
+
+// splitBotLogin separates "<name> <token>" answers from registered bots.
+// Any other answer is returned unchanged as the name.
+func splitBotLogin(line string) (name, token string) {
+	fields := strings.Fields(line)
+	if len(fields) == 2 {
+		if _, _, ok := lookupBot(fields[0]); ok {
+			return fields[0], fields[1]
+		}
+	}
+	return line, ""
+}
+
+// botNameHeld reports whether the name of a registered bot is on hold.
+func botNameHeld(name string, now time.Time) bool {
+	botHolds.Lock()
+	defer botHolds.Unlock()
+	return now.Before(botHolds.until[strings.ToLower(name)])
+}
+
+// checkBotName decides whether name may be registered with token. It
+// reports whether the client logs in as the bot.
+func checkBotName(name, token string, now time.Time) (bool, error) {
+	if _, _, ok := lookupBot(name); !ok {
+		return false, nil
+	}
+	if token != "" {
+		if !validBotToken(name, token) {
+			return false, errBotToken
+		}
+		return true, nil
+	}
+	if botNameHeld(name, now) {
+		return false, errBotNameReserved
+	}
+	return false, nil
+}
+
+// holdBotName reserves the name of a registered bot until until; a zero
+// time releases the hold.
+func holdBotName(name string, until time.Time) {
+	botHolds.Lock()
+	defer botHolds.Unlock()
+	if until.IsZero() {
+		delete(botHolds.until, strings.ToLower(name))
+		return
+	}
+	botHolds.until[strings.ToLower(name)] = until
+}
+
+// serveBotHold handles the bot API at /bots/hold. POST with the form
+// values name and optional duration holds the name; DELETE with the name
+// in the query string releases it. Requests authenticate with "Authorization: Bearer <token>".
+func serveBotHold(w http.ResponseWriter, r *http.Request) {
+	// Addresses that keep guessing tokens are turned away unheard
+	ip := addrHost(r.RemoteAddr)
+	if failures.isBlocked(ip, time.Now()) {
+		http.Error(w, "too many failed attempts", http.StatusTooManyRequests)
+		return
+	}
+	name := r.FormValue("name")
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	botName, _, ok := lookupBot(name)
+	if !ok || !validBotToken(name, token) {
+		failures.record(ip, failureAuth, time.Now())
+		http.Error(w, "unknown bot or invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		hold := defaultBotHold
+		if value := r.FormValue("duration"); value != "" {
+			d, err := parseDuration(value)
+			if err != nil || d <= 0 || d > maxBotHold {
+				http.Error(w, "duration must be positive and at most 7d", http.StatusBadRequest)
+				return
+			}
+			hold = d
+		}
+		until := time.Now().Add(hold)
+		holdBotName(botName, until)
+		log.Printf("Bot %s holds its name until %s", botName, until.Format(time.RFC3339))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"name": botName, "held_until": until.Format(time.RFC3339)})
+	case http.MethodDelete:
+		holdBotName(botName, time.Time{})
+		log.Printf("Bot %s released its name", botName)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
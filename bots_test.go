@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitBotLogin(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.Bots = map[string]BotConfig{"DeployBot": {Token: "s3cret"}}
+
+	tests := []struct {
+		line, name, token string
+	}{
+		{"deploybot s3cret", "deploybot", "s3cret"},
+		{"DeployBot", "DeployBot", ""},
+		{"alice secret", "alice secret", ""},
+		{"alice", "alice", ""},
+	}
+	for _, tt := range tests {
+		name, token := splitBotLogin(tt.line)
+		if name != tt.name || token != tt.token {
+			t.Errorf("splitBotLogin(%q) = %q, %q, want %q, %q", tt.line, name, token, tt.name, tt.token)
+		}
+	}
+}
+
+func TestCheckBotName(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.Bots = map[string]BotConfig{"deploybot": {Token: "s3cret"}}
+	defer holdBotName("deploybot", time.Time{})
+	now := time.Now()
+
+	if isBot, err := checkBotName("alice", "", now); isBot || err != nil {
+		t.Errorf("unregistered name: got %v, %v", isBot, err)
+	}
+	if isBot, err := checkBotName("deploybot", "s3cret", now); !isBot || err != nil {
+		t.Errorf("valid token: got %v, %v", isBot, err)
+	}
+	if _, err := checkBotName("deploybot", "wrong", now); err != errBotToken {
+		t.Errorf("wrong token: got %v, want %v", err, errBotToken)
+	}
+	if isBot, err := checkBotName("DeployBot", "", now); isBot || err != nil {
+		t.Errorf("name without hold: got %v, %v", isBot, err)
+	}
+
+	holdBotName("deploybot", now.Add(time.Hour))
+	if _, err := checkBotName("DeployBot", "", now); err != errBotNameReserved {
+		t.Errorf("held name: got %v, want %v", err, errBotNameReserved)
+	}
+	if isBot, err := checkBotName("deploybot", "s3cret", now); !isBot || err != nil {
+		t.Errorf("bot during hold: got %v, %v", isBot, err)
+	}
+	if _, err := checkBotName("deploybot", "", now.Add(2*time.Hour)); err != nil {
+		t.Errorf("expired hold: got %v", err)
+	}
+}
+
+func TestServeBotHold(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.Bots = map[string]BotConfig{"deploybot": {Token: "s3cret"}}
+	defer holdBotName("deploybot", time.Time{})
+
+	request := func(method, token string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/bots/hold?"+form.Encode(), nil)
+		if method == http.MethodPost {
+			req = httptest.NewRequest(method, "/bots/hold", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		serveBotHold(rec, req)
+		return rec
+	}
+
+	t.Run("rejects bad token", func(t *testing.T) {
+		rec := request(http.MethodPost, "wrong", url.Values{"name": {"deploybot"}})
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+		if botNameHeld("deploybot", time.Now()) {
+			t.Error("name held after rejected request")
+		}
+	})
+
+	t.Run("rejects long duration", func(t *testing.T) {
+		rec := request(http.MethodPost, "s3cret", url.Values{"name": {"deploybot"}, "duration": {"8d"}})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("holds and releases", func(t *testing.T) {
+		rec := request(http.MethodPost, "s3cret", url.Values{"name": {"deploybot"}, "duration": {"2h"}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		if !botNameHeld("deploybot", time.Now().Add(90*time.Minute)) {
+			t.Error("name not held for the requested duration")
+		}
+
+		rec = request(http.MethodDelete, "s3cret", url.Values{"name": {"deploybot"}})
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+		}
+		if botNameHeld("deploybot", time.Now()) {
+			t.Error("name still held after release")
+		}
+	})
+}
+
+func TestServeBotHoldBlocksGuessing(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	defer func(saved *failureTracker) { failures = saved }(failures)
+	config.Bots = map[string]BotConfig{"deploybot": {Token: "s3cret"}}
+	config.Failures = FailureSettings{Limit: 3, Window: 600, Block: 60}
+	failures = newFailureTracker()
+	defer holdBotName("deploybot", time.Time{})
+
+	request := func(token string) int {
+		form := url.Values{"name": {"deploybot"}}
+		req := httptest.NewRequest(http.MethodPost, "/bots/hold", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		serveBotHold(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := request("guess"); code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status = %d, want %d", i+1, code, http.StatusUnauthorized)
+		}
+	}
+	if code := request("s3cret"); code != http.StatusTooManyRequests {
+		t.Errorf("blocked address with the right token: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if botNameHeld("deploybot", time.Now()) {
+		t.Error("name held for a blocked address")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxCannedLength is the longest canned reply in characters.
+const maxCannedLength = 1024
+
+// cannedStore holds the canned replies defined by operators, mirrored to a
+// JSON file.
+type cannedStore struct {
+	mu      sync.Mutex
+	path    string            // Empty path keeps the store in memory only
+	Replies map[string]string `json:"replies"` // Reply text by name
+}
+
+var canned = newCannedStore("") // Active canned replies, replaced by the persisted store on startup
+
+func newCannedStore(path string) *cannedStore {
+	return &cannedStore{path: path, Replies: make(map[string]string)}
+}
+
+// loadCannedStore reads the replies stored at path. A missing file yields
+// an empty store that will be created on the first change.
+func loadCannedStore(path string) (*cannedStore, error) {
+	store := newCannedStore(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if store.Replies == nil {
+		store.Replies = make(map[string]string)
+	}
+	return store, nil
+}
+
+// save writes the store to disk. The caller must hold s.mu.
+func (s *cannedStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0o600)
+}
+
+// set defines or replaces the reply called name.
+func (s *cannedStore) set(name, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validGroupName(name) {
+		return fmt.Errorf("Invalid canned reply name %q", name)
+	}
+	s.Replies[name] = text
+	return s.save()
+}
+
+func (s *cannedStore) remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.Replies[name]; !exists {
+		return fmt.Errorf("No canned reply %q", name)
+	}
+	delete(s.Replies, name)
+	return s.save()
+}
+
+// get returns the reply called name and whether it exists.
+func (s *cannedStore) get(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	text, exists := s.Replies[name]
+	return text, exists
+}
+
+// names returns the reply names in sorted order.
+func (s *cannedStore) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.Replies))
+	for name := range s.Replies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unquote strips one pair of double quotes around text, so replies can be
+// written as /canned add hours "We're open 9-5 UTC".
+func unquote(text string) string {
+	if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
+
+// handleCannedCommand processes "/canned [list | add <name> <text> |
+// remove <name>]" and "/c <name>". Moderators list and send the replies,
+// operators define them.
+func handleCannedCommand(c *client, call commandCall) {
+	if call.name == "/c" {
+		sendCanned(c, call)
+		return
+	}
+
+	args := call.args
+	usage := "Usage: /canned [list | add <name> <text> | remove <name>]"
+	if !manages(args) {
+		names := canned.names()
+		if len(names) == 0 {
+			c.send("No canned replies defined")
+			return
+		}
+		for _, name := range names {
+			text, _ := canned.get(name)
+			c.send(fmt.Sprintf("%s: %s", name, text))
+		}
+		return
+	}
+
+	var err error
+	switch {
+	case args[0] == "add" && len(args) >= 3:
+		text := unquote(call.textAfter(2))
+		if text == "" || len([]rune(text)) > maxCannedLength {
+			c.send(fmt.Sprintf("Canned replies must have 1 to %d characters", maxCannedLength))
+			return
+		}
+		err = canned.set(args[1], text)
+	case args[0] == "remove" && len(args) == 2:
+		err = canned.remove(args[1])
+	default:
+		c.send(usage)
+		return
+	}
+	if err != nil {
+		c.send(err.Error())
+		return
+	}
+	log.Printf("%s ran %s %s", c.name, call.name, call.text)
+	c.send("Canned replies updated")
+}
+
+// sendCanned posts the canned reply named by "/c <name>" to the room of c
+// as a chat message from c.
+func sendCanned(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send("Usage: /c <name>")
+		return
+	}
+	text, exists := canned.get(call.args[0])
+	if !exists {
+		c.send(fmt.Sprintf("No canned reply %q, see /canned list", call.args[0]))
+		return
+	}
+	line, err := formatChatMessage(c.name, text)
+	if err != nil {
+		log.Printf("Refusing canned reply from %s: %v", c.name, err)
+		return
+	}
+	postToRoom(c.room, line, c.conn, call.received)
+	events.record(auditEntry{Time: call.received, Action: "message", Actor: c.name, Room: c.room, Text: text})
+	// The sender did not type the text, so they get the line as well
+	c.send(line)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCannedStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "canned.json")
+	store, err := loadCannedStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.set("hours", "We're open 9-5 UTC"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.set("faq", "See the pinned FAQ")
+	if err := store.set("bad name", "x"); err == nil {
+		t.Error("Expected a name with a space to be rejected")
+	}
+	store.remove("faq")
+	if err := store.remove("faq"); err == nil {
+		t.Error("Expected removing a missing reply to fail")
+	}
+
+	reloaded, err := loadCannedStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error reloading: %v", err)
+	}
+	if text, ok := reloaded.get("hours"); !ok || text != "We're open 9-5 UTC" {
+		t.Errorf("Expected the reply to persist, got %q", text)
+	}
+	if names := reloaded.names(); strings.Join(names, ",") != "hours" {
+		t.Errorf("Expected only hours, got %v", names)
+	}
+}
+
+func TestCannedCommand(t *testing.T) {
+	defer func(saved *cannedStore) { canned = saved }(canned)
+	canned = newCannedStore("")
+
+	const room = "#support-canned"
+	opConn, modConn, userConn := newMockConn(), newMockConn(), newMockConn()
+	op := &client{conn: opConn, name: "op", operator: true, room: room}
+	mod := &client{conn: modConn, name: "mod", moderator: true, room: room}
+	user := &client{conn: userConn, name: "visitor", room: room}
+	mutex.Lock()
+	clients[opConn], clients[modConn], clients[userConn] = op, mod, user
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, opConn)
+		delete(clients, modConn)
+		delete(clients, userConn)
+		mutex.Unlock()
+	}()
+
+	handleCommand(mod, `/canned add hours "We're open"`, time.Now())
+	if !strings.Contains(modConn.writeBuffer.String(), "operator only") {
+		t.Error("Expected moderators to be refused defining replies")
+	}
+	handleCommand(user, "/c hours", time.Now())
+	if !strings.Contains(userConn.writeBuffer.String(), "moderator only") {
+		t.Error("Expected regular users to be refused")
+	}
+
+	handleCommand(op, `/canned add hours "We're open 9-5 UTC"`, time.Now())
+	if text, _ := canned.get("hours"); text != "We're open 9-5 UTC" {
+		t.Fatalf("Expected the quotes to be stripped, got %q", text)
+	}
+
+	modConn.writeBuffer.Reset()
+	handleCommand(mod, "/canned", time.Now())
+	if !strings.Contains(modConn.writeBuffer.String(), "hours: We're open 9-5 UTC") {
+		t.Errorf("Expected the list to show the reply, got %q", modConn.writeBuffer.String())
+	}
+
+	modConn.writeBuffer.Reset()
+	userConn.writeBuffer.Reset()
+	handleCommand(mod, "/c hours", time.Now())
+	for who, conn := range map[string]*mockConn{"sender": modConn, "room": userConn} {
+		if !strings.Contains(conn.writeBuffer.String(), "mod: We're open 9-5 UTC") {
+			t.Errorf("Expected the %s to see the reply, got %q", who, conn.writeBuffer.String())
+		}
+	}
+
+	handleCommand(mod, "/c missing", time.Now())
+	if !strings.Contains(modConn.writeBuffer.String(), `No canned reply "missing"`) {
+		t.Error("Expected an unknown reply to be reported")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Optional protocol features a client can enable with "/cap req".
+const (
+	capTyping   = "typing"   // Typing notices of people in the same room
+	capReplay   = "replay"   // Framing of history replays
+	capIDs      = "ids"      // IDs of broadcast messages
+	capAcks     = "acks"     // Acknowledgement of private messages
+	capPing     = "ping"     // Heartbeats from the server
+	capResume   = "resume"   // Session tokens for resuming after a reconnect
+	capMentions = "mentions" // Flag on chat messages that mention the recipient
+)
+
+// capability describes an optional protocol feature.
+type capability struct {
+	name        string
+	description string
+}
+
+// capabilities are the features the server advertises, in the order they
+// are listed.
+var capabilities = []capability{
+	{capTyping, "Receive TYPING <name> when someone in your room starts typing"},
+	{capReplay, "Receive REPLAY <room> <n> before and REPLAY END <room> after the history of a room"},
+	{capIDs, "Receive @id=<n> before every broadcast message, history included"},
+	{capPing, "Receive PING <token> at the configured interval and answer PONG <token>, or be disconnected"},
+	{capResume, "Receive SESSION <token> after login; reconnect with " + resumeToken + "<token>:<last message ID> in the handshake to get only the messages you missed"},
+	{capMentions, "Receive " + strings.TrimSpace(mentionTag) + " before chat messages, history included, that mention you as @name"},
+	{capAcks, "Acknowledge private messages with /ack <id> or get them again; learn with PM DELIVERED|QUEUED|FAILED <id> <user> what became of yours"},
+}
+
+// capSet holds the capabilities a client enabled. It is read by other
+// clients' goroutines when they decide what to send.
+type capSet struct {
+	mu      sync.Mutex
+	enabled map[string]bool
+}
+
+// has reports whether the capability called name is enabled.
+func (s *capSet) has(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled[name]
+}
+
+func (s *capSet) set(name string, on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enabled == nil {
+		s.enabled = make(map[string]bool)
+	}
+	if on {
+		s.enabled[name] = true
+	} else {
+		delete(s.enabled, name)
+	}
+}
+
+// names returns the enabled capabilities in sorted order.
+func (s *capSet) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.enabled))
+	for name := range s.enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// capabilityNames lists the advertised capabilities.
+func capabilityNames() []string {
+	names := make([]string, len(capabilities))
+	for i, cap := range capabilities {
+		names[i] = cap.name
+	}
+	return names
+}
+
+func isCapability(name string) bool {
+	for _, cap := range capabilities {
+		if cap.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// capListLine advertises the capabilities. Clients that negotiate the
+// protocol version get it right after the version reply.
+func capListLine() string {
+	return "CAP LS " + strings.Join(capabilityNames(), " ")
+}
+
+// handleCapCommand processes "/cap [ls|list|req <cap>...]". A request
+// enables every capability it names, or disables those prefixed with '-',
+// and is acknowledged with "CAP ACK"; a request naming an unknown
+// capability changes nothing and gets "CAP NAK".
+func handleCapCommand(c *client, call commandCall) {
+	args := call.args
+	switch {
+	case len(args) == 0 || (len(args) == 1 && args[0] == "ls"):
+		c.send(capListLine())
+	case len(args) == 1 && args[0] == "list":
+		c.send(strings.TrimSpace("CAP LIST " + strings.Join(c.caps.names(), " ")))
+	case len(args) > 1 && args[0] == "req":
+		requested := args[1:]
+		for _, token := range requested {
+			if !isCapability(strings.TrimPrefix(token, "-")) {
+				c.send("CAP NAK " + strings.Join(requested, " "))
+				return
+			}
+		}
+		for _, token := range requested {
+			name, off := strings.CutPrefix(token, "-")
+			c.caps.set(name, !off)
+		}
+		c.send("CAP ACK " + strings.Join(requested, " "))
+	default:
+		c.send(fmt.Sprintf("Usage: /cap [ls|list|req <cap>...], capabilities: %s", strings.Join(capabilityNames(), ", ")))
+	}
+}
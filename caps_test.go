@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapCommand(t *testing.T) {
+	t.Parallel()
+	conn := newMockConn()
+	c := &client{conn: conn, name: "alice"}
+
+	tests := []struct {
+		message string
+		want    string
+		enabled string
+	}{
+		{"/cap", "CAP LS typing", ""},
+		{"/cap req typing", "CAP ACK typing", "typing"},
+		{"/cap list", "CAP LIST typing", "typing"},
+		{"/cap req -typing bogus", "CAP NAK -typing bogus", "typing"},
+		{"/cap req -typing", "CAP ACK -typing", ""},
+		{"/cap list", "CAP LIST", ""},
+		{"/cap frobnicate", "Usage: /cap", ""},
+	}
+	for _, tt := range tests {
+		conn.writeBuffer.Reset()
+		handleCapCommand(c, callOf(tt.message))
+		if got := conn.writeBuffer.String(); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.message, tt.want, got)
+		}
+		if got := strings.Join(c.caps.names(), " "); got != tt.enabled {
+			t.Errorf("%s: expected %q enabled, got %q", tt.message, tt.enabled, got)
+		}
+	}
+}
+
+func TestTypingNotices(t *testing.T) {
+	t.Parallel()
+	const room = "#typing-test"
+	typistConn, watcherConn, otherConn := newMockConn(), newMockConn(), newMockConn()
+	typist := &client{conn: typistConn, name: "typist", room: room}
+	watcher := &client{conn: watcherConn, name: "watcher", room: room}
+	other := &client{conn: otherConn, name: "other", room: room}
+	watcher.caps.set(capTyping, true)
+	mutex.Lock()
+	clients[typistConn], clients[watcherConn], clients[otherConn] = typist, watcher, other
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, typistConn)
+		delete(clients, watcherConn)
+		delete(clients, otherConn)
+		mutex.Unlock()
+	}()
+
+	start := time.Now()
+	handleTyping(typist, start)
+	handleTyping(typist, start.Add(typingInterval/2))
+	if got := watcherConn.writeBuffer.String(); got != "TYPING typist\n" {
+		t.Errorf("Expected one typing notice, got %q", got)
+	}
+	if otherConn.writeBuffer.Len() != 0 || typistConn.writeBuffer.Len() != 0 {
+		t.Error("Expected no notice without the capability or for the typist")
+	}
+
+	handleTyping(typist, start.Add(typingInterval))
+	if got := strings.Count(watcherConn.writeBuffer.String(), "TYPING"); got != 2 {
+		t.Errorf("Expected another notice after the interval, got %d", got)
+	}
+}
@@ -0,0 +1,280 @@
+// Package chattest provides helpers for integration tests of the chat
+// server: a server listening on a loopback port, scripted clients speaking
+// the line protocol, and assertions on delivered lines and on the JSON-lines
+// event and audit logs.
+//
+// The server itself lives in a main package, so tests hand NewServer the
+// function that serves a listener; the server's own tests pass its serve
+// function after setting up the configuration they need:
+//
+//	srv := chattest.NewServer(t, serve)
+//	alice := srv.Connect(t, "alice")
+//	bob := srv.Connect(t, "bob")
+//	alice.Run(chattest.Send("hello"))
+//	bob.Expect("alice: hello")
+package chattest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// Handshake is the first line a client sends.
+	Handshake = "CHAT/1.0"
+	// GuestHandshake asks the server for a generated guest name.
+	GuestHandshake = Handshake + " GUEST"
+	// NamePrompt is sent by the server when it waits for a name.
+	NamePrompt = "[ENTER YOUR NAME]: "
+)
+
+// Timeout bounds every wait for the server, such as Expect.
+var Timeout = 2 * time.Second
+
+// Server is a chat server serving a loopback listener for one test.
+type Server struct {
+	Addr string // Address clients connect to
+}
+
+// NewServer listens on a free loopback port and runs serve on it in the
+// background. The listener is closed when the test ends; serve should
+// return once Accept fails with net.ErrClosed.
+func NewServer(t testing.TB, serve func(net.Listener)) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("chattest: listening: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve(ln)
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		select {
+		case <-done:
+		case <-time.After(Timeout):
+			t.Errorf("chattest: server did not stop after its listener was closed")
+		}
+	})
+	return &Server{Addr: ln.Addr().String()}
+}
+
+// Dial opens a connection that has not sent anything yet. It is closed
+// when the test ends.
+func (s *Server) Dial(t testing.TB) *Client {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", s.Addr, Timeout)
+	if err != nil {
+		t.Fatalf("chattest: connecting to %s: %v", s.Addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &Client{t: t, conn: conn}
+}
+
+// Connect logs in as name and waits for the welcome line.
+func (s *Server) Connect(t testing.TB, name string) *Client {
+	t.Helper()
+	c := s.Dial(t)
+	c.Name = name
+	c.Run(Send(Handshake), Expect(NamePrompt), Send(name), Expect("Welcome, "+name))
+	return c
+}
+
+// ConnectGuest logs in as a guest and records the generated name.
+func (s *Server) ConnectGuest(t testing.TB) *Client {
+	t.Helper()
+	c := s.Dial(t)
+	c.Send(GuestHandshake)
+	welcome := c.Expect("You are connected as a guest")
+	name, _, _ := strings.Cut(strings.TrimPrefix(welcome, "Welcome, "), "!")
+	c.Name = name
+	return c
+}
+
+// Client is a scripted connection to the server.
+type Client struct {
+	Name string // Name the client logged in with, empty before login
+
+	t    testing.TB
+	conn net.Conn
+	buf  []byte // Received and not yet consumed
+}
+
+// Send writes line to the server.
+func (c *Client) Send(line string) {
+	c.t.Helper()
+	c.conn.SetWriteDeadline(time.Now().Add(Timeout))
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		c.t.Fatalf("chattest: %s sending %q: %v", c.label(), line, err)
+	}
+}
+
+// Expect waits for a line containing substr and returns it. Lines received
+// before it are skipped. Prompts that do not end in a newline match too.
+func (c *Client) Expect(substr string) string {
+	c.t.Helper()
+	line, err := c.waitFor(substr, time.Now().Add(Timeout))
+	if err != nil {
+		c.t.Fatalf("chattest: %s expected %q: %v; unread: %q", c.label(), substr, err, c.buf)
+	}
+	return line
+}
+
+// ExpectNone fails if a line containing substr arrives within d.
+func (c *Client) ExpectNone(substr string, d time.Duration) {
+	c.t.Helper()
+	if line, err := c.waitFor(substr, time.Now().Add(d)); err == nil {
+		c.t.Fatalf("chattest: %s expected no %q, got %q", c.label(), substr, line)
+	}
+}
+
+// ExpectClosed waits for the server to close the connection.
+func (c *Client) ExpectClosed() {
+	c.t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		c.conn.SetReadDeadline(deadline)
+		chunk := make([]byte, 4096)
+		n, err := c.conn.Read(chunk)
+		c.buf = append(c.buf, chunk[:n]...)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			c.t.Fatalf("chattest: %s expected the connection to be closed", c.label())
+		}
+		return
+	}
+}
+
+// Close closes the connection, as a client quitting would.
+func (c *Client) Close() {
+	c.conn.Close()
+}
+
+// waitFor consumes input up to and including the first line containing
+// substr.
+func (c *Client) waitFor(substr string, deadline time.Time) (string, error) {
+	for {
+		if i := bytes.Index(c.buf, []byte(substr)); i >= 0 {
+			start := bytes.LastIndexByte(c.buf[:i], '\n') + 1
+			end := len(c.buf)
+			if nl := bytes.IndexByte(c.buf[i:], '\n'); nl >= 0 {
+				end = i + nl + 1
+			}
+			line := strings.TrimRight(string(c.buf[start:end]), "\n")
+			c.buf = c.buf[end:]
+			return line, nil
+		}
+		c.conn.SetReadDeadline(deadline)
+		chunk := make([]byte, 4096)
+		n, err := c.conn.Read(chunk)
+		c.buf = append(c.buf, chunk[:n]...)
+		if err != nil && n == 0 {
+			return "", err
+		}
+	}
+}
+
+func (c *Client) label() string {
+	if c.Name == "" {
+		return "client"
+	}
+	return c.Name
+}
+
+// Step is one action of a client script.
+type Step func(c *Client)
+
+// Send is a step writing line.
+func Send(line string) Step {
+	return func(c *Client) {
+		c.t.Helper()
+		c.Send(line)
+	}
+}
+
+// Expect is a step waiting for a line containing substr.
+func Expect(substr string) Step {
+	return func(c *Client) {
+		c.t.Helper()
+		c.Expect(substr)
+	}
+}
+
+// Run performs the steps in order, stopping the test at the first failure.
+func (c *Client) Run(steps ...Step) {
+	c.t.Helper()
+	for _, step := range steps {
+		step(c)
+	}
+}
+
+// Entry is one line of the server's event or audit log.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Actor    string    `json:"actor"`
+	Target   string    `json:"target,omitempty"`
+	Room     string    `json:"room,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Context  []string  `json:"context,omitempty"`
+}
+
+// ReadLog parses the JSON-lines log at path. A missing file reads as empty.
+func ReadLog(t testing.TB, path string) []Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("chattest: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("chattest: %s: invalid entry %q: %v", path, scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("chattest: reading %s: %v", path, err)
+	}
+	return entries
+}
+
+// ExpectEntry waits for the log at path to hold an entry with the given
+// action by actor and returns it. Entries are written as things happen,
+// so it polls until Timeout.
+func ExpectEntry(t testing.TB, path, action, actor string) Entry {
+	t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		for _, e := range ReadLog(t, path) {
+			if e.Action == action && e.Actor == actor {
+				return e
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("chattest: no %q entry by %s in %s", action, actor, path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
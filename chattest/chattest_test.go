@@ -0,0 +1,74 @@
+package chattest
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveEcho is a toy server: it prompts for a name after the handshake,
+// welcomes the client and echoes every line until "quit".
+func serveEcho(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			if line, _ := r.ReadString('\n'); strings.TrimSpace(line) != Handshake {
+				return
+			}
+			conn.Write([]byte(NamePrompt))
+			name, _ := r.ReadString('\n')
+			conn.Write([]byte("Welcome, " + strings.TrimSpace(name) + "!\n"))
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || strings.TrimSpace(line) == "quit" {
+					return
+				}
+				conn.Write([]byte("echo: " + line))
+			}
+		}()
+	}
+}
+
+func TestClientScript(t *testing.T) {
+	srv := NewServer(t, serveEcho)
+	c := srv.Connect(t, "alice")
+	c.Run(Send("one"), Send("two"), Expect("echo: two"))
+	c.ExpectNone("echo: one", 50*time.Millisecond)
+	c.Send("three")
+	if got := c.Expect("three"); got != "echo: three" {
+		t.Errorf("Expected the whole line, got %q", got)
+	}
+	c.Send("quit")
+	c.ExpectClosed()
+}
+
+func TestExpectEntry(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if entries := ReadLog(t, path); len(entries) != 0 {
+		t.Fatalf("Expected a missing log to read as empty, got %v", entries)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		// Renamed into place so the log is never read half-written
+		os.WriteFile(path+".tmp", []byte(`{"action":"join","actor":"bob"}`+"\n"+`{"action":"message","actor":"bob","text":"hi"}`+"\n"), 0o600)
+		os.Rename(path+".tmp", path)
+	}()
+	if e := ExpectEntry(t, path, "message", "bob"); e.Text != "hi" {
+		t.Errorf("Expected the message entry, got %+v", e)
+	}
+}
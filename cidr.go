@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// addressFilter holds the parsed allow and deny ranges for connections.
+type addressFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+var connFilter addressFilter // Built from the configuration at startup
+
+// parseCIDRs parses ranges such as "10.0.0.0/8". A plain address stands
+// for itself.
+func parseCIDRs(ranges []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, r := range ranges {
+		r = strings.TrimSpace(r)
+		if !strings.Contains(r, "/") {
+			ip := net.ParseIP(r)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", r)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// newAddressFilter parses the configured allow and deny ranges.
+func newAddressFilter(allow, deny []string) (addressFilter, error) {
+	var f addressFilter
+	var err error
+	if f.allow, err = parseCIDRs(allow); err != nil {
+		return f, fmt.Errorf("allow_cidrs: %w", err)
+	}
+	if f.deny, err = parseCIDRs(deny); err != nil {
+		return f, fmt.Errorf("deny_cidrs: %w", err)
+	}
+	return f, nil
+}
+
+// permits reports whether connections from ip are accepted: ip must not
+// be in a denied range and, when allowed ranges are set, must be in one.
+func (f addressFilter) permits(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+	for _, n := range f.deny {
+		if n.Contains(addr) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
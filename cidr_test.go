@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestAddressFilter(t *testing.T) {
+	t.Parallel()
+	f, err := newAddressFilter([]string{"10.0.0.0/8", "fd00::/8", "192.0.2.7"}, []string{"10.66.0.0/16"})
+	if err != nil {
+		t.Fatalf("newAddressFilter: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"10.66.1.1", false},
+		{"192.0.2.7", true},
+		{"192.0.2.8", false},
+		{"fd12::1", true},
+		{"2001:db8::1", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := f.permits(tt.ip); got != tt.want {
+			t.Errorf("permits(%q) = %t, want %t", tt.ip, got, tt.want)
+		}
+	}
+
+	var open addressFilter
+	if !open.permits("203.0.113.1") {
+		t.Error("Expected an empty filter to permit everything")
+	}
+	denyOnly, _ := newAddressFilter(nil, []string{"203.0.113.0/24"})
+	if denyOnly.permits("203.0.113.1") || !denyOnly.permits("198.51.100.1") {
+		t.Error("Expected a deny-only filter to refuse just the denied range")
+	}
+}
+
+func TestNewAddressFilterInvalid(t *testing.T) {
+	t.Parallel()
+	if _, err := newAddressFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Error("Expected an invalid range to be an error")
+	}
+	if _, err := newAddressFilter(nil, []string{"nope"}); err == nil {
+		t.Error("Expected an invalid address to be an error")
+	}
+}
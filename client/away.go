@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Commands sent for the automatic away status.
+const (
+	awayIdle  = "/away idle"
+	awayClear = "/away"
+)
+
+// awayCheckInterval is how often the client looks for an idle keyboard.
+const awayCheckInterval = 5 * time.Second
+
+// autoAway marks the user away after a period without input and back on
+// the next input. It only clears the away status it set itself.
+type autoAway struct {
+	mu        sync.Mutex
+	after     time.Duration // Idle period before going away, 0 disables
+	lastInput time.Time
+	away      bool // Set while the automatic away status is on
+}
+
+func newAutoAway(after time.Duration, now time.Time) *autoAway {
+	return &autoAway{after: after, lastInput: now}
+}
+
+// idle reports whether the away command must be sent because nothing was
+// typed for the idle period.
+func (a *autoAway) idle(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.after <= 0 || a.away || now.Sub(a.lastInput) < a.after {
+		return false
+	}
+	a.away = true
+	return true
+}
+
+// input records a line typed by the user and reports whether the away
+// status must be cleared first. A line that sets the away status itself
+// replaces the automatic one.
+func (a *autoAway) input(line string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastInput = now
+	if !a.away {
+		return false
+	}
+	a.away = false
+	fields := strings.Fields(line)
+	return len(fields) == 0 || fields[0] != "/away"
+}
+
+// reset forgets the away status after a reconnect, as the server does,
+// so a user who is still idle is marked away again.
+func (a *autoAway) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.away = false
+}
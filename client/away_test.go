@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoAway(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	a := newAutoAway(10*time.Minute, start)
+
+	if a.idle(start.Add(9 * time.Minute)) {
+		t.Error("Expected no away status before the idle period")
+	}
+	if !a.idle(start.Add(10 * time.Minute)) {
+		t.Fatal("Expected the away status after the idle period")
+	}
+	if a.idle(start.Add(11 * time.Minute)) {
+		t.Error("Expected the away status to be sent once")
+	}
+	if !a.input("back", start.Add(12*time.Minute)) {
+		t.Error("Expected the next input to clear the away status")
+	}
+	if a.input("again", start.Add(13*time.Minute)) {
+		t.Error("Expected the away status to be cleared once")
+	}
+
+	// A manual away status replaces the automatic one
+	a.idle(start.Add(30 * time.Minute))
+	if a.input("/away lunch", start.Add(31*time.Minute)) {
+		t.Error("Expected /away to replace the automatic status")
+	}
+
+	// After a reconnect an idle user is marked away again
+	a.idle(start.Add(50 * time.Minute))
+	a.reset()
+	if !a.idle(start.Add(50 * time.Minute)) {
+		t.Error("Expected the away status to be sent again after a reset")
+	}
+
+	if newAutoAway(0, start).idle(start.Add(24 * time.Hour)) {
+		t.Error("Expected a zero period to disable the away status")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// backoffJitter is the fraction by which every retry delay is randomized
+// either way, so clients dropped together do not redial together.
+const backoffJitter = 0.2
+
+// backoff spaces out connection attempts: the delay starts at initial,
+// doubles after every failed attempt up to max and is randomized by
+// backoffJitter.
+type backoff struct {
+	initial  time.Duration
+	max      time.Duration
+	attempts int            // Attempts before giving up, 0 retries forever
+	random   func() float64 // Source of jitter in [0, 1), rand.Float64 by default
+}
+
+func newBackoff(initial, max time.Duration, attempts int) *backoff {
+	return &backoff{initial: initial, max: max, attempts: attempts, random: rand.Float64}
+}
+
+// delay returns how long to wait before the given retry, counted from 1.
+func (b *backoff) delay(retry int) time.Duration {
+	d := b.initial
+	for i := 1; i < retry && d < b.max; i++ {
+		d *= 2
+	}
+	d = min(d, b.max)
+	spread := float64(d) * backoffJitter
+	return time.Duration(float64(d) - spread + 2*spread*b.random())
+}
+
+// more reports whether another attempt follows the given failed one.
+func (b *backoff) more(attempt int) bool {
+	return b.attempts == 0 || attempt < b.attempts
+}
+
+// progress describes the given retry for the user, "attempt 2/5" or
+// "attempt 2" when retrying forever.
+func (b *backoff) progress(retry int) string {
+	if b.attempts == 0 {
+		return fmt.Sprintf("attempt %d", retry)
+	}
+	return fmt.Sprintf("attempt %d/%d", retry, b.attempts)
+}
+
+// wait sleeps for d and reports false when the client is shut down
+// first.
+func (b *backoff) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-shutdownChan:
+		return false
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+	b := newBackoff(time.Second, 10*time.Second, 5)
+	b.random = func() float64 { return 0.5 } // No jitter
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := b.delay(i + 1); got != w {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	b.random = func() float64 { return 0 }
+	if got := b.delay(2); got != 1600*time.Millisecond {
+		t.Errorf("delay(2) with the lowest jitter = %v, want 1.6s", got)
+	}
+	b.random = func() float64 { return 0.999999 }
+	if got := b.delay(2); got < 2399*time.Millisecond || got > 2400*time.Millisecond {
+		t.Errorf("delay(2) with the highest jitter = %v, want about 2.4s", got)
+	}
+}
+
+func TestBackoffAttempts(t *testing.T) {
+	t.Parallel()
+	b := newBackoff(time.Second, time.Minute, 3)
+	if !b.more(2) || b.more(3) {
+		t.Errorf("Expected 3 attempts in all")
+	}
+	if got := b.progress(2); got != "attempt 2/3" {
+		t.Errorf("progress(2) = %q", got)
+	}
+
+	forever := newBackoff(time.Second, time.Minute, 0)
+	if !forever.more(1000) {
+		t.Errorf("Expected retries to go on with 0 attempts")
+	}
+	if got := forever.progress(7); got != "attempt 7" {
+		t.Errorf("progress(7) = %q", got)
+	}
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -16,22 +18,117 @@ import (
 
 const (
 	connectionTimeout = 10 * time.Second
-	reconnectDelay    = 5 * time.Second
 	maxMessageSize    = 1024
+	quitTimeout       = 3 * time.Second // Longest wait for the server to close after /quit
 )
 
 var shutdownChan = make(chan struct{})
 
 func main() {
+	// "client send" delivers one message and exits
+	if len(os.Args) > 1 && os.Args[1] == "send" {
+		os.Exit(runSend(os.Args[2:]))
+	}
+
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	if len(os.Args) != 3 {
-		fmt.Println("Usage: ./client <server_address> <port>")
+	flags := flag.NewFlagSet("client", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	forceIPv4 := flags.Bool("4", false, "connect over IPv4 only")
+	forceIPv6 := flags.Bool("6", false, "connect over IPv6 only")
+	guest := flags.Bool("guest", false, "join as a guest with a generated name")
+	pingInterval := flags.Duration("ping", 15*time.Second, "interval between pings to the server, 0 disables them")
+	missedPongs := flags.Int("missed-pongs", 3, "unanswered pings after which the connection is redialed")
+	compress := flags.String("compress", "", "compress the connection with gzip or deflate, if the server agrees")
+	timeFormat := flags.String("time-format", "24h", "how message times are shown: 24h, 12h or relative")
+	zone := flags.String("tz", "Local", "timezone message times are shown in, such as UTC or Europe/Berlin")
+	awayAfter := flags.Duration("away", 10*time.Minute, "mark yourself away after this long without typing, 0 disables it")
+	plain := flags.Bool("plain", false, "use the line-by-line interface instead of the full-screen one")
+	noColor := flags.Bool("no-color", false, "show messages without colors or formatting")
+	configFile := flags.String("config", "", "client configuration file, ~/.tcpchat.toml by default")
+	profile := flags.String("profile", "", "connect with the named profile of the configuration instead of [server]")
+	retries := flags.Int("retries", 10, "connection attempts before giving up, 0 retries forever")
+	retryDelay := flags.Duration("retry-delay", time.Second, "wait before the first retry, doubled after every failed attempt")
+	retryMax := flags.Duration("retry-max", time.Minute, "longest wait between connection attempts")
+	pipeMode := flags.Bool("pipe", false, "send every line read from stdin as a chat message, for scripts")
+	pipeName := flags.String("as", "", "name to log in with, as [server] name of the configuration")
+	pipeRoom := flags.String("room", "", "room the -pipe messages go to")
+	pipeInterval := flags.Duration("interval", 2*time.Second, "shortest time between two -pipe messages")
+	logFile := flags.String("log", "", "append everything shown, with timestamps, to this file")
+	parseErr := flags.Parse(os.Args[1:])
+
+	// The configuration file provides defaults, flags given override them
+	cfgPath, cfgRequired := configPath(*configFile)
+	cfg, cfgErr := loadClientConfig(cfgPath, cfgRequired)
+	if cfgErr != nil {
+		fmt.Println("Invalid configuration:", cfgErr)
+		return
+	}
+	if *profile != "" {
+		if cfg.server, cfgErr = cfg.profile(*profile); cfgErr != nil {
+			fmt.Println(cfgErr)
+			return
+		}
+	}
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if !given["time-format"] {
+		*timeFormat = cfg.display.timeFormat
+	}
+	if !given["tz"] {
+		*zone = cfg.display.timezone
+	}
+	if *pipeName != "" {
+		cfg.server.name = *pipeName
+	}
+	clk, clockErr := newClock(*timeFormat, *zone)
+	// Without an address the client connects to the configured server
+	args := flags.Args()
+	if len(args) == 0 && cfg.server.address != "" {
+		args = []string{cfg.server.address, strconv.Itoa(cfg.server.port)}
+	}
+	if parseErr != nil || len(args) != 2 || (*forceIPv4 && *forceIPv6) || *pingInterval < 0 || *awayAfter < 0 ||
+		*retries < 0 || *retryDelay <= 0 || *retryMax < *retryDelay ||
+		(*pipeMode && cfg.server.name == "" && !*guest) || *pipeInterval < 0 ||
+		(*compress != "" && !slices.Contains(compressions, *compress)) || clockErr != nil {
+		fmt.Println("Usage: ./client [<server_address> <port>]")
+		fmt.Println("Options: -4 connect over IPv4 only, -6 connect over IPv6 only, -guest join as a guest,")
+		fmt.Println("         -ping 15s interval between pings (0 disables), -missed-pongs 3 unanswered pings before redialing,")
+		fmt.Println("         -compress gzip|deflate compress the connection,")
+		fmt.Println("         -time-format 24h|12h|relative how message times are shown, -tz Local timezone of message times,")
+		fmt.Println("         -away 10m idle time before you are marked away (0 disables),")
+		fmt.Println("         -retries 10 connection attempts (0 retries forever), -retry-delay 1s first wait, doubled")
+		fmt.Println("         after every failed attempt up to -retry-max 1m,")
+		fmt.Println("         -plain line-by-line interface instead of the full-screen one, -no-color no colors or formatting,")
+		fmt.Println("         -config file client configuration (~/.tcpchat.toml by default), -profile name connect with a")
+		fmt.Println("         profile of the configuration,")
+		fmt.Println("         -log file append a timestamped transcript of the session to file,")
+		fmt.Println("         -pipe send every line of stdin as a message, logged in -as name (or -guest),")
+		fmt.Println("         to -room #room, at most one every -interval 2s")
+		fmt.Println("The address and port may be left out when the configuration names a server.")
 		fmt.Println("Example: ./client localhost 8989")
 		return
 	}
+	// The transcript file is opened early, so a bad path is reported
+	// before connecting
+	var logOut *os.File
+	if *logFile != "" {
+		f, err := openTranscript(*logFile)
+		if err != nil {
+			fmt.Println("Unable to open the transcript:", err)
+			return
+		}
+		logOut = f
+	}
+
+	family := familyAny
+	if *forceIPv4 {
+		family = familyIPv4
+	} else if *forceIPv6 {
+		family = familyIPv6
+	}
 
 	// Handle shutdown signals
 	go func() {
@@ -39,8 +136,8 @@ func main() {
 		close(shutdownChan)
 	}()
 
-	serverAddress := os.Args[1]
-	port := os.Args[2]
+	serverAddress := args[0]
+	port := args[1]
 
 	// Validate port first
 	portNum, portErr := strconv.Atoi(port)
@@ -50,116 +147,295 @@ func main() {
 	}
 
 	// Then validate server address
-	if _, addrErr := net.ResolveTCPAddr("tcp", serverAddress+":"+port); addrErr != nil {
+	if _, addrErr := net.ResolveTCPAddr("tcp", net.JoinHostPort(serverAddress, port)); addrErr != nil {
 		fmt.Println("Invalid server address")
 		return
 	}
 
-	var conn net.Conn
-	var err error
-
-	// Connection loop with retry logic
-	connected := false
-	retryCount := 0
-	maxRetries := 3
+	tlsConf, err := cfg.server.tlsConfig(serverAddress)
+	if err != nil {
+		fmt.Println("Invalid TLS settings:", err)
+		return
+	}
 
-	for !connected && retryCount < maxRetries {
-		conn, err = net.DialTimeout("tcp", serverAddress+":"+port, connectionTimeout)
-		if err != nil {
-			retryCount++
-			fmt.Printf("Unable to connect to server: %v\n", err)
-			if retryCount < maxRetries {
-				fmt.Printf("Retrying in %v... (attempt %d/%d)\n", reconnectDelay, retryCount, maxRetries)
-				time.Sleep(reconnectDelay)
-				continue
-			}
+	// Failed attempts are retried with exponential backoff, here and after
+	// the connection drops
+	retry := newBackoff(*retryDelay, *retryMax, *retries)
+	var conn net.Conn
+	for attempt := 1; ; attempt++ {
+		conn, err = dialChat(serverAddress, port, family, connectionTimeout, tlsConf)
+		if err == nil {
+			break
+		}
+		fmt.Printf("Unable to connect to server: %v\n", err)
+		if !retry.more(attempt) {
 			fmt.Println("Max connection attempts reached")
 			return
 		}
-		connected = true
+		delay := retry.delay(attempt)
+		fmt.Printf("Retrying in %v... (%s)\n", delay.Round(time.Millisecond), retry.progress(attempt+1))
+		if !retry.wait(delay) {
+			return
+		}
 	}
 
-	if !connected {
-		fmt.Println("Failed to establish connection")
+	// Send protocol handshake
+	hello := handshakeLine(*guest)
+	if *compress != "" {
+		hello += " " + compressToken(*compress)
+	}
+	if _, err := conn.Write([]byte(hello + "\n")); err != nil {
+		log.Fatalf("Error sending handshake: %v", err)
 		return
 	}
+	if *compress != "" {
+		compressed, refusal, err := negotiateCompression(conn)
+		if err != nil {
+			log.Fatalf("Error negotiating compression: %v", err)
+		}
+		fmt.Print(refusal)
+		conn = compressed
+	}
+	// A configured name answers the name prompt as soon as it arrives
+	var fromServer io.Reader = conn
+	if cfg.server.name != "" && !*guest {
+		fromServer = &promptAnswer{r: conn, w: conn, answer: cfg.server.name}
+	}
 
-	// Setup connection status monitoring
-	connStatus := make(chan bool, 1)
+	// Lost connections are dialed again and logged back in
+	sess := newSession(conn, func() (net.Conn, error) {
+		return dialChat(serverAddress, port, family, connectionTimeout, tlsConf)
+	}, *guest)
+	sess.compress = *compress
+	sess.retry = retry
+
+	// In pipe mode stdin is the messages, not the user
+	if *pipeMode {
+		os.Exit(startPipe(sess, fromServer, *pipeRoom, *pipeInterval))
+	}
+
+	// Ping the server; a connection that stops answering is closed so the
+	// receiving side notices and reconnects
 	done := make(chan struct{})
 	defer close(done)
+	beat := &heartbeat{limit: *missedPongs}
 
-	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
+	if *pingInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(*pingInterval)
+			defer ticker.Stop()
 
-		for {
-			select {
-			case <-ticker.C:
-				conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
-				_, err := conn.Write([]byte{})
-				if err != nil {
-					select {
-					case connStatus <- false:
-					case <-done:
-						return
+			for {
+				select {
+				case <-ticker.C:
+					current := sess.current()
+					line, alive := beat.tick()
+					if !alive {
+						fmt.Println("\nNo answer from the server")
+						beat.reset()
+						current.Close()
+						continue
+					}
+					current.SetWriteDeadline(time.Now().Add(2 * time.Second))
+					if _, err := current.Write([]byte(line + "\n")); err == nil {
+						beat.pinged()
 					}
+				case <-shutdownChan:
+					return
+				case <-done:
 					return
 				}
+			}
+		}()
+	}
+
+	// Mark the user away while the keyboard is idle
+	away := newAutoAway(*awayAfter, time.Now())
+	if *awayAfter > 0 {
+		go func() {
+			ticker := time.NewTicker(awayCheckInterval)
+			defer ticker.Stop()
+
+			for {
 				select {
-				case connStatus <- true:
+				case now := <-ticker.C:
+					if away.idle(now) {
+						sess.Write([]byte(awayIdle + "\n"))
+					}
+				case <-shutdownChan:
+					return
 				case <-done:
 					return
 				}
-			case <-shutdownChan:
-				return
-			case <-done:
-				return
 			}
-		}
-	}()
+		}()
+	}
 
-	// Send protocol handshake
-	_, err = conn.Write([]byte("CHAT/1.0\n"))
-	if err != nil {
-		log.Fatalf("Error sending handshake: %v", err)
-		return
+	defer func() { sess.current().Close() }()
+
+	// The full-screen interface needs a terminal on both ends
+	color := useColor() && !*noColor && cfg.display.color
+	activeTheme = cfg.theme
+	v := newView(clk, color, cfg.display)
+	comp := newCompleter()
+	for name := range cfg.aliases {
+		comp.addCommand("/" + name)
+	}
+	status := newStatusBar(net.JoinHostPort(serverAddress, port))
+	// Terminal notifications go around the full-screen interface and the
+	// transcript, as does measuring the terminal in line mode
+	stdout := os.Stdout
+	var term io.Writer
+	if isTerminal(stdout) {
+		term = stdout
+	}
+	notify := newNotifier(cfg.notify, term)
+	fullScreen := false
+	if !*plain && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		restore, err := startTUI(comp, status, notify)
+		if err != nil {
+			fmt.Printf("Full-screen interface unavailable (%v), using line mode\n", err)
+		} else {
+			defer restore()
+			fullScreen = true
+		}
 	}
 
-	defer conn.Close()
+	// The transcript sees the output before the full-screen interface
+	// does, so it gets the lines without the screen's drawing
+	var record *transcript
+	if logOut != nil {
+		t, stop, err := startTranscript(logOut)
+		if err != nil {
+			logOut.Close()
+			fmt.Printf("Unable to start the transcript: %v\n", err)
+			return
+		}
+		defer stop()
+		record = t
+	}
 
 	fmt.Println("Connected to the server!")
 
+	compose := &composer{color: color}
+
+	// Messages shown are kept for /find
+	shown := newScrollback(maxScrollback)
+	// In line mode messages are wrapped to the terminal between words,
+	// which the terminal itself would break anywhere
+	lineWidth := func() int { return 0 }
+	if !fullScreen && term != nil {
+		width, stop := followWidth(stdout)
+		defer stop()
+		lineWidth = width
+	}
+	// In the full-screen interface every room and private conversation
+	// has a window of its own; room is the current one
+	room := lobbyRoom
+	show := func(message, text string) {
+		shown.add(text)
+		if name := conversationOf(message, room); fullScreen && name != "" {
+			text = markWindow(name, strings.HasPrefix(message, "Now talking in "), text)
+		}
+		if width := lineWidth(); width > 0 {
+			fmt.Print(wrapText(text, width))
+		} else {
+			fmt.Print(text)
+		}
+	}
+
 	// Handle receiving messages from the server
+	received := make(chan struct{})
 	go func() {
-		reader := bufio.NewReader(conn)
+		defer close(received)
+		reader := newLineReader(bufio.NewReader(fromServer), maxMessageSize)
 		for {
 			select {
-			case status := <-connStatus:
-				if !status {
-					fmt.Println("\nConnection lost. Please restart the client.")
-					conn.Close()
-					return
-				}
+			case <-shutdownChan:
+				return
 			default:
-				message, err := reader.ReadString('\n')
+				message, err := reader.readLine()
+				if err == errLineTooLong {
+					fmt.Println("\nMessage too large, skipping")
+					continue
+				}
 				if err != nil {
+					if sess.hasQuit() {
+						return
+					}
 					if err == io.EOF {
 						fmt.Println("\nServer closed the connection")
 					} else {
 						fmt.Printf("\nConnection error: %v\n", err)
 					}
-					return
+					if !sess.canReconnect() {
+						status.setState(stateDisconnected)
+						return
+					}
+					status.setState(stateReconnecting)
+					r, err := sess.reconnect()
+					if err != nil {
+						status.setState(stateDisconnected)
+						fmt.Printf("Unable to reconnect: %v\n", err)
+						return
+					}
+					status.setState(stateConnected)
+					room = lobbyRoom // Until a resumed session rejoins another
+					reader = newLineReader(r, maxMessageSize)
+					beat.reset()
+					away.reset()
+					continue
 				}
 
-				// Enforce message size limit
-				if len(message) > maxMessageSize {
-					fmt.Println("\nMessage too large, skipping")
+				// Message IDs are not shown, the latest is kept for resuming
+				id, message := splitIDTag(message)
+				if id != 0 {
+					sess.seen(id)
+				}
+				mentioned, message := splitMentionTag(message)
+
+				if reply, ok := beat.handle(message); ok {
+					if reply != "" {
+						sess.Write([]byte(reply + "\n"))
+					} else {
+						status.setLatency(beat.latency())
+					}
+					continue
+				}
+
+				// The answers to the version offer are not meant for the user
+				if _, ok := parseVersionReply(message); ok || isCapabilityLine(message) {
+					continue
+				}
+
+				// History replays are unframed, and summarized after a reconnect
+				replayed := sess.track(id, message)
+				status.setName(sess.userName())
+				if name, ok := strings.CutPrefix(strings.TrimRight(message, "\n"), "Now talking in "); ok {
+					room = name
+				}
+				if len(replayed) != 1 || replayed[0] != message {
+					for _, line := range replayed {
+						if !v.hides(line) {
+							show(line, v.render(line))
+						}
+					}
+					continue
+				}
+				// Tab completion learns from what goes by; after login the
+				// commands and users to complete are fetched quietly
+				if comp.learn(message) {
+					continue
+				}
+				if name, ok := welcomedName(strings.TrimRight(message, "\n")); ok && comp.welcomed(name) && fullScreen {
+					comp.fetch(sess)
+				}
+				if v.hides(message) {
 					continue
 				}
 
 				if strings.HasPrefix(message, "Connected users:") {
-					fmt.Print(message)
+					show(message, message)
 					continue
 				}
 
@@ -169,18 +445,22 @@ func main() {
 				   strings.HasPrefix(message, "[ENTER YOUR NAME]:") ||
 				   strings.HasPrefix(message, "        dGGGGMMb") ||
 				   strings.HasPrefix(message, "       @p~qp~~qMb") {
+					// The welcome after the prompt names the first window
+					if _, ok := welcomedName(strings.TrimRight(message, "\n")); ok && fullScreen {
+						message = markWindow(room, false, message)
+					}
 					fmt.Print(message)
 					continue
 				}
 				
-				// Parse and display message with timestamp
-				parts := strings.SplitN(message, "] ", 2)
-				if len(parts) == 2 {
-					timestamp := strings.TrimPrefix(parts[0], "[")
-					fmt.Printf("[%s] %s", timestamp, parts[1])
-				} else {
-					fmt.Print(message)
+				// Render Markdown, emoji and mentions, with the time in the
+				// user's timezone and format
+				if mentioned || alerts(message, sess.userName()) {
+					notify.notify(message)
+					show(message, v.alert(v.render(message)))
+					continue
 				}
+				show(message, v.render(message))
 			}
 		}
 	}()
@@ -188,21 +468,81 @@ func main() {
 	// Handle sending messages to the server
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
-		message := scanner.Text()
-		trimmedMessage := strings.TrimSpace(message)
+		if record != nil {
+			record.entered(scanner.Text())
+		}
+		if away.input(scanner.Text(), time.Now()) {
+			sess.Write([]byte(awayClear + "\n"))
+		}
+		message, ok := compose.review(scanner.Text(), scanner)
+		if !ok {
+			continue
+		}
+		trimmedMessage := expandAlias(cfg.aliases, strings.TrimSpace(message))
 		if trimmedMessage == "/list" {
-			_, err := conn.Write([]byte("/list\n"))
+			_, err := sess.Write([]byte("/list\n"))
 			if err != nil {
 				fmt.Println("Error sending list command:", err)
 				return
 			}
 			continue // Don't send the /list command as a regular message
+		} else if trimmedMessage == "/quit" || strings.HasPrefix(trimmedMessage, "/quit ") {
+			// Leave once the server has said goodbye and closed the
+			// connection, so everything it sent before is shown
+			if err := sess.quit(strings.TrimSpace(strings.TrimPrefix(trimmedMessage, "/quit"))); err != nil {
+				fmt.Println("Error sending quit command:", err)
+				return
+			}
+			select {
+			case <-received:
+			case <-time.After(quitTimeout):
+			}
+			return
+		} else if trimmedMessage == "/clear" {
+			// Nothing is sent, the screen and its scrollback are wiped
+			if fullScreen || isTerminal(os.Stdout) {
+				fmt.Print(clearScreen)
+			}
+			shown.clear()
+			continue
+		} else if trimmedMessage == "/find" || strings.HasPrefix(trimmedMessage, "/find ") {
+			// Searched locally, nothing is sent
+			for _, line := range findResults(shown, strings.TrimSpace(strings.TrimPrefix(trimmedMessage, "/find"))) {
+				fmt.Println(line)
+			}
+			continue
+		} else if trimmedMessage == "/bell" || strings.HasPrefix(trimmedMessage, "/bell ") {
+			fmt.Println(v.toggleBell(strings.TrimSpace(strings.TrimPrefix(trimmedMessage, "/bell"))))
+			continue
+		} else if trimmedMessage == "/notify" || strings.HasPrefix(trimmedMessage, "/notify ") {
+			fmt.Println(notify.toggle(strings.TrimSpace(strings.TrimPrefix(trimmedMessage, "/notify"))))
+			continue
+		} else if trimmedMessage == "/help" || strings.HasPrefix(trimmedMessage, "/help ") {
+			// Local commands are explained here, the server explains its own
+			local := clientHelp(trimmedMessage)
+			for _, line := range local {
+				fmt.Println(line)
+			}
+			if len(local) == 0 || trimmedMessage == "/help" {
+				if _, err := sess.Write([]byte(trimmedMessage + "\n")); err != nil {
+					fmt.Println("Error sending help command:", err)
+					return
+				}
+			}
+			continue
+		} else if trimmedMessage == "/exec" || strings.HasPrefix(trimmedMessage, "/exec ") {
+			command := strings.TrimSpace(strings.TrimPrefix(trimmedMessage, "/exec"))
+			if err := handleExecCommand(sess, scanner, command); err != nil {
+				fmt.Println("Error sending command output:", err)
+				return
+			}
+			continue
 		} else if strings.HasPrefix(trimmedMessage, "/msg ") {
 			parts := strings.SplitN(trimmedMessage, " ", 3)
 			if len(parts) == 3 {
 				recipient := parts[1]
 				privateMessage := parts[2]
-				_, err := conn.Write([]byte(fmt.Sprintf("/msg %s %s\n", recipient, privateMessage)))
+				_, err := sess.Write([]byte(fmt.Sprintf("/msg %s %s\n", recipient, privateMessage)))
 				if err != nil {
 					fmt.Println("Error sending private message:", err)
 					return
@@ -213,7 +553,7 @@ func main() {
 				continue
 			}
 		} else if trimmedMessage != "" {
-			_, err := conn.Write([]byte(trimmedMessage + "\n"))
+			_, err := sess.Write([]byte(trimmedMessage + "\n"))
 			if err != nil {
 				fmt.Println("Error sending message:", err)
 				return
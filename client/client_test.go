@@ -9,20 +9,29 @@ import (
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 )
 
 func TestCommandLineValidation(t *testing.T) {
+	// An empty configuration, so the developer's ~/.tcpchat.toml does not
+	// supply a server
+	cfgPath := filepath.Join(t.TempDir(), "client.toml")
+	if err := os.WriteFile(cfgPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name     string
 		args     []string
 		expected string
 	}{
-		{"No arguments", []string{""}, "Usage: ./client <server_address> <port>"},
-		{"Invalid address", []string{"", "invalid", "8989"}, "Invalid server address"},
-		{"Invalid port", []string{"", "localhost", "invalid"}, "Invalid port number"},
+		{"No arguments", nil, "Usage: ./client [<server_address> <port>]"},
+		{"Invalid address", []string{"invalid", "8989"}, "Invalid server address"},
+		{"Invalid port", []string{"localhost", "invalid"}, "Invalid port number"},
+		{"Unknown time format", []string{"-time-format", "iso", "localhost", "8989"}, "Usage: ./client [<server_address> <port>]"},
 	}
 
 	for _, tt := range tests {
@@ -30,7 +39,7 @@ func TestCommandLineValidation(t *testing.T) {
 			// Backup and restore original args
 			oldArgs := os.Args
 			defer func() { os.Args = oldArgs }()
-			os.Args = tt.args
+			os.Args = append([]string{"", "-config", cfgPath}, tt.args...)
 
 			// Capture stdout
 			oldStdout := os.Stdout
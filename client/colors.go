@@ -0,0 +1,63 @@
+package main
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// Default colors of the parts of a line that are not chat text.
+const (
+	ansiPM     = "\x1b[1;35m" // Private message prefixes
+	ansiSystem = "\x1b[2m"    // Server notices
+)
+
+// senderColors are the colors names are drawn in by default. Yellow is
+// left out, it marks mentions.
+var senderColors = []string{
+	"\x1b[31m", "\x1b[32m", "\x1b[34m", "\x1b[35m", "\x1b[36m",
+	"\x1b[91m", "\x1b[92m", "\x1b[94m", "\x1b[95m", "\x1b[96m",
+}
+
+// systemSender is the name the server sends its notices under.
+const systemSender = "SERVER"
+
+// senderColor returns the color of name, the same on every line and in
+// every session.
+func senderColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	palette := activeTheme.senders
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// renderBody renders a line without its timestamp. Server notices are
+// dimmed and private message prefixes stand out; when chat says it is a
+// chat line, the sender's name is drawn in the sender's color.
+func renderBody(body string, color, chat bool) string {
+	if !color {
+		return renderText(body, false)
+	}
+	if text, ok := strings.CutPrefix(body, systemSender+": "); ok {
+		text, newline := strings.CutSuffix(text, "\n")
+		line := activeTheme.system + systemSender + ": " + renderText(text, false) + ansiReset
+		if newline {
+			line += "\n"
+		}
+		return line
+	}
+	for _, prefix := range []string{"[PM from ", "[PM to "} {
+		if rest, ok := strings.CutPrefix(body, prefix); ok {
+			if name, text, ok := strings.Cut(rest, "]: "); ok {
+				pm := activeTheme.pm
+				return pm + prefix + ansiReset + senderColor(name) + name + ansiReset + pm + "]:" + ansiReset + " " + renderText(text, true)
+			}
+		}
+	}
+	if chat {
+		if isChatLine(body) {
+			name, text, _ := strings.Cut(body, ": ")
+			return senderColor(name) + name + ansiReset + ": " + renderText(text, true)
+		}
+	}
+	return renderText(body, true)
+}
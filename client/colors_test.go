@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSenderColor(t *testing.T) {
+	t.Parallel()
+	if senderColor("alice") != senderColor("alice") {
+		t.Error("senderColor is not stable")
+	}
+	seen := make(map[string]bool)
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi"} {
+		c := senderColor(name)
+		if c == ansiMention {
+			t.Errorf("%s is drawn in the mention color", name)
+		}
+		seen[c] = true
+	}
+	if len(seen) < 3 {
+		t.Errorf("eight names share %d colors", len(seen))
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	alice := senderColor("alice")
+	bob := senderColor("bob")
+	tests := []struct {
+		name  string
+		body  string
+		color bool
+		chat  bool
+		want  string
+	}{
+		{"Chat sender", "alice: **hi**", true, true, alice + "alice" + ansiReset + ": " + ansiBold + "hi" + ansiReset},
+		{"No color", "alice: **hi** :wave:", false, true, "alice: **hi** 👋"},
+		{"Reply is not chat", "Usage: /msg <user> <message>", true, false, "Usage: /msg <user> <message>"},
+		{"Sentence is not a sender", "the plan: lunch", true, true, "the plan: lunch"},
+		{"System notice", "SERVER: bob has joined our chat...\n", true, false, ansiSystem + "SERVER: bob has joined our chat..." + ansiReset + "\n"},
+		{"PM from", "[PM from bob]: yo", true, false, ansiPM + "[PM from " + ansiReset + bob + "bob" + ansiReset + ansiPM + "]:" + ansiReset + " yo"},
+		{"PM to", "[PM to alice]: yo", true, true, ansiPM + "[PM to " + ansiReset + alice + "alice" + ansiReset + ansiPM + "]:" + ansiReset + " yo"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := renderBody(tt.body, tt.color, tt.chat); got != tt.want {
+				t.Errorf("renderBody(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+
+	// Timestamped lines are chat, others are replies
+	if got := renderMessage("[10:00] alice: hi", true); !strings.Contains(got, alice+"alice") {
+		t.Errorf("renderMessage did not color the sender: %q", got)
+	}
+	if got := renderMessage("Error: x", true); got != "Error: x" {
+		t.Errorf("renderMessage colored a reply: %q", got)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Lines framing the server's /help listing.
+const (
+	helpStart = "Commands:"
+	helpEnd   = "Type /help <command> for details"
+)
+
+// completer remembers the commands and user names the input line of the
+// full-screen interface completes with Tab. Commands come from /help
+// listings and users from /list replies, join, leave and rename notices
+// and chat lines. After login the client asks for both itself and hides
+// the replies.
+type completer struct {
+	mu        sync.Mutex
+	commands  map[string]bool
+	users     map[string]bool
+	self      string
+	inHelp    bool // Inside a /help listing
+	quietHelp bool // The listing was requested by the client, hide it
+	quietList int  // /list replies requested by the client, to hide
+}
+
+func newCompleter() *completer {
+	c := &completer{commands: make(map[string]bool), users: make(map[string]bool)}
+	for _, name := range []string{"/help", "/list", "/msg", "/preview"} {
+		c.commands[name] = true
+	}
+	for _, cmd := range clientCommands {
+		c.commands[cmd.name] = true
+	}
+	return c
+}
+
+// addCommand adds a command to complete, such as an alias.
+func (c *completer) addCommand(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands[name] = true
+}
+
+// welcomed records the name the user logged in with and reports whether
+// it is the first login of the session.
+func (c *completer) welcomed(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	first := c.self == ""
+	c.self = name
+	return first
+}
+
+// fetch asks the server for its commands and users, hiding the replies.
+func (c *completer) fetch(w io.Writer) error {
+	c.mu.Lock()
+	c.quietHelp = true
+	c.quietList++
+	c.mu.Unlock()
+	_, err := io.WriteString(w, "/help\n/list\n")
+	return err
+}
+
+// learn picks commands and user names out of a line from the server and
+// reports whether the line answers a request of fetch and is to be
+// hidden.
+func (c *completer) learn(line string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	text := strings.TrimRight(line, "\n")
+	switch {
+	case text == helpStart:
+		c.inHelp = true
+		return c.quietHelp
+	case c.inHelp && text == helpEnd:
+		c.inHelp = false
+		quiet := c.quietHelp
+		c.quietHelp = false
+		return quiet
+	case c.inHelp:
+		if name, _, _ := strings.Cut(strings.TrimSpace(text), " "); strings.HasPrefix(name, "/") {
+			c.commands[name] = true
+		}
+		return c.quietHelp
+	}
+
+	if list, ok := strings.CutPrefix(text, "Connected users: "); ok {
+		clear(c.users)
+		for _, name := range strings.Split(list, ", ") {
+			c.addUser(name)
+		}
+		if c.quietList > 0 {
+			c.quietList--
+			return true
+		}
+		return false
+	}
+
+	text = withoutTimestamp(text)
+	if notice, ok := strings.CutPrefix(text, systemSender+": "); ok {
+		if name, _, ok := strings.Cut(notice, " has joined "); ok {
+			c.addUser(name)
+		} else if name, ok := strings.CutSuffix(notice, " has left our chat..."); ok {
+			delete(c.users, name)
+		} else if name, _, ok := strings.Cut(notice, " has left: "); ok {
+			delete(c.users, name) // Left with /quit
+		} else if old, name, ok := strings.Cut(notice, " is now known as "); ok {
+			delete(c.users, old)
+			c.addUser(name)
+		}
+		return false
+	}
+	if isChatLine(text) {
+		name, _, _ := strings.Cut(text, ": ")
+		c.addUser(name)
+	}
+	return false
+}
+
+// addUser adds a name as listed by the server, without markers such as
+// " (away)". The caller must hold mu.
+func (c *completer) addUser(listed string) {
+	name, _, _ := strings.Cut(strings.TrimSpace(listed), " ")
+	if name != "" {
+		c.users[name] = true
+	}
+}
+
+// complete completes the word before the cursor in input: commands at
+// the start of the line, user names elsewhere or after an @. A single
+// match is completed followed by a space and several are completed as
+// far as they agree; when that adds nothing, the matches are returned for
+// display instead.
+func (c *completer) complete(input []rune, cursor int) ([]rune, int, []string) {
+	start := cursor
+	for start > 0 && input[start-1] != ' ' {
+		start--
+	}
+	word := string(input[start:cursor])
+	at := strings.HasPrefix(word, "@")
+	word = strings.TrimPrefix(word, "@")
+	if word == "" {
+		return input, cursor, nil
+	}
+
+	c.mu.Lock()
+	pool := c.users
+	if start == 0 && !at && strings.HasPrefix(word, "/") {
+		pool = c.commands
+	}
+	var matches []string
+	for name := range pool {
+		if name != c.self && strings.HasPrefix(strings.ToLower(name), strings.ToLower(word)) {
+			matches = append(matches, name)
+		}
+	}
+	c.mu.Unlock()
+	sort.Strings(matches)
+
+	var completion string
+	switch {
+	case len(matches) == 0:
+		return input, cursor, nil
+	case len(matches) == 1:
+		completion = matches[0]
+		if cursor == len(input) || input[cursor] != ' ' {
+			completion += " "
+		}
+	default:
+		completion = commonPrefix(matches)
+		if len([]rune(completion)) <= len([]rune(word)) {
+			return input, cursor, matches
+		}
+	}
+	if at {
+		completion = "@" + completion
+	}
+	replaced := append([]rune(completion), input[cursor:]...)
+	return append(input[:start:start], replaced...), start + len([]rune(completion)), nil
+}
+
+// commonPrefix returns the longest prefix the words share, ignoring case
+// and spelled as in the first word.
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, word := range words[1:] {
+		other := []rune(strings.ToLower(word))
+		n := 0
+		for n < len(prefix) && n < len(other) && strings.ToLower(string(prefix[n])) == string(other[n]) {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCompleterLearn(t *testing.T) {
+	t.Parallel()
+	c := newCompleter()
+	if !c.welcomed("alice") || c.welcomed("alice") {
+		t.Error("welcomed should report the first login only")
+	}
+	var sent bytes.Buffer
+	if err := c.fetch(&sent); err != nil || sent.String() != "/help\n/list\n" {
+		t.Fatalf("fetch sent %q, %v", sent.String(), err)
+	}
+
+	lines := []struct {
+		line   string
+		hidden bool
+	}{
+		{"Commands:\n", true},
+		{"  /join <#room> - Join a room\n", true},
+		{"  /nick <name> - Change your name\n", true},
+		{"Type /help <command> for details\n", true},
+		{"Connected users: alice, bob (away), guest-7 (guest)\n", true},
+		{"Connected users: alice, bob (away), guest-7 (guest)\n", false}, // Asked for by the user
+		{"Commands:\n", false},
+		{"  /poll - Start a poll\n", false},
+		{"Type /help <command> for details\n", false},
+		{"[2024-01-01 10:00:00] SERVER: carol has joined our chat...\n", false},
+		{"SERVER: guest-7 has left our chat...\n", false},
+		{"SERVER: erin has joined our chat...\n", false},
+		{"SERVER: erin has left: bye all\n", false},
+		{"SERVER: bob is now known as robert\n", false},
+		{"[2024-01-01 10:00:00] dave: hi\n", false},
+	}
+	for _, l := range lines {
+		if got := c.learn(l.line); got != l.hidden {
+			t.Errorf("learn(%q) = %v, want %v", l.line, got, l.hidden)
+		}
+	}
+
+	for _, name := range []string{"/join", "/nick", "/poll", "/exec", "/msg"} {
+		if !c.commands[name] {
+			t.Errorf("command %s not learned", name)
+		}
+	}
+	want := map[string]bool{"alice": true, "robert": true, "carol": true, "dave": true}
+	if !reflect.DeepEqual(c.users, want) {
+		t.Errorf("users = %v, want %v", c.users, want)
+	}
+}
+
+func TestCompleterComplete(t *testing.T) {
+	t.Parallel()
+	c := newCompleter()
+	c.welcomed("alice")
+	for _, line := range []string{"Connected users: alice, albert, alfred, bob", "Commands:", "  /me <action> - x", "  /motd - x", "  /join <#room> - x", helpEnd} {
+		c.learn(line)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		cursor  int
+		want    string
+		wantCur int
+		matches []string
+	}{
+		{"unique command", "/j", 2, "/join ", 6, nil},
+		{"ambiguous command extends", "/mo", 3, "/motd ", 6, nil},
+		{"ambiguous command lists", "/m", 2, "/m", 2, []string{"/me", "/motd", "/msg"}},
+		{"unique user", "hi b", 4, "hi bob ", 7, nil},
+		{"user at line start", "Bo", 2, "bob ", 4, nil},
+		{"users extend to common prefix", "al", 2, "al", 2, []string{"albert", "alfred"}},
+		{"mention", "hey @alb", 8, "hey @albert ", 12, nil},
+		{"in the middle", "bo there", 2, "bob there", 3, nil},
+		{"command is not completed later in the line", "say /j", 6, "say /j", 6, nil},
+		{"no match", "zed", 3, "zed", 3, nil},
+		{"empty word", "hi ", 3, "hi ", 3, nil},
+		{"own name left out", "alic", 4, "alic", 4, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, cursor, matches := c.complete([]rune(tt.input), tt.cursor)
+			if string(got) != tt.want || cursor != tt.wantCur || !reflect.DeepEqual(matches, tt.matches) {
+				t.Errorf("complete(%q, %d) = %q, %d, %q; want %q, %d, %q", tt.input, tt.cursor, string(got), cursor, matches, tt.want, tt.wantCur, tt.matches)
+			}
+		})
+	}
+}
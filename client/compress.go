@@ -0,0 +1,105 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// compressions are the stream compression methods the client can offer
+// with -compress.
+var compressions = []string{"gzip", "deflate"}
+
+// compressToken offers method in the handshake.
+func compressToken(method string) string {
+	return "COMPRESS=" + method
+}
+
+// negotiateCompression reads the server's answer to a compression offer,
+// the first line after the handshake, and returns conn wrapped in the
+// method the server picked. Any other first line is a refusal sent before
+// the server got to the offer; it is returned for showing, with conn as
+// it is.
+func negotiateCompression(conn net.Conn) (net.Conn, string, error) {
+	// Byte by byte, as what follows the answer is compressed
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := conn.Read(b); err != nil {
+			return nil, "", fmt.Errorf("waiting for the compression answer: %w", err)
+		}
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			break
+		}
+	}
+	method, ok := strings.CutPrefix(strings.TrimSpace(string(line)), "COMPRESSION ")
+	switch {
+	case !ok:
+		return conn, string(line), nil
+	case method == "none":
+		return conn, "", nil
+	}
+	z, err := newCompressedConn(conn, method)
+	if err != nil {
+		return nil, "", err
+	}
+	return z, "", nil
+}
+
+// compressedConn compresses a connection in both directions, flushing
+// every write so messages go out right away.
+type compressedConn struct {
+	net.Conn
+	open func() (io.Reader, error)
+	r    io.Reader // Opened on the first read, as gzip starts with a header
+
+	writeMu sync.Mutex
+	w       interface {
+		io.Writer
+		Flush() error
+	}
+}
+
+func newCompressedConn(conn net.Conn, method string) (*compressedConn, error) {
+	z := &compressedConn{Conn: conn}
+	switch method {
+	case "gzip":
+		z.w = gzip.NewWriter(conn)
+		z.open = func() (io.Reader, error) { return gzip.NewReader(conn) }
+	case "deflate":
+		z.w, _ = flate.NewWriter(conn, flate.DefaultCompression)
+		z.open = func() (io.Reader, error) { return flate.NewReader(conn), nil }
+	default:
+		return nil, errors.New("server picked unknown compression " + method)
+	}
+	return z, nil
+}
+
+func (z *compressedConn) Read(p []byte) (int, error) {
+	if z.r == nil {
+		r, err := z.open()
+		if err != nil {
+			return 0, err
+		}
+		z.r = r
+	}
+	return z.r.Read(p)
+}
+
+func (z *compressedConn) Write(p []byte) (int, error) {
+	z.writeMu.Lock()
+	defer z.writeMu.Unlock()
+	if _, err := z.w.Write(p); err != nil {
+		return 0, err
+	}
+	if err := z.w.Flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"net"
+	"testing"
+)
+
+func TestNegotiateCompression(t *testing.T) {
+	t.Parallel()
+
+	t.Run("gzip", func(t *testing.T) {
+		t.Parallel()
+		server, client := net.Pipe()
+		defer server.Close()
+		go func() {
+			server.Write([]byte("COMPRESSION gzip\n"))
+			w := gzip.NewWriter(server)
+			w.Write([]byte("Welcome to TCP-Chat!\n"))
+			w.Flush()
+		}()
+		conn, refusal, err := negotiateCompression(client)
+		if err != nil || refusal != "" {
+			t.Fatalf("negotiateCompression() = %q, %v", refusal, err)
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || line != "Welcome to TCP-Chat!\n" {
+			t.Errorf("Read %q, %v", line, err)
+		}
+	})
+
+	tests := []struct {
+		name        string
+		answer      string
+		wantRefusal string
+	}{
+		{"none", "COMPRESSION none\n", ""},
+		{"refused", "You are banned from this server.\n", "You are banned from this server.\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server, client := net.Pipe()
+			defer server.Close()
+			go server.Write([]byte(tt.answer))
+			conn, refusal, err := negotiateCompression(client)
+			if err != nil || refusal != tt.wantRefusal || conn != client {
+				t.Errorf("negotiateCompression() = %v, %q, %v, want the plain connection and %q", conn, refusal, err, tt.wantRefusal)
+			}
+		})
+	}
+}
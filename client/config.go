@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// configEnv names the client configuration file instead of the default
+// ~/.tcpchat.toml.
+const configEnv = "TCPCHAT_CLIENT_CONFIG"
+
+// Levels of system message verbosity, see displayConfig.
+const (
+	systemAll   = "all"   // Every server notice
+	systemQuiet = "quiet" // No join, leave or rename notices
+	systemOff   = "off"   // No server notices at all
+)
+
+// displayConfig is the [display] section of the configuration.
+type displayConfig struct {
+	color      bool   // Colors and formatting, when the terminal allows them
+	timestamps bool   // Show the time in front of messages
+	system     string // systemAll, systemQuiet or systemOff
+	logo       bool   // Show the banner and logo before the name prompt
+	bell       bool   // Ring the terminal bell on mentions and private messages
+	timeFormat string // As -time-format
+	timezone   string // As -tz
+}
+
+// serverConfig is the [server] section of the configuration, or one of
+// the [profiles.<name>] sections: where to connect when no address is
+// given and the name to log in with.
+type serverConfig struct {
+	address string
+	port    int
+	name    string // Sent at the name prompt, empty to be asked
+	tls     bool   // Connect over TLS, to a server behind a TLS proxy
+	tlsCA   string // PEM file of the certificates to trust instead of the system's
+}
+
+// clientConfig holds the settings read from the configuration file.
+type clientConfig struct {
+	server   serverConfig
+	profiles map[string]serverConfig // Chosen with -profile instead of [server]
+	display  displayConfig
+	notify   notifyConfig
+	theme    theme
+	aliases  map[string]string // Command names without the slash and what they stand for
+}
+
+func defaultClientConfig() clientConfig {
+	return clientConfig{
+		server:   serverConfig{port: 8989},
+		profiles: make(map[string]serverConfig),
+		display:  displayConfig{color: true, timestamps: true, system: systemAll, logo: true, bell: true, timeFormat: "24h", timezone: "Local"},
+		notify:   notifyConfig{terminal: notifyTermOff},
+		theme:    defaultTheme(),
+		aliases:  make(map[string]string),
+	}
+}
+
+// configPath returns the configuration file to read and whether it was
+// named explicitly, in which case it must exist.
+func configPath(flagPath string) (string, bool) {
+	if flagPath != "" {
+		return flagPath, true
+	}
+	if path := os.Getenv(configEnv); path != "" {
+		return path, true
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(home, ".tcpchat.toml"), false
+}
+
+// loadClientConfig reads the configuration file over the defaults. A
+// missing default file is not an error.
+func loadClientConfig(path string, required bool) (clientConfig, error) {
+	cfg := defaultClientConfig()
+	if path == "" {
+		return cfg, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	values, err := parseConfig(f)
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.apply(values); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// apply sets the fields named in values, keyed "section.key".
+func (cfg *clientConfig) apply(values map[string]any) error {
+	for key, value := range values {
+		var err error
+		switch key {
+		case "display.color":
+			err = setValue(&cfg.display.color, value)
+		case "display.timestamps":
+			err = setValue(&cfg.display.timestamps, value)
+		case "display.logo":
+			err = setValue(&cfg.display.logo, value)
+		case "display.bell":
+			err = setValue(&cfg.display.bell, value)
+		case "display.system":
+			if err = setValue(&cfg.display.system, value); err == nil &&
+				cfg.display.system != systemAll && cfg.display.system != systemQuiet && cfg.display.system != systemOff {
+				err = fmt.Errorf("must be %q, %q or %q", systemAll, systemQuiet, systemOff)
+			}
+		case "display.time_format":
+			err = setValue(&cfg.display.timeFormat, value)
+		case "display.timezone":
+			err = setValue(&cfg.display.timezone, value)
+		case "notify.command":
+			err = setValue(&cfg.notify.command, value)
+		case "notify.terminal":
+			if err = setValue(&cfg.notify.terminal, value); err == nil &&
+				cfg.notify.terminal != notifyTermOff && cfg.notify.terminal != notifyTermOSC9 && cfg.notify.terminal != notifyTermOSC777 {
+				err = fmt.Errorf("must be %q, %q or %q", notifyTermOff, notifyTermOSC9, notifyTermOSC777)
+			}
+		default:
+			if name, ok := strings.CutPrefix(key, "colors."); ok {
+				err = cfg.theme.set(name, value)
+				break
+			}
+			if name, ok := strings.CutPrefix(key, "aliases."); ok {
+				err = cfg.setAlias(name, value)
+				break
+			}
+			if field, ok := strings.CutPrefix(key, "server."); ok {
+				err = cfg.server.set(field, value)
+				break
+			}
+			if rest, ok := strings.CutPrefix(key, "profiles."); ok {
+				name, field, found := strings.Cut(rest, ".")
+				if !found || name == "" {
+					err = errors.New("unknown setting")
+					break
+				}
+				profile, ok := cfg.profiles[name]
+				if !ok {
+					profile = serverConfig{port: 8989}
+				}
+				err = profile.set(field, value)
+				cfg.profiles[name] = profile
+				break
+			}
+			err = errors.New("unknown setting")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// set changes the setting called field, a key of the [server] section.
+func (s *serverConfig) set(field string, value any) error {
+	switch field {
+	case "address":
+		return setValue(&s.address, value)
+	case "port":
+		if err := setValue(&s.port, value); err != nil {
+			return err
+		}
+		if s.port < 1 || s.port > 65535 {
+			return errors.New("must be between 1 and 65535")
+		}
+	case "name":
+		if err := setValue(&s.name, value); err != nil {
+			return err
+		}
+		if strings.ContainsAny(s.name, " \t") {
+			return errors.New("must not contain spaces")
+		}
+	case "tls":
+		return setValue(&s.tls, value)
+	case "tls_ca":
+		return setValue(&s.tlsCA, value)
+	default:
+		return errors.New("unknown setting")
+	}
+	return nil
+}
+
+// profile returns the server settings of the named profile.
+func (cfg *clientConfig) profile(name string) (serverConfig, error) {
+	profile, ok := cfg.profiles[name]
+	if !ok {
+		names := make([]string, 0, len(cfg.profiles))
+		for name := range cfg.profiles {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		if len(names) == 0 {
+			return profile, fmt.Errorf("unknown profile %q, the configuration has none", name)
+		}
+		return profile, fmt.Errorf("unknown profile %q, the configuration has %s", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// tlsConfig returns the TLS settings to connect to host with, or nil when
+// the server is not reached over TLS.
+func (s serverConfig) tlsConfig(host string) (*tls.Config, error) {
+	if !s.tls {
+		return nil, nil
+	}
+	conf := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if s.tlsCA != "" {
+		pem, err := os.ReadFile(s.tlsCA)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", s.tlsCA)
+		}
+	}
+	return conf, nil
+}
+
+// setAlias makes /name stand for the command in value.
+func (cfg *clientConfig) setAlias(name string, value any) error {
+	var command string
+	if err := setValue(&command, value); err != nil {
+		return err
+	}
+	if name == "" || strings.ContainsAny(name, " /") {
+		return errors.New("invalid alias name")
+	}
+	if !strings.HasPrefix(command, "/") || strings.TrimSpace(command) == "/" {
+		return errors.New("must be a command starting with /")
+	}
+	cfg.aliases[name] = command
+	return nil
+}
+
+// expandAlias replaces an alias at the start of message with the command
+// it stands for, keeping the arguments.
+func expandAlias(aliases map[string]string, message string) string {
+	name, args, _ := strings.Cut(message, " ")
+	command, ok := aliases[strings.TrimPrefix(name, "/")]
+	if !ok || !strings.HasPrefix(name, "/") {
+		return message
+	}
+	if args == "" {
+		return command
+	}
+	return command + " " + args
+}
+
+// setValue stores value in dst when the types agree.
+func setValue[T any](dst *T, value any) error {
+	v, ok := value.(T)
+	if !ok {
+		var zero T
+		return fmt.Errorf("expected a %T, got %v", zero, value)
+	}
+	*dst = v
+	return nil
+}
+
+// parseConfig reads the subset of TOML the configuration needs: [section]
+// headers, # comments and key = value lines whose values are strings,
+// booleans, integers or arrays of strings. It returns the values keyed
+// "section.key".
+func parseConfig(r io.Reader) (map[string]any, error) {
+	values := make(map[string]any)
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			name, ok = strings.CutSuffix(name, "]")
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("line %d: invalid section header %q", n, line)
+			}
+			section = strings.TrimSpace(name)
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		value, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// stripComment removes a # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++ // Skip the escaped character
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseConfigValue(raw string) (any, error) {
+	switch {
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") || strings.Contains(raw[1:len(raw)-1], "'") {
+			return nil, fmt.Errorf("invalid string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case strings.HasPrefix(raw, "["):
+		inner, ok := strings.CutSuffix(raw[1:], "]")
+		if !ok {
+			return nil, fmt.Errorf("invalid array %s", raw)
+		}
+		list := []string{}
+		for _, item := range strings.Split(inner, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue // Trailing comma
+			}
+			value, err := parseConfigValue(item)
+			s, ok := value.(string)
+			if err != nil || !ok {
+				return nil, fmt.Errorf("arrays may only hold strings: %s", raw)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s", raw)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	input := `# Display settings
+[display]
+color = false   # no colors
+timestamps = true
+system = "quiet"
+time_format = '12h'
+
+[colors]
+pm = "bold #1"
+senders = ["red", "green",]
+count = 3
+`
+	got, err := parseConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	want := map[string]any{
+		"display.color":       false,
+		"display.timestamps":  true,
+		"display.system":      "quiet",
+		"display.time_format": "12h",
+		"colors.pm":           "bold #1",
+		"colors.senders":      []string{"red", "green"},
+		"colors.count":        3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"[display", "color", "x = yes", `x = "open`, "x = [1, 2]", "x = 'a'b'"} {
+		if _, err := parseConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseConfig(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := loadClientConfig(filepath.Join(dir, "missing.toml"), false)
+	if err != nil || !reflect.DeepEqual(cfg, defaultClientConfig()) {
+		t.Errorf("missing default file: %v, %+v", err, cfg)
+	}
+	if _, err := loadClientConfig(filepath.Join(dir, "missing.toml"), true); err == nil {
+		t.Error("missing named file accepted")
+	}
+
+	path := write("ok.toml", "[display]\nlogo = false\nsystem = 'off'\ntimestamps = false\n[colors]\nmention = \"underline red\"\n")
+	cfg, err = loadClientConfig(path, true)
+	if err != nil {
+		t.Fatalf("loadClientConfig: %v", err)
+	}
+	if cfg.display.logo || cfg.display.timestamps || cfg.display.system != systemOff || !cfg.display.color {
+		t.Errorf("display = %+v", cfg.display)
+	}
+	if cfg.theme.mention != "\x1b[4;31m" || cfg.theme.pm != ansiPM {
+		t.Errorf("theme = %q", cfg.theme)
+	}
+
+	path = write("server.toml", "[server]\naddress = \"chat.example.com\"\nname = \"alice\"\n[aliases]\nj = \"/join\"\nw = \"/msg\"\n")
+	cfg, err = loadClientConfig(path, true)
+	if err != nil {
+		t.Fatalf("loadClientConfig: %v", err)
+	}
+	if want := (serverConfig{address: "chat.example.com", port: 8989, name: "alice"}); cfg.server != want {
+		t.Errorf("server = %+v, want %+v", cfg.server, want)
+	}
+	if want := map[string]string{"j": "/join", "w": "/msg"}; !reflect.DeepEqual(cfg.aliases, want) {
+		t.Errorf("aliases = %q, want %q", cfg.aliases, want)
+	}
+
+	path = write("profiles.toml", "[server]\naddress = \"home\"\n[profiles.work]\naddress = \"chat.corp\"\nport = 6697\nname = \"alice.w\"\ntls = true\n[profiles.friends]\naddress = \"friends.example\"\n")
+	cfg, err = loadClientConfig(path, true)
+	if err != nil {
+		t.Fatalf("loadClientConfig: %v", err)
+	}
+	work, err := cfg.profile("work")
+	if want := (serverConfig{address: "chat.corp", port: 6697, name: "alice.w", tls: true}); err != nil || work != want {
+		t.Errorf("profile(work) = %+v, %v, want %+v", work, err, want)
+	}
+	if friends, _ := cfg.profile("friends"); friends.port != 8989 || friends.name != "" {
+		t.Errorf("profile(friends) = %+v, want the default port and no name", friends)
+	}
+	if _, err := cfg.profile("school"); err == nil || !strings.Contains(err.Error(), "friends, work") {
+		t.Errorf("profile(school) = %v, want the profiles listed", err)
+	}
+
+	path = write("notify.toml", "[notify]\ncommand = 'notify-send \"$TCPCHAT_NOTIFY_TITLE\"'\nterminal = \"osc777\"\n")
+	cfg, err = loadClientConfig(path, true)
+	if err != nil {
+		t.Fatalf("loadClientConfig: %v", err)
+	}
+	if want := (notifyConfig{command: `notify-send "$TCPCHAT_NOTIFY_TITLE"`, terminal: notifyTermOSC777}); cfg.notify != want {
+		t.Errorf("notify = %+v, want %+v", cfg.notify, want)
+	}
+
+	for name, content := range map[string]string{
+		"bad terminal":   "[notify]\nterminal = \"popup\"\n",
+		"profile key":    "[profiles.work]\nfont = \"mono\"\n",
+		"profile no key": "[profiles]\nwork = 1\n",
+		"bad port":       "[server]\nport = 70000\n",
+		"spaced name":    "[server]\nname = \"al ice\"\n",
+		"alias no slash": "[aliases]\nj = \"join\"\n",
+		"unknown key":    "[display]\nfont = \"mono\"\n",
+		"wrong type":     "[display]\ncolor = \"yes\"\n",
+		"bad level":      "[display]\nsystem = \"loud\"\n",
+		"unknown color":  "[colors]\npm = \"plaid\"\n",
+	} {
+		if _, err := loadClientConfig(write("bad.toml", content), true); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestExpandAlias(t *testing.T) {
+	t.Parallel()
+	aliases := map[string]string{"j": "/join", "hi": "/me waves"}
+	for message, want := range map[string]string{
+		"/j #random": "/join #random",
+		"/j":         "/join",
+		"/hi":        "/me waves",
+		"/hi to bob": "/me waves to bob",
+		"j #random":  "j #random",
+		"/join #x":   "/join #x",
+		"hello /j":   "hello /j",
+	} {
+		if got := expandAlias(aliases, message); got != want {
+			t.Errorf("expandAlias(%q) = %q, want %q", message, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Address families accepted by dialServer.
+const (
+	familyAny  = ""
+	familyIPv4 = "ipv4"
+	familyIPv6 = "ipv6"
+)
+
+// fallbackDelay is how long the preferred family gets before the other one
+// joins the race, as in RFC 8305 (happy eyeballs).
+const fallbackDelay = 250 * time.Millisecond
+
+// lastFamily is the family of the last successful connection. It is tried
+// first on reconnects so the faster family keeps winning without delay.
+var lastFamily = familyIPv6
+
+// addrGroup is the addresses of one family, in resolver order.
+type addrGroup struct {
+	family string
+	addrs  []string
+}
+
+// splitFamilies groups ips by family, the preferred family first. A
+// forced family drops the other one.
+func splitFamilies(ips []net.IPAddr, force, prefer string) []addrGroup {
+	v4 := addrGroup{family: familyIPv4}
+	v6 := addrGroup{family: familyIPv6}
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			v4.addrs = append(v4.addrs, ip.IP.String())
+		} else {
+			v6.addrs = append(v6.addrs, ip.String())
+		}
+	}
+
+	ordered := []addrGroup{v6, v4}
+	if prefer == familyIPv4 {
+		ordered = []addrGroup{v4, v6}
+	}
+	var groups []addrGroup
+	for _, g := range ordered {
+		if len(g.addrs) > 0 && (force == familyAny || force == g.family) {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+type dialResult struct {
+	conn   net.Conn
+	err    error
+	family string
+}
+
+// dialGroup tries the addresses of g one after the other.
+func dialGroup(ctx context.Context, g addrGroup, port string) dialResult {
+	var dialer net.Dialer
+	err := fmt.Errorf("no %s address", g.family)
+	for _, addr := range g.addrs {
+		conn, dialErr := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, port))
+		if dialErr == nil {
+			return dialResult{conn: conn, family: g.family}
+		}
+		err = dialErr
+	}
+	return dialResult{err: err, family: g.family}
+}
+
+// dialServer connects to host:port. When host has both IPv4 and IPv6
+// addresses the families race, the preferred one starting fallbackDelay
+// ahead, and the first connection wins. force restricts dialing to one
+// family.
+func dialServer(host, port, force string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	groups := splitFamilies(ips, force, lastFamily)
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no %s address for %s", force, host)
+	}
+
+	results := make(chan dialResult, len(groups))
+	primaryFailed := make(chan struct{})
+	for i, g := range groups {
+		go func(i int, g addrGroup) {
+			if i > 0 {
+				select {
+				case <-time.After(fallbackDelay):
+				case <-primaryFailed:
+				case <-ctx.Done():
+				}
+			}
+			results <- dialGroup(ctx, g, port)
+		}(i, g)
+	}
+
+	var firstErr error
+	for remaining := len(groups); remaining > 0; remaining-- {
+		r := <-results
+		if r.err == nil {
+			lastFamily = r.family
+			// Close whatever the losing attempts still connect
+			go func(left int) {
+				for ; left > 0; left-- {
+					if late := <-results; late.conn != nil {
+						late.conn.Close()
+					}
+				}
+			}(remaining - 1)
+			return r.conn, nil
+		}
+		if r.family == groups[0].family {
+			close(primaryFailed)
+		}
+		if firstErr == nil {
+			firstErr = r.err
+		}
+	}
+	return nil, firstErr
+}
+
+// dialChat connects to host:port as dialServer does and, when tlsConf is
+// set, completes a TLS handshake within the same timeout.
+func dialChat(host, port, force string, timeout time.Duration, tlsConf *tls.Config) (net.Conn, error) {
+	start := time.Now()
+	conn, err := dialServer(host, port, force, timeout)
+	if err != nil || tlsConf == nil {
+		return conn, err
+	}
+	secure := tls.Client(conn, tlsConf)
+	secure.SetDeadline(start.Add(timeout))
+	if err := secure.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	secure.SetDeadline(time.Time{})
+	return secure, nil
+}
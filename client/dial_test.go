@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitFamilies(t *testing.T) {
+	ips := []net.IPAddr{
+		{IP: net.ParseIP("192.0.2.1")},
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("192.0.2.2")},
+	}
+	tests := []struct {
+		name   string
+		force  string
+		prefer string
+		want   []addrGroup
+	}{
+		{"IPv6 first", familyAny, familyIPv6, []addrGroup{
+			{familyIPv6, []string{"2001:db8::1"}},
+			{familyIPv4, []string{"192.0.2.1", "192.0.2.2"}},
+		}},
+		{"Remembered IPv4", familyAny, familyIPv4, []addrGroup{
+			{familyIPv4, []string{"192.0.2.1", "192.0.2.2"}},
+			{familyIPv6, []string{"2001:db8::1"}},
+		}},
+		{"Forced IPv4", familyIPv4, familyIPv6, []addrGroup{
+			{familyIPv4, []string{"192.0.2.1", "192.0.2.2"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitFamilies(ips, tt.force, tt.prefer); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDialServer(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	conn, err := dialServer("127.0.0.1", port, familyAny, time.Second)
+	if err != nil {
+		t.Fatalf("dialServer: %v", err)
+	}
+	conn.Close()
+	if lastFamily != familyIPv4 {
+		t.Errorf("Expected IPv4 to be remembered, got %q", lastFamily)
+	}
+
+	if _, err := dialServer("127.0.0.1", port, familyIPv6, time.Second); err == nil {
+		t.Error("Expected forcing IPv6 on an IPv4 address to fail")
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and
+// returns it with its key.
+func writeTestCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(t.TempDir(), "server.crt")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, certFile
+}
+
+func TestDialChatTLS(t *testing.T) {
+	cert, certFile := writeTestCertificate(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("hello over tls\n"))
+				bufio.NewReader(conn).ReadString('\n')
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	conf, err := serverConfig{tls: true, tlsCA: certFile}.tlsConfig("127.0.0.1")
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	conn, err := dialChat("127.0.0.1", port, familyIPv4, 2*time.Second, conf)
+	if err != nil {
+		t.Fatalf("dialChat: %v", err)
+	}
+	defer conn.Close()
+	if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "hello over tls\n" {
+		t.Errorf("read %q, %v", line, err)
+	}
+
+	// The system roots do not know the test certificate
+	untrusted, _ := serverConfig{tls: true}.tlsConfig("127.0.0.1")
+	if conn, err := dialChat("127.0.0.1", port, familyIPv4, 2*time.Second, untrusted); err == nil {
+		conn.Close()
+		t.Error("Expected an unknown certificate to be refused")
+	}
+	if conf, err := (serverConfig{}).tlsConfig("127.0.0.1"); conf != nil || err != nil {
+		t.Errorf("tlsConfig without tls = %v, %v, want nothing", conf, err)
+	}
+}
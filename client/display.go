@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// noisyNotices end the server notices that systemQuiet hides: joins,
+// leaves and renames.
+var noisyNotices = []string{" has joined our chat...", " has left our chat...", " has left: ", " has joined #", " has left #", " is now known as "}
+
+// hidesNotice reports whether line, without its timestamp, is a server
+// notice the system message level leaves out.
+func hidesNotice(line, level string) bool {
+	text, ok := strings.CutPrefix(strings.TrimRight(withoutTimestamp(line), "\n"), systemSender+": ")
+	switch {
+	case !ok || level == systemAll:
+		return false
+	case level == systemOff:
+		return true
+	}
+	for _, noisy := range noisyNotices {
+		if strings.Contains(text, noisy) {
+			return true
+		}
+	}
+	return false
+}
+
+// logoFilter leaves out the banner and logo the server sends before the
+// name prompt, for users who turned the logo off.
+type logoFilter struct {
+	done bool // The prompt or welcome went by, everything is shown
+}
+
+// hide reports whether line belongs to the logo.
+func (f *logoFilter) hide(line string) bool {
+	if f.done {
+		return false
+	}
+	text := strings.TrimRight(line, "\n")
+	if _, welcomed := welcomedName(text); welcomed || strings.HasPrefix(text, namePrompt) {
+		f.done = true
+		return false
+	}
+	return true
+}
+
+// view turns lines from the server into what the user sees, following the
+// display configuration.
+type view struct {
+	clock   *clock
+	color   bool
+	display displayConfig
+	logo    logoFilter
+	bell    atomic.Bool // Ring the bell on alerts, toggled with /bell
+}
+
+func newView(clk *clock, color bool, display displayConfig) *view {
+	v := &view{clock: clk, color: color, display: display}
+	v.bell.Store(display.bell)
+	return v
+}
+
+// hides reports whether line is left out of the display.
+func (v *view) hides(line string) bool {
+	return (!v.display.logo && v.logo.hide(line)) || hidesNotice(line, v.display.system)
+}
+
+// render renders line with its timestamp in the user's timezone and
+// format, or without it when timestamps are turned off.
+func (v *view) render(line string) string {
+	if !v.display.timestamps {
+		if at, rest := splitTimestamp(line); !at.IsZero() {
+			return renderBody(rest, v.color, true)
+		}
+	}
+	return renderMessage(v.clock.restamp(line), v.color)
+}
+
+// alerts reports whether line calls for the user's attention: a private
+// message to them, or chat from someone else that contains their name.
+func alerts(line, name string) bool {
+	text := strings.TrimRight(withoutTimestamp(line), "\n")
+	if strings.HasPrefix(text, "[PM from ") {
+		return true
+	}
+	if name == "" || !isChatLine(text) {
+		return false
+	}
+	sender, body, _ := strings.Cut(text, ": ")
+	return sender != name && containsName(body, name)
+}
+
+// containsName reports whether name appears in text as a word of its own,
+// ignoring case: "alice" is in "hi Alice!" and "@alice" but not "malice".
+func containsName(text, name string) bool {
+	lower, name := strings.ToLower(text), strings.ToLower(name)
+	for offset := 0; ; {
+		i := strings.Index(lower[offset:], name)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(name)
+		before, _ := utf8.DecodeLastRuneInString(lower[:start])
+		after, _ := utf8.DecodeRuneInString(lower[end:])
+		if !isNameRune(before) && !isNameRune(after) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// isNameRune reports whether r can be part of a name; the empty ends of
+// a string decode as utf8.RuneError, which cannot.
+func isNameRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-')
+}
+
+// alert marks a rendered line that calls for attention, ringing the
+// terminal bell first unless it is turned off.
+func (v *view) alert(rendered string) string {
+	line := highlightMention(rendered, v.color)
+	if v.bell.Load() {
+		return "\a" + line
+	}
+	return line
+}
+
+// toggleBell answers "/bell [on|off]", which turns the bell on alerts on
+// or off, or toggles it without an argument.
+func (v *view) toggleBell(arg string) string {
+	switch arg {
+	case "":
+		v.bell.Store(!v.bell.Load())
+	case "on", "off":
+		v.bell.Store(arg == "on")
+	default:
+		return "Usage: /bell [on|off]"
+	}
+	if v.bell.Load() {
+		return "Bell on mentions and private messages on"
+	}
+	return "Bell on mentions and private messages off"
+}
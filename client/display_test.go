@@ -0,0 +1,153 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHidesNotice(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line  string
+		level string
+		want  bool
+	}{
+		{"[2024-01-01 10:00:00] SERVER: bob has joined our chat...\n", systemAll, false},
+		{"[2024-01-01 10:00:00] SERVER: bob has joined our chat...\n", systemQuiet, true},
+		{"SERVER: bob has left #dev\n", systemQuiet, true},
+		{"SERVER: bob is now known as rob\n", systemQuiet, true},
+		{"SERVER: bob has left: see you\n", systemQuiet, true},
+		{"SERVER: Reminder: stand-up\n", systemQuiet, false},
+		{"SERVER: Reminder: stand-up\n", systemOff, true},
+		{"alice: SERVER: bob has joined our chat...\n", systemOff, false},
+		{"Usage: /msg <user> <message>\n", systemOff, false},
+	}
+	for _, tt := range tests {
+		if got := hidesNotice(tt.line, tt.level); got != tt.want {
+			t.Errorf("hidesNotice(%q, %s) = %v, want %v", tt.line, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestLogoFilter(t *testing.T) {
+	t.Parallel()
+	lines := []struct {
+		line   string
+		hidden bool
+	}{
+		{"Welcome to TCP-Chat!\n", true},
+		{"         _nnnn_\n", true},
+		{"\n", true},
+		{namePrompt + "Welcome, alice!\n", false},
+		{"SERVER: bob has joined our chat...\n", false},
+	}
+	var f logoFilter
+	for _, l := range lines {
+		if got := f.hide(l.line); got != l.hidden {
+			t.Errorf("hide(%q) = %v, want %v", l.line, got, l.hidden)
+		}
+	}
+
+	// Guests skip the prompt
+	var guest logoFilter
+	if guest.hide("Welcome, guest-12! You are connected as a guest.\n") {
+		t.Error("guest welcome hidden")
+	}
+}
+
+func TestViewRender(t *testing.T) {
+	t.Parallel()
+	clk, err := newClock("24h", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.now = func() time.Time { return time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC) }
+	display := defaultClientConfig().display
+	v := &view{clock: clk, display: display}
+	line := "[2024-01-01 10:00:00] alice: hi\n"
+	if got := v.render(line); got != line {
+		t.Errorf("render = %q", got)
+	}
+
+	display.timestamps = false
+	v = &view{clock: clk, color: true, display: display}
+	if got, want := v.render(line), senderColor("alice")+"alice"+ansiReset+": hi\n"; got != want {
+		t.Errorf("render without timestamps = %q, want %q", got, want)
+	}
+
+	display.logo = false
+	v = &view{clock: clk, display: display}
+	if !v.hides("Welcome to TCP-Chat!\n") || v.hides(namePrompt+"Welcome, alice!\n") {
+		t.Error("logo not filtered")
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"[2024-01-01 10:00:00] [PM from bob]: psst\n", true},
+		{"[PM to bob]: psst\n", false},
+		{"[2024-01-01 10:00:00] bob: lunch, Alice?\n", true},
+		{"bob: ping @alice\n", true},
+		{"bob: no malice here\n", false},
+		{"alice: I am alice\n", false},
+		{"SERVER: alice has joined #dev\n", false},
+	}
+	for _, tt := range tests {
+		if got := alerts(tt.line, "alice"); got != tt.want {
+			t.Errorf("alerts(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+	if alerts("bob: hi alice\n", "") {
+		t.Error("alert before login")
+	}
+}
+
+func TestContainsName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text, name string
+		want       bool
+	}{
+		{"alice", "alice", true},
+		{"hi ALICE!", "alice", true},
+		{"malice and alice_2", "alice", false},
+		{"malice, then alice", "alice", true},
+		{"héloïse?", "héloïse", true},
+		{"bob-2 said", "bob", false},
+	}
+	for _, tt := range tests {
+		if got := containsName(tt.text, tt.name); got != tt.want {
+			t.Errorf("containsName(%q, %q) = %v, want %v", tt.text, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestViewAlert(t *testing.T) {
+	t.Parallel()
+	display := defaultClientConfig().display
+	v := newView(nil, false, display)
+	if got := v.alert("bob: hi alice\n"); got != "\a» bob: hi alice\n" {
+		t.Errorf("alert = %q", got)
+	}
+	if got := v.toggleBell(""); got != "Bell on mentions and private messages off" {
+		t.Errorf("toggleBell = %q", got)
+	}
+	if got := v.alert("bob: hi alice\n"); got != "» bob: hi alice\n" {
+		t.Errorf("alert with the bell off = %q", got)
+	}
+	if got := v.toggleBell("on"); got != "Bell on mentions and private messages on" || !v.bell.Load() {
+		t.Errorf("toggleBell(on) = %q", got)
+	}
+	if got := v.toggleBell("loud"); got != "Usage: /bell [on|off]" {
+		t.Errorf("toggleBell(loud) = %q", got)
+	}
+
+	display.bell = false
+	if newView(nil, false, display).bell.Load() {
+		t.Error("bell = false in the configuration ignored")
+	}
+}
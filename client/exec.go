@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// execEnv must be set to 1 to enable /exec. Running local commands
+	// from the chat prompt is off by default.
+	execEnv     = "TCPCHAT_ALLOW_EXEC"
+	execTimeout = 10 * time.Second
+	execFence   = "```"
+)
+
+func execEnabled() bool {
+	return os.Getenv(execEnv) == "1"
+}
+
+// runExec runs command through the shell and returns its combined output.
+func runExec(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %v", execTimeout)
+	}
+	return string(output), err
+}
+
+// formatExecOutput renders the output of command as a fenced code block,
+// one chat line per non-blank output line. Output is cut so that the whole block
+// stays within limit bytes.
+func formatExecOutput(command, output string, limit int) []string {
+	header := "$ " + command
+	lines := []string{execFence, header}
+	used := len(execFence) + len(header) + 2
+	// Room for the closing fence and the truncation marker
+	reserve := len(execFence) + len("... (truncated)") + 2
+
+	output = strings.ToValidUTF8(strings.TrimRight(output, "\n"), "?")
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.ReplaceAll(strings.TrimRight(line, "\r"), "\t", "    ")
+		if strings.TrimSpace(line) == "" {
+			continue // The server drops blank lines
+		}
+		if used+len(line)+1 > limit-reserve {
+			lines = append(lines, "... (truncated)")
+			break
+		}
+		lines = append(lines, line)
+		used += len(line) + 1
+	}
+	return append(lines, execFence)
+}
+
+// handleExecCommand runs command, previews its output and sends it to conn
+// once the user confirms on scanner.
+func handleExecCommand(conn io.Writer, scanner *bufio.Scanner, command string) error {
+	if !execEnabled() {
+		fmt.Printf("/exec is disabled. Set %s=1 to enable it.\n", execEnv)
+		return nil
+	}
+	if command == "" {
+		fmt.Println("Usage: /exec <command>")
+		return nil
+	}
+
+	output, err := runExec(command)
+	if err != nil {
+		output += fmt.Sprintf("\n(exit: %v)", err)
+	}
+	lines := formatExecOutput(command, output, maxMessageSize)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	fmt.Print("Send this output to the chat? [y/N] ")
+	if !scanner.Scan() || !strings.EqualFold(strings.TrimSpace(scanner.Text()), "y") {
+		fmt.Println("Not sent")
+		return nil
+	}
+
+	for _, line := range lines {
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatExecOutput(t *testing.T) {
+	t.Run("Short output", func(t *testing.T) {
+		lines := formatExecOutput("uptime", "up 3 days\n\n\tload 0.1\n", maxMessageSize)
+		want := []string{"```", "$ uptime", "up 3 days", "    load 0.1", "```"}
+		if strings.Join(lines, "|") != strings.Join(want, "|") {
+			t.Errorf("Expected %q, got %q", want, lines)
+		}
+	})
+
+	t.Run("Truncated output", func(t *testing.T) {
+		output := strings.Repeat(strings.Repeat("x", 99)+"\n", 50)
+		lines := formatExecOutput("make", output, maxMessageSize)
+		total := 0
+		for _, line := range lines {
+			total += len(line) + 1
+		}
+		if total > maxMessageSize {
+			t.Errorf("Expected at most %d bytes, got %d", maxMessageSize, total)
+		}
+		if lines[len(lines)-2] != "... (truncated)" || lines[len(lines)-1] != "```" {
+			t.Errorf("Expected truncation marker before the closing fence, got %q", lines[len(lines)-2:])
+		}
+	})
+}
+
+func TestHandleExecCommand(t *testing.T) {
+	t.Run("Disabled by default", func(t *testing.T) {
+		t.Setenv(execEnv, "")
+		var sent bytes.Buffer
+		handleExecCommand(&sent, bufio.NewScanner(strings.NewReader("y\n")), "echo hi")
+		if sent.Len() != 0 {
+			t.Errorf("Expected nothing sent, got %q", sent.String())
+		}
+	})
+
+	t.Run("Declined", func(t *testing.T) {
+		t.Setenv(execEnv, "1")
+		var sent bytes.Buffer
+		handleExecCommand(&sent, bufio.NewScanner(strings.NewReader("n\n")), "echo hi")
+		if sent.Len() != 0 {
+			t.Errorf("Expected nothing sent, got %q", sent.String())
+		}
+	})
+
+	t.Run("Confirmed", func(t *testing.T) {
+		t.Setenv(execEnv, "1")
+		var sent bytes.Buffer
+		if err := handleExecCommand(&sent, bufio.NewScanner(strings.NewReader("y\n")), "echo hi"); err != nil {
+			t.Fatalf("handleExecCommand: %v", err)
+		}
+		if want := "```\n$ echo hi\nhi\n```\n"; sent.String() != want {
+			t.Errorf("Expected %q, got %q", want, sent.String())
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxFindResults is how many matches /find shows, the most recent ones.
+const maxFindResults = 20
+
+// scrollback keeps the last messages shown, without colors, so /find can
+// search them without asking the server.
+type scrollback struct {
+	mu    sync.Mutex
+	lines []string // Oldest first
+	limit int
+}
+
+func newScrollback(limit int) *scrollback {
+	return &scrollback{limit: limit}
+}
+
+// add records text as printed, which may hold several lines.
+func (s *scrollback) add(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	text = strings.NewReplacer("\r", "", "\a", "").Replace(ansiSequence.ReplaceAllString(text, ""))
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			s.lines = append(s.lines, line)
+		}
+	}
+	if extra := len(s.lines) - s.limit; extra > 0 {
+		s.lines = append(s.lines[:0:0], s.lines[extra:]...)
+	}
+}
+
+// clear forgets every line, as /clear does with the screen.
+func (s *scrollback) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = nil
+}
+
+// find returns the lines containing query, ignoring case, oldest first.
+func (s *scrollback) find(query string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.ToLower(query)
+	var matches []string
+	for _, line := range s.lines {
+		if strings.Contains(strings.ToLower(line), query) {
+			matches = append(matches, line)
+		}
+	}
+	return matches
+}
+
+// findResults answers "/find <text>" with the matching lines, at most
+// maxFindResults of the latest.
+func findResults(s *scrollback, query string) []string {
+	if query == "" {
+		return []string{"Usage: /find <text>"}
+	}
+	matches := s.find(query)
+	switch len(matches) {
+	case 0:
+		return []string{fmt.Sprintf("No messages match %q", query)}
+	case 1:
+		return []string{fmt.Sprintf("1 message matches %q:", query), "  " + matches[0]}
+	}
+	lines := []string{fmt.Sprintf("%d messages match %q:", len(matches), query)}
+	if len(matches) > maxFindResults {
+		lines = append(lines, fmt.Sprintf("  ... %d earlier", len(matches)-maxFindResults))
+		matches = matches[len(matches)-maxFindResults:]
+	}
+	for _, match := range matches {
+		lines = append(lines, "  "+match)
+	}
+	return lines
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestScrollbackFind(t *testing.T) {
+	t.Parallel()
+	s := newScrollback(3)
+	s.add("[09:00] alice: old lunch plans\n")
+	s.add(ansiBold + "[09:01] bob" + ansiReset + ": Lunch?\n[09:02] carol: no\n")
+	s.add("[09:03] alice: LUNCH at noon\a\n")
+
+	want := []string{"[09:01] bob: Lunch?", "[09:03] alice: LUNCH at noon"}
+	if got := s.find("lunch"); !reflect.DeepEqual(got, want) {
+		t.Errorf("find(lunch) = %q, want %q", got, want)
+	}
+	s.clear()
+	if got := s.find("lunch"); got != nil {
+		t.Errorf("find after clear = %q, want nothing", got)
+	}
+}
+
+func TestFindResults(t *testing.T) {
+	t.Parallel()
+	s := newScrollback(100)
+	for i := 1; i <= maxFindResults+2; i++ {
+		s.add(fmt.Sprintf("alice: ping %d\n", i))
+	}
+
+	if got := findResults(s, ""); !reflect.DeepEqual(got, []string{"Usage: /find <text>"}) {
+		t.Errorf("findResults without text = %q", got)
+	}
+	if got := findResults(s, "pong"); !reflect.DeepEqual(got, []string{`No messages match "pong"`}) {
+		t.Errorf("findResults(pong) = %q", got)
+	}
+	if got := findResults(s, "ping 7"); !reflect.DeepEqual(got, []string{`1 message matches "ping 7":`, "  alice: ping 7"}) {
+		t.Errorf("findResults(ping 7) = %q", got)
+	}
+	got := findResults(s, "ping")
+	if len(got) != 2+maxFindResults || got[0] != `22 messages match "ping":` || got[1] != "  ... 2 earlier" || got[2] != "  alice: ping 3" {
+		t.Errorf("findResults(ping) = %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat checks that the server is still there: every interval the
+// client sends "PING <n>", and once missedPongs of them in a row go
+// unanswered the connection is given up for dead and redialed. The PONGs
+// also tell the round-trip time.
+type heartbeat struct {
+	limit      int          // Unanswered pings that end the connection, 0 never does
+	sent       atomic.Int64 // Pings sent so far, the token of the next one
+	unanswered atomic.Int32 // Pings sent since the last PONG
+
+	mu     sync.Mutex
+	sentAt map[string]time.Time // When the unanswered pings went out, by token
+	rtt    time.Duration        // Round-trip time of the last answered ping
+}
+
+// tick returns the next PING line to send, or false when too many pings
+// went unanswered and the connection should be dropped.
+func (h *heartbeat) tick() (string, bool) {
+	if h.limit > 0 && int(h.unanswered.Load()) >= h.limit {
+		return "", false
+	}
+	token := strconv.FormatInt(h.sent.Add(1), 10)
+	h.mu.Lock()
+	if h.sentAt == nil {
+		h.sentAt = make(map[string]time.Time)
+	}
+	h.sentAt[token] = time.Now()
+	h.mu.Unlock()
+	return "PING " + token, true
+}
+
+// latency returns the round-trip time of the last answered ping, 0 before
+// the first answer.
+func (h *heartbeat) latency() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rtt
+}
+
+// pinged records that a PING went out.
+func (h *heartbeat) pinged() {
+	h.unanswered.Add(1)
+}
+
+// reset forgets the unanswered pings, for a new connection.
+func (h *heartbeat) reset() {
+	h.unanswered.Store(0)
+	h.mu.Lock()
+	clear(h.sentAt)
+	h.mu.Unlock()
+}
+
+// handle deals with the heartbeat lines of the server: a PONG answers our
+// pings and a PING asks for the returned reply. It reports whether line
+// was either; neither is shown.
+func (h *heartbeat) handle(line string) (reply string, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "PONG" || strings.HasPrefix(line, "PONG ") {
+		h.mu.Lock()
+		if sent, ok := h.sentAt[strings.TrimPrefix(line, "PONG ")]; ok {
+			h.rtt = time.Since(sent)
+			clear(h.sentAt) // Earlier pings will not be answered any more
+		}
+		h.mu.Unlock()
+		h.unanswered.Store(0)
+		return "", true
+	}
+	if line == "PING" || strings.HasPrefix(line, "PING ") {
+		return "PONG" + strings.TrimPrefix(line, "PING"), true
+	}
+	return "", false
+}
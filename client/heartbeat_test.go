@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatTick(t *testing.T) {
+	t.Parallel()
+	h := &heartbeat{limit: 2}
+	for i, want := range []string{"PING 1", "PING 2"} {
+		line, alive := h.tick()
+		if !alive || line != want {
+			t.Fatalf("tick %d = %q, %t, want %q", i+1, line, alive, want)
+		}
+		h.pinged()
+	}
+	if _, alive := h.tick(); alive {
+		t.Fatal("Expected the connection to be given up after 2 unanswered pings")
+	}
+	if _, ok := h.handle("PONG 2\n"); !ok {
+		t.Fatal("Expected PONG to be handled")
+	}
+	if line, alive := h.tick(); !alive || line != "PING 3" {
+		t.Errorf("Expected a PONG to keep the connection, got %q, %t", line, alive)
+	}
+
+	unlimited := &heartbeat{}
+	for i := 0; i < 10; i++ {
+		unlimited.pinged()
+	}
+	if _, alive := unlimited.tick(); !alive {
+		t.Error("Expected no limit without missed pongs")
+	}
+}
+
+func TestHeartbeatHandle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line      string
+		wantReply string
+		wantOK    bool
+	}{
+		{"PING 1700000000000\n", "PONG 1700000000000", true},
+		{"PING\n", "PONG", true},
+		{"PONG 4\n", "", true},
+		{"alice: PING 3\n", "", false},
+		{"PINGU\n", "", false},
+	}
+	for _, tt := range tests {
+		reply, ok := (&heartbeat{}).handle(tt.line)
+		if reply != tt.wantReply || ok != tt.wantOK {
+			t.Errorf("handle(%q) = %q, %t, want %q, %t", tt.line, reply, ok, tt.wantReply, tt.wantOK)
+		}
+	}
+}
+
+func TestHeartbeatLatency(t *testing.T) {
+	t.Parallel()
+	h := &heartbeat{}
+	line, _ := h.tick()
+	if h.latency() != 0 {
+		t.Fatal("Expected no latency before a PONG")
+	}
+	time.Sleep(5 * time.Millisecond)
+	h.handle("PONG 99\n") // Not ours
+	if h.latency() != 0 {
+		t.Fatal("Expected a PONG to an unknown token to be ignored")
+	}
+	h.handle("PONG" + strings.TrimPrefix(line, "PING") + "\n")
+	if got := h.latency(); got < 5*time.Millisecond || got > time.Second {
+		t.Errorf("latency = %v, want about 5ms", got)
+	}
+}
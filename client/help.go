@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// clientCommand describes a command handled by the client itself rather
+// than the server.
+type clientCommand struct {
+	name        string
+	syntax      string
+	description string
+}
+
+// clientCommands are the commands the client handles locally. The server
+// lists its own in reply to /help.
+var clientCommands = []clientCommand{
+	{"/exec", "/exec <command>", "Run a local shell command and send its output after confirming, with " + execEnv + "=1"},
+	{"/bell", "/bell [on|off]", "Turn the terminal bell on mentions and private messages on or off"},
+	{"/notify", "/notify [on|off]", "Turn desktop notifications of mentions and private messages on or off"},
+	{"/quit", "/quit [message]", "Leave the chat with an optional farewell and exit the client"},
+	{"/clear", "/clear", "Clear the screen and its scrollback"},
+	{"/find", "/find <text>", "List the recent messages containing text"},
+}
+
+// clientHelp answers "/help [command]" for the local commands. It
+// returns nothing when the command is one for the server to explain.
+func clientHelp(message string) []string {
+	fields := strings.Fields(message)
+	switch len(fields) {
+	case 1:
+		lines := []string{"Client commands:"}
+		for _, cmd := range clientCommands {
+			lines = append(lines, fmt.Sprintf("  %s - %s", cmd.syntax, cmd.description))
+		}
+		return lines
+	case 2:
+		name := "/" + strings.TrimPrefix(fields[1], "/")
+		for _, cmd := range clientCommands {
+			if cmd.name == name {
+				return []string{"Usage: " + cmd.syntax, cmd.description}
+			}
+		}
+	}
+	return nil
+}
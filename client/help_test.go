@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientHelp(t *testing.T) {
+	t.Parallel()
+	if got := clientHelp("/help"); len(got) != 1+len(clientCommands) || !strings.HasPrefix(got[1], "  /exec <command> - ") {
+		t.Errorf("Expected the local commands listed, got %q", got)
+	}
+	for _, message := range []string{"/help exec", "/help /exec"} {
+		if got := clientHelp(message); len(got) != 2 || got[0] != "Usage: /exec <command>" {
+			t.Errorf("clientHelp(%q) = %q, want the usage of /exec", message, got)
+		}
+	}
+	for _, message := range []string{"/help msg", "/help a b"} {
+		if got := clientHelp(message); got != nil {
+			t.Errorf("clientHelp(%q) = %q, want nothing for the server to answer", message, got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+)
+
+var errLineTooLong = errors.New("line too long")
+
+// lineReader reads newline-terminated lines of bounded length from the
+// server. The rest of an oversized line is consumed and dropped so that
+// the following lines are read intact.
+type lineReader struct {
+	r          *bufio.Reader
+	max        int
+	partial    []byte
+	discarding bool
+}
+
+func newLineReader(r *bufio.Reader, max int) *lineReader {
+	return &lineReader{r: r, max: max}
+}
+
+// readLine returns the next line including its newline, or errLineTooLong
+// once the end of an oversized line has been read.
+func (l *lineReader) readLine() (string, error) {
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		if !l.discarding {
+			l.partial = append(l.partial, chunk...)
+			length := len(l.partial)
+			if err == nil {
+				length--
+			}
+			if length > l.max {
+				l.discarding = true
+				l.partial = l.partial[:0]
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if l.discarding {
+			l.discarding = false
+			return "", errLineTooLong
+		}
+		line := string(l.partial)
+		l.partial = l.partial[:0]
+		return line, nil
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	input := "hello\n" + strings.Repeat("z", maxMessageSize*10) + "\nworld\n"
+	lines := newLineReader(bufio.NewReaderSize(strings.NewReader(input), 16), maxMessageSize)
+
+	steps := []struct {
+		line string
+		err  error
+	}{
+		{"hello\n", nil},
+		{"", errLineTooLong},
+		{"world\n", nil},
+		{"", io.EOF},
+	}
+	for i, step := range steps {
+		line, err := lines.readLine()
+		if line != step.line || err != step.err {
+			t.Errorf("Step %d: expected (%q, %v), got (%q, %v)", i+1, step.line, step.err, line, err)
+		}
+	}
+}
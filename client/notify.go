@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Terminal notifications, see notifyConfig.
+const (
+	notifyTermOff    = "off"
+	notifyTermOSC9   = "osc9"   // iTerm2, Windows Terminal, kitty, WezTerm
+	notifyTermOSC777 = "osc777" // urxvt, foot and VTE terminals such as GNOME Terminal
+)
+
+const (
+	// notifyQuiet is the least time between two notifications, so a
+	// burst of private messages pops up once.
+	notifyQuiet = 2 * time.Second
+	// maxNotifyText is the most characters of a message a notification
+	// shows.
+	maxNotifyText = 200
+)
+
+// Focus of the terminal window as reported by the full-screen interface.
+// Without reports the focus is unknown and every alert notifies.
+const (
+	focusUnknown = iota
+	focusIn
+	focusOut
+)
+
+// notifyConfig is the [notify] section of the configuration.
+type notifyConfig struct {
+	command  string // Run through the shell on alerts, empty for none
+	terminal string // notifyTermOff, notifyTermOSC9 or notifyTermOSC777
+}
+
+// notifier tells the desktop about mentions and private messages while
+// the terminal window is not focused, with a command such as notify-send,
+// a terminal notification sequence, or both.
+type notifier struct {
+	cfg  notifyConfig
+	term io.Writer   // The terminal, nil when stdout is not one
+	on   atomic.Bool // Toggled with /notify
+
+	// run runs the command with the notification in its environment
+	run func(command string, env []string) error
+	now func() time.Time
+
+	mu     sync.Mutex
+	focus  int
+	last   time.Time // Of the latest notification
+	failed bool      // The command failed, which was reported once
+}
+
+func newNotifier(cfg notifyConfig, term io.Writer) *notifier {
+	n := &notifier{cfg: cfg, term: term, run: runNotifyCommand, now: time.Now}
+	n.on.Store(n.configured())
+	return n
+}
+
+// configured reports whether the configuration asks for notifications
+// that can be delivered.
+func (n *notifier) configured() bool {
+	return n.cfg.command != "" || (n.term != nil && n.cfg.terminal != notifyTermOff && n.cfg.terminal != "")
+}
+
+// setFocus records a focus report of the terminal.
+func (n *notifier) setFocus(focused bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if focused {
+		n.focus = focusIn
+	} else {
+		n.focus = focusOut
+	}
+}
+
+// notify sends a notification for line, a mention of the user or a
+// private message to them, unless the window has the focus or the last
+// notification was only just sent. It reports whether it did.
+func (n *notifier) notify(line string) bool {
+	if !n.on.Load() {
+		return false
+	}
+	n.mu.Lock()
+	now := n.now()
+	if n.focus == focusIn || (!n.last.IsZero() && now.Sub(n.last) < notifyQuiet) {
+		n.mu.Unlock()
+		return false
+	}
+	n.last = now
+	n.mu.Unlock()
+
+	kind, from, text := describeAlert(line)
+	title := notifyTitle(kind, from)
+	if n.term != nil {
+		switch n.cfg.terminal {
+		case notifyTermOSC9:
+			fmt.Fprintf(n.term, "\x1b]9;%s: %s\x1b\\", title, text)
+		case notifyTermOSC777:
+			fmt.Fprintf(n.term, "\x1b]777;notify;%s;%s\x1b\\", strings.ReplaceAll(title, ";", ","), text)
+		}
+	}
+	if n.cfg.command != "" {
+		env := []string{
+			"TCPCHAT_NOTIFY_KIND=" + kind,
+			"TCPCHAT_NOTIFY_FROM=" + from,
+			"TCPCHAT_NOTIFY_TITLE=" + title,
+			"TCPCHAT_NOTIFY_MESSAGE=" + text,
+		}
+		go func() {
+			err := n.run(n.cfg.command, env)
+			n.mu.Lock()
+			report := err != nil && !n.failed
+			n.failed = n.failed || err != nil
+			n.mu.Unlock()
+			if report {
+				fmt.Printf("Notification command failed: %v\n", err)
+			}
+		}()
+	}
+	return true
+}
+
+// toggle answers "/notify [on|off]", which turns notifications on or
+// off, or toggles them without an argument.
+func (n *notifier) toggle(arg string) string {
+	if !n.configured() {
+		return "Desktop notifications are not set up, see [notify] in the configuration"
+	}
+	switch arg {
+	case "":
+		n.on.Store(!n.on.Load())
+	case "on", "off":
+		n.on.Store(arg == "on")
+	default:
+		return "Usage: /notify [on|off]"
+	}
+	if n.on.Load() {
+		return "Desktop notifications on"
+	}
+	return "Desktop notifications off"
+}
+
+// describeAlert splits a line that calls for attention into its kind,
+// "pm" or "mention", the sender when there is one and the message, made
+// safe to pass on.
+func describeAlert(line string) (kind, from, text string) {
+	text = strings.TrimRight(withoutTimestamp(line), "\n")
+	kind = "mention"
+	if rest, ok := strings.CutPrefix(text, "[PM from "); ok {
+		if sender, body, ok := strings.Cut(rest, "]: "); ok {
+			kind, from, text = "pm", sender, body
+		}
+	} else if isChatLine(text) {
+		from, text, _ = strings.Cut(text, ": ")
+	}
+	return kind, notifyText(from), notifyText(text)
+}
+
+// notifyTitle is the heading of a notification.
+func notifyTitle(kind, from string) string {
+	switch {
+	case kind == "pm":
+		return "Private message from " + from
+	case from != "":
+		return from + " mentioned you"
+	}
+	return "You were mentioned"
+}
+
+// notifyText drops colors and control characters from text, which
+// would end a terminal sequence early, and cuts it to maxNotifyText.
+func notifyText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, ansiSequence.ReplaceAllString(strings.ToValidUTF8(text, "?"), ""))
+	if runes := []rune(text); len(runes) > maxNotifyText {
+		text = string(runes[:maxNotifyText-1]) + "…"
+	}
+	return text
+}
+
+// runNotifyCommand runs command through the shell with env added to the
+// client's environment, giving up after execTimeout.
+func runNotifyCommand(command string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %v", execTimeout)
+		}
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("%w: %s", err, text)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeAlert(t *testing.T) {
+	t.Parallel()
+	for line, want := range map[string][3]string{
+		"[2024-05-01 10:00:00] [PM from bob]: lunch?\n": {"pm", "bob", "lunch?"},
+		"[2024-05-01 10:00:00] bob: hi alice\n":         {"mention", "bob", "hi alice"},
+		"bob: ring\a\x1b[31m alice":                     {"mention", "bob", "ring alice"},
+		"* bob waves at alice":                          {"mention", "", "* bob waves at alice"},
+	} {
+		kind, from, text := describeAlert(line)
+		if got := [3]string{kind, from, text}; got != want {
+			t.Errorf("describeAlert(%q) = %q, want %q", line, got, want)
+		}
+	}
+	if got := notifyText(strings.Repeat("a", 300)); len([]rune(got)) != maxNotifyText || !strings.HasSuffix(got, "…") {
+		t.Errorf("long text not cut: %q", got)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+	var term bytes.Buffer
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	ran := make(chan []string, 10)
+	n := newNotifier(notifyConfig{command: "notify-send", terminal: notifyTermOSC9}, &term)
+	n.now = func() time.Time { return now }
+	n.run = func(command string, env []string) error {
+		ran <- env
+		return nil
+	}
+
+	if !n.notify("[PM from bob]: lunch?\n") {
+		t.Fatal("no notification without focus reports")
+	}
+	if want := "\x1b]9;Private message from bob: lunch?\x1b\\"; term.String() != want {
+		t.Errorf("terminal got %q, want %q", term.String(), want)
+	}
+	env := <-ran
+	if want := []string{"TCPCHAT_NOTIFY_KIND=pm", "TCPCHAT_NOTIFY_FROM=bob", "TCPCHAT_NOTIFY_TITLE=Private message from bob", "TCPCHAT_NOTIFY_MESSAGE=lunch?"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("command environment = %q, want %q", env, want)
+	}
+
+	// A burst pops up once
+	now = now.Add(time.Second)
+	if n.notify("[PM from bob]: are you there?\n") {
+		t.Error("notified again within notifyQuiet")
+	}
+
+	// Nothing while the window has the focus
+	now = now.Add(notifyQuiet)
+	n.setFocus(true)
+	if n.notify("bob: alice?\n") {
+		t.Error("notified while focused")
+	}
+	n.setFocus(false)
+	if !n.notify("bob: alice?\n") {
+		t.Error("no notification after the focus was lost")
+	}
+
+	now = now.Add(notifyQuiet)
+	if got := n.toggle(""); got != "Desktop notifications off" || n.notify("bob: alice?\n") {
+		t.Errorf("toggle = %q, notifications still sent", got)
+	}
+	if got := n.toggle("loud"); got != "Usage: /notify [on|off]" {
+		t.Errorf("toggle(loud) = %q", got)
+	}
+
+	// Terminal sequences need a terminal
+	if off := newNotifier(notifyConfig{terminal: notifyTermOSC777}, nil); off.on.Load() || !strings.Contains(off.toggle("on"), "not set up") {
+		t.Error("terminal notifications without a terminal")
+	}
+}
+
+func TestScreenFocus(t *testing.T) {
+	t.Parallel()
+	s := newScreen(4, 80)
+	var reports []bool
+	s.focus = func(focused bool) { reports = append(reports, focused) }
+	s.key([]byte("\x1b[Oa\x1b"))
+	s.key([]byte("[I\x1bOA"))
+	if want := []bool{false, true}; !reflect.DeepEqual(reports, want) {
+		t.Errorf("focus reports = %v, want %v", reports, want)
+	}
+	if string(s.input) != "a" {
+		t.Errorf("input = %q, want %q", string(s.input), "a")
+	}
+}
+
+func TestRunNotifyCommand(t *testing.T) {
+	t.Parallel()
+	if err := runNotifyCommand(`test "$TCPCHAT_NOTIFY_FROM" = bob`, []string{"TCPCHAT_NOTIFY_FROM=bob"}); err != nil {
+		t.Errorf("runNotifyCommand: %v", err)
+	}
+	if err := runNotifyCommand("echo no display >&2; exit 1", nil); err == nil || !strings.Contains(err.Error(), "no display") {
+		t.Errorf("runNotifyCommand = %v, want the command's output", err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// pipe sends every line read from stdin as a chat message, at most one
+// per interval so the server's flood limit is not hit, and keeps the
+// connection up while it waits: pings are answered and a dropped
+// connection is dialed again. Answers of the server such as refusals go
+// to stderr.
+type pipe struct {
+	sess     *session
+	room     string // Room to post in, empty for the one the server picks
+	interval time.Duration
+	errOut   io.Writer
+	ready    chan struct{} // Closed at the first welcome
+}
+
+// run sends the lines of in while receive follows the server on conn. It
+// returns once in is used up, with a /quit, or the connection is lost for
+// good.
+func (p *pipe) run(conn io.Reader, in io.Reader) error {
+	p.ready = make(chan struct{})
+	lost := make(chan error, 1)
+	go func() { lost <- p.receive(bufio.NewReader(conn)) }()
+
+	// Lines sent before the welcome would be taken for the name
+	select {
+	case <-p.ready:
+	case err := <-lost:
+		return err
+	}
+
+	lines := make(chan string)
+	inputErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		inputErr <- scanner.Err()
+	}()
+
+	var last time.Time
+	for {
+		select {
+		case line := <-lines:
+			text := pipeMessage(line)
+			if text == "" {
+				continue
+			}
+			if wait := p.interval - time.Since(last); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case err := <-lost:
+					return err
+				}
+			}
+			// While the receiving side reconnects, the line waits
+			for {
+				last = time.Now()
+				if _, err := p.sess.Write([]byte(text + "\n")); err == nil {
+					break
+				}
+				select {
+				case <-time.After(max(p.interval, time.Second)):
+				case err := <-lost:
+					return err
+				}
+			}
+		case err := <-inputErr:
+			if err != nil {
+				return err
+			}
+			if err := p.sess.quit(""); err != nil {
+				return err
+			}
+			select {
+			case <-lost:
+			case <-time.After(quitTimeout):
+			}
+			return nil
+		case err := <-lost:
+			return err
+		}
+	}
+}
+
+// receive handles the lines of the server. A lost connection is dialed
+// again; receive only returns when that fails or is not allowed.
+func (p *pipe) receive(reader *bufio.Reader) error {
+	lines := newLineReader(reader, maxMessageSize)
+	beat := &heartbeat{}
+	for {
+		message, err := lines.readLine()
+		if err == errLineTooLong {
+			continue
+		}
+		if err != nil {
+			if p.sess.hasQuit() {
+				return nil
+			}
+			fmt.Fprintln(p.errOut, "Connection lost:", err)
+			if !p.sess.canReconnect() {
+				return err
+			}
+			r, err := p.sess.reconnect()
+			if err != nil {
+				return err
+			}
+			lines = newLineReader(r, maxMessageSize)
+			continue
+		}
+
+		id, message := splitIDTag(message)
+		if id != 0 {
+			p.sess.seen(id)
+		}
+		_, message = splitMentionTag(message)
+		if reply, ok := beat.handle(message); ok {
+			if reply != "" {
+				p.sess.Write([]byte(reply + "\n"))
+			}
+			continue
+		}
+		if _, ok := parseVersionReply(message); ok {
+			continue
+		}
+		// Every login ends with the welcome, after which the room is
+		// joined again
+		if _, ok := welcomedName(strings.TrimSpace(message)); ok {
+			p.sess.track(id, message)
+			if p.room != "" {
+				p.sess.Write([]byte("/join " + p.room + "\n"))
+			}
+			select {
+			case <-p.ready:
+			default:
+				close(p.ready)
+			}
+			continue
+		}
+		for _, line := range p.sess.track(id, message) {
+			if p.sess.userName() == "" || p.sess.hasQuit() {
+				continue // The logo, or the goodbye
+			}
+			for _, reply := range directReplies([]string{strings.TrimRight(line, "\n")}) {
+				if !strings.HasPrefix(reply, "Now talking in ") {
+					fmt.Fprintln(p.errOut, reply)
+				}
+			}
+		}
+	}
+}
+
+// pipeMessage turns a line of input into a chat message: control
+// characters are dropped, and the line is cut to the message size limit.
+func pipeMessage(line string) string {
+	line = strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(line, "?"))
+	line = strings.TrimSpace(line)
+	for len(line) > maxMessageSize {
+		_, size := utf8.DecodeLastRuneInString(line)
+		line = line[:len(line)-size]
+	}
+	return line
+}
+
+// startPipe runs the pipe mode of the client on sess, reading the server
+// from conn, until stdin ends and returns the exit status.
+func startPipe(sess *session, conn io.Reader, room string, interval time.Duration) int {
+	p := &pipe{sess: sess, room: room, interval: interval, errOut: os.Stderr}
+	if err := p.run(conn, os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, "Pipe stopped:", err)
+		return 1
+	}
+	return 0
+}
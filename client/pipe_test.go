@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeMessage(t *testing.T) {
+	t.Parallel()
+	for line, want := range map[string]string{
+		"  build ok ":                       "build ok",
+		"step\t1\x1b[31m done":              "step 1[31m done",
+		"\r":                                "",
+		strings.Repeat("é", maxMessageSize): strings.Repeat("é", maxMessageSize/2),
+	} {
+		if got := pipeMessage(line); got != want {
+			t.Errorf("pipeMessage(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestPipeRun(t *testing.T) {
+	t.Parallel()
+	client, server := net.Pipe()
+	defer client.Close()
+
+	var errOut strings.Builder
+	p := &pipe{sess: newSession(client, nil, false), room: "#builds", errOut: &errOut}
+	done := make(chan error, 1)
+	go func() { done <- p.run(client, strings.NewReader("build started\n\nbuild failed\n")) }()
+
+	// The messages wait for the welcome and the room
+	server.Write([]byte("Welcome to TCP-Chat!\n" + namePrompt + "Welcome, bot!\nPING 7\n"))
+	reader := bufio.NewReader(server)
+	var got []string
+	for len(got) < 5 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the client: %v", err)
+		}
+		got = append(got, strings.TrimSpace(line))
+		if len(got) == 3 {
+			server.Write([]byte("Slow down: at most 5 messages per 10 seconds, message dropped\n"))
+		}
+	}
+	server.Write([]byte("Goodbye!\n"))
+	server.Close()
+
+	// The PONG goes out from the receiving side, in no fixed order
+	if i := slices.Index(got, "PONG 7"); i < 0 {
+		t.Errorf("sent %q, want the PING answered", got)
+	} else {
+		got = slices.Delete(got, i, i+1)
+	}
+	want := []string{"/join #builds", "build started", "build failed", "/quit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("run did not return after the input ended")
+	}
+	if !strings.Contains(errOut.String(), "Slow down") || strings.Contains(errOut.String(), "Welcome") {
+		t.Errorf("stderr = %q, want only the server's answers", errOut.String())
+	}
+}
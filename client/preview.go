@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// previewKey is the byte a terminal in line mode inserts for Ctrl+P.
+const previewKey = "\x10"
+
+// composer holds the state of message composition at the prompt.
+type composer struct {
+	preview bool // Show each message rendered and ask before sending
+	color   bool
+}
+
+// review decides whether message should be sent, returning the text to
+// send. A line consisting of Ctrl+P (or /preview) toggles preview mode; a
+// message containing Ctrl+P is previewed once. Previewed messages are
+// shown through the same renderer as incoming ones and sent only after
+// confirmation on scanner.
+func (c *composer) review(message string, scanner *bufio.Scanner) (string, bool) {
+	if message == previewKey || message == "/preview" {
+		c.preview = !c.preview
+		state := "off"
+		if c.preview {
+			state = "on"
+		}
+		fmt.Printf("Message preview %s\n", state)
+		return "", false
+	}
+
+	once := strings.Contains(message, previewKey)
+	message = strings.TrimSpace(strings.ReplaceAll(message, previewKey, ""))
+	if message == "" || strings.HasPrefix(message, "/") || (!c.preview && !once) {
+		return message, message != ""
+	}
+
+	fmt.Printf("Preview: %s\n", renderText(message, c.color))
+	fmt.Print("Send? [Y/n] ")
+	if !scanner.Scan() {
+		return "", false
+	}
+	if answer := strings.ToLower(strings.TrimSpace(scanner.Text())); answer != "" && answer != "y" && answer != "yes" {
+		fmt.Println("Not sent")
+		return "", false
+	}
+	return message, true
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestComposerReview(t *testing.T) {
+	tests := []struct {
+		name     string
+		preview  bool
+		message  string
+		answer   string
+		want     string
+		wantSend bool
+	}{
+		{"Preview off sends", false, "hello", "", "hello", true},
+		{"Empty line", false, "   ", "", "", false},
+		{"Ctrl+P previews once", false, "hello" + previewKey, "y\n", "hello", true},
+		{"Preview declined", true, "hello", "n\n", "", false},
+		{"Preview accepted with Enter", true, "hello", "\n", "hello", true},
+		{"Commands skip preview", true, "/list", "", "/list", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &composer{preview: tt.preview}
+			got, send := c.review(tt.message, bufio.NewScanner(strings.NewReader(tt.answer)))
+			if got != tt.want || send != tt.wantSend {
+				t.Errorf("Expected (%q, %t), got (%q, %t)", tt.want, tt.wantSend, got, send)
+			}
+		})
+	}
+
+	t.Run("Toggle", func(t *testing.T) {
+		c := &composer{}
+		if _, send := c.review(previewKey, nil); send || !c.preview {
+			t.Error("Expected Ctrl+P alone to turn preview on without sending")
+		}
+		if _, send := c.review("/preview", nil); send || c.preview {
+			t.Error("Expected /preview to turn preview off without sending")
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ANSI sequences used when rendering to a terminal. The colors are the
+// defaults of the theme.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiItalic  = "\x1b[3m"
+	ansiCode    = "\x1b[36m"
+	ansiMention = "\x1b[1;33m"
+)
+
+// emojiShortcodes maps the supported :shortcodes: to emoji.
+var emojiShortcodes = map[string]string{
+	":smile:":    "😄",
+	":laughing:": "😆",
+	":wink:":     "😉",
+	":cry:":      "😢",
+	":heart:":    "❤️",
+	":+1:":       "👍",
+	":thumbsup:": "👍",
+	":-1:":       "👎",
+	":wave:":     "👋",
+	":tada:":     "🎉",
+	":fire:":     "🔥",
+	":rocket:":   "🚀",
+	":eyes:":     "👀",
+	":check:":    "✅",
+	":x:":        "❌",
+	":warning:":  "⚠️",
+	":coffee:":   "☕",
+}
+
+var (
+	codeSpanPattern = regexp.MustCompile("`[^`]+`")
+	boldPattern     = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicPattern   = regexp.MustCompile(`(^|\s)_([^_]+)_`)
+	mentionPattern  = regexp.MustCompile(`(^|\s)(@[\w.-]+)`)
+	emojiPattern    = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+	ansiSequence    = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+)
+
+// useColor reports whether output should carry ANSI formatting: stdout
+// must be a terminal and NO_COLOR unset.
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// renderText applies emoji shortcodes and, with color, Markdown emphasis,
+// inline code and @mention highlighting. Code spans are left verbatim.
+func renderText(text string, color bool) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range codeSpanPattern.FindAllStringIndex(text, -1) {
+		b.WriteString(renderPlain(text[last:span[0]], color))
+		code := text[span[0]:span[1]]
+		if color {
+			code = activeTheme.code + strings.Trim(code, "`") + ansiReset
+		}
+		b.WriteString(code)
+		last = span[1]
+	}
+	b.WriteString(renderPlain(text[last:], color))
+	return b.String()
+}
+
+// renderPlain renders text that contains no code spans.
+func renderPlain(text string, color bool) string {
+	text = emojiPattern.ReplaceAllStringFunc(text, func(code string) string {
+		if emoji, ok := emojiShortcodes[code]; ok {
+			return emoji
+		}
+		return code
+	})
+	if !color {
+		return text
+	}
+	text = boldPattern.ReplaceAllString(text, ansiBold+"$1"+ansiReset)
+	text = italicPattern.ReplaceAllString(text, "$1"+ansiItalic+"$2"+ansiReset)
+	return mentionPattern.ReplaceAllString(text, "$1"+activeTheme.mention+"$2"+ansiReset)
+}
+
+// mentionTag flags the chat lines that mention the user, once the
+// mentions capability is on: "@mention alice: lunch, @bob?".
+const mentionTag = "@mention "
+
+// splitMentionTag separates the mention flag from a line.
+func splitMentionTag(line string) (bool, string) {
+	rest, ok := strings.CutPrefix(line, mentionTag)
+	if !ok {
+		return false, line
+	}
+	return true, rest
+}
+
+// highlightMention marks a rendered line that mentions the user.
+func highlightMention(line string, color bool) string {
+	if !color {
+		return "» " + line
+	}
+	return activeTheme.mention + "»" + ansiReset + " " + line
+}
+
+// renderMessage renders a line received from the server for display,
+// keeping a leading "[timestamp] " untouched. Only timestamped lines are
+// chat, so replies such as "Usage: ..." are not colored as senders.
+func renderMessage(message string, color bool) string {
+	parts := strings.SplitN(message, "] ", 2)
+	if len(parts) == 2 && strings.HasPrefix(parts[0], "[") {
+		return parts[0] + "] " + renderBody(parts[1], color, true)
+	}
+	return renderBody(message, color, false)
+}
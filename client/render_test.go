@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestRenderText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		color bool
+		want  string
+	}{
+		{"Emoji without color", "ship it :rocket: :unknown:", false, "ship it 🚀 :unknown:"},
+		{"Markdown kept without color", "**bold** and `code`", false, "**bold** and `code`"},
+		{"Bold", "a **big** deal", true, "a " + ansiBold + "big" + ansiReset + " deal"},
+		{"Italic", "_quiet_ please", true, ansiItalic + "quiet" + ansiReset + " please"},
+		{"Snake case is not italic", "my_var_name", true, "my_var_name"},
+		{"Mention", "hi @bob", true, "hi " + ansiMention + "@bob" + ansiReset},
+		{"Email is not a mention", "mail a@b.c", true, "mail a@b.c"},
+		{"Code is verbatim", "run `**x** :fire:` now", true, "run " + ansiCode + "**x** :fire:" + ansiReset + " now"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderText(tt.text, tt.color); got != tt.want {
+				t.Errorf("renderText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMessage(t *testing.T) {
+	got := renderMessage("[2024-01-01 10:00:00][alice]: :wave: **hi**\n", true)
+	want := "[2024-01-01 10:00:00][alice]: 👋 " + ansiBold + "hi" + ansiReset + "\n"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := renderMessage("[PM from bob]: :tada:", false); got != "[PM from bob]: 🎉" {
+		t.Errorf("Expected bracketed prefixes to keep working, got %q", got)
+	}
+}
+
+func TestSplitMentionTag(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line          string
+		wantMentioned bool
+		want          string
+	}{
+		{"@mention alice: hi @bob\n", true, "alice: hi @bob\n"},
+		{"alice: @mention\n", false, "alice: @mention\n"},
+		{"@mentions are nice\n", false, "@mentions are nice\n"},
+	}
+	for _, tt := range tests {
+		mentioned, line := splitMentionTag(tt.line)
+		if mentioned != tt.wantMentioned || line != tt.want {
+			t.Errorf("splitMentionTag(%q) = %t, %q, want %t, %q", tt.line, mentioned, line, tt.wantMentioned, tt.want)
+		}
+	}
+	if got := highlightMention("alice: hi @bob\n", false); got != "» alice: hi @bob\n" {
+		t.Errorf("highlightMention() = %q", got)
+	}
+}
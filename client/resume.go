@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// idTag starts the lines of broadcast messages, "@id=42 alice: hello",
+// once the ids capability is on. The IDs increase server-wide.
+const idTag = "@id="
+
+// splitIDTag separates the message ID from a line. Lines without one
+// have ID 0.
+func splitIDTag(line string) (uint64, string) {
+	rest, ok := strings.CutPrefix(line, idTag)
+	if !ok {
+		return 0, line
+	}
+	number, message, ok := strings.Cut(rest, " ")
+	id, err := strconv.ParseUint(number, 10, 64)
+	if !ok || err != nil {
+		return 0, line
+	}
+	return id, message
+}
+
+// seen notes the ID of a message received, so a reconnect can resume
+// after the latest one.
+func (s *session) seen(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID = max(s.lastID, id)
+}
+
+// resumeToken returns the handshake token that resumes the session, or
+// "" before the server handed out a session token.
+func (s *session) resumeToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == "" {
+		return ""
+	}
+	return "RESUME=" + s.token + ":" + strconv.FormatUint(s.lastID, 10)
+}
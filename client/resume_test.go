@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestSplitIDTag(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line   string
+		wantID uint64
+		want   string
+	}{
+		{"@id=42 alice: hello\n", 42, "alice: hello\n"},
+		{"alice: hello\n", 0, "alice: hello\n"},
+		{"@id=x alice: hello", 0, "@id=x alice: hello"},
+		{"@id=7", 0, "@id=7"},
+	}
+	for _, tt := range tests {
+		id, line := splitIDTag(tt.line)
+		if id != tt.wantID || line != tt.want {
+			t.Errorf("splitIDTag(%q) = %d, %q, want %d, %q", tt.line, id, line, tt.wantID, tt.want)
+		}
+	}
+}
+
+func TestSessionResumeToken(t *testing.T) {
+	t.Parallel()
+	s := newSession(nil, nil, false)
+	s.seen(12)
+	if got := s.resumeToken(); got != "" {
+		t.Errorf("Expected no resume token before SESSION, got %q", got)
+	}
+	if shown := s.track(0, "SESSION 0123abcd\n"); len(shown) != 0 {
+		t.Errorf("Expected the session token to be hidden, got %q", shown)
+	}
+	s.seen(40)
+	s.seen(39)
+	if got, want := s.resumeToken(), "RESUME=0123abcd:40"; got != want {
+		t.Errorf("resumeToken() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exit codes of "client send".
+const (
+	exitDelivered   = 0 // The server accepted the message
+	exitRefused     = 1 // The server turned the login or the message down
+	exitUsage       = 2 // Invalid arguments or configuration
+	exitUnreachable = 3 // No connection, or it broke or timed out
+)
+
+// sendSyncToken starts the tokens of the PINGs "client send" follows every
+// line with. The server answers in order, so its replies to the line are
+// the ones before the PONG.
+const sendSyncToken = "send-"
+
+// refusal is the server turning down what "client send" asked for, with
+// the server's own words.
+type refusal struct{ reason string }
+
+func (r refusal) Error() string { return r.reason }
+
+// runSend implements "client send --to <room|user> [<address> <port>]
+// <message>": it logs in, delivers one message, leaves and returns the
+// exit code.
+func runSend(args []string) int {
+	flags := flag.NewFlagSet("client send", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	to := flags.String("to", "", "room (#general) or user to send the message to")
+	name := flags.String("name", "", "name to log in with, [server] name of the configuration by default")
+	guest := flags.Bool("guest", false, "log in as a guest with a generated name")
+	timeout := flags.Duration("timeout", 10*time.Second, "longest time to wait for the server")
+	configFile := flags.String("config", "", "client configuration file, ~/.tcpchat.toml by default")
+	profile := flags.String("profile", "", "connect with the named profile of the configuration instead of [server]")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfgPath, cfgRequired := configPath(*configFile)
+	cfg, err := loadClientConfig(cfgPath, cfgRequired)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		return exitUsage
+	}
+	if *profile != "" {
+		if cfg.server, err = cfg.profile(*profile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	if *name == "" {
+		*name = cfg.server.name
+	}
+	rest := flags.Args()
+	if len(rest) == 1 && cfg.server.address != "" {
+		rest = []string{cfg.server.address, strconv.Itoa(cfg.server.port), rest[0]}
+	}
+	if len(rest) != 3 || *to == "" || strings.ContainsAny(*to, " \t") || *timeout <= 0 || (*name == "" && !*guest) {
+		fmt.Fprintln(os.Stderr, "Usage: ./client send -to <#room|user> [-name name | -guest] [-profile name] [<server_address> <port>] <message>")
+		fmt.Fprintln(os.Stderr, "The address and port may be left out when the configuration names a server, and the")
+		fmt.Fprintln(os.Stderr, "name when it gives one. Exit codes: 0 sent, 1 refused by the server, 2 usage, 3 no connection.")
+		return exitUsage
+	}
+	text := strings.TrimSpace(rest[2])
+	if text == "" || strings.ContainsAny(text, "\r\n") || len(text) > maxMessageSize {
+		fmt.Fprintf(os.Stderr, "The message must be a single non-empty line of at most %d bytes\n", maxMessageSize)
+		return exitUsage
+	}
+
+	tlsConf, err := cfg.server.tlsConfig(rest[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid TLS settings:", err)
+		return exitUsage
+	}
+	conn, err := dialChat(rest[0], rest[1], familyAny, *timeout, tlsConf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to connect to server:", err)
+		return exitUnreachable
+	}
+	defer conn.Close()
+
+	err = sendOnce(conn, *name, *guest, *to, text, *timeout)
+	var refused refusal
+	switch {
+	case errors.As(err, &refused):
+		fmt.Fprintln(os.Stderr, "Not sent:", refused.reason)
+		return exitRefused
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "Not sent:", err)
+		return exitUnreachable
+	}
+	return exitDelivered
+}
+
+// sendOnce logs in over conn, delivers text to a room or a user and says
+// goodbye. The server turning something down is reported as a refusal.
+func sendOnce(conn net.Conn, name string, guest bool, to, text string, timeout time.Duration) error {
+	sess := newSession(conn, nil, guest)
+	sess.name = name
+	conn, reader, err := sess.login(conn)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := awaitWelcome(reader, guest); err != nil {
+		return err
+	}
+
+	sync := 0
+	exchange := func(line string) ([]string, error) {
+		sync++
+		token := sendSyncToken + strconv.Itoa(sync)
+		if _, err := fmt.Fprintf(conn, "%s\nPING %s\n", line, token); err != nil {
+			return nil, err
+		}
+		return readUntilPong(reader, token)
+	}
+
+	if strings.HasPrefix(to, "#") {
+		replies, err := exchange("/join " + to)
+		if err != nil {
+			return err
+		}
+		if !containsLine(replies, "Now talking in "+to) {
+			return refusedWith(replies)
+		}
+		if replies, err = exchange(text); err != nil {
+			return err
+		}
+		if len(directReplies(replies)) > 0 {
+			return refusedWith(replies)
+		}
+	} else {
+		replies, err := exchange("/msg " + to + " " + text)
+		if err != nil {
+			return err
+		}
+		if !containsPrefix(replies, "[PM to "+to+"]: ") {
+			return refusedWith(replies)
+		}
+	}
+
+	// The message is delivered; leaving politely is best effort
+	if _, err := io.WriteString(conn, "/quit\n"); err == nil {
+		io.Copy(io.Discard, reader)
+	}
+	return nil
+}
+
+// refusedWith turns the server's answers to a request it did not carry
+// out into a refusal.
+func refusedWith(replies []string) refusal {
+	direct := directReplies(replies)
+	if len(direct) == 0 {
+		return refusal{"no confirmation from the server"}
+	}
+	return refusal{strings.Join(direct, "; ")}
+}
+
+// awaitWelcome reads up to the server's welcome. Before it, a user gets
+// nothing but the reason a name is refused; a guest gets the logo first.
+func awaitWelcome(reader *bufio.Reader, guest bool) error {
+	var line strings.Builder
+	last := ""
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if last != "" {
+				return refusal{last}
+			}
+			return fmt.Errorf("waiting for the welcome: %w", err)
+		}
+		if b != '\n' {
+			line.WriteByte(b)
+			// A taken name is asked for again, without a newline
+			if strings.HasSuffix(line.String(), "type another name: ") {
+				return refusal{strings.TrimSpace(line.String())}
+			}
+			continue
+		}
+		text := strings.TrimSpace(line.String())
+		line.Reset()
+		if _, ok := welcomedName(text); ok {
+			return nil
+		}
+		if text == "" || isCapabilityLine(text) {
+			continue
+		}
+		if _, ok := parseVersionReply(text); ok {
+			continue
+		}
+		if !guest {
+			return refusal{text}
+		}
+		last = text
+	}
+}
+
+// readUntilPong returns the lines the server sends before the PONG to
+// token, without message tags.
+func readUntilPong(reader *bufio.Reader, token string) ([]string, error) {
+	lines := newLineReader(reader, maxMessageSize)
+	var replies []string
+	for {
+		line, err := lines.readLine()
+		if err == errLineTooLong {
+			continue
+		}
+		if err != nil {
+			return replies, err
+		}
+		_, line = splitIDTag(line)
+		_, line = splitMentionTag(line)
+		line = strings.TrimRight(line, "\r\n")
+		if line == "PONG "+token {
+			return replies, nil
+		}
+		replies = append(replies, line)
+	}
+}
+
+// directReplies leaves out of lines everything that is not an answer to
+// the client: chat lines, server notices, private messages from others,
+// heartbeats and protocol framing.
+func directReplies(lines []string) []string {
+	var direct []string
+	for _, line := range lines {
+		text := withoutTimestamp(line)
+		switch {
+		case strings.TrimSpace(text) == "",
+			isChatLine(text),
+			strings.HasPrefix(text, systemSender+": "),
+			strings.HasPrefix(text, "[PM from "),
+			strings.HasPrefix(text, "REPLAY "),
+			strings.HasPrefix(text, "SESSION "),
+			strings.HasPrefix(text, "PING "),
+			strings.HasPrefix(text, "PONG "),
+			isCapabilityLine(text):
+			continue
+		}
+		direct = append(direct, text)
+	}
+	return direct
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPrefix(lines []string, prefix string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(withoutTimestamp(line), prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSendServer answers a "client send" session on conn the way the chat
+// server would.
+func fakeSendServer(conn net.Conn, takenName string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if _, err := reader.ReadString('\n'); err != nil { // Handshake
+		return
+	}
+	conn.Write([]byte("Welcome to TCP-Chat!\n" + namePrompt))
+	name, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	name = strings.TrimSpace(name)
+	if name == takenName {
+		conn.Write([]byte("Name already taken. Press Enter to use " + name + "_1 or type another name: "))
+		return
+	}
+	conn.Write([]byte("Welcome, " + name + "!\n[2026-03-01 12:00:00] carol: earlier\n"))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		var reply string
+		switch {
+		case strings.HasPrefix(line, "PING "):
+			reply = "PONG " + strings.TrimPrefix(line, "PING ")
+		case line == "/join #dev":
+			reply = "Now talking in #dev\nREPLAY #dev 1\n[2026-03-01 12:00:00] dave: hi\nREPLAY END #dev"
+		case strings.HasPrefix(line, "/join "):
+			reply = "No such room"
+		case line == "/msg bob hello":
+			reply = "[PM to bob]: hello"
+		case strings.HasPrefix(line, "/msg "):
+			reply = "User " + strings.Fields(line)[1] + " not found"
+		case line == "/quit":
+			conn.Write([]byte("Goodbye!\n"))
+			return
+		case strings.Contains(line, "spam"):
+			reply = "You are muted and cannot send messages"
+		default:
+			reply = "SERVER: erin has joined #dev" // Not an answer to us
+		}
+		conn.Write([]byte(reply + "\n"))
+	}
+}
+
+func TestSendOnce(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		login   string
+		to      string
+		text    string
+		refusal string // Empty when the message goes through
+	}{
+		{"room", "alice", "#dev", "deploy done", ""},
+		{"user", "alice", "bob", "hello", ""},
+		{"unknown room", "alice", "#nope", "deploy done", "No such room"},
+		{"unknown user", "alice", "nobody", "hello", "User nobody not found"},
+		{"refused message", "alice", "#dev", "spam", "You are muted and cannot send messages"},
+		{"taken name", "taken", "#dev", "deploy done", "Name already taken. Press Enter to use taken_1 or type another name:"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			client, server := net.Pipe()
+			go fakeSendServer(server, "taken")
+			defer client.Close()
+
+			err := sendOnce(client, tt.login, false, tt.to, tt.text, 2*time.Second)
+			var refused refusal
+			switch {
+			case tt.refusal == "" && err != nil:
+				t.Errorf("sendOnce: %v", err)
+			case tt.refusal != "" && !errors.As(err, &refused):
+				t.Errorf("sendOnce = %v, want the refusal %q", err, tt.refusal)
+			case tt.refusal != "" && refused.reason != tt.refusal:
+				t.Errorf("refusal = %q, want %q", refused.reason, tt.refusal)
+			}
+		})
+	}
+}
+
+func TestDirectReplies(t *testing.T) {
+	t.Parallel()
+	lines := []string{"[2026-03-01 12:00:00] bob: hi", "SERVER: bob has joined #dev", "[PM from bob]: psst", "PING 5", "CAP ACK ids", "Slow down"}
+	if got := directReplies(lines); len(got) != 1 || got[0] != "Slow down" {
+		t.Errorf("directReplies = %q, want only the warning", got)
+	}
+}
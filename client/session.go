@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// namePrompt is how the server asks for a name after the logo.
+const namePrompt = "[ENTER YOUR NAME]: "
+
+// promptAnswer passes the server's output through and answers the first
+// name prompt in it. The server may drop a name sent before it asks.
+type promptAnswer struct {
+	r        io.Reader
+	w        io.Writer
+	answer   string
+	seen     string // End of the output so far, as long as the prompt
+	answered bool
+}
+
+func (p *promptAnswer) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 && !p.answered {
+		p.seen += string(b[:n])
+		if strings.Contains(p.seen, namePrompt) {
+			p.answered = true
+			if _, werr := io.WriteString(p.w, p.answer+"\n"); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		if len(p.seen) > len(namePrompt) {
+			p.seen = p.seen[len(p.seen)-len(namePrompt):]
+		}
+	}
+	return n, err
+}
+
+// farewells start the last lines of servers that dropped the client on
+// purpose. The client does not reconnect after them.
+var farewells = []string{"You have been kicked", "You have been banned", "You are banned", "Disconnected", "Too many failed attempts"}
+
+// session is the connection to the server. When it drops, the client
+// dials again and logs back in under the same name, resuming the session
+// so that only the missed messages are replayed; writes always go to the
+// current connection.
+type session struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	dial     func() (net.Conn, error)
+	guest    bool
+	compress string   // Compression offered in the handshake, empty for none
+	retry    *backoff // Spacing of the attempts to reconnect
+
+	// The fields below follow the lines from the server, see track
+	name        string         // Name the server welcomed us with, empty until then
+	farewell    bool           // The server said why it is dropping us
+	quitting    bool           // The user left with /quit
+	replaying   []replayedLine // History received since REPLAY, nil outside a replay
+	reconnected bool           // The next replay follows a reconnect and gets a banner
+	droppedAt   uint64         // Latest message ID received before the reconnect
+	missedPMs   []string       // Private messages replayed after the reconnect, shown with the banner
+	token       string         // Session token for resuming after a reconnect, see resume.go
+	lastID      uint64         // Latest message ID received
+}
+
+// replayedLine is a line of a history replay with its message ID, 0 when
+// the server sent none.
+type replayedLine struct {
+	id   uint64
+	text string
+}
+
+func newSession(conn net.Conn, dial func() (net.Conn, error), guest bool) *session {
+	return &session{conn: conn, dial: dial, guest: guest, retry: newBackoff(time.Second, time.Minute, 10)}
+}
+
+// Write sends p over the current connection.
+func (s *session) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	return conn.Write(p)
+}
+
+// current returns the current connection.
+func (s *session) current() net.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+// userName returns the name the server welcomed us with, empty before
+// login.
+func (s *session) userName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+// canReconnect reports whether a lost connection should be dialed again:
+// only after a successful login and when neither the server nor the user
+// ended the session on purpose.
+func (s *session) canReconnect() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name != "" && !s.farewell && !s.quitting
+}
+
+// quit sends /quit with an optional farewell message. The connection the
+// server then closes is not dialed again.
+func (s *session) quit(message string) error {
+	s.mu.Lock()
+	s.quitting = true
+	s.mu.Unlock()
+	line := "/quit"
+	if message != "" {
+		line += " " + message
+	}
+	_, err := s.Write([]byte(line + "\n"))
+	return err
+}
+
+// hasQuit reports whether the user left with /quit.
+func (s *session) hasQuit() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quitting
+}
+
+// reconnect dials the server again, backing off between attempts, logs
+// in under the same name and returns a reader of the new connection. The
+// history replay that follows is summarized by track.
+func (s *session) reconnect() (*bufio.Reader, error) {
+	s.current().Close()
+	var lastErr error
+	for attempt := 1; s.retry.more(attempt - 1); attempt++ {
+		delay := s.retry.delay(attempt)
+		fmt.Printf("Reconnecting in %v... (%s)\n", delay.Round(time.Millisecond), s.retry.progress(attempt))
+		if !s.retry.wait(delay) {
+			return nil, errors.New("shutting down")
+		}
+		conn, err := s.dial()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn, reader, err := s.login(conn)
+		if err != nil {
+			conn.Close()
+			lastErr = err
+			continue
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.reconnected = true
+		s.droppedAt = s.lastID
+		s.missedPMs = nil
+		s.mu.Unlock()
+		return reader, nil
+	}
+	return nil, lastErr
+}
+
+// login sends the handshake on conn and answers the name prompt with the
+// current name. It returns the connection to use from now on, which is
+// conn unless the server agreed to compress it.
+func (s *session) login(conn net.Conn) (net.Conn, *bufio.Reader, error) {
+	conn.SetDeadline(time.Now().Add(connectionTimeout))
+	defer conn.SetDeadline(time.Time{})
+	hello := handshakeLine(s.guest)
+	if resume := s.resumeToken(); resume != "" {
+		hello += " " + resume
+	}
+	if s.compress != "" {
+		hello += " " + compressToken(s.compress)
+	}
+	if _, err := conn.Write([]byte(hello + "\n")); err != nil {
+		return conn, nil, err
+	}
+	if s.compress != "" {
+		compressed, refusal, err := negotiateCompression(conn)
+		if err != nil {
+			return conn, nil, err
+		}
+		if refusal != "" {
+			return conn, nil, errors.New(strings.TrimSpace(refusal))
+		}
+		conn = compressed
+	}
+	reader := bufio.NewReader(conn)
+	if s.guest {
+		// Guests get a new generated name without a prompt
+		return conn, reader, nil
+	}
+	var seen strings.Builder
+	for !strings.HasSuffix(seen.String(), namePrompt) {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return conn, nil, fmt.Errorf("waiting for the name prompt: %w", err)
+		}
+		seen.WriteByte(b)
+		if b == '\n' {
+			line := seen.String()
+			if strings.HasPrefix(line, "Invalid protocol") || strings.Contains(line, "not accepting") || strings.Contains(line, "banned") {
+				return conn, nil, errors.New(strings.TrimSpace(line))
+			}
+			seen.Reset()
+		}
+	}
+	if _, err := conn.Write([]byte(s.name + "\n")); err != nil {
+		return conn, nil, err
+	}
+	return conn, reader, nil
+}
+
+// track follows the session state through a line from the server, which
+// carried the message ID id, and returns the lines to show in its place.
+// The REPLAY frames themselves are never shown, and a replay after a
+// reconnect is held back until it ends and then shown as a banner followed
+// by the missed messages only. The private messages that arrived in the
+// meantime are replayed first, as "REPLAY PM <n>", and join the banner of
+// the room.
+func (s *session) track(id uint64, line string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	text := strings.TrimRight(line, "\n")
+	if name, ok := welcomedName(text); ok {
+		s.name = name
+	}
+	if name, ok := strings.CutPrefix(text, "You are now known as "); ok {
+		s.name = name
+	}
+	if token, ok := strings.CutPrefix(text, "SESSION "); ok {
+		s.token = token
+		return nil
+	}
+	for _, farewell := range farewells {
+		if strings.HasPrefix(text, farewell) {
+			s.farewell = true
+		}
+	}
+
+	if _, _, ok := parseReplayStart(text); ok {
+		s.replaying = []replayedLine{}
+		return nil
+	}
+	if room, ok := strings.CutPrefix(text, "REPLAY END "); ok && s.replaying != nil {
+		replayed := s.replaying
+		s.replaying = nil
+		if !s.reconnected {
+			return nil
+		}
+		if room == pmReplay {
+			for _, msg := range replayed {
+				s.missedPMs = append(s.missedPMs, msg.text+"\n")
+			}
+			return nil
+		}
+		missed := missedLines(replayed, s.droppedAt, s.name)
+		shown := []string{reconnectBanner(room, len(missed), len(s.missedPMs)) + "\n"}
+		shown = append(shown, s.missedPMs...)
+		for _, msg := range missed {
+			shown = append(shown, msg+"\n")
+		}
+		s.reconnected, s.missedPMs = false, nil
+		return shown
+	}
+	if s.replaying != nil {
+		s.replaying = append(s.replaying, replayedLine{id: id, text: text})
+		if s.reconnected {
+			return nil
+		}
+	}
+	return []string{line}
+}
+
+// welcomedName reads the name from the server's "Welcome, <name>!" line,
+// which follows the name prompt on the same line.
+func welcomedName(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(line, namePrompt), "Welcome, ")
+	if !ok {
+		return "", false
+	}
+	name, _, ok := strings.Cut(rest, "!")
+	return name, ok && name != ""
+}
+
+// isChatLine reports whether line is a chat message, "<sender>: <text>",
+// rather than a notice.
+func isChatLine(line string) bool {
+	sender, _, ok := strings.Cut(line, ": ")
+	return ok && sender != "" && sender != systemSender && !strings.ContainsAny(sender, " []")
+}
+
+// pmReplay stands in for the room in the frames of the private messages
+// replayed when a session is resumed.
+const pmReplay = "PM"
+
+// parseReplayStart reads the "REPLAY <room> <n>" line that precedes a
+// history replay.
+func parseReplayStart(line string) (string, int, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != "REPLAY" || fields[1] == "END" {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return fields[1], n, true
+}
+
+// missedLines returns the replayed lines with a message ID above
+// droppedAt, the latest one received before the connection dropped. Lines
+// without an ID count as missed, as the server cannot tell either. The
+// user's own messages were not missed and are left out.
+func missedLines(replayed []replayedLine, droppedAt uint64, name string) []string {
+	missed := []string{}
+	for _, line := range replayed {
+		if line.id != 0 && line.id <= droppedAt {
+			continue
+		}
+		if sender, _, ok := strings.Cut(withoutTimestamp(line.text), ": "); ok && name != "" && sender == name {
+			continue
+		}
+		missed = append(missed, line.text)
+	}
+	return missed
+}
+
+// reconnectBanner summarizes a reconnect, "Reconnected — 37 messages
+// missed in #general, 2 PMs". Private messages are only mentioned when
+// there were any.
+func reconnectBanner(room string, missed, pms int) string {
+	var banner string
+	switch missed {
+	case 0:
+		banner = fmt.Sprintf("Reconnected — no messages missed in %s", room)
+	case 1:
+		banner = fmt.Sprintf("Reconnected — 1 message missed in %s", room)
+	default:
+		banner = fmt.Sprintf("Reconnected — %d messages missed in %s", missed, room)
+	}
+	switch {
+	case pms == 1:
+		banner += ", 1 PM"
+	case pms > 1:
+		banner += fmt.Sprintf(", %d PMs", pms)
+	}
+	return banner
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseReplayStart(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line     string
+		wantRoom string
+		wantN    int
+		wantOK   bool
+	}{
+		{"REPLAY #general 12", "#general", 12, true},
+		{"REPLAY #general 0\n", "#general", 0, true},
+		{"REPLAY END #general", "", 0, false},
+		{"REPLAY #general many", "", 0, false},
+		{"alice: REPLAY #general 3", "", 0, false},
+	}
+	for _, tt := range tests {
+		room, n, ok := parseReplayStart(tt.line)
+		if room != tt.wantRoom || n != tt.wantN || ok != tt.wantOK {
+			t.Errorf("parseReplayStart(%q) = %q, %d, %t, want %q, %d, %t", tt.line, room, n, ok, tt.wantRoom, tt.wantN, tt.wantOK)
+		}
+	}
+}
+
+func TestMissedLines(t *testing.T) {
+	t.Parallel()
+	replayed := []replayedLine{{7, "alice: hi"}, {8, "bob: hello"}, {9, "alice: hi"}, {10, "carol: hey"}}
+	tests := []struct {
+		name      string
+		droppedAt uint64
+		want      []string
+	}{
+		{"after the last ID", 8, []string{"carol: hey"}},
+		{"nothing missed", 10, []string{}},
+		{"no ID received", 0, []string{"bob: hello", "carol: hey"}},
+		{"repeated lines", 7, []string{"bob: hello", "carol: hey"}},
+	}
+	stamped := []replayedLine{{1, "[2026-03-01 12:30:00] alice: mine"}, {0, "[2026-03-01 12:31:00] bob: hello"}}
+	if got := missedLines(stamped, 0, "alice"); !reflect.DeepEqual(got, []string{stamped[1].text}) {
+		t.Errorf("Expected own lines to be left out and lines without an ID kept, got %q", got)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := missedLines(replayed, tt.droppedAt, "alice"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missedLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconnectBanner(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		missed, pms int
+		want        string
+	}{
+		{0, 0, "Reconnected — no messages missed in #general"},
+		{1, 0, "Reconnected — 1 message missed in #general"},
+		{37, 2, "Reconnected — 37 messages missed in #general, 2 PMs"},
+		{0, 1, "Reconnected — no messages missed in #general, 1 PM"},
+	}
+	for _, tt := range tests {
+		if got := reconnectBanner("#general", tt.missed, tt.pms); got != tt.want {
+			t.Errorf("reconnectBanner(%d, %d) = %q, want %q", tt.missed, tt.pms, got, tt.want)
+		}
+	}
+}
+
+func TestSessionTrack(t *testing.T) {
+	t.Parallel()
+	s := newSession(nil, nil, false)
+	feed := func(lines ...string) []string {
+		var shown []string
+		for _, line := range lines {
+			id, text := splitIDTag(line)
+			s.seen(id)
+			shown = append(shown, s.track(id, text+"\n")...)
+		}
+		return shown
+	}
+
+	// The first replay is shown without its frames
+	got := feed("[ENTER YOUR NAME]: Welcome, alice!", "REPLAY #general 2", "@id=1 bob: one", "@id=2 carol: two", "REPLAY END #general", "@id=3 bob: three")
+	want := []string{"[ENTER YOUR NAME]: Welcome, alice!\n", "bob: one\n", "carol: two\n", "bob: three\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("First session shown %q, want %q", got, want)
+	}
+	if !s.canReconnect() {
+		t.Fatal("Expected a logged in session to reconnect")
+	}
+
+	// After a reconnect only the banner, the held private messages and the
+	// missed messages are shown; a repeated line is still missed
+	s.reconnected, s.droppedAt = true, s.lastID
+	got = feed("Welcome, alice!", "REPLAY PM 2", "@id=5 [PM from dave]: ping", "@id=7 [PM from erin]: pong", "REPLAY END PM",
+		"REPLAY #general 5", "@id=2 carol: two", "@id=3 bob: three", "@id=4 alice: four", "@id=6 bob: three", "@id=8 bob: five", "REPLAY END #general")
+	want = []string{"Welcome, alice!\n", "Reconnected — 2 messages missed in #general, 2 PMs\n", "[PM from dave]: ping\n", "[PM from erin]: pong\n", "bob: three\n", "bob: five\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reconnected session shown %q, want %q", got, want)
+	}
+
+	feed("You have been kicked by an operator")
+	if s.canReconnect() {
+		t.Error("Expected no reconnect after a kick")
+	}
+}
+
+func TestSessionQuit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"", "/quit\n"},
+		{"see you tomorrow", "/quit see you tomorrow\n"},
+	}
+	for _, tt := range tests {
+		conn := newMockConn()
+		s := newSession(conn, nil, false)
+		s.track(0, "Welcome, alice!\n")
+		if !s.canReconnect() || s.hasQuit() {
+			t.Fatal("Expected a logged in session to reconnect")
+		}
+		if err := s.quit(tt.message); err != nil {
+			t.Fatalf("quit(%q): %v", tt.message, err)
+		}
+		if got := conn.writeBuffer.String(); got != tt.want {
+			t.Errorf("quit(%q) sent %q, want %q", tt.message, got, tt.want)
+		}
+		if s.canReconnect() || !s.hasQuit() {
+			t.Errorf("Expected no reconnect after quit(%q)", tt.message)
+		}
+	}
+}
+
+func TestPromptAnswer(t *testing.T) {
+	t.Parallel()
+	var sent strings.Builder
+	p := &promptAnswer{r: iotest.OneByteReader(strings.NewReader("logo\n" + namePrompt + "Welcome, alice!\n" + namePrompt)), w: &sent, answer: "alice"}
+	out, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if want := "logo\n" + namePrompt + "Welcome, alice!\n" + namePrompt; string(out) != want {
+		t.Errorf("read %q, want the output unchanged", out)
+	}
+	if sent.String() != "alice\n" {
+		t.Errorf("sent %q, want the name once", sent.String())
+	}
+}
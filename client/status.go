@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connection states shown in the status bar.
+const (
+	stateConnected    = "connected"
+	stateReconnecting = "reconnecting"
+	stateDisconnected = "disconnected"
+)
+
+// statusBar is what the full-screen interface shows about the session
+// between the message pane and the input line: the connection state, the
+// server, the name and the round-trip time of the last ping. The rest of
+// the client updates it; changed, when set, redraws the screen.
+type statusBar struct {
+	mu      sync.Mutex
+	state   string
+	server  string
+	name    string
+	latency time.Duration // 0 until a PONG arrived
+	changed func()
+}
+
+func newStatusBar(server string) *statusBar {
+	return &statusBar{state: stateConnected, server: server}
+}
+
+// update changes the status under its lock and redraws.
+func (b *statusBar) update(change func(b *statusBar)) {
+	b.mu.Lock()
+	before := b.parts()
+	change(b)
+	changed := b.changed
+	if slices.Equal(before, b.parts()) {
+		changed = nil
+	}
+	b.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+}
+
+func (b *statusBar) setState(state string) {
+	b.update(func(b *statusBar) { b.state = state })
+}
+
+func (b *statusBar) setName(name string) {
+	b.update(func(b *statusBar) { b.name = name })
+}
+
+func (b *statusBar) setLatency(latency time.Duration) {
+	b.update(func(b *statusBar) { b.latency = latency })
+}
+
+// line returns the pieces of the status line, such as "connected",
+// "chat.example.com:8989", "alice" and "23 ms".
+func (b *statusBar) line() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.parts()
+}
+
+// parts is line for callers holding mu.
+func (b *statusBar) parts() []string {
+	parts := []string{b.state, b.server}
+	if b.name != "" {
+		parts = append(parts, b.name)
+	}
+	if b.latency > 0 && b.state == stateConnected {
+		parts = append(parts, formatLatency(b.latency))
+	}
+	return parts
+}
+
+// formatLatency shows a round-trip time in milliseconds, or seconds when
+// it is long.
+func formatLatency(d time.Duration) string {
+	if d >= 10*time.Second {
+		return fmt.Sprintf("%d s", d/time.Second)
+	}
+	return fmt.Sprintf("%d ms", max(d.Milliseconds(), 1))
+}
+
+// statusText joins the parts of a status line.
+func statusText(parts []string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " │ ") + " "
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusBar(t *testing.T) {
+	t.Parallel()
+	redraws := 0
+	b := newStatusBar("chat.example.com:8989")
+	b.changed = func() { redraws++ }
+
+	b.setName("alice")
+	b.setLatency(23 * time.Millisecond)
+	if want := []string{"connected", "chat.example.com:8989", "alice", "23 ms"}; !reflect.DeepEqual(b.line(), want) {
+		t.Errorf("line = %q, want %q", b.line(), want)
+	}
+	b.setName("alice") // No change, no redraw
+	if redraws != 2 {
+		t.Errorf("redraws = %d, want 2", redraws)
+	}
+
+	// The latency of a dropped connection means nothing
+	b.setState(stateReconnecting)
+	if want := []string{"reconnecting", "chat.example.com:8989", "alice"}; !reflect.DeepEqual(b.line(), want) {
+		t.Errorf("line = %q, want %q", b.line(), want)
+	}
+}
+
+func TestScreenStatus(t *testing.T) {
+	s := newScreen(4, 80)
+	s.status = newStatusBar("localhost:8989")
+	s.status.setName("bob")
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(s, "message %d\n", i)
+	}
+	if frame := s.frame(); !strings.Contains(frame, " connected │ localhost:8989 │ bob ") {
+		t.Errorf("frame has no status: %q", frame)
+	}
+
+	s.key([]byte("\x1b[A"))
+	fmt.Fprint(s, "message 6\nmessage 7\n")
+	if frame := s.frame(); !strings.Contains(frame, "bob │ 3 more below, Page Down to return │ 2 unread") {
+		t.Errorf("frame has no unread count: %q", frame)
+	}
+	s.key([]byte("\x1b[6~\x1b[6~\x1b[6~")) // Page Down to the bottom
+	if frame := s.frame(); strings.Contains(frame, "unread") {
+		t.Errorf("unread count kept at the bottom: %q", frame)
+	}
+
+	// A narrow terminal cuts the status to its width
+	s.resize(4, 10)
+	if status := fmtStatus(statusText(s.status.line()), 10); status != " connected" {
+		t.Errorf("status = %q, want it cut to 10 columns", status)
+	}
+}
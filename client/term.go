@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// sigwinch is the window size change signal on Linux, macOS and the BSDs.
+// The syscall package has no name for it on Windows, where the full-screen
+// interface is not available anyway.
+const sigwinch = syscall.Signal(0x1c)
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stty runs stty on the terminal tty and returns its output.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// makeRaw switches tty to raw input: no line editing, echo, signal keys
+// or flow control, one byte at a time. It returns the previous settings
+// for restoreTerminal.
+func makeRaw(tty *os.File) (string, error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return "", fmt.Errorf("reading terminal settings: %w", err)
+	}
+	if _, err := stty(tty, "-icanon", "-echo", "-isig", "-iexten", "-ixon", "-icrnl", "min", "1", "time", "0"); err != nil {
+		return "", fmt.Errorf("setting raw mode: %w", err)
+	}
+	return saved, nil
+}
+
+// restoreTerminal puts back the settings makeRaw returned.
+func restoreTerminal(tty *os.File, saved string) error {
+	_, err := stty(tty, saved)
+	return err
+}
+
+// terminalSize returns the rows and columns of tty.
+func terminalSize(tty *os.File) (rows, cols int, err error) {
+	out, err := stty(tty, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil {
+		return 0, 0, fmt.Errorf("reading terminal size %q: %w", out, err)
+	}
+	return rows, cols, nil
+}
+
+// followWidth keeps track of the columns of tty as the terminal is
+// resized. width returns 0 while they are unknown; stop ends the tracking.
+func followWidth(tty *os.File) (width func() int, stop func()) {
+	var cols atomic.Int64
+	measure := func() {
+		if _, c, err := terminalSize(tty); err == nil {
+			cols.Store(int64(c))
+		}
+	}
+	measure()
+
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, sigwinch)
+	go func() {
+		for range resized {
+			measure()
+		}
+	}()
+	return func() int { return int(cols.Load()) }, func() {
+		signal.Stop(resized)
+		close(resized)
+	}
+}
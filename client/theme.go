@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// theme holds the colors of the rendered messages, as ANSI sequences.
+type theme struct {
+	pm      string   // Private message prefixes
+	system  string   // Server notices
+	mention string   // @mentions and the » mark
+	code    string   // Inline code
+	senders []string // Palette sender names are drawn from
+}
+
+func defaultTheme() theme {
+	return theme{pm: ansiPM, system: ansiSystem, mention: ansiMention, code: ansiCode, senders: senderColors}
+}
+
+// activeTheme is the theme messages are rendered with, set once at startup
+// from the [colors] section of the configuration.
+var activeTheme = defaultTheme()
+
+// colorCodes are the SGR parameters of the color and attribute names a
+// theme may use.
+var colorCodes = map[string]string{
+	"black": "30", "red": "31", "green": "32", "yellow": "33",
+	"blue": "34", "magenta": "35", "cyan": "36", "white": "37",
+	"gray": "90", "grey": "90",
+	"bright-red": "91", "bright-green": "92", "bright-yellow": "93",
+	"bright-blue": "94", "bright-magenta": "95", "bright-cyan": "96", "bright-white": "97",
+	"bold": "1", "dim": "2", "italic": "3", "underline": "4",
+}
+
+// parseColor turns a color description such as "bold magenta" or
+// "dim 208" into an ANSI sequence. Numbers pick from the 256-color
+// palette.
+func parseColor(spec string) (string, error) {
+	var codes []string
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		if code, ok := colorCodes[word]; ok {
+			codes = append(codes, code)
+			continue
+		}
+		n, err := strconv.Atoi(word)
+		if err != nil || n < 0 || n > 255 {
+			return "", fmt.Errorf("unknown color %q", word)
+		}
+		codes = append(codes, "38;5;"+word)
+	}
+	if len(codes) == 0 {
+		return "", errors.New("empty color")
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m", nil
+}
+
+// set changes the color called name, a key of the [colors] section.
+func (t *theme) set(name string, value any) error {
+	if name == "senders" {
+		var specs []string
+		if err := setValue(&specs, value); err != nil {
+			return err
+		}
+		if len(specs) == 0 {
+			return errors.New("needs at least one color")
+		}
+		palette := make([]string, len(specs))
+		for i, spec := range specs {
+			color, err := parseColor(spec)
+			if err != nil {
+				return err
+			}
+			palette[i] = color
+		}
+		t.senders = palette
+		return nil
+	}
+
+	var dst *string
+	switch name {
+	case "pm":
+		dst = &t.pm
+	case "system":
+		dst = &t.system
+	case "mention":
+		dst = &t.mention
+	case "code":
+		dst = &t.code
+	default:
+		return errors.New("unknown setting")
+	}
+	var spec string
+	if err := setValue(&spec, value); err != nil {
+		return err
+	}
+	color, err := parseColor(spec)
+	if err != nil {
+		return err
+	}
+	*dst = color
+	return nil
+}
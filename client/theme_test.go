@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseColor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		spec string
+		want string
+		ok   bool
+	}{
+		{"red", "\x1b[31m", true},
+		{"Bold Magenta", "\x1b[1;35m", true},
+		{"dim 208", "\x1b[2;38;5;208m", true},
+		{"bright-cyan", "\x1b[96m", true},
+		{"", "", false},
+		{"plaid", "", false},
+		{"256", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.spec)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseColor(%q) = %q, %v; want %q, ok %v", tt.spec, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestThemeSet(t *testing.T) {
+	t.Parallel()
+	th := defaultTheme()
+	if err := th.set("code", "green"); err != nil || th.code != "\x1b[32m" {
+		t.Errorf("set code: %v, %q", err, th.code)
+	}
+	if err := th.set("senders", []string{"red", "blue"}); err != nil || len(th.senders) != 2 || th.senders[1] != "\x1b[34m" {
+		t.Errorf("set senders: %v, %q", err, th.senders)
+	}
+	for name, value := range map[string]any{"senders": []string{}, "pm": 3, "code": "plaid", "bell": "red"} {
+		if err := th.set(name, value); err == nil {
+			t.Errorf("set(%q, %v) accepted", name, value)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampLayout is the format of the UTC time the server puts in front
+// of chat messages: "[2026-03-01 12:30:00] alice: hi".
+const timestampLayout = "2006-01-02 15:04:05"
+
+// splitTimestamp separates the server's timestamp from a line. Lines
+// without one have the zero time.
+func splitTimestamp(line string) (time.Time, string) {
+	rest, ok := strings.CutPrefix(line, "[")
+	if !ok || len(rest) < len(timestampLayout)+2 || rest[len(timestampLayout):len(timestampLayout)+2] != "] " {
+		return time.Time{}, line
+	}
+	at, err := time.Parse(timestampLayout, rest[:len(timestampLayout)])
+	if err != nil {
+		return time.Time{}, line
+	}
+	return at, rest[len(timestampLayout)+2:]
+}
+
+// withoutTimestamp returns line without the server's timestamp.
+func withoutTimestamp(line string) string {
+	_, rest := splitTimestamp(line)
+	return rest
+}
+
+// timeFormats are the ways of showing the server's timestamps, selected
+// with -time-format. relative has no layout.
+var timeFormats = map[string]string{
+	"24h":      "2006-01-02 15:04:05",
+	"12h":      "2006-01-02 3:04:05 PM",
+	"relative": "",
+}
+
+// clock shows the server's UTC timestamps in the user's timezone and
+// format.
+type clock struct {
+	format   string
+	location *time.Location
+	now      func() time.Time
+}
+
+// newClock returns a clock for a -time-format name and a -tz timezone,
+// "Local" for the system's.
+func newClock(format, zone string) (*clock, error) {
+	if _, ok := timeFormats[format]; !ok {
+		return nil, fmt.Errorf("unknown time format %q", format)
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, err
+	}
+	return &clock{format: format, location: location, now: time.Now}, nil
+}
+
+// restamp rewrites the timestamp in front of a line, if any.
+func (c *clock) restamp(line string) string {
+	at, rest := splitTimestamp(line)
+	if at.IsZero() {
+		return line
+	}
+	if c.format == "relative" {
+		return "[" + relativeTime(c.now().Sub(at)) + "] " + rest
+	}
+	return "[" + at.In(c.location).Format(timeFormats[c.format]) + "] " + rest
+}
+
+// relativeTime describes how long ago something happened in the largest
+// whole unit.
+func relativeTime(ago time.Duration) string {
+	switch {
+	case ago < time.Minute:
+		return "just now"
+	case ago < time.Hour:
+		return fmt.Sprintf("%dm ago", ago/time.Minute)
+	case ago < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", ago/time.Hour)
+	default:
+		return fmt.Sprintf("%dd ago", ago/(24*time.Hour))
+	}
+}
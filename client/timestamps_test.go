@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitTimestamp(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line     string
+		wantTime time.Time
+		want     string
+	}{
+		{"[2026-03-01 12:30:05] alice: hi\n", time.Date(2026, 3, 1, 12, 30, 5, 0, time.UTC), "alice: hi\n"},
+		{"[PM from bob]: hi", time.Time{}, "[PM from bob]: hi"},
+		{"[2026-03-01] alice: hi", time.Time{}, "[2026-03-01] alice: hi"},
+		{"alice: [2026-03-01 12:30:05] hi", time.Time{}, "alice: [2026-03-01 12:30:05] hi"},
+	}
+	for _, tt := range tests {
+		at, line := splitTimestamp(tt.line)
+		if !at.Equal(tt.wantTime) || line != tt.want {
+			t.Errorf("splitTimestamp(%q) = %v, %q, want %v, %q", tt.line, at, line, tt.wantTime, tt.want)
+		}
+	}
+}
+
+func TestClockRestamp(t *testing.T) {
+	t.Parallel()
+	line := "[2026-03-01 12:30:05] alice: hi\n"
+	now := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format string
+		zone   string
+		line   string
+		want   string
+	}{
+		{"24h", "UTC", line, "[2026-03-01 12:30:05] alice: hi\n"},
+		{"24h", "Asia/Tokyo", line, "[2026-03-01 21:30:05] alice: hi\n"},
+		{"12h", "America/New_York", line, "[2026-03-01 7:30:05 AM] alice: hi\n"},
+		{"relative", "UTC", line, "[2h ago] alice: hi\n"},
+		{"relative", "UTC", "[2026-03-01 14:59:30] alice: hi\n", "[just now] alice: hi\n"},
+		{"12h", "UTC", "[PM from bob]: hi\n", "[PM from bob]: hi\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.format+" "+tt.zone, func(t *testing.T) {
+			t.Parallel()
+			clk, err := newClock(tt.format, tt.zone)
+			if err != nil {
+				t.Skipf("Timezone unavailable: %v", err)
+			}
+			clk.now = func() time.Time { return now }
+			if got := clk.restamp(tt.line); got != tt.want {
+				t.Errorf("restamp(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+	if _, err := newClock("iso", "UTC"); err == nil {
+		t.Error("Expected an unknown format to be refused")
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	t.Parallel()
+	for ago, want := range map[time.Duration]string{
+		10 * time.Second: "just now",
+		5 * time.Minute:  "5m ago",
+		90 * time.Minute: "1h ago",
+		50 * time.Hour:   "2d ago",
+	} {
+		if got := relativeTime(ago); got != want {
+			t.Errorf("relativeTime(%v) = %q, want %q", ago, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transcriptLayout is the local time in front of every transcript line.
+const transcriptLayout = "2006-01-02 15:04:05"
+
+// transcript appends what the client displays to a file, one line at a
+// time with the local time in front and without colors, and the lines the
+// user enters after a "> ".
+type transcript struct {
+	mu      sync.Mutex
+	w       io.Writer
+	now     func() time.Time
+	partial string // Output after the last newline
+}
+
+func newTranscript(w io.Writer) *transcript {
+	return &transcript{w: w, now: time.Now}
+}
+
+// Write logs output as shown on screen. Complete lines are written out;
+// the rest waits for its newline, so a prompt and its answer share one
+// line.
+func (t *transcript) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	text := ansiSequence.ReplaceAllString(windowMarks.ReplaceAllString(t.partial+string(p), ""), "")
+	text = strings.NewReplacer("\r", "", "\a", "").Replace(text)
+	lines := strings.Split(text, "\n")
+	t.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		if err := t.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// entered logs a line the user entered, completing a pending prompt.
+func (t *transcript) entered(line string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.partial != "" {
+		line, t.partial = t.partial+line, ""
+		return t.writeLine(line)
+	}
+	return t.writeLine("> " + line)
+}
+
+// writeLine writes one stamped line. The caller must hold mu.
+func (t *transcript) writeLine(line string) error {
+	_, err := fmt.Fprintf(t.w, "[%s] %s\n", t.now().Format(transcriptLayout), line)
+	return err
+}
+
+// openTranscript opens the transcript file at path for appending.
+func openTranscript(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+}
+
+// startTranscript appends everything printed to stdout from now on to
+// file as well. The returned function flushes and closes it.
+func startTranscript(file *os.File) (*transcript, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	t := newTranscript(file)
+	out := os.Stdout
+	os.Stdout = w
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		io.Copy(io.MultiWriter(out, t), r)
+	}()
+
+	return t, func() {
+		os.Stdout = out
+		w.Close()
+		<-copied
+		t.mu.Lock()
+		if t.partial != "" {
+			t.writeLine(t.partial)
+		}
+		t.mu.Unlock()
+		file.Close()
+	}, nil
+}
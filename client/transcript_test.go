@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscript(t *testing.T) {
+	var out strings.Builder
+	tr := newTranscript(&out)
+	tr.now = func() time.Time { return time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local) }
+
+	fmt.Fprint(tr, "Connected to the server!\n[ENTER YOUR NAME]: ")
+	tr.entered("alice")
+	fmt.Fprint(tr, ansiBold+"bob"+ansiReset+": hi\a\r\nhalf a ")
+	fmt.Fprint(tr, "line\n")
+	tr.entered("hello")
+
+	want := "[2024-05-01 09:30:00] Connected to the server!\n" +
+		"[2024-05-01 09:30:00] [ENTER YOUR NAME]: alice\n" +
+		"[2024-05-01 09:30:00] bob: hi\n" +
+		"[2024-05-01 09:30:00] half a line\n" +
+		"[2024-05-01 09:30:00] > hello\n"
+	if got := out.String(); got != want {
+		t.Errorf("transcript =\n%s\nwant\n%s", got, want)
+	}
+}
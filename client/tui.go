@@ -0,0 +1,501 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	maxScrollback = 1000 // Lines the message pane keeps
+	inputPrompt   = "> "
+
+	// clearScreen clears a terminal and its scrollback. Printed while the
+	// full-screen interface runs, it empties the message pane.
+	clearScreen = "\x1b[H\x1b[2J\x1b[3J"
+)
+
+// Keys the full-screen interface reads from a raw terminal.
+const (
+	keyCtrlA     = 0x01
+	keyCtrlC     = 0x03
+	keyCtrlD     = 0x04
+	keyCtrlE     = 0x05
+	keyCtrlU     = 0x15
+	keyCtrlW     = 0x17
+	keyTab       = 0x09
+	keyBackspace = 0x7f
+	keyEscape    = 0x1b
+)
+
+// screen is the full-screen interface: a scrollable message pane above a
+// status line and the input line. Incoming messages go to the pane, so
+// they no longer run into what the user is typing. The pane shows one of
+// the windows, one for every room and private conversation. Output and
+// keys are fed in by startTUI; screen itself only keeps the state and
+// draws it.
+type screen struct {
+	mu      sync.Mutex
+	*window           // The one shown
+	windows []*window // In the order they opened
+	route   *window   // Where the rest of the output line goes, nil for the shown one
+	room    string    // Current room, as last marked
+	rows    int
+	cols    int
+	input   []rune
+	cursor  int    // Position in input
+	pending []byte // Start of a key split across reads
+	output  string // Start of a window mark split across writes
+	bell    bool   // Output rang the bell since the last frame
+	status  *statusBar
+
+	// complete completes the input at the cursor on Tab, see
+	// completer.complete
+	complete func(input []rune, cursor int) ([]rune, int, []string)
+	// focus is told when the terminal window gains or loses the focus
+	focus func(focused bool)
+}
+
+func newScreen(rows, cols int) *screen {
+	first := &window{}
+	return &screen{window: first, windows: []*window{first}, rows: rows, cols: cols}
+}
+
+// Write appends output to the message pane, as printed to stdout in line
+// mode. Carriage returns are dropped, bells are rung once with the next
+// frame instead of on every redraw, clearScreen empties the window shown
+// and windowMark sends a line to another.
+func (s *screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appendOutput(string(p))
+	return len(p), nil
+}
+
+func (s *screen) appendOutput(text string) {
+	text = s.output + strings.ReplaceAll(text, "\r", "")
+	s.output = ""
+	if i := strings.LastIndex(text, clearScreen); i >= 0 {
+		s.lines, s.partial, s.scroll, s.unread = nil, "", 0, 0
+		text = text[i+len(clearScreen):]
+	}
+	if strings.Contains(text, "\a") {
+		s.bell = true
+		text = strings.ReplaceAll(text, "\a", "")
+	}
+	for text != "" {
+		i := strings.Index(text, windowMarkStart)
+		if i < 0 {
+			// A mark may be cut off at the end
+			for n := min(len(windowMarkStart)-1, len(text)); n > 0; n-- {
+				if strings.HasSuffix(text, windowMarkStart[:n]) {
+					s.output = text[len(text)-n:]
+					text = text[:len(text)-n]
+					break
+				}
+			}
+			s.addOutput(text)
+			return
+		}
+		s.addOutput(text[:i])
+		end := strings.Index(text[i:], "\x1b\\")
+		if end < 0 {
+			s.output = text[i:]
+			return
+		}
+		s.mark(text[i+len(windowMarkStart) : i+end])
+		text = text[i+end+2:]
+	}
+}
+
+// addOutput adds text without marks to the windows: up to the end of a
+// marked line to the window marked, the rest to the one shown.
+func (s *screen) addOutput(text string) {
+	if s.route != nil {
+		line, rest, ended := strings.Cut(text, "\n")
+		if !ended {
+			s.route.add(text, s.cols, s.route == s.window)
+			return
+		}
+		s.route.add(line+"\n", s.cols, s.route == s.window)
+		s.route, text = nil, rest
+	}
+	if text != "" {
+		s.window.add(text, s.cols, true)
+	}
+}
+
+// resize records a new terminal size.
+func (s *screen) resize(rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows, s.cols = rows, cols
+}
+
+// paneRows is the height of the message pane.
+func (s *screen) paneRows() int {
+	return max(s.rows-2, 1)
+}
+
+// wrapped returns the pane content as screen rows.
+func (s *screen) wrapped() []string {
+	var rows []string
+	for _, line := range s.lines {
+		rows = append(rows, wrapLine(line, s.cols)...)
+	}
+	if s.partial != "" {
+		rows = append(rows, wrapLine(s.partial, s.cols)...)
+	}
+	return rows
+}
+
+// key handles the bytes of one read from the terminal. It returns the
+// lines the user entered and whether they asked to quit.
+func (s *screen) key(b []byte) (entered []string, quit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending != nil {
+		b = append(s.pending, b...)
+		s.pending = nil
+	}
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == keyEscape:
+			n, complete := s.escapeSequence(b, &entered)
+			if !complete {
+				s.pending = append([]byte(nil), b...)
+				return entered, false
+			}
+			b = b[n:]
+			continue
+		case c == '\r' || c == '\n':
+			line := string(s.input)
+			s.input, s.cursor, s.scroll, s.unread = nil, 0, 0, 0
+			s.appendOutput(line + "\n") // Echoed as the terminal does in line mode
+			entered = append(entered, s.outgoing(line))
+		case c == keyCtrlC:
+			return entered, true
+		case c == keyCtrlD:
+			if len(s.input) == 0 {
+				return entered, true
+			}
+		case c == keyCtrlA:
+			s.cursor = 0
+		case c == keyCtrlE:
+			s.cursor = len(s.input)
+		case c == keyCtrlU:
+			s.input, s.cursor = s.input[s.cursor:], 0
+		case c == keyCtrlW:
+			start := s.cursor
+			for start > 0 && s.input[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && s.input[start-1] != ' ' {
+				start--
+			}
+			s.input = append(s.input[:start], s.input[s.cursor:]...)
+			s.cursor = start
+		case c == keyTab:
+			if s.complete == nil {
+				break
+			}
+			var matches []string
+			s.input, s.cursor, matches = s.complete(s.input, s.cursor)
+			if len(matches) > 0 {
+				s.appendOutput(strings.Join(matches, "  ") + "\n")
+			}
+		case c == keyBackspace || c == '\b':
+			if s.cursor > 0 {
+				s.input = append(s.input[:s.cursor-1], s.input[s.cursor:]...)
+				s.cursor--
+			}
+		case c == previewKey[0] || c >= ' ':
+			r, size := utf8.DecodeRune(b)
+			if r == utf8.RuneError && size == 1 && !utf8.FullRune(b) {
+				s.pending = append([]byte(nil), b...)
+				return entered, false
+			}
+			s.input = append(s.input[:s.cursor], append([]rune{r}, s.input[s.cursor:]...)...)
+			s.cursor++
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return entered, false
+}
+
+// escapeSequence handles the escape sequence at the start of b and
+// returns its length, or false when b ends before the sequence does.
+// Arrows move the cursor or scroll a line, Page Up and Page Down scroll a
+// page and focus reports are passed on. Alt+1 to Alt+9 and Alt+Left and
+// Alt+Right switch windows, Alt+A goes to the next with unread lines and
+// Alt+W closes the one shown; a room switched to is joined with a line
+// added to entered. Other sequences are ignored.
+func (s *screen) escapeSequence(b []byte, entered *[]string) (int, bool) {
+	if len(b) < 2 {
+		return 0, false
+	}
+	switchTo := func(i int) {
+		if join := s.selectWindow(i); join != "" {
+			*entered = append(*entered, join)
+		}
+	}
+	if b[1] != '[' && b[1] != 'O' {
+		// Alt+key
+		switch c := b[1]; {
+		case c >= '1' && c <= '9':
+			switchTo(int(c - '1'))
+		case c == 'a':
+			switchTo(s.nextUnread())
+		case c == 'w':
+			s.closeWindow()
+		}
+		return 2, true
+	}
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+	if end == len(b) {
+		return 0, false
+	}
+	seq := string(b[2 : end+1])
+	page := s.paneRows() - 1
+	switch seq {
+	case "A":
+		s.scrollBy(1)
+	case "B":
+		s.scrollBy(-1)
+	case "C":
+		s.cursor = min(s.cursor+1, len(s.input))
+	case "D":
+		s.cursor = max(s.cursor-1, 0)
+	case "H", "1~":
+		s.cursor = 0
+	case "F", "4~":
+		s.cursor = len(s.input)
+	case "3~":
+		if s.cursor < len(s.input) {
+			s.input = append(s.input[:s.cursor], s.input[s.cursor+1:]...)
+		}
+	case "5~":
+		s.scrollBy(max(page, 1))
+	case "6~":
+		s.scrollBy(-max(page, 1))
+	case "1;3D":
+		switchTo((s.shownIndex() + len(s.windows) - 1) % len(s.windows))
+	case "1;3C":
+		switchTo((s.shownIndex() + 1) % len(s.windows))
+	case "I", "O":
+		if b[1] == '[' && s.focus != nil {
+			s.focus(seq == "I")
+		}
+	}
+	return end + 1, true
+}
+
+// scrollBy moves the pane n rows back in time, or forward for negative n.
+func (s *screen) scrollBy(n int) {
+	limit := max(len(s.wrapped())-s.paneRows(), 0)
+	s.scroll = min(max(s.scroll+n, 0), limit)
+	if s.scroll == 0 {
+		s.unread = 0
+	}
+}
+
+// frame draws the whole screen: the visible part of the pane, the status
+// line and the input line, leaving the cursor in the input.
+func (s *screen) frame() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := s.wrapped()
+	pane := s.paneRows()
+	end := max(len(rows)-s.scroll, 0)
+	start := max(end-pane, 0)
+	visible := rows[start:end]
+
+	var b strings.Builder
+	if s.bell {
+		b.WriteString("\a")
+		s.bell = false
+	}
+	b.WriteString("\x1b[H")
+	for i := 0; i < pane; i++ {
+		b.WriteString("\x1b[2K")
+		if i < len(visible) {
+			b.WriteString(visible[i] + ansiReset)
+		}
+		b.WriteString("\r\n")
+	}
+	var parts []string
+	if s.status != nil {
+		parts = s.status.line()
+	}
+	if len(s.windows) > 1 {
+		parts = append(parts, s.windowList())
+	}
+	if s.scroll > 0 {
+		parts = append(parts, fmt.Sprintf("%d more below, Page Down to return", s.scroll))
+	}
+	if s.unread > 0 {
+		parts = append(parts, fmt.Sprintf("%d unread", s.unread))
+	}
+	b.WriteString("\x1b[2K" + fmtStatus(statusText(parts), s.cols) + "\r\n")
+
+	// The input scrolls sideways to keep the cursor on screen, moving
+	// by whole characters, which may be two columns wide
+	shown := []rune(displayInput(s.input))
+	cursor := stringWidth(displayInput(s.input[:s.cursor]))
+	width := max(s.cols-len(inputPrompt)-1, 1)
+	offset := 0
+	for cursor-offset > width && len(shown) > 0 {
+		offset += runeWidth(shown[0])
+		shown = shown[1:]
+	}
+	b.WriteString("\x1b[2K" + inputPrompt + cutWidth(string(shown), width+1))
+	fmt.Fprintf(&b, "\x1b[%d;%dH", pane+2, len(inputPrompt)+cursor-offset+1)
+	return b.String()
+}
+
+// fmtStatus centers text in a status line of the given width, cutting
+// what does not fit.
+func fmtStatus(text string, width int) string {
+	pad := width - stringWidth(text)
+	if pad <= 0 {
+		return cutWidth(text, max(width, 0))
+	}
+	return strings.Repeat("─", pad/2) + text + strings.Repeat("─", pad-pad/2)
+}
+
+// displayInput shows control characters in the input, such as Ctrl+P, in
+// caret notation.
+func displayInput(input []rune) string {
+	var b strings.Builder
+	for _, r := range input {
+		if r < ' ' {
+			b.WriteString("^" + string(r+'@'))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// startTUI takes over the terminal with the full-screen interface. Until
+// the returned function restores it, whatever the client prints goes to
+// the message pane and os.Stdin delivers the lines typed at the input
+// line, so the rest of the client works unchanged. Tab completes with
+// comp, status is shown above the input line and notify learns whether
+// the window has the focus.
+func startTUI(comp *completer, status *statusBar, notify *notifier) (func(), error) {
+	tty, out := os.Stdin, os.Stdout
+	rows, cols, err := terminalSize(tty)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := makeRaw(tty)
+	if err != nil {
+		return nil, err
+	}
+
+	scr := newScreen(rows, cols)
+	scr.complete = comp.complete
+	scr.status = status
+	scr.focus = notify.setFocus
+	var drawMu sync.Mutex
+	draw := func() {
+		drawMu.Lock()
+		defer drawMu.Unlock()
+		io.WriteString(out, scr.frame())
+	}
+
+	outputR, outputW, err := os.Pipe()
+	if err != nil {
+		restoreTerminal(tty, saved)
+		return nil, err
+	}
+	inputR, inputW, err := os.Pipe()
+	if err != nil {
+		outputR.Close()
+		outputW.Close()
+		restoreTerminal(tty, saved)
+		return nil, err
+	}
+
+	// Alternate screen, so the shell's scrollback is left as it was, and
+	// focus reports for notifications
+	io.WriteString(out, "\x1b[?1049h\x1b[2J\x1b[?1004h")
+	os.Stdout, os.Stdin = outputW, inputR
+	status.mu.Lock()
+	status.changed = draw
+	status.mu.Unlock()
+	draw()
+
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 4096)
+		for {
+			n, err := outputR.Read(buf)
+			if n > 0 {
+				scr.Write(buf[:n])
+				draw()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer inputW.Close()
+		buf := make([]byte, 256)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				return
+			}
+			lines, quit := scr.key(buf[:n])
+			for _, line := range lines {
+				if _, err := io.WriteString(inputW, line+"\n"); err != nil {
+					return
+				}
+			}
+			if quit {
+				return
+			}
+			draw()
+		}
+	}()
+
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, sigwinch)
+	go func() {
+		for range resized {
+			if rows, cols, err := terminalSize(tty); err == nil {
+				scr.resize(rows, cols)
+				draw()
+			}
+		}
+	}()
+
+	return func() {
+		status.mu.Lock()
+		status.changed = nil
+		status.mu.Unlock()
+		signal.Stop(resized)
+		close(resized)
+		os.Stdout, os.Stdin = out, tty
+		outputW.Close()
+		<-outputDone
+		io.WriteString(out, "\x1b[?1004l\x1b[?1049l")
+		restoreTerminal(tty, saved)
+	}, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScreenOutput(t *testing.T) {
+	s := newScreen(5, 20)
+	fmt.Fprint(s, "first\r\nsecond\n[ENTER YOUR NAME]: ")
+	if want := []string{"first", "second"}; !reflect.DeepEqual(s.lines, want) {
+		t.Fatalf("lines = %q, want %q", s.lines, want)
+	}
+	if s.partial != "[ENTER YOUR NAME]: " {
+		t.Fatalf("partial = %q", s.partial)
+	}
+
+	// The entered line completes the prompt, as echoed in line mode
+	entered, quit := s.key([]byte("alice\r"))
+	if quit || !reflect.DeepEqual(entered, []string{"alice"}) {
+		t.Fatalf("key = %q, %v", entered, quit)
+	}
+	if last := s.lines[len(s.lines)-1]; last != "[ENTER YOUR NAME]: alice" || s.partial != "" {
+		t.Errorf("last line = %q, partial %q", last, s.partial)
+	}
+
+	for i := 0; i < maxScrollback+10; i++ {
+		fmt.Fprintf(s, "line %d\n", i)
+	}
+	if len(s.lines) != maxScrollback || s.lines[0] != "line 10" {
+		t.Errorf("scrollback holds %d lines from %q", len(s.lines), s.lines[0])
+	}
+}
+
+func TestScreenBell(t *testing.T) {
+	s := newScreen(5, 20)
+	fmt.Fprint(s, "\abob: hi alice\n")
+	if s.lines[0] != "bob: hi alice" {
+		t.Errorf("bell kept in the pane: %q", s.lines[0])
+	}
+	if frame := s.frame(); !strings.HasPrefix(frame, "\a") {
+		t.Errorf("frame does not ring the bell: %q", frame)
+	}
+	if frame := s.frame(); strings.Contains(frame, "\a") {
+		t.Errorf("bell rung again on redraw: %q", frame)
+	}
+}
+
+func TestScreenClear(t *testing.T) {
+	s := newScreen(5, 20)
+	fmt.Fprint(s, "one\ntwo\nthree\nfour\nfive\n")
+	s.key([]byte("\x1b[A"))
+	fmt.Fprint(s, "six\n"+clearScreen+"seven\n[ENTER")
+	if !reflect.DeepEqual(s.lines, []string{"seven"}) || s.partial != "[ENTER" || s.scroll != 0 {
+		t.Errorf("after clearing: lines %q, partial %q, scroll %d", s.lines, s.partial, s.scroll)
+	}
+	if frame := s.frame(); strings.Contains(frame, "one") || strings.Contains(frame, "six") {
+		t.Errorf("cleared lines still shown: %q", frame)
+	}
+}
+
+func TestScreenKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string // Separate reads
+		input   string
+		cursor  int
+		entered []string
+		quit    bool
+	}{
+		{"typing", []string{"hi there"}, "hi there", 8, nil, false},
+		{"backspace", []string{"hey\x7f\x7f"}, "h", 1, nil, false},
+		{"cursor movement", []string{"ac\x1b[Db"}, "abc", 2, nil, false},
+		{"home and end", []string{"bc\x01a\x05d"}, "abcd", 4, nil, false},
+		{"delete", []string{"abc\x01\x1b[3~"}, "bc", 0, nil, false},
+		{"kill line", []string{"abc def\x15"}, "", 0, nil, false},
+		{"kill word", []string{"abc def  \x17"}, "abc ", 4, nil, false},
+		{"enter", []string{"one\rtwo\r"}, "", 0, []string{"one", "two"}, false},
+		{"ctrl+p kept for preview", []string{"\x10hi"}, "\x10hi", 3, nil, false},
+		{"utf-8 split across reads", []string{"\xc3", "\xa9"}, "é", 1, nil, false},
+		{"escape split across reads", []string{"ab\x1b[", "D"}, "ab", 1, nil, false},
+		{"ctrl+c quits", []string{"abc\x03"}, "abc", 3, nil, true},
+		{"ctrl+d quits on empty input", []string{"\x04"}, "", 0, nil, true},
+		{"ctrl+d ignored otherwise", []string{"a\x04"}, "a", 1, nil, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newScreen(10, 40)
+			var entered []string
+			var quit bool
+			for _, k := range tt.keys {
+				lines, q := s.key([]byte(k))
+				entered = append(entered, lines...)
+				quit = q
+			}
+			if string(s.input) != tt.input || s.cursor != tt.cursor {
+				t.Errorf("input = %q at %d, want %q at %d", string(s.input), s.cursor, tt.input, tt.cursor)
+			}
+			if !reflect.DeepEqual(entered, tt.entered) || quit != tt.quit {
+				t.Errorf("entered %q, quit %v; want %q, %v", entered, quit, tt.entered, tt.quit)
+			}
+		})
+	}
+}
+
+func TestScreenTab(t *testing.T) {
+	c := newCompleter()
+	c.learn("Connected users: albert, alfred, bob")
+	s := newScreen(10, 40)
+	s.complete = c.complete
+
+	s.key([]byte("hi b\t"))
+	if string(s.input) != "hi bob " {
+		t.Errorf("input = %q after Tab", string(s.input))
+	}
+	s.key([]byte("and al\t"))
+	if string(s.input) != "hi bob and al" || s.lines[len(s.lines)-1] != "albert  alfred" {
+		t.Errorf("input = %q, matches shown as %q", string(s.input), s.lines)
+	}
+
+	// Without a completer Tab does nothing
+	s = newScreen(10, 40)
+	s.key([]byte("a\tb"))
+	if string(s.input) != "ab" {
+		t.Errorf("input = %q", string(s.input))
+	}
+}
+
+func TestScreenScroll(t *testing.T) {
+	s := newScreen(5, 20) // Three rows of messages
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(s, "message %d\n", i)
+	}
+	frame := s.frame()
+	if !strings.Contains(frame, "message 10") || strings.Contains(frame, "message 7") {
+		t.Errorf("bottom frame shows the wrong messages: %q", frame)
+	}
+
+	s.key([]byte("\x1b[5~")) // Page Up
+	frame = s.frame()
+	if !strings.Contains(frame, "message 6") || strings.Contains(frame, "message 10") {
+		t.Errorf("scrolled frame shows the wrong messages: %q", frame)
+	}
+	if !strings.Contains(frame, "2 more below") {
+		t.Errorf("scrolled frame has no status: %q", frame)
+	}
+
+	// New messages keep the scrolled view in place
+	fmt.Fprint(s, "message 11\n")
+	if frame = s.frame(); !strings.Contains(frame, "message 6") || !strings.Contains(frame, "3 more below") {
+		t.Errorf("view moved on new output: %q", frame)
+	}
+
+	s.key([]byte("\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A"))
+	if s.scroll != 8 {
+		t.Errorf("scroll = %d, want it to stop at the oldest message (8)", s.scroll)
+	}
+
+	// Entering a line returns to the bottom
+	s.key([]byte("hi\r"))
+	if frame = s.frame(); s.scroll != 0 || !strings.Contains(frame, "hi") {
+		t.Errorf("scroll = %d after enter, frame %q", s.scroll, frame)
+	}
+}
+
+func TestScreenInputLine(t *testing.T) {
+	s := newScreen(4, 12)
+	s.key([]byte("abcdefghijklmnop"))
+	frame := s.frame()
+	// Nine columns after the prompt: the cursor stays on screen
+	if !strings.Contains(frame, inputPrompt+"hijklmnop") {
+		t.Errorf("input line not scrolled to the cursor: %q", frame)
+	}
+	if !strings.HasSuffix(frame, "\x1b[4;12H") {
+		t.Errorf("cursor not placed at the end of the input: %q", frame)
+	}
+
+	s = newScreen(4, 40)
+	s.key([]byte("\x10hi"))
+	if frame := s.frame(); !strings.Contains(frame, inputPrompt+"^Phi") || !strings.HasSuffix(frame, "\x1b[4;7H") {
+		t.Errorf("control character not shown in caret notation: %q", frame)
+	}
+}
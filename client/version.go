@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+)
+
+// protocolVersions lists the protocol versions this client speaks, oldest
+// first. They are offered in the handshake and the server picks one.
+var protocolVersions = []string{"1.0"}
+
+// clientCapabilities are requested in the handshake. The replay framing
+// lets the client tell history from live messages after a reconnect,
+// message IDs and session tokens let it resume where it left off, the
+// server's pings are answered by the heartbeat and mentions of the user
+// are highlighted.
+var clientCapabilities = []string{"replay", "ping", "ids", "resume", "mentions"}
+
+// handshakeLine builds the first line sent to the server.
+func handshakeLine(guest bool) string {
+	line := "CHAT/1.0"
+	if guest {
+		line += " GUEST"
+	}
+	return line + " VERSIONS=" + strings.Join(protocolVersions, ",") + " CAPS=" + strings.Join(clientCapabilities, ",")
+}
+
+// isCapabilityLine reports whether line is one of the server's CAP
+// replies, which list or confirm optional protocol features. They are
+// not shown.
+func isCapabilityLine(line string) bool {
+	return strings.HasPrefix(line, "CAP ")
+}
+
+// parseVersionReply reads the server's answer to the version offer, such
+// as "CHAT/1.0 SUPPORTED 1.0,1.1", and returns the version of the session.
+func parseVersionReply(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[1] != "SUPPORTED" {
+		return "", false
+	}
+	return strings.CutPrefix(fields[0], "CHAT/")
+}
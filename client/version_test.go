@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestHandshakeLine(t *testing.T) {
+	t.Parallel()
+	if got := handshakeLine(false); got != "CHAT/1.0 VERSIONS=1.0 CAPS=replay,ping,ids,resume,mentions" {
+		t.Errorf("Unexpected handshake %q", got)
+	}
+	if got := handshakeLine(true); got != "CHAT/1.0 GUEST VERSIONS=1.0 CAPS=replay,ping,ids,resume,mentions" {
+		t.Errorf("Unexpected guest handshake %q", got)
+	}
+}
+
+func TestIsCapabilityLine(t *testing.T) {
+	t.Parallel()
+	for line, want := range map[string]bool{
+		"CAP LS typing\n":   true,
+		"CAP ACK typing":    true,
+		"alice: CAP LS":     false,
+		"CAPITALS are loud": false,
+	} {
+		if got := isCapabilityLine(line); got != want {
+			t.Errorf("isCapabilityLine(%q) = %t, want %t", line, got, want)
+		}
+	}
+}
+
+func TestParseVersionReply(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line   string
+		want   string
+		wantOK bool
+	}{
+		{"CHAT/1.0 SUPPORTED 1.0\n", "1.0", true},
+		{"CHAT/1.1 SUPPORTED 1.0,1.1", "1.1", true},
+		{"Welcome to TCP-Chat!", "", false},
+		{"SERVER: CHAT/1.0 SUPPORTED 1.0", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseVersionReply(tt.line)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseVersionReply(%q) = %q, %t, want %q, %t", tt.line, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
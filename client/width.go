@@ -0,0 +1,166 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges are the characters a terminal shows two columns wide: East
+// Asian wide and fullwidth characters and emoji, sorted.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f}, {0x231a, 0x231b}, {0x2329, 0x232a}, {0x23e9, 0x23ec},
+	{0x23f0, 0x23f0}, {0x23f3, 0x23f3}, {0x25fd, 0x25fe}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267f, 0x267f}, {0x2693, 0x2693}, {0x26a1, 0x26a1},
+	{0x26aa, 0x26ab}, {0x26bd, 0x26be}, {0x26c4, 0x26c5}, {0x26ce, 0x26ce},
+	{0x26d4, 0x26d4}, {0x26ea, 0x26ea}, {0x26f2, 0x26f3}, {0x26f5, 0x26f5},
+	{0x26fa, 0x26fa}, {0x26fd, 0x26fd}, {0x2705, 0x2705}, {0x270a, 0x270b},
+	{0x2728, 0x2728}, {0x274c, 0x274c}, {0x274e, 0x274e}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27b0, 0x27b0}, {0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c}, {0x2b50, 0x2b50}, {0x2b55, 0x2b55}, {0x2e80, 0x303e},
+	{0x3041, 0x33ff}, {0x3400, 0x4dbf}, {0x4e00, 0x9fff}, {0xa000, 0xa4cf},
+	{0xa960, 0xa97f}, {0xac00, 0xd7a3}, {0xf900, 0xfaff}, {0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x16fe0, 0x16fe4},
+	{0x17000, 0x18aff}, {0x1b000, 0x1b2ff}, {0x1f004, 0x1f004}, {0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e}, {0x1f191, 0x1f19a}, {0x1f200, 0x1f251}, {0x1f300, 0x1f64f},
+	{0x1f680, 0x1f6ff}, {0x1f7e0, 0x1f7eb}, {0x1f90c, 0x1f9ff}, {0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd}, {0x30000, 0x3fffd},
+}
+
+const zeroWidthJoiner = '\u200d'
+
+// runeWidth returns the columns r takes in a terminal: none for control
+// characters, combining marks and emoji skin tones, which join the
+// character before them, two for wide characters and one otherwise.
+func runeWidth(r rune) int {
+	switch {
+	case r < ' ' || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r < 0x1100:
+		if unicode.In(r, unicode.Mn, unicode.Me) {
+			return 0
+		}
+		return 1
+	case (r >= 0x1160 && r <= 0x11ff) || (r >= 0x1f3fb && r <= 0x1f3ff):
+		return 0 // Hangul vowels and finals, skin tones
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0 // Combining marks, variation selectors, joiners
+	}
+	_, wide := slices.BinarySearchFunc(wideRanges, r, func(span [2]rune, r rune) int {
+		switch {
+		case span[1] < r:
+			return -1
+		case span[0] > r:
+			return 1
+		}
+		return 0
+	})
+	if wide {
+		return 2
+	}
+	return 1
+}
+
+// stringWidth returns the columns text takes in a terminal. ANSI
+// sequences take none, and an emoji joined to the one before it with a
+// zero width joiner shares its columns.
+func stringWidth(text string) int {
+	width := 0
+	var prev rune
+	for i := 0; i < len(text); {
+		if loc := ansiSequence.FindStringIndex(text[i:]); loc != nil && loc[0] == 0 {
+			i += loc[1]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if prev != zeroWidthJoiner {
+			width += runeWidth(r)
+		}
+		prev = r
+		i += size
+	}
+	return width
+}
+
+// cutWidth returns the longest start of text that fits in width columns.
+func cutWidth(text string, width int) string {
+	used := 0
+	for i, r := range text {
+		used += runeWidth(r)
+		if used > width {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// wrapLine breaks line into rows of at most width columns, between words
+// where it can and inside a word only when it is longer than a row. The
+// space a row ends at is dropped. ANSI sequences take no room, and the
+// colors in effect at a break are set again at the start of the next row,
+// which the screen otherwise draws reset. An empty line is one empty row.
+func wrapLine(line string, width int) []string {
+	if width < 1 {
+		return []string{line}
+	}
+	var rows []string
+	start, count := 0, 0 // Of the row being filled
+	prefix, active := "", ""
+	space, spaceActive := -1, "" // Last space of the row and the colors there
+	var prev rune
+	breakRow := func(end, next int, nextActive string) {
+		rows = append(rows, prefix+line[start:end])
+		prefix, start, count, space = nextActive, next, 0, -1
+	}
+	for i := 0; i < len(line); {
+		if loc := ansiSequence.FindStringIndex(line[i:]); loc != nil && loc[0] == 0 {
+			seq := line[i : i+loc[1]]
+			if seq == ansiReset || seq == "\x1b[m" {
+				active = ""
+			} else if strings.HasSuffix(seq, "m") {
+				active += seq
+			}
+			i += loc[1]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		w := runeWidth(r)
+		if prev == zeroWidthJoiner {
+			w = 0
+		}
+		prev = r
+		if count+w > width && count > 0 {
+			switch {
+			case r == ' ':
+				breakRow(i, i+size, active)
+				i += size
+				continue
+			case space > start:
+				breakRow(space, space+1, spaceActive)
+				count = stringWidth(line[start:i])
+			default:
+				breakRow(i, i, active)
+			}
+		}
+		if r == ' ' {
+			space, spaceActive = i, active
+		}
+		count += w
+		i += size
+	}
+	if start == len(line) && len(rows) > 0 {
+		return rows // The line ended in the space of a break
+	}
+	return append(rows, prefix+line[start:])
+}
+
+// wrapText wraps every line of text to width columns, keeping the line
+// ends it has.
+func wrapText(text string, width int) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(wrapLine(line, width), "\n")
+	}
+	return strings.Join(lines, "\n")
+}
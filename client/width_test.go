@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStringWidth(t *testing.T) {
+	t.Parallel()
+	for text, want := range map[string]int{
+		"hello":                     5,
+		"héllo":                     5,
+		"é":                        1, // e and a combining acute accent
+		"日本語":                       6,
+		"ｈｉ":                        4, // Fullwidth
+		"안녕":                        4,
+		"👍":                         2,
+		"👍🏽":                        2, // With a skin tone
+		"👩‍💻":                       2, // Joined into one emoji
+		ansiBold + "日本" + ansiReset: 4,
+		"a\tb":                      2,
+	} {
+		if got := stringWidth(text); got != want {
+			t.Errorf("stringWidth(%q) = %d, want %d", text, got, want)
+		}
+	}
+	if got := cutWidth("日本語", 5); got != "日本" {
+		t.Errorf("cutWidth = %q, want %q", got, "日本")
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		width int
+		want  []string
+	}{
+		{"short", "hello", 10, []string{"hello"}},
+		{"empty", "", 10, []string{""}},
+		{"exact", "abcd", 4, []string{"abcd"}},
+		{"long", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"multibyte", "ééééé", 2, []string{"éé", "éé", "é"}},
+		{"ansi takes no room", ansiBold + "abcd" + ansiReset + "ef", 4, []string{ansiBold + "abcd" + ansiReset, "ef"}},
+		{"between words", "the quick brown fox", 10, []string{"the quick", "brown fox"}},
+		{"space at the edge", "abcd efgh", 4, []string{"abcd", "efgh"}},
+		{"word longer than a row", "a verylongword b", 6, []string{"a", "verylo", "ngword", "b"}},
+		{"wide characters", "日本語のテキスト", 7, []string{"日本語", "のテキ", "スト"}},
+		{"wide character not split", "ab日本", 3, []string{"ab", "日", "本"}},
+		{"emoji", "ok 👍👍 done", 6, []string{"ok", "👍👍", "done"}},
+		{"combining mark stays", "cafés", 4, []string{"café", "s"}},
+		{"colors carried over", ansiBold + "bold text" + ansiReset + " plain", 5, []string{ansiBold + "bold", ansiBold + "text" + ansiReset, "plain"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := wrapLine(tt.line, tt.width)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapLine(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+			}
+			for _, row := range got {
+				if w := stringWidth(row); w > tt.width {
+					t.Errorf("row %q is %d columns wide", row, w)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	t.Parallel()
+	if got, want := wrapText("bob: see you all tomorrow\nok\n", 12), "bob: see you\nall tomorrow\nok\n"; got != want {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+}
+
+func TestScreenWideInput(t *testing.T) {
+	t.Parallel()
+	s := newScreen(4, 12)
+	s.key([]byte("日本語のテキスト"))
+	frame := s.frame()
+	// The first 4 characters, 8 columns, scroll out so that the cursor
+	// after all 16 fits in the 9 columns the input has
+	if !strings.HasSuffix(frame, "\x1b[4;11H") || !strings.Contains(frame, inputPrompt+"テキスト\x1b") {
+		t.Errorf("frame = %q", frame)
+	}
+}
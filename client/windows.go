@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// lobbyRoom is the room the server puts every client in at login.
+const lobbyRoom = "#general"
+
+// windowMarkStart begins the sequence that sends output to a window of
+// the full-screen interface, see windowMark.
+const windowMarkStart = "\x1b]tcpchat;"
+
+// windowMarks matches the marks, which the transcript leaves out.
+var windowMarks = regexp.MustCompile(`\x1b\]tcpchat;[^\x1b]*\x1b\\`)
+
+// windowMark, printed while the full-screen interface runs, sends the
+// rest of the line after it to the window of a room or private
+// conversation instead of the one shown. With show the window is brought
+// up as well, as when the user joins a room.
+func windowMark(name string, show bool) string {
+	kind := "window"
+	if show {
+		kind = "show"
+	}
+	return windowMarkStart + kind + ";" + name + "\x1b\\"
+}
+
+// markWindow puts windowMark in front of every line of text.
+func markWindow(name string, show bool, text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = windowMark(name, show) + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// conversationOf returns the window a line from the server belongs to:
+// the other user of a private message, room for what is said in the
+// current room, or nothing for replies to the user, which stay in the
+// window shown.
+func conversationOf(line, room string) string {
+	text := strings.TrimRight(withoutTimestamp(line), "\n")
+	for _, prefix := range []string{"[PM from ", "[PM to "} {
+		if rest, ok := strings.CutPrefix(text, prefix); ok {
+			// Digests of watched rooms come as private messages
+			if peer, _, ok := strings.Cut(rest, "]: "); ok && !strings.HasPrefix(peer, "#") {
+				return peer
+			}
+			return ""
+		}
+	}
+	// The welcome names the first window after the room of the login
+	if _, welcomed := welcomedName(text); welcomed {
+		return room
+	}
+	if strings.HasPrefix(text, "Now talking in ") || strings.HasPrefix(text, "* ") ||
+		strings.HasPrefix(text, systemSender+": ") || isChatLine(text) {
+		return room
+	}
+	return ""
+}
+
+// window is the scrollback of one room or private conversation in the
+// full-screen interface.
+type window struct {
+	name    string   // Room or user, empty for the first window until a room is known
+	lines   []string // Complete lines, oldest first
+	partial string   // Output after the last newline, such as a prompt
+	scroll  int      // Rows scrolled back from the bottom
+	unread  int      // Lines that arrived while scrolled back or hidden
+}
+
+// add appends text to the window, which is at cols columns and shown
+// or not.
+func (w *window) add(text string, cols int, shown bool) {
+	parts := strings.Split(w.partial+text, "\n")
+	w.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		w.lines = append(w.lines, line)
+		if w.scroll > 0 {
+			w.scroll += len(wrapLine(line, cols))
+		}
+		if w.scroll > 0 || !shown {
+			w.unread++
+		}
+	}
+	if extra := len(w.lines) - maxScrollback; extra > 0 {
+		w.lines = append(w.lines[:0:0], w.lines[extra:]...)
+	}
+}
+
+// isRoom reports whether the window is that of a room.
+func (w *window) isRoom() bool {
+	return strings.HasPrefix(w.name, "#")
+}
+
+// windowNamed returns the window called name, opening it when there is
+// none. The first room named takes over the first window.
+func (s *screen) windowNamed(name string) *window {
+	for _, w := range s.windows {
+		if w.name == name {
+			return w
+		}
+	}
+	if first := s.windows[0]; first.name == "" && strings.HasPrefix(name, "#") {
+		first.name = name
+		return first
+	}
+	w := &window{name: name}
+	s.windows = append(s.windows, w)
+	return w
+}
+
+// mark handles the window mark spec, "window;<name>" or "show;<name>".
+func (s *screen) mark(spec string) {
+	kind, name, _ := strings.Cut(spec, ";")
+	if name == "" {
+		return
+	}
+	w := s.windowNamed(name)
+	s.route = w
+	if w.isRoom() {
+		s.room = name
+	}
+	if kind == "show" {
+		s.showWindow(w)
+	}
+}
+
+// showWindow brings up w. Its unread lines are seen, unless it was left
+// scrolled back.
+func (s *screen) showWindow(w *window) {
+	s.window = w
+	if w.scroll == 0 {
+		w.unread = 0
+	}
+}
+
+// selectWindow brings up the window at index i and returns the line to
+// send for it: a room other than the current one is joined.
+func (s *screen) selectWindow(i int) string {
+	if i < 0 || i >= len(s.windows) {
+		return ""
+	}
+	w := s.windows[i]
+	s.showWindow(w)
+	if w.isRoom() && w.name != s.room {
+		return "/join " + w.name
+	}
+	return ""
+}
+
+// shownIndex returns the position of the shown window.
+func (s *screen) shownIndex() int {
+	for i, w := range s.windows {
+		if w == s.window {
+			return i
+		}
+	}
+	return 0
+}
+
+// nextUnread returns the index of the first window after the shown one
+// with unread lines, or -1.
+func (s *screen) nextUnread() int {
+	shown := s.shownIndex()
+	for n := 1; n < len(s.windows); n++ {
+		if i := (shown + n) % len(s.windows); s.windows[i].unread > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// closeWindow closes the shown window and brings up the one before it.
+// The last window stays; a room or user heard from again opens anew.
+func (s *screen) closeWindow() {
+	if len(s.windows) == 1 {
+		return
+	}
+	i := s.shownIndex()
+	s.windows = append(s.windows[:i:i], s.windows[i+1:]...)
+	if s.route == s.window {
+		s.route = nil
+	}
+	s.showWindow(s.windows[max(i-1, 0)])
+}
+
+// outgoing turns a line entered in the shown window into what is sent:
+// text typed in a private conversation goes to that user.
+func (s *screen) outgoing(line string) string {
+	w := s.window
+	if w.name == "" || w.isRoom() || strings.TrimSpace(line) == "" || strings.HasPrefix(line, "/") {
+		return line
+	}
+	return "/msg " + w.name + " " + line
+}
+
+// windowList shows the windows in the status line, numbered for Alt+1 to
+// Alt+9, with the shown one in brackets and unread counts for the rest.
+func (s *screen) windowList() string {
+	var items []string
+	for i, w := range s.windows {
+		name := w.name
+		if name == "" {
+			name = "chat"
+		}
+		item := fmt.Sprintf("%d:%s", i+1, name)
+		switch {
+		case w == s.window:
+			item = "[" + item + "]"
+		case w.unread > 0:
+			item += fmt.Sprintf("(%d)", w.unread)
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, " ")
+}
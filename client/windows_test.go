@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConversationOf(t *testing.T) {
+	t.Parallel()
+	for line, want := range map[string]string{
+		"[2024-05-01 10:00:00] bob: hi\n":         "#random",
+		"[2024-05-01 10:00:00] * bob waves\n":     "#random",
+		"SERVER: bob has joined #random\n":        "#random",
+		"Now talking in #random\n":                "#random",
+		"[PM from bob]: lunch?\n":                 "bob",
+		"[PM to carol]: sure\n":                   "carol",
+		"[PM from #dev]: 3 new messages, latest:": "",
+		"User dave not found\n":                   "",
+		"Connected users: alice, bob\n":           "",
+	} {
+		if got := conversationOf(line, "#random"); got != want {
+			t.Errorf("conversationOf(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestScreenWindows(t *testing.T) {
+	s := newScreen(6, 80)
+	fmt.Fprint(s, "Welcome, alice!\n")
+	fmt.Fprint(s, markWindow("#general", false, "bob: hi\n"))
+	if len(s.windows) != 1 || s.window.name != "#general" || len(s.lines) != 2 {
+		t.Fatalf("first room not taken over by the first window: %d windows, %q %q", len(s.windows), s.window.name, s.lines)
+	}
+
+	// A private conversation opens a window in the background, even with
+	// the mark cut off between writes
+	out := markWindow("bob", false, "[PM from bob]: lunch?\n")
+	fmt.Fprint(s, out[:5])
+	fmt.Fprint(s, out[5:20])
+	fmt.Fprint(s, out[20:]+"not marked\n")
+	if len(s.windows) != 2 || s.windows[1].name != "bob" || !reflect.DeepEqual(s.windows[1].lines, []string{"[PM from bob]: lunch?"}) {
+		t.Fatalf("windows = %+v", s.windows[1])
+	}
+	if s.lines[len(s.lines)-1] != "not marked" || s.windows[1].unread != 1 {
+		t.Errorf("unmarked output not in the shown window, or no unread count: %q, %d", s.lines, s.windows[1].unread)
+	}
+	if frame := s.frame(); !strings.Contains(frame, "[1:#general] 2:bob(1)") {
+		t.Errorf("frame has no window list: %q", frame)
+	}
+
+	// Alt+2 brings it up; what is typed there goes to bob
+	if entered, _ := s.key([]byte("\x1b2sure\r/list\r")); !reflect.DeepEqual(entered, []string{"/msg bob sure", "/list"}) {
+		t.Errorf("entered in bob's window = %q", entered)
+	}
+	if s.window.name != "bob" || s.window.unread != 0 {
+		t.Errorf("shown %q with %d unread", s.window.name, s.window.unread)
+	}
+
+	// Joining a room brings its window up; switching back joins again
+	fmt.Fprint(s, markWindow("#random", true, "Now talking in #random\n"))
+	if s.window.name != "#random" || s.room != "#random" {
+		t.Errorf("shown %q in %q after joining", s.window.name, s.room)
+	}
+	if entered, _ := s.key([]byte("\x1b1")); !reflect.DeepEqual(entered, []string{"/join #general"}) {
+		t.Errorf("switching rooms sent %q", entered)
+	}
+	if entered, _ := s.key([]byte("\x1b[1;3D")); entered != nil || s.window.name != "#random" {
+		t.Errorf("Alt+Left went to %q, sent %q", s.window.name, entered)
+	}
+
+	// Alt+A finds unread lines, Alt+W closes
+	fmt.Fprint(s, markWindow("carol", false, "[PM from carol]: hey\n"))
+	s.key([]byte("\x1ba"))
+	if s.window.name != "carol" {
+		t.Errorf("Alt+A went to %q", s.window.name)
+	}
+	s.key([]byte("\x1bw"))
+	if s.window.name != "#random" || len(s.windows) != 3 {
+		t.Errorf("after closing: shown %q, %d windows", s.window.name, len(s.windows))
+	}
+}
+
+func TestTranscriptSkipsWindowMarks(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	tr := newTranscript(&b)
+	fmt.Fprint(tr, markWindow("bob", false, "[PM from bob]: lunch?\n"))
+	if strings.Contains(b.String(), "tcpchat") || !strings.HasSuffix(b.String(), "] [PM from bob]: lunch?\n") {
+		t.Errorf("transcript = %q", b.String())
+	}
+}
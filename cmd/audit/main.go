@@ -0,0 +1,234 @@
+// Command audit rebuilds a timeline from the server's JSON-lines event and
+// audit logs for a user or room, to settle moderation disputes.
+//
+// Usage:
+//
+//	audit [-user name] [-room #room] [-since time] [-until time] file...
+//
+// Times are RFC 3339 timestamps, dates such as 2024-05-01, or durations
+// before now such as 90m, 24h or 7d.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entry mirrors the lines written by the server's audit and event logs.
+type entry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Actor    string    `json:"actor"`
+	Target   string    `json:"target,omitempty"`
+	Room     string    `json:"room,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Context  []string  `json:"context,omitempty"`
+}
+
+// query selects the entries of a timeline. Empty fields match anything.
+type query struct {
+	user  string
+	room  string
+	since time.Time
+	until time.Time
+}
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func main() {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	user := flags.String("user", "", "only entries by or about this user, following renames")
+	room := flags.String("room", "", "only entries in this room")
+	since := flags.String("since", "", "start of the time range")
+	until := flags.String("until", "", "end of the time range")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: audit [-user name] [-room #room] [-since time] [-until time] file...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	now := time.Now()
+	q := query{user: *user, room: *room}
+	var err error
+	if q.since, err = parseTime(*since, now); err != nil {
+		fatal(err)
+	}
+	if q.until, err = parseTime(*until, now); err != nil {
+		fatal(err)
+	}
+
+	var entries []entry
+	for _, path := range flags.Args() {
+		read, err := readFile(path)
+		if err != nil {
+			fatal(err)
+		}
+		entries = append(entries, read...)
+	}
+	writeReport(os.Stdout, q, timeline(entries, q))
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "audit:", err)
+	os.Exit(1)
+}
+
+// parseTime reads a -since or -until value. An empty value yields the zero
+// time, which leaves that end of the range open.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// readFile parses a JSON-lines log, skipping lines that do not parse.
+func readFile(path string) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readEntries(f, path)
+}
+
+func readEntries(r io.Reader, name string) ([]entry, error) {
+	var entries []entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Action == "" {
+			fmt.Fprintf(os.Stderr, "audit: %s:%d: skipping invalid entry\n", name, line)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	return entries, nil
+}
+
+// timeline sorts entries by time and keeps those matching q. A user query
+// follows the user through renames: once they take a new name, entries
+// under that name match as well.
+func timeline(entries []entry, q query) []entry {
+	sorted := append([]entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	names := map[string]bool{strings.ToLower(q.user): true}
+	var matched []entry
+	for _, e := range sorted {
+		isUser := q.user == "" || names[strings.ToLower(e.Actor)] || names[strings.ToLower(e.Target)]
+		if q.user != "" && e.Action == "nick" && names[strings.ToLower(e.Actor)] {
+			names[strings.ToLower(e.Target)] = true
+		}
+		if !isUser || (q.room != "" && e.Room != q.room) {
+			continue
+		}
+		if (!q.since.IsZero() && e.Time.Before(q.since)) || (!q.until.IsZero() && e.Time.After(q.until)) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return matched
+}
+
+// describe renders one entry as a line of text.
+func describe(e entry) string {
+	var text string
+	switch e.Action {
+	case "message":
+		text = fmt.Sprintf("<%s> %s", e.Actor, e.Text)
+	case "join":
+		text = e.Actor + " connected"
+	case "leave":
+		text = e.Actor + " disconnected"
+	case "room":
+		text = e.Actor + " entered the room"
+		if e.Target != "" && e.Target != e.Room {
+			text += " from " + e.Target
+		}
+	case "nick":
+		text = fmt.Sprintf("%s is now known as %s", e.Actor, e.Target)
+	case "report":
+		text = fmt.Sprintf("%s reported %s: %s", e.Actor, e.Target, e.Reason)
+	default:
+		text = strings.TrimSpace(strings.Join([]string{e.Actor, e.Action, e.Target}, " "))
+		if e.Duration != "" {
+			text += " for " + e.Duration
+		}
+		if e.Reason != "" {
+			text += ": " + e.Reason
+		}
+	}
+	room := e.Room
+	if room == "" {
+		room = "-"
+	}
+	return fmt.Sprintf("%s  %-12s %s", e.Time.Local().Format(timeLayout), room, text)
+}
+
+// writeReport prints the timeline with a summary header.
+func writeReport(w io.Writer, q query, entries []entry) {
+	var scope []string
+	if q.user != "" {
+		scope = append(scope, "user "+q.user)
+	}
+	if q.room != "" {
+		scope = append(scope, "room "+q.room)
+	}
+	if len(scope) == 0 {
+		scope = append(scope, "everyone")
+	}
+	header := "Timeline for " + strings.Join(scope, " in ")
+	if !q.since.IsZero() {
+		header += " from " + q.since.Local().Format(timeLayout)
+	}
+	if !q.until.IsZero() {
+		header += " until " + q.until.Local().Format(timeLayout)
+	}
+	fmt.Fprintf(w, "%s: %d %s\n", header, len(entries), plural(len(entries), "entry", "entries"))
+
+	for _, e := range entries {
+		fmt.Fprintln(w, describe(e))
+		for _, line := range e.Context {
+			fmt.Fprintln(w, "        | "+line)
+		}
+	}
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
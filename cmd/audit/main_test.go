@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleLog = `{"time":"2024-05-01T10:00:00Z","action":"join","actor":"alice","room":"#general"}
+{"time":"2024-05-01T10:01:00Z","action":"message","actor":"alice","room":"#general","text":"hi"}
+not json
+{"time":"2024-05-01T10:02:00Z","action":"nick","actor":"alice","target":"alice2"}
+{"time":"2024-05-01T10:03:00Z","action":"message","actor":"alice2","room":"#general","text":"renamed"}
+{"time":"2024-05-01T10:04:00Z","action":"message","actor":"bob","room":"#ops","text":"elsewhere"}
+`
+
+const sampleAudit = `{"time":"2024-05-01T10:03:30Z","action":"report","actor":"bob","target":"alice2","room":"#general","reason":"rude","context":["alice2: renamed"]}
+`
+
+func TestParseTime(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"", time.Time{}},
+		{"2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"24h", now.Add(-24 * time.Hour)},
+		{"2d", now.Add(-48 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.value, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := parseTime("yesterday", now); err == nil {
+		t.Error("Expected an invalid time to be refused")
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	t.Parallel()
+	events, err := readEntries(strings.NewReader(sampleLog), "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audits, err := readEntries(strings.NewReader(sampleAudit), "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := append(audits, events...)
+
+	actions := func(entries []entry) string {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Action+":"+e.Actor)
+		}
+		return strings.Join(names, " ")
+	}
+
+	tests := []struct {
+		name string
+		q    query
+		want string
+	}{
+		{"Follows renames", query{user: "alice"}, "join:alice message:alice nick:alice message:alice2 report:bob"},
+		{"Room", query{room: "#ops"}, "message:bob"},
+		{"Time range", query{
+			since: time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC),
+			until: time.Date(2024, 5, 1, 10, 3, 0, 0, time.UTC),
+		}, "message:alice nick:alice message:alice2"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := actions(timeline(all, tt.q)); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+	entries, err := readEntries(strings.NewReader(sampleAudit), "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writeReport(&buf, query{user: "alice2"}, entries)
+	got := buf.String()
+	for _, want := range []string{"Timeline for user alice2: 1 entry", "bob reported alice2: rude", "| alice2: renamed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in report:\n%s", want, got)
+		}
+	}
+}
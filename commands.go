@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// commandCall is one use of a slash command, as the registry split it up.
+type commandCall struct {
+	name     string    // Command name as sent, with the slash
+	args     []string  // Words after the command name
+	text     string    // Everything after the command name, spacing kept
+	received time.Time // When the line was read
+}
+
+// parseCommandCall splits message, a command line received at received,
+// into the command name and its arguments.
+func parseCommandCall(message string, received time.Time) commandCall {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return commandCall{received: received}
+	}
+	text := strings.TrimSpace(message)
+	text = strings.TrimSpace(text[len(fields[0]):])
+	return commandCall{name: fields[0], args: fields[1:], text: text, received: received}
+}
+
+// textAfter returns the text of call after its first n arguments, with
+// the spacing in between kept.
+func (call commandCall) textAfter(n int) string {
+	text := call.text
+	for i := 0; i < n; i++ {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		text = strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	}
+	return text
+}
+
+// command is an entry of the command registry: the description served in
+// the protocol description and by /help, and the function running it.
+// The registry checks the permissions and the number of arguments before
+// run is called.
+type command struct {
+	commandSpec
+	minArgs int // Calls with fewer arguments get the syntax in reply
+	run     func(c *client, call commandCall)
+}
+
+// managePermissions holds the permissions of commands anyone allowed to
+// use them may list with, but whose calls that change something need more,
+// as their descriptions say.
+var managePermissions = map[string]string{
+	"/canned": permOperator,
+	"/group":  permOperator,
+	"/links":  permOperator,
+}
+
+// commands is the registry of slash commands by name, and commandOrder
+// the names in the order they were registered, which listings keep.
+var (
+	commands     = make(map[string]*command)
+	commandOrder []string
+)
+
+// registerCommand adds cmd to the registry. Names must be unique.
+func registerCommand(cmd command) {
+	if _, exists := commands[cmd.Name]; exists {
+		panic("command " + cmd.Name + " registered twice")
+	}
+	commands[cmd.Name] = &cmd
+	commandOrder = append(commandOrder, cmd.Name)
+}
+
+// The commands are registered at startup rather than listed in a variable
+// since /help reads the registry it is part of.
+func init() {
+	for _, cmd := range []command{
+		{commandSpec{"/help", "/help [command]", permUser, "List the commands you may use, or show how to use one"}, 0, handleHelpCommand},
+		{commandSpec{"/motd", "/motd", permUser, "Show the message of the day again"}, 0, handleMOTDCommand},
+		{commandSpec{"/msg", "/msg <user> <message>", permUser, "Send a private message"}, 2, handlePrivateMessage},
+		{commandSpec{"/r", "/r <message>", permUser, "Reply privately to the last user who sent you a private message"}, 1, handleReplyCommand},
+		{commandSpec{"/me", "/me <action>", permUser, "Post an action to the current room, shown as * <name> <action>"}, 1, handleMeCommand},
+		{commandSpec{"/ack", "/ack <id>", permUser, "Acknowledge a private message, with the acks capability"}, 0, handleAckCommand},
+		{commandSpec{"/list", "/list", permUser, "List connected users"}, 0, func(c *client, _ commandCall) { handleListCommand(c) }},
+		{commandSpec{"/quit", "/quit [message]", permUser, "Leave the chat, with a farewell message for the others"}, 0, quitCommand},
+		{commandSpec{"/nick", "/nick <name>", permUser, "Change your name"}, 0, handleNickCommand},
+		{commandSpec{"/oper", "/oper <password>", permUser, "Become an operator or moderator"}, 1, handleOperCommand},
+		{commandSpec{"/create", "/create #room [--template name]", permUser, "Create a room from a settings template and join it"}, 0, handleRoomCommand},
+		{commandSpec{"/join", "/join #room", permUser, "Switch to another room"}, 0, handleRoomCommand},
+		{commandSpec{"/rooms", "/rooms", permUser, "List rooms with their member counts"}, 0, handleRoomCommand},
+		{commandSpec{"/seen", "/seen <user>", permUser, "Tell when a user was last online and in which room"}, 1, handleSeenCommand},
+		{commandSpec{"/dnd", "/dnd [on|off]", permUser, "Turn away private messages to you, toggling without an argument"}, 0, handleDNDCommand},
+		{commandSpec{"/ignore", "/ignore <user>", permUser, "Stop receiving a user's chat messages, typing notices and private messages for this session"}, 1, handleIgnoreCommand},
+		{commandSpec{"/unignore", "/unignore <user>", permUser, "Receive an ignored user's messages again"}, 1, handleIgnoreCommand},
+		{commandSpec{"/ignorelist", "/ignorelist", permUser, "List the users you ignore"}, 0, handleIgnoreCommand},
+		{commandSpec{"/away", "/away [message]", permUser, "Mark yourself as away, or back without a message; private message senders are told the message"}, 0, handleAwayCommand},
+		{commandSpec{"/greeting", "/greeting [text|off]", permUser, "Show the current room's greeting; the room owner and operators can change it"}, 0, handleGreetingCommand},
+		{commandSpec{"/pins", "/pins", permUser, "List the messages pinned in the current room"}, 0, handlePinCommand},
+		{commandSpec{"/pin", "/pin <id>", permUser, "Pin a message of the current room by ID, as the room owner or an operator"}, 0, handlePinCommand},
+		{commandSpec{"/unpin", "/unpin <id>", permUser, "Unpin a message of the current room, as the room owner or an operator"}, 0, handlePinCommand},
+		{commandSpec{"/links", "/links [off|block|guests|allow <domain>...|deny <domain>...]", permUser, "Show the current room's link policy; changing it requires operator rights"}, 0, handleLinksCommand},
+		{commandSpec{"/react", "/react <user> <reaction>", permUser, "React to a user's latest message in the current room"}, 0, handleReactionCommand},
+		{commandSpec{"/reactions", "/reactions [off|any|allow <reaction>...]", permUser, "Show the current room's allowed reactions; the room owner and operators can change them"}, 0, handleReactionCommand},
+		{commandSpec{"/poll", `/poll ["Question?" <option> <option>... | close]`, permUser, "Open a poll in the current room, show the open one, or close it early as its creator or a moderator"}, 0, handlePollCommand},
+		{commandSpec{"/vote", "/vote <n>", permUser, "Vote for option n of the poll open in the current room, once per poll"}, 1, handleVoteCommand},
+		{commandSpec{"/remind", "/remind [here] <duration> <text> | cancel <n>", permUser, "Get the text back after the duration, or have it posted to your room with here; without arguments list your pending reminders"}, 0, handleRemindCommand},
+		{commandSpec{"/watch", "/watch [#room [interval] | <user>]", permUser, "List watched rooms and users, get a periodic digest of a room's activity, or get notified when a user connects, disconnects or changes status"}, 0, handleWatchCommand},
+		{commandSpec{"/unwatch", "/unwatch #room|<user>", permUser, "Stop the digest of a room or the notices about a user"}, 0, handleWatchCommand},
+		{commandSpec{"/cap", "/cap [ls|list|req <cap>...]", permUser, "List the optional capabilities, or enable them (disable with a '-' prefix)"}, 0, handleCapCommand},
+		{commandSpec{"/typing", "/typing", permUser, "Tell the room you are typing; relayed at most every 3 seconds"}, 0, func(c *client, call commandCall) { handleTyping(c, call.received) }},
+		{commandSpec{"/report", "/report <user> <reason>", permUser, "Report a user to the moderators"}, 0, handleReportCommand},
+		{commandSpec{"/locale", "/locale [tag|default]", permUser, "Show or set how numbers and times are written for you"}, 0, handleLocaleCommand},
+		{commandSpec{"/group", "/group list [name] | create <name> | delete <name> | add <name> <user> | remove <name> <user>", permUser, "List groups; managing them requires operator rights"}, 0, handleGroupCommand},
+		{commandSpec{"/exportpm", "/exportpm <user> [text|json]", permUser, "Get a download link for your private conversation with a user"}, 0, handlePMExportCommand},
+		{commandSpec{"/pmhistory", "/pmhistory <user> [count]", permUser, "Replay your latest private messages with a user, 20 by default"}, 1, handlePMHistoryCommand},
+		{commandSpec{"/privacy", "/privacy [export|history on|off]", permUser, "Show or set whether others may export conversations with you and whether your private messages are kept"}, 0, handlePMExportCommand},
+		{commandSpec{"/canned", "/canned [list | add <name> <text> | remove <name>]", permModerator, "List canned replies; defining them requires operator rights"}, 0, handleCannedCommand},
+		{commandSpec{"/c", "/c <name>", permModerator, "Post a canned reply to the current room"}, 0, handleCannedCommand},
+		{commandSpec{"/mute", "/mute <name> [duration]", permModerator, "Silence a user, indefinitely without a duration"}, 0, handleMuteCommand},
+		{commandSpec{"/unmute", "/unmute <name>", permModerator, "Lift a mute"}, 0, handleMuteCommand},
+		{commandSpec{"/shadowban", "/shadowban <name>", permModerator, "Silently drop everything a user and their address send"}, 0, handleShadowBanCommand},
+		{commandSpec{"/unshadowban", "/unshadowban <name>", permModerator, "Lift a shadow ban"}, 0, handleShadowBanCommand},
+		{commandSpec{"/say", "/say #room <message>", permBot, "Post a chat message to a room without joining it"}, 2, handleSayCommand},
+		{commandSpec{"/ban", "/ban <name|ip> [duration] [--ip]", permOperator, "Ban a name or address, permanently without a duration"}, 0, handleBanCommand},
+		{commandSpec{"/unban", "/unban <name|ip>", permOperator, "Lift a ban"}, 0, handleBanCommand},
+		{commandSpec{"/banlist", "/banlist", permOperator, "List active bans"}, 0, handleBanCommand},
+		{commandSpec{"/modlog", "/modlog <user> [count]", permOperator, "List the latest kicks, bans, mutes and reports about a user"}, 0, handleModlogCommand},
+		{commandSpec{"/heatmap", "/heatmap [#room]", permOperator, "Draw the message counts of a room or the whole server by weekday and hour"}, 0, handleHeatmapCommand},
+		{commandSpec{"/maintenance", "/maintenance [minutes [reason]|cancel]", permOperator, "Show, schedule or cancel maintenance; when it starts the server stops accepting connections"}, 0, handleMaintenanceCommand},
+		{commandSpec{"/schedule", `/schedule [list | add <name> "<cron>" [#room] <text> | remove <name>]`, permOperator, "List, add or remove server notices broadcast on a cron schedule; added ones last until restart"}, 0, handleScheduleCommand},
+		{commandSpec{"/purge", "/purge <user>", permOperator, "Delete a user's messages, private conversations, group memberships and queued notices"}, 0, handlePurgeCommand},
+	} {
+		registerCommand(cmd)
+	}
+}
+
+// commandSpecs describes the registered commands in registration order.
+func commandSpecs() []commandSpec {
+	specs := make([]commandSpec, 0, len(commandOrder))
+	for _, name := range commandOrder {
+		specs = append(specs, commands[name].commandSpec)
+	}
+	return specs
+}
+
+// mayUse reports whether c has the permission level a command requires.
+func (c *client) mayUse(permission string) bool {
+	switch permission {
+	case permOperator:
+		return c.operator
+	case permModerator:
+		return c.isModerator()
+	case permBot:
+		return c.operator || c.bot
+	}
+	return true
+}
+
+// handleCommand runs the slash command in message, received at received,
+// after checking the permission and the number of arguments it requires.
+// It reports whether message was a command; anything else is chat.
+func handleCommand(c *client, message string, received time.Time) bool {
+	call := parseCommandCall(message, received)
+	cmd, ok := commands[call.name]
+	if !ok {
+		return false
+	}
+
+	permission := cmd.Permission
+	if manage, ok := managePermissions[cmd.Name]; ok && manages(call.args) {
+		permission = manage
+	}
+	if !c.mayUse(permission) {
+		c.send(fmt.Sprintf("Permission denied: %s only command", permission))
+		return true
+	}
+	if len(call.args) < cmd.minArgs {
+		c.send("Usage: " + cmd.Syntax)
+		return true
+	}
+	cmd.run(c, call)
+	return true
+}
+
+// manages reports whether a call with args changes something rather than
+// listing it, for commands whose changes need the Manage permission.
+func manages(args []string) bool {
+	return len(args) > 0 && args[0] != "list"
+}
+
+// handlePrivateMessage processes "/msg <user> <message>".
+func handlePrivateMessage(c *client, call commandCall) {
+	sendPrivateMessage(c, call.args[0], call.textAfter(1), call.received)
+}
+
+// sendPrivateMessage confirms a private message to the sender and
+// delivers it to the recipient, see deliverPM.
+func sendPrivateMessage(c *client, recipient, privateMessage string, received time.Time) {
+	// Checked here rather than by the caller so /r and other routes to
+	// private messages cannot get around it
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+
+	target := findClientByName(recipient)
+	if target == nil {
+		// A dropped connection may still come back and resume; shadow-banned
+		// senders get the usual confirmation only
+		pm := &pmDelivery{id: nextMessageID(), from: c.name, to: recipient, text: privateMessage, received: received}
+		if isShadowBanned(c) && isSuspended(recipient) || !isShadowBanned(c) && holdPM(pm) {
+			c.send(fmt.Sprintf("[PM to %s]: %s", recipient, privateMessage))
+			c.send(fmt.Sprintf("%s could not be reached; your message will be delivered when they are back", recipient))
+			return
+		}
+		if name, ok := authenticatedName(recipient); ok {
+			queueOfflinePM(c, name, privateMessage, received)
+			return
+		}
+		c.send(fmt.Sprintf("User %s not found", recipient))
+		return
+	}
+	if target.dnd.Load() {
+		c.send(dndNotice(target.currentName()))
+		return
+	}
+	pm := &pmDelivery{id: nextMessageID(), from: c.name, to: recipient, text: privateMessage, received: received}
+	sendPM(c, pm.id, fmt.Sprintf("[PM to %s]: %s", recipient, privateMessage))
+	// Shadow-banned and ignored senders get the usual confirmation only
+	if !isShadowBanned(c) && !target.ignores(c.name) {
+		target.setReplyTo(c.name)
+		deliverPM(pm)
+	}
+	if away := target.awayMessage(); away != "" {
+		c.send(fmt.Sprintf("%s is away: %s", target.currentName(), away))
+	}
+}
+
+// handleListCommand sends the names of all connected users.
+func handleListCommand(c *client) {
+	mutex.Lock()
+	var userList []string
+	for _, other := range clients {
+		name := other.displayName()
+		if other.dnd.Load() {
+			name += " (dnd)"
+		}
+		if other.away != "" {
+			name += " (away)"
+		}
+		userList = append(userList, name)
+	}
+	mutex.Unlock()
+	c.send(fmt.Sprintf("Connected users: %s", strings.Join(userList, ", ")))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleCommand(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		client  *client
+		message string
+		handled bool
+		want    string
+	}{
+		{"chat", &client{name: "registry-user"}, "hello there", false, ""},
+		{"unknown command", &client{name: "registry-user"}, "/frobnicate", false, ""},
+		{"moderator command", &client{name: "registry-user"}, "/mute someone", true, "Permission denied: moderator only command\n"},
+		{"operator command", &client{name: "registry-mod", moderator: true}, "/purge someone", true, "Permission denied: operator only command\n"},
+		{"managing command", &client{name: "registry-user"}, "/group create registry-team", true, "Permission denied: operator only command\n"},
+		{"listing a managed command", &client{name: "registry-user"}, "/group list registry-nogroup", true, "No such group @registry-nogroup\n"},
+		{"missing arguments", &client{name: "registry-user"}, "/msg bob", true, "Usage: /msg <user> <message>\n"},
+		{"enough arguments", &client{name: "registry-user"}, "/seen registry-nobody", true, "registry-nobody has not been seen\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			conn := newMockConn()
+			tt.client.conn = conn
+			if got := handleCommand(tt.client, tt.message, time.Now()); got != tt.handled {
+				t.Fatalf("handleCommand(%q) = %t, want %t", tt.message, got, tt.handled)
+			}
+			if got := conn.writeBuffer.String(); got != tt.want {
+				t.Errorf("handleCommand(%q) answered %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	t.Parallel()
+	for i, name := range commandOrder {
+		cmd := commands[name]
+		if cmd == nil || cmd.run == nil {
+			t.Errorf("%s is listed but has no handler", name)
+		}
+		if specs := commandSpecs(); specs[i].Name != name {
+			t.Errorf("Expected the descriptions in registration order, got %s at %d", specs[i].Name, i)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a name twice to panic")
+		}
+	}()
+	registerCommand(command{commandSpec: commandSpec{Name: "/help"}})
+}
+
+// callOf parses line as a command call received now, for the tests that
+// run a handler without the registry.
+func callOf(line string) commandCall {
+	return parseCommandCall(line, time.Now())
+}
+
+func TestParseCommandCall(t *testing.T) {
+	t.Parallel()
+	call := callOf("  /remind here  10m   stand  up ")
+	if call.name != "/remind" || strings.Join(call.args, ",") != "here,10m,stand,up" {
+		t.Errorf("Expected the name and the words, got %q %q", call.name, call.args)
+	}
+	if call.text != "here  10m   stand  up" {
+		t.Errorf("Expected the text after the name, got %q", call.text)
+	}
+	for n, want := range []string{"here  10m   stand  up", "10m   stand  up", "stand  up", "up", ""} {
+		if got := call.textAfter(n); got != want {
+			t.Errorf("textAfter(%d) = %q, want %q", n, got, want)
+		}
+	}
+	if call := callOf(""); call.name != "" || call.args != nil {
+		t.Errorf("Expected nothing from an empty line, got %+v", call)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compressToken offers stream compression in the handshake, methods in
+// order of preference: "CHAT/1.0 COMPRESS=zstd,gzip".
+const compressToken = "COMPRESS="
+
+// Compression methods of the stream. zstd is not among them, as the
+// standard library has no implementation.
+const (
+	compressNone    = "none"
+	compressGzip    = "gzip"
+	compressDeflate = "deflate"
+)
+
+// compressions lists the methods the server speaks, in its order of
+// preference.
+var compressions = []string{compressGzip, compressDeflate}
+
+var errCompressedStream = errors.New("Compressed stream broken off by a failed write")
+
+// pickCompression returns the first offered method the server speaks, or
+// compressNone.
+func pickCompression(offered []string) string {
+	for _, method := range offered {
+		for _, known := range compressions {
+			if strings.EqualFold(method, known) {
+				return known
+			}
+		}
+	}
+	return compressNone
+}
+
+// compressionReply answers a COMPRESS= offer. It goes out uncompressed;
+// everything after it in both directions uses the method, unless it is
+// none.
+func compressionReply(method string) string {
+	return "COMPRESSION " + method
+}
+
+// compressedConn compresses a connection in both directions. Every write
+// is flushed, so lines reach the client right away at some cost in
+// ratio. Decompression runs on its own goroutine because the decoders
+// cannot go on after a read timeout; read deadlines are kept here instead.
+type compressedConn struct {
+	net.Conn
+
+	writeMu sync.Mutex
+	w       interface {
+		io.Writer
+		Flush() error
+	}
+	broken bool // A write failed halfway and the stream cannot go on
+
+	incoming chan compressedChunk
+	pending  []byte
+	readErr  error
+	closed   chan struct{}
+	close    sync.Once
+
+	mu           sync.Mutex
+	readDeadline time.Time
+}
+
+// compressedChunk is decompressed input or the error that ended it.
+type compressedChunk struct {
+	data []byte
+	err  error
+}
+
+// newCompressedConn wraps conn in the given method, which must be one of
+// compressions.
+func newCompressedConn(conn net.Conn, method string) *compressedConn {
+	z := &compressedConn{Conn: conn, incoming: make(chan compressedChunk), closed: make(chan struct{})}
+	var open func() (io.Reader, error)
+	switch method {
+	case compressGzip:
+		z.w = gzip.NewWriter(conn)
+		open = func() (io.Reader, error) { return gzip.NewReader(conn) }
+	case compressDeflate:
+		z.w, _ = flate.NewWriter(conn, flate.DefaultCompression)
+		open = func() (io.Reader, error) { return flate.NewReader(conn), nil }
+	}
+	go z.decompress(open)
+	return z
+}
+
+// decompress feeds the decompressed input of the client to Read until it
+// fails or the connection is closed.
+func (z *compressedConn) decompress(open func() (io.Reader, error)) {
+	r, err := open() // Blocks until the client sends its first bytes
+	buf := make([]byte, 4096)
+	for err == nil {
+		var n int
+		n, err = r.Read(buf)
+		if n > 0 {
+			select {
+			case z.incoming <- compressedChunk{data: append([]byte(nil), buf[:n]...)}:
+			case <-z.closed:
+				return
+			}
+		}
+	}
+	select {
+	case z.incoming <- compressedChunk{err: err}:
+	case <-z.closed:
+	}
+}
+
+func (z *compressedConn) Read(p []byte) (int, error) {
+	for len(z.pending) == 0 {
+		if z.readErr != nil {
+			return 0, z.readErr
+		}
+		z.mu.Lock()
+		deadline := z.readDeadline
+		z.mu.Unlock()
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case chunk := <-z.incoming:
+			z.pending, z.readErr = chunk.data, chunk.err
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		case <-z.closed:
+			return 0, net.ErrClosed
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	n := copy(p, z.pending)
+	z.pending = z.pending[n:]
+	return n, nil
+}
+
+// Write compresses and flushes p. A write that fails leaves the client
+// with part of a compressed block, so the connection is closed.
+func (z *compressedConn) Write(p []byte) (int, error) {
+	z.writeMu.Lock()
+	defer z.writeMu.Unlock()
+	if z.broken {
+		return 0, errCompressedStream
+	}
+	_, err := z.w.Write(p)
+	if err == nil {
+		err = z.w.Flush()
+	}
+	if err != nil {
+		z.broken = true
+		log.Printf("Closing compressed connection of %s: %v", remoteIP(z.Conn), err)
+		z.Close()
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (z *compressedConn) Close() error {
+	err := net.ErrClosed
+	z.close.Do(func() {
+		close(z.closed)
+		err = z.Conn.Close()
+	})
+	return err
+}
+
+func (z *compressedConn) SetReadDeadline(t time.Time) error {
+	z.mu.Lock()
+	z.readDeadline = t
+	z.mu.Unlock()
+	return nil
+}
+
+func (z *compressedConn) SetDeadline(t time.Time) error {
+	z.SetReadDeadline(t)
+	return z.Conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPickCompression(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		offered []string
+		want    string
+	}{
+		{[]string{"gzip"}, compressGzip},
+		{[]string{"zstd", "deflate", "gzip"}, compressDeflate},
+		{[]string{"Gzip"}, compressGzip},
+		{[]string{"zstd"}, compressNone},
+		{[]string{""}, compressNone},
+	}
+	for _, tt := range tests {
+		if got := pickCompression(tt.offered); got != tt.want {
+			t.Errorf("pickCompression(%q) = %q, want %q", tt.offered, got, tt.want)
+		}
+	}
+}
+
+func TestCompressedConn(t *testing.T) {
+	t.Parallel()
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	z := newCompressedConn(serverSide, compressGzip)
+	defer z.Close()
+
+	// Nothing has arrived yet, and the timeout must not end the stream
+	z.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := z.Read(make([]byte, 16)); err == nil {
+		t.Fatal("Expected a read timeout")
+	} else if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	z.SetReadDeadline(time.Time{})
+
+	go func() {
+		w := gzip.NewWriter(clientSide)
+		w.Write([]byte("alice\n"))
+		w.Flush()
+	}()
+	line, err := bufio.NewReader(z).ReadString('\n')
+	if err != nil || line != "alice\n" {
+		t.Fatalf("Read %q, %v", line, err)
+	}
+
+	received := make(chan string, 1)
+	go func() {
+		r, err := gzip.NewReader(clientSide)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		received <- line
+	}()
+	if _, err := z.Write([]byte("Welcome, alice!\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got != "Welcome, alice!\n" {
+			t.Errorf("Client got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the write to be flushed to the client")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+const defaultConfigFile = "tcpchat.json"
+
+// Config holds the server settings read from the JSON configuration file.
+// Every field is optional; missing values keep their defaults.
+type Config struct {
+	OperPassword      string `json:"oper_password"`      // Password for the /oper command, empty disables it
+	ModeratorPassword string `json:"moderator_password"` // Password granting moderator rights through /oper
+	BanFile           string `json:"ban_file"`           // File where bans are persisted
+	MuteFile          string `json:"mute_file"`          // File where mutes are persisted
+	GroupFile         string `json:"group_file"`         // File where user groups are persisted
+	CannedFile        string `json:"canned_file"`        // File where canned replies are persisted
+	SeenFile          string `json:"seen_file"`          // File where the last-seen times of /seen are persisted
+	OfflineFile       string `json:"offline_file"`       // File where private messages to offline users wait for them
+	AuditFile         string `json:"audit_file"`         // JSON-lines log of reports, empty disables it
+	EventFile         string `json:"event_file"`         // JSON-lines log of joins, leaves and chat messages, empty disables it
+
+	ServerName string `json:"server_name"` // Name filled in for {server} in the texts below
+	Banner     string `json:"banner"`      // First line sent to text clients, before the logo
+	Welcome    string `json:"welcome"`     // Sent once the client has a name
+	MOTD       string `json:"motd"`        // Message of the day sent after the welcome and by /motd, empty for none
+
+	MaxNameLength int           `json:"max_name_length"` // Longest client name in characters, 0 for no limit
+	ReservedNames []string      `json:"reserved_names"`  // Names no client may register, besides the server's own
+	Guests        GuestSettings `json:"guests"`          // Guest mode with generated names
+
+	Bots          map[string]BotConfig    `json:"bots"`           // Registered bots by name
+	RoomTemplates map[string]RoomSettings `json:"room_templates"` // Settings profiles applied to new rooms
+	Escalations   map[string]Escalation   `json:"escalations"`    // On-call webhooks by group name
+
+	AllowCIDRs          []string          `json:"allow_cidrs"`            // Address ranges allowed to connect, empty allows all
+	DenyCIDRs           []string          `json:"deny_cidrs"`             // Address ranges refused right after accepting
+	MaxConnectionsPerIP int               `json:"max_connections_per_ip"` // Simultaneous connections allowed from one address, 0 for no limit
+	IdleTimeout         int               `json:"idle_timeout"`           // Seconds of silence after which a client is disconnected, 0 disables
+	WriteTimeout        int               `json:"write_timeout"`          // Milliseconds a single write to a client may take, 0 for the default
+	Heartbeat           HeartbeatSettings `json:"heartbeat"`              // PING messages to clients with the ping capability
+	Failures            FailureSettings   `json:"failures"`               // Blocking of addresses that keep failing the handshake or logins
+	Accept              AcceptSettings    `json:"accept"`                 // Throttling of new connections
+
+	HTTPAddr  string `json:"http_addr"`  // Address of the HTTP endpoints such as /metrics, empty disables them
+	PublicURL string `json:"public_url"` // Base URL of the HTTP endpoints as seen by clients, defaults to http://<http_addr>
+	AdminAddr string `json:"admin_addr"` // Address of the admin console, empty disables it
+
+	WebSocketAddr    string   `json:"websocket_addr"`    // Address of the WebSocket gateway for browser clients, empty disables it
+	WebSocketOrigins []string `json:"websocket_origins"` // Origins of other sites' pages allowed to connect to the gateway
+	WebClient        bool     `json:"web_client"`        // Serve the browser client at / of the gateway
+
+	AdminPassword string   `json:"admin_password"` // Password accepted by the admin console "auth" command
+	AdminTokens   []string `json:"admin_tokens"`   // Tokens accepted by the admin console "auth" command
+	AdminTLSCert  string   `json:"admin_tls_cert"` // Certificate file serving the admin console over TLS
+	AdminTLSKey   string   `json:"admin_tls_key"`  // Key file of AdminTLSCert
+
+	DeliverySLO map[string]int `json:"delivery_slo"` // Write and acknowledgement latency objective in milliseconds by message class
+
+	ProfanityFile   string `json:"profanity_file"`   // Wordlist of the profanity filter, empty disables it
+	ProfanityAction string `json:"profanity_action"` // What to do with matching messages: mask, reject or flag
+
+	MessageHooks []HookConfig `json:"message_hooks"` // Regular expression filters run before broadcast
+
+	ScheduledMessages []ScheduledMessage `json:"scheduled_messages"` // Server notices broadcast on a cron schedule
+
+	Flood  FloodSettings  `json:"flood"`  // Per-client flood protection
+	Repeat RepeatSettings `json:"repeat"` // Suppression of repeated messages
+}
+
+var config = defaultConfig() // Active server configuration
+
+func defaultConfig() Config {
+	return Config{
+		BanFile:     "bans.json",
+		MuteFile:    "mutes.json",
+		GroupFile:   "groups.json",
+		CannedFile:  "canned.json",
+		SeenFile:    "seen.json",
+		OfflineFile: "offline.json",
+		AuditFile:   "audit.jsonl",
+
+		ServerName: "TCP-Chat",
+		Banner:     "Welcome to {server}!",
+		Welcome:    "Welcome, {name}!",
+
+		MaxNameLength: 32,
+		ReservedNames: []string{"admin", "administrator", "root", "operator", "moderator"},
+
+		MaxConnectionsPerIP: 3,
+		Accept:              AcceptSettings{Rate: 20, Burst: 40, Tarpit: 500},
+		IdleTimeout:         1800,
+		WriteTimeout:        5000,
+		Heartbeat:           HeartbeatSettings{Interval: 30, MissedPongs: 3},
+		Failures:            FailureSettings{Limit: 5, Window: 600, Block: 60},
+
+		ProfanityAction: profanityMask,
+		Flood:           FloodSettings{Messages: 5, Window: 10, Strikes: 3, Mute: 60},
+		Repeat:          RepeatSettings{Count: 3, Window: 60},
+		DeliverySLO: map[string]int{
+			classChat:   500,
+			classPM:     500,
+			classSystem: 1000,
+		},
+	}
+}
+
+// configPath returns the configuration file location, which can be
+// overridden with the TCPCHAT_CONFIG environment variable.
+func configPath() string {
+	if path := os.Getenv("TCPCHAT_CONFIG"); path != "" {
+		return path
+	}
+	return defaultConfigFile
+}
+
+// loadConfig reads the configuration file at path on top of the defaults.
+// A missing file is not an error so the server runs without one.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
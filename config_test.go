@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+	t.Run("MissingFile", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := loadConfig(filepath.Join(t.TempDir(), "missing.json"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.BanFile != defaultConfig().BanFile {
+			t.Errorf("Expected default ban file, got %q", cfg.BanFile)
+		}
+	})
+
+	t.Run("OverridesDefaults", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "tcpchat.json")
+		os.WriteFile(path, []byte(`{"oper_password": "secret"}`), 0o600)
+
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.OperPassword != "secret" {
+			t.Errorf("Expected operator password to be loaded, got %q", cfg.OperPassword)
+		}
+		if cfg.BanFile != defaultConfig().BanFile {
+			t.Errorf("Expected unset fields to keep defaults, got %q", cfg.BanFile)
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "tcpchat.json")
+		os.WriteFile(path, []byte(`{`), 0o600)
+		if _, err := loadConfig(path); err == nil {
+			t.Error("Expected an error for invalid JSON")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import "errors"
+
+var (
+	errServerFull    = errors.New("Server is full. Please try again later.")
+	errTooManyFromIP = errors.New("Too many connections from your address. Please try again later.")
+	connsByIP        = make(map[string]int) // Open connections by remote address, protected by mutex
+)
+
+// acquireConnection reserves a slot for a new connection from ip, checking
+// both the server-wide limit and the per-address limit.
+func acquireConnection(ip string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if connCount >= maxConnections {
+		return errServerFull
+	}
+	if limit := config.MaxConnectionsPerIP; limit > 0 && ip != "" && connsByIP[ip] >= limit {
+		return errTooManyFromIP
+	}
+	connCount++
+	if ip != "" {
+		connsByIP[ip]++
+	}
+	return nil
+}
+
+// releaseConnection frees the slot taken by a connection from ip.
+func releaseConnection(ip string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	connCount--
+	if connsByIP[ip] > 1 {
+		connsByIP[ip]--
+	} else {
+		delete(connsByIP, ip)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestAcquireConnection(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.MaxConnectionsPerIP = 2
+
+	const ip = "203.0.113.7"
+	for i := 0; i < 2; i++ {
+		if err := acquireConnection(ip); err != nil {
+			t.Fatalf("Expected connection %d to be accepted, got %v", i+1, err)
+		}
+	}
+	if err := acquireConnection(ip); err != errTooManyFromIP {
+		t.Errorf("Expected %v, got %v", errTooManyFromIP, err)
+	}
+	if err := acquireConnection("203.0.113.8"); err != nil {
+		t.Errorf("Expected another address to be accepted, got %v", err)
+	}
+	releaseConnection("203.0.113.8")
+
+	releaseConnection(ip)
+	if err := acquireConnection(ip); err != nil {
+		t.Errorf("Expected a released slot to be reusable, got %v", err)
+	}
+	releaseConnection(ip)
+	releaseConnection(ip)
+
+	mutex.Lock()
+	left := connsByIP[ip]
+	mutex.Unlock()
+	if left != 0 {
+		t.Errorf("Expected no connections left for %s, got %d", ip, left)
+	}
+}
+
+func TestAcquireConnectionServerFull(t *testing.T) {
+	mutex.Lock()
+	saved := connCount
+	connCount = maxConnections
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		connCount = saved
+		mutex.Unlock()
+	}()
+
+	if err := acquireConnection("203.0.113.9"); err != errServerFull {
+		t.Errorf("Expected %v, got %v", errServerFull, err)
+	}
+}
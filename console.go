@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxConsoleHistory bounds the lines remembered by the stdin console.
+const maxConsoleHistory = 100
+
+// consoleCommands lists the commands offered by completion at the prompt.
+var consoleCommands = []string{
+	"announce", "ban", "banlist", "canned", "clients", "drain", "heatmap", "help", "history",
+	"kick", "list", "maintenance", "modlog", "mute", "purge", "reload", "stats", "unban", "undrain", "unmute",
+}
+
+// writerConn lets a console session reply through client.send. Only
+// writes and deadlines are supported; the embedded connection is never
+// set.
+type writerConn struct {
+	net.Conn
+	w io.Writer
+}
+
+func (c writerConn) Write(b []byte) (int, error) { return c.w.Write(b) }
+func (c writerConn) Close() error                { return nil }
+
+func (c writerConn) SetWriteDeadline(time.Time) error { return nil }
+
+// stdinConsole is the operator REPL on the server's own terminal. It runs
+// the admin console commands and adds history and completion: with the
+// line editor in raw mode, or on top of the terminal's own line editing
+// when that is not available.
+type stdinConsole struct {
+	admin   *client
+	out     io.Writer
+	history []string
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startStdinConsole reads operator commands from the terminal on stdin in
+// the background, editing lines in raw mode when stty can switch to it.
+func startStdinConsole() {
+	saved, err := makeRaw(os.Stdin)
+	if err != nil {
+		log.Printf("Console line editing unavailable: %v", err)
+		go runStdinConsole(os.Stdin, os.Stdout)
+		return
+	}
+	stop := restoreOnSignal(os.Stdin, saved)
+	go func() {
+		runTerminalConsole(os.Stdin, os.Stdout)
+		stop()
+		restoreTerminal(os.Stdin, saved)
+	}()
+}
+
+func newStdinConsole(out io.Writer) *stdinConsole {
+	return &stdinConsole{
+		admin: &client{conn: writerConn{w: out}, name: "console", operator: true},
+		out:   out,
+	}
+}
+
+// runTerminalConsole serves the console on a terminal in raw mode until
+// in is exhausted, the operator presses Ctrl-D or quits. The server keeps
+// running either way.
+func runTerminalConsole(in io.Reader, out io.Writer) {
+	s := newStdinConsole(out)
+	editor := &lineEditor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		prompt:   "> ",
+		history:  func() []string { return s.history },
+		complete: completions,
+	}
+	fmt.Fprintln(out, "Server console ready. Type 'help' for commands; Tab completes, Up and Down recall earlier commands.")
+	for {
+		line, err := editor.readLine()
+		if err != nil || !s.run(line) {
+			break
+		}
+	}
+	fmt.Fprintln(out, "Server console closed")
+}
+
+// runStdinConsole serves the console on input read line by line, until in
+// is exhausted or the operator quits. The server keeps running either way.
+func runStdinConsole(in io.Reader, out io.Writer) {
+	s := newStdinConsole(out)
+	fmt.Fprintln(out, "Server console ready. Type 'help' for commands; end a line with Tab to complete it.")
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(out, "> "); scanner.Scan(); fmt.Fprint(out, "> ") {
+		if !s.run(scanner.Text()) {
+			break
+		}
+	}
+	fmt.Fprintln(out, "Server console closed")
+}
+
+// run executes one input line, reporting whether the console stays open.
+func (s *stdinConsole) run(line string) bool {
+	if i := strings.IndexByte(line, '\t'); i >= 0 {
+		s.showCompletions(line[:i])
+		return true
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	if strings.HasPrefix(line, "!") {
+		expanded, err := s.expandHistory(line)
+		if err != nil {
+			fmt.Fprintln(s.out, err)
+			return true
+		}
+		fmt.Fprintln(s.out, expanded)
+		line = expanded
+	}
+	s.remember(line)
+
+	switch line {
+	case "quit", "exit":
+		return false
+	case "history":
+		for i, entry := range s.history {
+			fmt.Fprintf(s.out, "%4d  %s\n", i+1, entry)
+		}
+	case "help":
+		runAdminCommand(s.admin, line)
+		s.admin.send("Console: history, !! repeats the last command, !<n> runs history entry n")
+	default:
+		runAdminCommand(s.admin, line)
+	}
+	return true
+}
+
+// remember appends line to the history, skipping immediate repeats.
+func (s *stdinConsole) remember(line string) {
+	if n := len(s.history); n > 0 && s.history[n-1] == line {
+		return
+	}
+	s.history = append(s.history, line)
+	if len(s.history) > maxConsoleHistory {
+		s.history = s.history[1:]
+	}
+}
+
+// expandHistory resolves "!!" and "!<n>" against the history.
+func (s *stdinConsole) expandHistory(line string) (string, error) {
+	if len(s.history) == 0 {
+		return "", fmt.Errorf("History is empty")
+	}
+	if line == "!!" {
+		return s.history[len(s.history)-1], nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > len(s.history) {
+		return "", fmt.Errorf("No history entry %s", line[1:])
+	}
+	return s.history[n-1], nil
+}
+
+// completions returns the candidates for the last word of prefix: command
+// names for the first word, connected users after it.
+func completions(prefix string) []string {
+	fields := strings.Fields(prefix)
+	word := ""
+	if len(fields) > 0 && !strings.HasSuffix(prefix, " ") {
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	candidates := consoleCommands
+	if len(fields) > 0 {
+		candidates = nil
+		for _, name := range GetClients() {
+			candidates = append(candidates, name)
+		}
+		sort.Strings(candidates)
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			matches = append(matches, strings.Join(append(fields, candidate), " "))
+		}
+	}
+	return matches
+}
+
+// showCompletions prints the completions of prefix.
+func (s *stdinConsole) showCompletions(prefix string) {
+	matches := completions(prefix)
+	if len(matches) == 0 {
+		fmt.Fprintln(s.out, "No completions")
+		return
+	}
+	for _, match := range matches {
+		fmt.Fprintln(s.out, match)
+	}
+}
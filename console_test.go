@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunStdinConsole(t *testing.T) {
+	input := strings.Join([]string{
+		"stats",
+		"!!",
+		"history",
+		"un\t",
+		"!9",
+		"quit",
+		"drain",
+	}, "\n")
+	var out strings.Builder
+	runStdinConsole(strings.NewReader(input), &out)
+
+	got := out.String()
+	for _, want := range []string{"Uptime:", "   1  stats", "unban\nundrain\nunmute\n", "No history entry 9", "Server console closed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, got)
+		}
+	}
+	if strings.Count(got, "Uptime:") != 2 {
+		t.Errorf("Expected !! to repeat stats, got %q", got)
+	}
+	if draining.Load() {
+		t.Error("Expected commands after quit to be ignored")
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"ba", []string{"ban", "banlist"}},
+		{"STA", []string{"stats"}},
+		{"xyz", nil},
+	}
+	for _, tt := range tests {
+		if got := completions(tt.prefix); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completions(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestRunTerminalConsole(t *testing.T) {
+	input := "sta\t\r\x1b[A\r\x04"
+	var out strings.Builder
+	runTerminalConsole(strings.NewReader(input), &out)
+
+	got := out.String()
+	if strings.Count(got, "Uptime:") != 2 {
+		t.Errorf("Expected Tab to complete stats and Up to recall it, got %q", got)
+	}
+	if !strings.HasSuffix(got, "Server console closed\n") {
+		t.Errorf("Expected Ctrl-D to close the console, got %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day
+// of month, month and day of week. Each field is the set of values it
+// matches.
+type cronSchedule struct {
+	minute, hour, day, month, weekday map[int]bool
+	anyDay, anyWeekday                bool // Whether the day fields were *
+}
+
+// cronFields names the fields of a cron expression with their ranges.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// parseCron reads a cron expression such as "0 2 * * *" (every night at
+// 02:00) or "*/15 9-17 * * 1-5". Fields take *, numbers, ranges a-b,
+// steps */n or a-b/n and comma-separated lists of those. Sunday is 0 or
+// 7 in the day of week.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q needs %d fields", expr, len(cronFields))
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		max := cronFields[i].max
+		if i == 4 {
+			max = 7 // Sunday written as 7
+		}
+		set, err := parseCronField(field, cronFields[i].min, max)
+		if err != nil {
+			return nil, fmt.Errorf("cron %s %q: %w", cronFields[i].name, field, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		delete(sets[4], 7)
+		sets[4][0] = true
+	}
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], day: sets[2], month: sets[3], weekday: sets[4],
+		anyDay: fields[2] == "*", anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField returns the values between min and max that field
+// matches.
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(first); err != nil {
+				return nil, fmt.Errorf("invalid value %q", first)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(last); err != nil {
+					return nil, fmt.Errorf("invalid value %q", last)
+				}
+			} else if stepped {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("values must lie between %d and %d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether t falls in a minute the schedule selects.
+func (s *cronSchedule) matches(t time.Time) bool {
+	return s.minute[t.Minute()] && s.hour[t.Hour()] && s.month[int(t.Month())] && s.dayMatches(t)
+}
+
+// dayMatches reports whether the day of t is one the schedule selects. As
+// in cron, when both day fields are restricted a day matching either
+// counts.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	day, weekday := s.day[t.Day()], s.weekday[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// next returns the first minute after t the schedule selects, or the zero
+// time when none comes within five years, as with February 30th.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	t.Parallel()
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("Expected %q to be refused", expr)
+		}
+	}
+	s, err := parseCron("*/15 9-17 * * 1-5,7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(s.minute) != 4 || !s.minute[45] || len(s.hour) != 9 || !s.weekday[0] || s.weekday[6] {
+		t.Errorf("Unexpected sets: %+v", s)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	t.Parallel()
+	// 2026-03-04 is a Wednesday
+	from := time.Date(2026, 3, 4, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 4, 10, 8, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 3, 5, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * 1", time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{"30 8 1 * *", time.Date(2026, 4, 1, 8, 30, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)}, // Friday or the 13th
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		got := s.next(from)
+		if !got.Equal(tt.want) {
+			t.Errorf("next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+		if !got.IsZero() && !s.matches(got) {
+			t.Errorf("Expected %q to match its next time %v", tt.expr, got)
+		}
+	}
+}
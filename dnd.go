@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// dndNotice tells a sender that name turns private messages away.
+func dndNotice(name string) string {
+	return fmt.Sprintf("%s is not taking private messages right now (do not disturb), please try again later", name)
+}
+
+// handleDNDCommand processes "/dnd [on|off]", which turns away private
+// messages to the client while room chat keeps flowing. Without an
+// argument it toggles the mode.
+func handleDNDCommand(c *client, call commandCall) {
+	on := !c.dnd.Load()
+	switch {
+	case len(call.args) == 0:
+	case len(call.args) == 1 && call.args[0] == "on":
+		on = true
+	case len(call.args) == 1 && call.args[0] == "off":
+		on = false
+	default:
+		c.send("Usage: /dnd [on|off]")
+		return
+	}
+
+	c.dnd.Store(on)
+	if on {
+		log.Printf("%s turned do not disturb on", c.name)
+		c.send("Do not disturb is on: private messages to you are turned away, /dnd off to receive them again")
+		notifyPresence(c.name, "turned on do not disturb")
+	} else {
+		log.Printf("%s turned do not disturb off", c.name)
+		c.send("Do not disturb is off")
+		notifyPresence(c.name, "is available again")
+	}
+}
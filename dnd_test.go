@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDNDCommand(t *testing.T) {
+	senderConn, busyConn := newMockConn(), newMockConn()
+	sender := &client{conn: senderConn, name: "dnd-sender", room: "#dnd"}
+	busy := &client{conn: busyConn, name: "dnd-busy", room: "#dnd"}
+	mutex.Lock()
+	rooms["#dnd"] = &room{name: "#dnd"}
+	clients[senderConn], clients[busyConn] = sender, busy
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, "#dnd")
+		delete(clients, senderConn)
+		delete(clients, busyConn)
+		mutex.Unlock()
+	}()
+
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"/dnd", true},
+		{"/dnd", false},
+		{"/dnd off", false},
+		{"/dnd on", true},
+		{"/dnd maybe", true},
+	}
+	for _, tt := range tests {
+		handleDNDCommand(busy, callOf(tt.message))
+		if got := busy.dnd.Load(); got != tt.want {
+			t.Errorf("After %q do not disturb is %t, want %t", tt.message, got, tt.want)
+		}
+	}
+	if !strings.Contains(busyConn.writeBuffer.String(), "Usage: /dnd [on|off]") {
+		t.Error("Expected an invalid argument to be answered with the usage")
+	}
+
+	busyConn.writeBuffer.Reset()
+	handlePrivateMessage(sender, callOf("/msg dnd-busy got a minute?"))
+	if got := busyConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected no private message, got %q", got)
+	}
+	if got := senderConn.writeBuffer.String(); got != dndNotice("dnd-busy")+"\n" {
+		t.Errorf("Sender got %q, want the do not disturb notice", got)
+	}
+
+	postToRoom("#dnd", "dnd-sender: standup in 5", senderConn, time.Now())
+	if got := busyConn.writeBuffer.String(); !strings.Contains(got, "dnd-sender: standup in 5") {
+		t.Errorf("Expected room chat to flow, got %q", got)
+	}
+
+	senderConn.writeBuffer.Reset()
+	handleListCommand(sender)
+	if got := senderConn.writeBuffer.String(); !strings.Contains(got, "dnd-busy (dnd)") {
+		t.Errorf("Expected /list to mark the user, got %q", got)
+	}
+}
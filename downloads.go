@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// downloadTTL is how long an offered download stays available.
+const downloadTTL = 10 * time.Minute
+
+var errDownloadsDisabled = errors.New("Downloads are not available on this server")
+
+// download is a file handed to a client out of band through the HTTP
+// endpoints. It can be fetched once, within downloadTTL.
+type download struct {
+	filename    string
+	contentType string
+	data        []byte
+}
+
+var downloads = struct {
+	sync.Mutex
+	files map[string]download
+}{files: make(map[string]download)}
+
+// downloadBaseURL returns the address clients use to reach the HTTP
+// endpoints, or "" when they are disabled.
+func downloadBaseURL() string {
+	if config.PublicURL != "" {
+		return strings.TrimRight(config.PublicURL, "/")
+	}
+	if config.HTTPAddr == "" {
+		return ""
+	}
+	return "http://" + config.HTTPAddr
+}
+
+// offerDownload stores a file and returns the one-time URL to fetch it.
+func offerDownload(filename, contentType string, data []byte) (string, error) {
+	base := downloadBaseURL()
+	if base == "" {
+		return "", errDownloadsDisabled
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	downloads.Lock()
+	downloads.files[token] = download{filename: filename, contentType: contentType, data: data}
+	downloads.Unlock()
+	jobs.schedule("download:"+token, time.Now().Add(downloadTTL), func() { takeDownload(token) })
+
+	return base + "/download/" + token, nil
+}
+
+// takeDownload removes and returns the file offered under token.
+func takeDownload(token string) (download, bool) {
+	downloads.Lock()
+	defer downloads.Unlock()
+
+	d, ok := downloads.files[token]
+	delete(downloads.files, token)
+	return d, ok
+}
+
+// serveDownload answers GET /download/<token>.
+func serveDownload(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/download/")
+	d, ok := takeDownload(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	jobs.cancel("download:" + token)
+	w.Header().Set("Content-Type", d.contentType)
+	// Filenames carry user names, which may hold quotes or semicolons
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": d.filename}))
+	w.Write(d.data)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"mime"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOfferDownload(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+
+	config.HTTPAddr = ""
+	if _, err := offerDownload("a.txt", "text/plain", []byte("hi")); err != errDownloadsDisabled {
+		t.Errorf("Expected downloads to be disabled without http_addr, got %v", err)
+	}
+
+	config.HTTPAddr, config.PublicURL = "127.0.0.1:8990", "https://chat.example.com/"
+	const name = `pm-a"b;c.txt`
+	url, err := offerDownload(name, "text/plain", []byte("hi"))
+	if err != nil {
+		t.Fatalf("offerDownload: %v", err)
+	}
+	if !strings.HasPrefix(url, "https://chat.example.com/download/") {
+		t.Fatalf("Expected URL under the public URL, got %q", url)
+	}
+	path := strings.TrimPrefix(url, "https://chat.example.com")
+
+	rec := httptest.NewRecorder()
+	serveDownload(rec, httptest.NewRequest("GET", path, nil))
+	if rec.Code != 200 || rec.Body.String() != "hi" {
+		t.Errorf("Expected the file, got %d %q", rec.Code, rec.Body.String())
+	}
+	disposition := rec.Header().Get("Content-Disposition")
+	if kind, params, err := mime.ParseMediaType(disposition); err != nil || kind != "attachment" || params["filename"] != name {
+		t.Errorf("Expected an attachment named %q, got %q", name, disposition)
+	}
+
+	rec = httptest.NewRecorder()
+	serveDownload(rec, httptest.NewRequest("GET", path, nil))
+	if rec.Code != 404 {
+		t.Errorf("Expected a second download to fail, got %d", rec.Code)
+	}
+}
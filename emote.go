@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// actionPrefix starts the lines of action messages sent with /me:
+// "* alice waves".
+const actionPrefix = "* "
+
+// formatAction builds the line of an action message.
+func formatAction(sender, text string) (string, error) {
+	if isReservedName(sender) {
+		return "", errReservedName
+	}
+	return actionPrefix + sender + " " + text, nil
+}
+
+// splitAction separates an action line into its sender and text.
+func splitAction(line string) (sender, text string, ok bool) {
+	rest, ok := strings.CutPrefix(line, actionPrefix)
+	if !ok {
+		return "", "", false
+	}
+	sender, text, ok = strings.Cut(rest, " ")
+	return sender, text, ok && sender != ""
+}
+
+// splitAuthor separates a chat line or an action line into the name of
+// its author and the text.
+func splitAuthor(line string) (author, text string, ok bool) {
+	if sender, text, ok := splitAction(line); ok {
+		return sender, text, true
+	}
+	if author, text, ok := strings.Cut(line, ": "); ok {
+		return author, text, true
+	}
+	return "", "", false
+}
+
+// writtenBy reports whether a chat or action line was written by name.
+func writtenBy(line, name string) bool {
+	author, _, ok := splitAuthor(line)
+	return ok && author == name
+}
+
+// handleMeCommand processes "/me <action>", which posts "* <name>
+// <action>" to the client's room. Actions pass the same checks as chat
+// messages and are kept in the room history in that form.
+func handleMeCommand(c *client, call commandCall) {
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+	text, ok := screenChatMessage(c, call.text, call.received)
+	if !ok {
+		return
+	}
+	line, err := formatAction(c.name, text)
+	if err != nil {
+		log.Printf("Refusing action from %s: %v", c.name, err)
+		return
+	}
+	publishChatMessage(c, line, text, call.received)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitAuthor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line       string
+		wantAuthor string
+		wantText   string
+		wantOK     bool
+	}{
+		{"alice: hi there", "alice", "hi there", true},
+		{"* alice waves", "alice", "waves", true},
+		{"* alice", "", "", false},
+		{"alice: * bob waves", "alice", "* bob waves", true},
+		{"no author here", "", "", false},
+	}
+	for _, tt := range tests {
+		author, text, ok := splitAuthor(tt.line)
+		if author != tt.wantAuthor || text != tt.wantText || ok != tt.wantOK {
+			t.Errorf("splitAuthor(%q) = %q, %q, %t, want %q, %q, %t", tt.line, author, text, ok, tt.wantAuthor, tt.wantText, tt.wantOK)
+		}
+	}
+}
+
+func TestMeCommand(t *testing.T) {
+	const name = "#me-test"
+	actorConn, watcherConn := newMockConn(), newMockConn()
+	actor := &client{conn: actorConn, name: "me-actor", room: name}
+	watcher := &client{conn: watcherConn, name: "me-watcher", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[actorConn], clients[watcherConn] = actor, watcher
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, actorConn)
+		delete(clients, watcherConn)
+		mutex.Unlock()
+	}()
+
+	if !handleCommand(actor, "/me", time.Now()) || actorConn.writeBuffer.String() != "Usage: /me <action>\n" {
+		t.Errorf("Expected the usage, got %q", actorConn.writeBuffer.String())
+	}
+
+	handleCommand(actor, "/me waves at @me-watcher", time.Now())
+	if got := watcherConn.writeBuffer.String(); !strings.HasSuffix(got, "] * me-actor waves at @me-watcher\n") {
+		t.Errorf("Expected the stamped action, got %q", got)
+	}
+	entries := roomHistoryEntries(name)
+	if len(entries) != 1 || entries[0].line != "* me-actor waves at @me-watcher" {
+		t.Errorf("Expected the action in the history, got %+v", entries)
+	}
+	watcher.caps.set(capMentions, true)
+	if got := watcher.markMention(entries[0].line); !strings.HasPrefix(got, mentionTag) {
+		t.Errorf("Expected the action to flag the mention, got %q", got)
+	}
+
+	// Actions are chat messages as far as the checks go
+	watcherConn.writeBuffer.Reset()
+	muteClient(actor, "test", 0)
+	defer unmuteClient(actor)
+	actorConn.writeBuffer.Reset()
+	handleCommand(actor, "/me shouts", time.Now())
+	if got := actorConn.writeBuffer.String(); got != "You are muted and cannot send messages\n" {
+		t.Errorf("Expected muted users to be refused, got %q", got)
+	}
+	if got := watcherConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected nothing posted, got %q", got)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Wire encodings a client can pick with ENCODING= in the handshake.
+const (
+	encodingText     = "text"     // Plain lines, the default
+	encodingJSON     = "json"     // One JSON object per line in both directions
+	encodingProtobuf = "protobuf" // Length-prefixed protobuf envelopes, see proto/chat.proto
+)
+
+// encodings lists the wire encodings the server speaks.
+var encodings = []string{encodingText, encodingJSON, encodingProtobuf}
+
+var (
+	errUnsupportedEncoding = errors.New("Unsupported encoding")
+	errMultilineBody       = errors.New("Message bodies cannot contain newlines")
+	errMessageType         = errors.New("Unknown message type")
+)
+
+// wireMessage is one message of a structured encoding. Servers fill in
+// every field that applies; clients only send type, body and, for
+// private messages, to.
+type wireMessage struct {
+	Type      string    `json:"type"`
+	Sender    string    `json:"sender,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Room      string    `json:"room,omitempty"`
+	Body      string    `json:"body"`
+	Timestamp time.Time `json:"timestamp"`
+	ID        uint64    `json:"id,omitempty"`      // Message ID of broadcast messages, see msgid.go
+	Seq       uint64    `json:"seq"`               // Number of the message in the session, from 1
+	Mention   bool      `json:"mention,omitempty"` // The chat message mentions the recipient, see mentions.go
+}
+
+// codec reads and writes the messages of one structured encoding.
+type codec interface {
+	// encode returns msg framed for the wire.
+	encode(msg wireMessage) ([]byte, error)
+	// decode reads the next message of the client. Messages that cannot
+	// be understood are reported as a badMessage.
+	decode() (wireMessage, error)
+}
+
+// badMessage is a message from the client that could not be understood.
+// It is answered with an error and skipped; the session goes on.
+type badMessage struct{ err error }
+
+func (b badMessage) Error() string { return b.err.Error() }
+
+// encodedConn speaks a structured encoding on behalf of the plain-text
+// session code: lines written to it go out as messages and messages read
+// from it come in as the lines a text client would send.
+type encodedConn struct {
+	net.Conn
+	codec   codec
+	pending []byte // Translated input not read yet
+	seq     atomic.Uint64
+	writeMu sync.Mutex // Keeps error replies from the reading side whole
+
+	mu   sync.Mutex
+	room string // Room the client is in, for the room field of chat lines
+}
+
+// newEncodedConn wraps conn in the named encoding, which must be one of
+// the structured ones.
+func newEncodedConn(conn net.Conn, encoding string) *encodedConn {
+	reader := bufio.NewReader(conn)
+	e := &encodedConn{Conn: conn, room: defaultRoom}
+	switch encoding {
+	case encodingJSON:
+		e.codec = newJSONCodec(reader)
+	case encodingProtobuf:
+		e.codec = newProtobufCodec(reader)
+	}
+	return e
+}
+
+func (e *encodedConn) setRoom(room string) {
+	e.mu.Lock()
+	e.room = room
+	e.mu.Unlock()
+}
+
+func (e *encodedConn) currentRoom() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.room
+}
+
+// Write encodes every line in p as a message, leaving out blank lines.
+// Text after the last newline is a prompt waiting for an answer and goes
+// out as one.
+func (e *encodedConn) Write(p []byte) (int, error) {
+	var out []byte
+	text := string(p)
+	for text != "" {
+		line, rest, complete := strings.Cut(text, "\n")
+		text = rest
+		if complete && line == "" {
+			continue // Spacing for people reading
+		}
+		id, line := splitIDTag(line)
+		mention, line := splitMentionTag(line)
+		posted, line := splitTimestamp(line)
+		msg := lineMessage(line, e.currentRoom())
+		if !complete {
+			msg = wireMessage{Type: "prompt", Body: line}
+		}
+		msg.Timestamp = time.Now().UTC()
+		if !posted.IsZero() {
+			msg.Timestamp = posted // Chat messages carry the time they were posted
+		}
+		msg.ID, msg.Seq, msg.Mention = id, e.seq.Add(1), mention
+		encoded, err := e.codec.encode(msg)
+		if err != nil {
+			return 0, err
+		}
+		out = append(out, encoded...)
+	}
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	if err := writeFull(e.Conn, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read returns the decoded lines of the client. Messages that are not
+// understood are answered with an error and skipped.
+func (e *encodedConn) Read(p []byte) (int, error) {
+	for len(e.pending) == 0 {
+		msg, err := e.codec.decode()
+		var bad badMessage
+		if errors.As(err, &bad) {
+			e.writeError(bad.err)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		line, err := clientLine(msg)
+		if err != nil {
+			e.writeError(err)
+			continue
+		}
+		e.pending = []byte(line + "\n")
+	}
+	n := copy(p, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+func (e *encodedConn) writeError(err error) {
+	encoded, _ := e.codec.encode(wireMessage{Type: "error", Body: err.Error(), Timestamp: time.Now().UTC(), Seq: e.seq.Add(1)})
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	writeFull(e.Conn, encoded)
+}
+
+// clientLine turns a message from the client into the line a text client
+// would have sent.
+func clientLine(msg wireMessage) (string, error) {
+	if strings.ContainsAny(msg.Body, "\r\n") {
+		return "", errMultilineBody
+	}
+	switch msg.Type {
+	case "chat", "name":
+		return msg.Body, nil
+	case "command":
+		if !strings.HasPrefix(msg.Body, "/") {
+			return "", errMessageType
+		}
+		return msg.Body, nil
+	case "private":
+		if msg.To == "" || strings.ContainsAny(msg.To, " \r\n") {
+			return "", errMessageType
+		}
+		return "/msg " + msg.To + " " + msg.Body, nil
+	case "action":
+		return "/me " + msg.Body, nil
+	case "ping", "pong":
+		return strings.TrimSpace(strings.ToUpper(msg.Type) + " " + msg.Body), nil
+	case "join":
+		if msg.Room == "" || strings.ContainsAny(msg.Room, " \r\n") {
+			return "", errMessageType
+		}
+		return "/join " + msg.Room, nil
+	}
+	return "", errMessageType
+}
+
+// lineMessage describes a line of the text protocol as a message, using
+// the event names of the protocol description as types. Lines that match
+// no event are notices.
+func lineMessage(line, room string) wireMessage {
+	if rest, ok := strings.CutPrefix(line, "[PM from "); ok {
+		if sender, body, ok := strings.Cut(rest, "]: "); ok {
+			return wireMessage{Type: "private", Sender: sender, Body: body}
+		}
+	}
+	if rest, ok := strings.CutPrefix(line, "[PM to "); ok {
+		if to, body, ok := strings.Cut(rest, "]: "); ok {
+			return wireMessage{Type: "private_echo", To: to, Body: body}
+		}
+	}
+	if body, ok := strings.CutPrefix(line, systemSender+": "); ok {
+		return wireMessage{Type: "system", Sender: systemSender, Room: room, Body: body}
+	}
+	if token, ok := heartbeatToken(line, pingLine); ok {
+		return wireMessage{Type: "ping", Body: token}
+	}
+	if token, ok := heartbeatToken(line, pongLine); ok {
+		return wireMessage{Type: "pong", Body: token}
+	}
+	if token, ok := strings.CutPrefix(line, "SESSION "); ok {
+		return wireMessage{Type: "session", Body: token}
+	}
+	if sender, ok := strings.CutPrefix(line, "TYPING "); ok {
+		return wireMessage{Type: "typing", Sender: sender, Room: room}
+	}
+	if replayed, ok := strings.CutPrefix(line, "REPLAY END "); ok {
+		return wireMessage{Type: "replay_end", Room: replayed}
+	}
+	if fields := strings.Fields(line); len(fields) == 3 && fields[0] == "REPLAY" {
+		return wireMessage{Type: "replay_start", Room: fields[1], Body: fields[2]}
+	}
+	if rest, ok := strings.CutPrefix(line, "CAP "); ok {
+		verb, body, _ := strings.Cut(rest, " ")
+		return wireMessage{Type: "cap_" + strings.ToLower(verb), Body: body}
+	}
+	if strings.HasPrefix(line, protocolName+"/") && strings.Contains(line, " SUPPORTED ") {
+		return wireMessage{Type: "version", Body: line}
+	}
+	if body, ok := strings.CutPrefix(line, "Connected users: "); ok {
+		return wireMessage{Type: "user_list", Body: body}
+	}
+	if sender, body, ok := splitAction(line); ok {
+		return wireMessage{Type: "action", Sender: sender, Room: room, Body: body}
+	}
+	if sender, body, ok := strings.Cut(line, ": "); ok && sender != "" && !strings.ContainsAny(sender, " []") {
+		return wireMessage{Type: "chat", Sender: sender, Room: room, Body: body}
+	}
+	return wireMessage{Type: "notice", Body: line}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestLineMessage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line string
+		want wireMessage
+	}{
+		{"alice: hello there", wireMessage{Type: "chat", Sender: "alice", Room: "#general", Body: "hello there"}},
+		{"SERVER: bob has joined our chat...", wireMessage{Type: "system", Sender: "SERVER", Room: "#general", Body: "bob has joined our chat..."}},
+		{"[PM from bob]: psst", wireMessage{Type: "private", Sender: "bob", Body: "psst"}},
+		{"[PM to bob]: psst", wireMessage{Type: "private_echo", To: "bob", Body: "psst"}},
+		{"* bob waves at everyone", wireMessage{Type: "action", Sender: "bob", Room: "#general", Body: "waves at everyone"}},
+		{"TYPING bob", wireMessage{Type: "typing", Sender: "bob", Room: "#general"}},
+		{"PING 1700000000000", wireMessage{Type: "ping", Body: "1700000000000"}},
+		{"PONG", wireMessage{Type: "pong"}},
+		{"REPLAY #dev 3", wireMessage{Type: "replay_start", Room: "#dev", Body: "3"}},
+		{"REPLAY END #dev", wireMessage{Type: "replay_end", Room: "#dev"}},
+		{"CAP LS typing replay", wireMessage{Type: "cap_ls", Body: "typing replay"}},
+		{"CHAT/1.0 SUPPORTED 1.0", wireMessage{Type: "version", Body: "CHAT/1.0 SUPPORTED 1.0"}},
+		{"Connected users: alice, bob", wireMessage{Type: "user_list", Body: "alice, bob"}},
+		{"Welcome, alice!", wireMessage{Type: "notice", Body: "Welcome, alice!"}},
+	}
+	for _, tt := range tests {
+		if got := lineMessage(tt.line, "#general"); got != tt.want {
+			t.Errorf("lineMessage(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestClientLine(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		msg     wireMessage
+		want    string
+		wantErr error
+	}{
+		{wireMessage{Type: "name", Body: "alice"}, "alice", nil},
+		{wireMessage{Type: "chat", Body: "hello"}, "hello", nil},
+		{wireMessage{Type: "command", Body: "/list"}, "/list", nil},
+		{wireMessage{Type: "private", To: "bob", Body: "psst"}, "/msg bob psst", nil},
+		{wireMessage{Type: "join", Room: "#dev"}, "/join #dev", nil},
+		{wireMessage{Type: "action", Body: "waves"}, "/me waves", nil},
+		{wireMessage{Type: "ping", Body: "7"}, "PING 7", nil},
+		{wireMessage{Type: "pong"}, "PONG", nil},
+		{wireMessage{Type: "command", Body: "list"}, "", errMessageType},
+		{wireMessage{Type: "private", Body: "psst"}, "", errMessageType},
+		{wireMessage{Type: "join", Room: "#dev extra"}, "", errMessageType},
+		{wireMessage{Type: "shout", Body: "hi"}, "", errMessageType},
+		{wireMessage{Type: "chat", Body: "one\ntwo"}, "", errMultilineBody},
+	}
+	for _, tt := range tests {
+		got, err := clientLine(tt.msg)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("clientLine(%+v) = %q, %v, want %q, %v", tt.msg, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEncodedConn(t *testing.T) {
+	t.Parallel()
+	server, peer := net.Pipe()
+	defer peer.Close()
+	conn := newEncodedConn(server, encodingJSON)
+	defer conn.Close()
+	conn.setRoom("#dev")
+	received := bufio.NewReader(peer)
+
+	expect := func(want wireMessage) {
+		t.Helper()
+		line, err := received.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading: %v", err)
+		}
+		var got wireMessage
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("Invalid JSON %q: %v", line, err)
+		}
+		if got.Timestamp.IsZero() || got.Seq == 0 {
+			t.Errorf("Expected a timestamp and a sequence number in %q", line)
+		}
+		got.Timestamp, got.Seq = want.Timestamp, want.Seq
+		if got != want {
+			t.Errorf("Received %+v, want %+v", got, want)
+		}
+	}
+
+	// Lines and a trailing prompt go out as separate objects, blank lines not at all
+	go conn.Write([]byte("alice: hi\n\n[ENTER YOUR NAME]: "))
+	expect(wireMessage{Type: "chat", Sender: "alice", Room: "#dev", Body: "hi"})
+	expect(wireMessage{Type: "prompt", Body: "[ENTER YOUR NAME]: "})
+
+	// Tags turn into fields
+	go conn.Write([]byte(tagID(7, mentionTag+"bob: hey @alice") + "\n"))
+	expect(wireMessage{Type: "chat", Sender: "bob", Room: "#dev", Body: "hey @alice", ID: 7, Mention: true})
+
+	// Invalid input is answered with an error and skipped
+	go peer.Write([]byte("not json\n" + `{"type":"command","body":"/list"}` + "\n"))
+	decoded := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		decoded <- line
+	}()
+	expect(wireMessage{Type: "error", Body: errInvalidJSON.Error()})
+	if line := <-decoded; line != "/list\n" {
+		t.Errorf("Read %q, want %q", line, "/list\n")
+	}
+}
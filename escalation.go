@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Escalation configures the webhook triggered when a mentioned group does
+// not answer in the room in time.
+type Escalation struct {
+	WebhookURL string `json:"webhook_url"` // Endpoint receiving the alert
+	RoutingKey string `json:"routing_key"` // Integration key sent with the alert
+	After      int    `json:"after"`       // Minutes to wait for a member to respond
+	Severity   string `json:"severity"`    // Alert severity, "critical" when empty
+}
+
+// alertPayload follows the PagerDuty Events v2 trigger format, which
+// Opsgenie and most alerting tools accept as well.
+type alertPayload struct {
+	RoutingKey  string       `json:"routing_key"`
+	EventAction string       `json:"event_action"`
+	DedupKey    string       `json:"dedup_key"`
+	Payload     alertDetails `json:"payload"`
+}
+
+type alertDetails struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     time.Time         `json:"timestamp"`
+	CustomDetails map[string]string `json:"custom_details"`
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// escalationJobKey names the scheduler job waiting for group to respond
+// in room.
+func escalationJobKey(group, room string) string {
+	return "escalate:" + group + ":" + room
+}
+
+// scheduleEscalations starts the response timer of every mentioned group
+// that has an escalation configured. A pending timer for the same group and
+// room is left running so repeated pings do not postpone the alert.
+func scheduleEscalations(sender *client, room, message string) {
+	for _, group := range mentionedGroups(message) {
+		esc, ok := config.Escalations[group]
+		if !ok || esc.WebhookURL == "" {
+			continue
+		}
+		key := escalationJobKey(group, room)
+		if jobs.pending(key) {
+			continue
+		}
+		after := time.Duration(esc.After) * time.Minute
+		sent, name := time.Now(), sender.name
+		jobs.schedule(key, sent.Add(after), func() {
+			log.Printf("No @%s member answered in %s, escalating", group, room)
+			if err := triggerEscalation(esc, group, room, name, message, sent); err != nil {
+				log.Printf("Error escalating @%s: %v", group, err)
+			}
+		})
+	}
+}
+
+// resolveEscalations cancels the pending escalations of every group c
+// belongs to in room, since a member has now responded there.
+func resolveEscalations(c *client, room string) {
+	for group := range config.Escalations {
+		members, _ := groups.members(group)
+		for _, member := range members {
+			if member == c.name {
+				if jobs.cancel(escalationJobKey(group, room)) {
+					log.Printf("%s answered for @%s in %s", c.name, group, room)
+				}
+				break
+			}
+		}
+	}
+}
+
+// triggerEscalation posts the alert for an unanswered group mention.
+func triggerEscalation(esc Escalation, group, room, sender, message string, sent time.Time) error {
+	severity := esc.Severity
+	if severity == "" {
+		severity = "critical"
+	}
+	alert := alertPayload{
+		RoutingKey:  esc.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    fmt.Sprintf("tcp_chat-%s-%s-%d", group, room, sent.Unix()),
+		Payload: alertDetails{
+			Summary:   fmt.Sprintf("@%s mentioned by %s in %s without response: %s", group, sender, room, message),
+			Source:    "tcp_chat",
+			Severity:  severity,
+			Timestamp: sent,
+			CustomDetails: map[string]string{
+				"group":   group,
+				"room":    room,
+				"sender":  sender,
+				"message": message,
+			},
+		},
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(esc.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTriggerEscalation(t *testing.T) {
+	t.Parallel()
+	received := make(chan alertPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert alertPayload
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("Invalid payload: %v", err)
+		}
+		received <- alert
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	esc := Escalation{WebhookURL: server.URL, RoutingKey: "key123"}
+	if err := triggerEscalation(esc, "oncall", "#ops", "alice", "db down", time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	alert := <-received
+	if alert.RoutingKey != "key123" || alert.EventAction != "trigger" {
+		t.Errorf("Unexpected alert envelope: %+v", alert)
+	}
+	if alert.Payload.Severity != "critical" || alert.Payload.CustomDetails["room"] != "#ops" {
+		t.Errorf("Unexpected alert details: %+v", alert.Payload)
+	}
+}
+
+func TestTriggerEscalationError(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	esc := Escalation{WebhookURL: server.URL}
+	if err := triggerEscalation(esc, "oncall", "#ops", "alice", "db down", time.Now()); err == nil {
+		t.Error("Expected an error for a rejected webhook")
+	}
+}
+
+func TestResolveEscalations(t *testing.T) {
+	oldGroups, oldConfig := groups, config
+	defer func() { groups, config = oldGroups, oldConfig }()
+	groups = newGroupStore("")
+	groups.create("oncall")
+	groups.addMember("oncall", "bob")
+	config.Escalations = map[string]Escalation{"oncall": {WebhookURL: "http://127.0.0.1:0", After: 5}}
+
+	alice := &client{conn: newMockConn(), name: "alice"}
+	scheduleEscalations(alice, "#ops", "help @oncall")
+	key := escalationJobKey("oncall", "#ops")
+	if !jobs.pending(key) {
+		t.Fatal("Expected escalation to be scheduled")
+	}
+
+	resolveEscalations(alice, "#ops")
+	if !jobs.pending(key) {
+		t.Error("Expected a non-member reply to leave the escalation pending")
+	}
+	resolveEscalations(&client{conn: newMockConn(), name: "bob"}, "#ops")
+	if jobs.pending(key) {
+		t.Error("Expected a member reply to cancel the escalation")
+	}
+}
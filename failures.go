@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Kinds of failed attempts tracked per address.
+const (
+	failureHandshake = "handshake" // Invalid or missing protocol handshake
+	failureAuth      = "auth"      // Wrong /oper or admin console password
+)
+
+const (
+	handshakeTimeout = 10 * time.Second       // Time allowed to send the handshake
+	failureDelayStep = 250 * time.Millisecond // Delay before answering the first failure, doubled per failure
+	maxFailureDelay  = 5 * time.Second
+	maxFailureBlock  = time.Hour
+	maxFailureIPs    = 10000 // Tracked addresses before stale ones are pruned
+)
+
+// FailureSettings controls the throttling of addresses that keep failing
+// the handshake or authentication.
+type FailureSettings struct {
+	Limit  int `json:"limit"`  // Failures within the window that block the address, 0 disables blocking
+	Window int `json:"window"` // Seconds a failure is remembered
+	Block  int `json:"block"`  // Seconds of the first block, doubled for each further block up to an hour
+}
+
+// failureRecord is the recent history of one address.
+type failureRecord struct {
+	count        int
+	last         time.Time
+	blocks       int
+	blockedUntil time.Time
+}
+
+// failureTracker counts failed attempts per address, answering them with
+// escalating delays and blocking addresses that fail too often.
+type failureTracker struct {
+	mu      sync.Mutex
+	records map[string]*failureRecord
+	totals  map[string]uint64 // Failures by kind
+	refused uint64            // Connections refused while blocked
+}
+
+var failures = newFailureTracker() // Failed attempts of all listeners
+
+func newFailureTracker() *failureTracker {
+	return &failureTracker{records: make(map[string]*failureRecord), totals: make(map[string]uint64)}
+}
+
+// record notes a failure of the given kind from ip at now. It returns how
+// long to wait before answering and whether the address is now blocked.
+func (t *failureTracker) record(ip, kind string, now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.totals[kind]++
+	settings := config.Failures
+	window := time.Duration(settings.Window) * time.Second
+	r, ok := t.records[ip]
+	if !ok {
+		if len(t.records) >= maxFailureIPs {
+			t.prune(now, window)
+		}
+		r = &failureRecord{}
+		t.records[ip] = r
+	}
+	if now.Sub(r.last) > window {
+		r.count = 0
+		if now.After(r.blockedUntil.Add(window)) {
+			r.blocks = 0 // Well behaved since the last block
+		}
+	}
+	r.count++
+	r.last = now
+
+	delay := maxFailureDelay
+	if r.count <= 10 && failureDelayStep<<(r.count-1) < maxFailureDelay {
+		delay = failureDelayStep << (r.count - 1)
+	}
+	if settings.Limit <= 0 || r.count < settings.Limit {
+		return delay, false
+	}
+
+	block := time.Duration(settings.Block) * time.Second
+	for i := 0; i < r.blocks && block < maxFailureBlock; i++ {
+		block *= 2
+	}
+	if block > maxFailureBlock {
+		block = maxFailureBlock
+	}
+	r.blocks++
+	r.count = 0
+	r.blockedUntil = now.Add(block)
+	return delay, true
+}
+
+// isBlocked reports whether ip is blocked at now, counting the refusal.
+func (t *failureTracker) isBlocked(ip string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.records[ip]
+	if !ok || !now.Before(r.blockedUntil) {
+		return false
+	}
+	t.refused++
+	return true
+}
+
+// prune forgets addresses without recent failures or an active block. The
+// caller must hold t.mu.
+func (t *failureTracker) prune(now time.Time, window time.Duration) {
+	for ip, r := range t.records {
+		if now.Sub(r.last) > window && now.After(r.blockedUntil) {
+			delete(t.records, ip)
+		}
+	}
+}
+
+// counters returns the failures by kind and the refused connections.
+func (t *failureTracker) counters() (map[string]uint64, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	totals := make(map[string]uint64, len(t.totals))
+	for kind, n := range t.totals {
+		totals[kind] = n
+	}
+	return totals, t.refused
+}
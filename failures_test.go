@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailureTracker(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.Failures = FailureSettings{Limit: 3, Window: 60, Block: 10}
+
+	tracker := newFailureTracker()
+	const ip = "198.51.100.1"
+	now := time.Now()
+
+	var delays []time.Duration
+	for i := 0; i < 2; i++ {
+		delay, blocked := tracker.record(ip, failureHandshake, now)
+		if blocked {
+			t.Fatalf("Expected failure %d not to block", i+1)
+		}
+		delays = append(delays, delay)
+	}
+	if delays[1] <= delays[0] {
+		t.Errorf("Expected escalating delays, got %v", delays)
+	}
+
+	if _, blocked := tracker.record(ip, failureAuth, now); !blocked {
+		t.Fatal("Expected the third failure to block the address")
+	}
+	if !tracker.isBlocked(ip, now.Add(9*time.Second)) {
+		t.Error("Expected the address to be blocked for the first block period")
+	}
+	if tracker.isBlocked(ip, now.Add(11*time.Second)) {
+		t.Error("Expected the first block to expire")
+	}
+	if tracker.isBlocked("198.51.100.2", now) {
+		t.Error("Expected other addresses not to be blocked")
+	}
+
+	// A second block within the window lasts twice as long
+	later := now.Add(12 * time.Second)
+	for i := 0; i < 3; i++ {
+		tracker.record(ip, failureHandshake, later)
+	}
+	if !tracker.isBlocked(ip, later.Add(19*time.Second)) {
+		t.Error("Expected the second block to be doubled")
+	}
+
+	totals, refused := tracker.counters()
+	if totals[failureHandshake] != 5 || totals[failureAuth] != 1 || refused != 2 {
+		t.Errorf("Unexpected counters: %v, refused %d", totals, refused)
+	}
+}
+
+func TestFailureTrackerWindow(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.Failures = FailureSettings{Limit: 2, Window: 60, Block: 10}
+
+	tracker := newFailureTracker()
+	now := time.Now()
+	tracker.record("198.51.100.3", failureHandshake, now)
+	if _, blocked := tracker.record("198.51.100.3", failureHandshake, now.Add(2*time.Minute)); blocked {
+		t.Error("Expected failures outside the window not to add up")
+	}
+
+	config.Failures.Limit = 0
+	for i := 0; i < 20; i++ {
+		if _, blocked := tracker.record("198.51.100.4", failureHandshake, now); blocked {
+			t.Fatal("Expected no blocking when disabled")
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// FloodSettings limits how fast a single client may send lines, commands
+// included.
+type FloodSettings struct {
+	Messages int `json:"messages"` // Lines allowed per window, 0 disables the limit
+	Window   int `json:"window"`   // Window length in seconds
+	Strikes  int `json:"strikes"`  // Violations within a window that get the client muted, 0 never mutes
+	Mute     int `json:"mute"`     // Length of that mute in seconds
+}
+
+// codeFence opens and closes a block of lines, such as the output /exec
+// sends from the client, that the flood limit counts as one message.
+const codeFence = "```"
+
+// maxBlockBytes is how much of a code block its opening fence covers, the
+// size of one chat message, newlines included. Blocks thus cannot carry
+// more past the flood limit than a single message could.
+const maxBlockBytes = 1024
+
+// floodState is the per-client bookkeeping of the flood limit. It is only
+// touched by the client's own connection goroutine.
+type floodState struct {
+	times      []time.Time // Accepted lines still inside the window
+	strikes    int
+	lastStrike time.Time
+	block      int // Bytes left in the open code block, 0 outside one
+}
+
+// checkFlood records line from c received at now against the configured
+// flood limit, see FloodSettings.check.
+func checkFlood(c *client, line string, now time.Time) error {
+	return config.Flood.check(c, line, now)
+}
+
+// check records line from c received at now and returns an error when it
+// exceeds limit. Repeated violations mute the client. An accepted code
+// fence opens a block whose lines are not counted.
+func (limit FloodSettings) check(c *client, line string, now time.Time) error {
+	if limit.Messages <= 0 || limit.Window <= 0 {
+		return nil
+	}
+	window := time.Duration(limit.Window) * time.Second
+	s := &c.flood
+
+	kept := s.times[:0]
+	for _, t := range s.times {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	s.times = kept
+	if len(s.times) < limit.Messages {
+		s.times = append(s.times, now)
+		if line == codeFence {
+			s.block = maxBlockBytes - len(codeFence) - 1
+		}
+		return nil
+	}
+
+	if now.Sub(s.lastStrike) >= window {
+		s.strikes = 0
+	}
+	s.strikes++
+	s.lastStrike = now
+	if limit.Strikes > 0 && s.strikes >= limit.Strikes {
+		s.strikes = 0
+		mute := time.Duration(limit.Mute) * time.Second
+		if mute <= 0 {
+			mute = window
+		}
+		muteClient(c, "flood protection", mute)
+		log.Printf("Muted %s for %v for flooding", c.name, mute)
+		return fmt.Errorf("You are muted for %d seconds for flooding", int(mute.Seconds()))
+	}
+	return fmt.Errorf("Slow down: at most %d messages per %d seconds, message dropped", limit.Messages, limit.Window)
+}
+
+// continueBlock reports whether line belongs to a code block that c opened
+// earlier and takes it out of the block's budget. The closing fence ends
+// the block, and so does a line that does not fit: it and the lines after
+// it are counted by checkFlood again.
+func continueBlock(c *client, line string) bool {
+	s := &c.flood
+	if s.block == 0 {
+		return false
+	}
+	s.block -= len(line) + 1
+	switch {
+	case s.block < 0:
+		s.block = 0
+		return false
+	case line == codeFence:
+		s.block = 0
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckFlood(t *testing.T) {
+	limit := FloodSettings{Messages: 3, Window: 10, Strikes: 2, Mute: 30}
+
+	c := &client{conn: newMockConn(), name: "flooder"}
+	defer unmuteClient(c)
+	start := time.Now()
+	at := func(seconds float64) time.Time {
+		return start.Add(time.Duration(seconds * float64(time.Second)))
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := limit.check(c, "hi", at(float64(i))); err != nil {
+			t.Fatalf("Expected line %d to pass, got %v", i+1, err)
+		}
+	}
+	if err := limit.check(c, "hi", at(3)); err == nil {
+		t.Fatal("Expected the fourth line in the window to be dropped")
+	}
+	if isMuted(c) {
+		t.Fatal("Expected a single violation to only warn")
+	}
+
+	// The first line has left the window
+	if err := limit.check(c, "hi", at(10.5)); err != nil {
+		t.Fatalf("Expected a line after the window to pass, got %v", err)
+	}
+	if err := limit.check(c, "hi", at(10.6)); err == nil {
+		t.Fatal("Expected another violation")
+	}
+	if !isMuted(c) {
+		t.Error("Expected repeated violations to mute the client")
+	}
+}
+
+func TestCheckFloodStrikesExpire(t *testing.T) {
+	limit := FloodSettings{Messages: 1, Window: 5, Strikes: 2, Mute: 30}
+
+	c := &client{conn: newMockConn(), name: "bursty"}
+	defer unmuteClient(c)
+	start := time.Now()
+
+	limit.check(c, "hi", start)
+	limit.check(c, "hi", start.Add(time.Second))    // first strike
+	limit.check(c, "hi", start.Add(20*time.Second)) // accepted
+	limit.check(c, "hi", start.Add(21*time.Second)) // strike count starts over
+	if isMuted(c) {
+		t.Error("Expected strikes older than the window to be forgotten")
+	}
+
+	limit.Messages = 0
+	for i := 0; i < 100; i++ {
+		if err := limit.check(c, "hi", start.Add(30*time.Second)); err != nil {
+			t.Fatalf("Expected no limit when disabled, got %v", err)
+		}
+	}
+}
+
+func TestServeExecOutputUnderFloodLimit(t *testing.T) {
+	// The sessions outlive the test, so the configuration is left alone
+	if config.Flood != defaultConfig().Flood {
+		t.Fatalf("Expected the default flood settings, got %+v", config.Flood)
+	}
+	srv, _ := startTestServer(t)
+
+	alice := srv.Connect(t, "exec-alice")
+	bob := srv.Connect(t, "exec-bob")
+	alice.Expect("exec-bob has joined our chat")
+
+	// The block the client's /exec sends for `ls -d /*`, over twice the flood limit
+	block := []string{codeFence, "$ ls -d /*"}
+	for _, dir := range []string{"/bin", "/dev", "/etc", "/home", "/lib", "/proc", "/root", "/tmp", "/usr", "/var"} {
+		block = append(block, dir)
+	}
+	block = append(block, codeFence)
+	for _, line := range block {
+		alice.Send(line)
+	}
+	for _, line := range block {
+		bob.Expect("exec-alice: " + line)
+	}
+	alice.ExpectNone("Slow down", 200*time.Millisecond)
+
+	alice.Send("done")
+	bob.Expect("exec-alice: done")
+}
+
+func TestCodeBlockBudget(t *testing.T) {
+	limit := FloodSettings{Messages: 1, Window: 10}
+
+	c := &client{conn: newMockConn(), name: "blocky"}
+	now := time.Now()
+	if err := limit.check(c, codeFence, now); err != nil {
+		t.Fatalf("Expected the opening fence to pass, got %v", err)
+	}
+	line := strings.Repeat("x", 99)
+	for i := 0; i < 10; i++ {
+		if !continueBlock(c, line) {
+			t.Fatalf("Expected line %d to be part of the block", i+1)
+		}
+	}
+	if continueBlock(c, line) {
+		t.Fatal("Expected the block to end past one message's worth of bytes")
+	}
+	if err := limit.check(c, line, now); err == nil {
+		t.Error("Expected lines past the block to be counted again")
+	}
+
+	c.flood = floodState{}
+	limit.check(c, codeFence, now.Add(time.Minute))
+	if !continueBlock(c, codeFence) || continueBlock(c, "after") {
+		t.Error("Expected the closing fence to end the block")
+	}
+}
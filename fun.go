@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxDice     = 20   // Most dice one /roll throws
+	maxDieSides = 1000 // Most sides of a die
+	maxQuestion = 200  // Longest /8ball question in characters
+)
+
+var eightBallAnswers = []string{
+	"It is certain", "Without a doubt", "Yes, definitely", "Most likely", "Outlook good",
+	"Reply hazy, try again", "Ask again later", "Cannot predict now",
+	"Don't count on it", "My reply is no", "Outlook not so good", "Very doubtful",
+}
+
+// The fun commands live apart from the core table and register
+// themselves, as any optional command module can.
+func init() {
+	for _, cmd := range []command{
+		{commandSpec{"/roll", "/roll [NdM]", permUser, fmt.Sprintf("Roll N dice with M sides for the room to see, 1d6 by default (at most %dd%d)", maxDice, maxDieSides)}, 0, rollCommand},
+		{commandSpec{"/flip", "/flip", permUser, "Flip a coin for the room to see"}, 0, flipCommand},
+		{commandSpec{"/8ball", "/8ball <question>", permUser, "Ask the magic 8-ball a yes or no question"}, 1, eightBallCommand},
+	} {
+		registerCommand(cmd)
+	}
+}
+
+// parseDice reads dice notation such as "2d6" or "d20".
+func parseDice(spec string) (dice, sides int, ok bool) {
+	count, faces, found := strings.Cut(strings.ToLower(spec), "d")
+	if !found {
+		return 0, 0, false
+	}
+	dice = 1
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, 0, false
+		}
+		dice = n
+	}
+	sides, err := strconv.Atoi(faces)
+	if err != nil || dice < 1 || dice > maxDice || sides < 2 || sides > maxDieSides {
+		return 0, 0, false
+	}
+	return dice, sides, true
+}
+
+// announceFun shows the outcome of a fun command to the room of c as a
+// system message. Shadow-banned users see it alone.
+func announceFun(c *client, text string) {
+	line := formatSystemMessage(text)
+	if isShadowBanned(c) {
+		c.send(line)
+		return
+	}
+	mutex.Lock()
+	room := c.room
+	mutex.Unlock()
+	broadcastToRoom(room, line, nil)
+}
+
+func rollCommand(c *client, call commandCall) {
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+	spec := "1d6"
+	if len(call.args) > 0 {
+		spec = call.args[0]
+	}
+	dice, sides, ok := parseDice(spec)
+	if !ok || len(call.args) > 1 {
+		c.send(fmt.Sprintf("Usage: /roll [NdM], at most %dd%d", maxDice, maxDieSides))
+		return
+	}
+	rolls := make([]string, dice)
+	total := 0
+	for i := range rolls {
+		n := 1 + rand.Intn(sides)
+		rolls[i] = strconv.Itoa(n)
+		total += n
+	}
+	text := fmt.Sprintf("%s rolled %dd%d: %d", c.name, dice, sides, total)
+	if dice > 1 {
+		text = fmt.Sprintf("%s rolled %dd%d: %s = %d", c.name, dice, sides, strings.Join(rolls, " + "), total)
+	}
+	announceFun(c, text)
+}
+
+func flipCommand(c *client, _ commandCall) {
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+	side := "heads"
+	if rand.Intn(2) == 1 {
+		side = "tails"
+	}
+	announceFun(c, fmt.Sprintf("%s flipped a coin: %s", c.name, side))
+}
+
+func eightBallCommand(c *client, call commandCall) {
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+	if len(call.text) > maxQuestion {
+		c.send(fmt.Sprintf("Question too long (max %d characters)", maxQuestion))
+		return
+	}
+	// The question is repeated to the room, so it passes the checks of
+	// any chat message first
+	question, ok := screenChatMessage(c, call.text, call.received)
+	if !ok {
+		return
+	}
+	answer := eightBallAnswers[rand.Intn(len(eightBallAnswers))]
+	announceFun(c, fmt.Sprintf("%s asked the magic 8-ball %q: %s", c.name, question, answer))
+}
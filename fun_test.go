@@ -0,0 +1,130 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDice(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		spec        string
+		dice, sides int
+		wantOK      bool
+	}{
+		{"2d6", 2, 6, true},
+		{"D20", 1, 20, true},
+		{"20d1000", 20, 1000, true},
+		{"21d6", 0, 0, false},
+		{"1d1", 0, 0, false},
+		{"0d6", 0, 0, false},
+		{"6", 0, 0, false},
+		{"xd6", 0, 0, false},
+	}
+	for _, tt := range tests {
+		dice, sides, ok := parseDice(tt.spec)
+		if dice != tt.dice || sides != tt.sides || ok != tt.wantOK {
+			t.Errorf("parseDice(%q) = %d, %d, %t, want %d, %d, %t", tt.spec, dice, sides, ok, tt.dice, tt.sides, tt.wantOK)
+		}
+	}
+}
+
+func TestFunCommands(t *testing.T) {
+	const name = "#fun-test"
+	playerConn, watcherConn := newMockConn(), newMockConn()
+	player := &client{conn: playerConn, name: "fun-player", room: name}
+	watcher := &client{conn: watcherConn, name: "fun-watcher", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name, settings: RoomSettings{Filters: []string{"forbidden"}}}
+	clients[playerConn], clients[watcherConn] = player, watcher
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, playerConn)
+		delete(clients, watcherConn)
+		mutex.Unlock()
+	}()
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"/roll", `^SERVER: fun-player rolled 1d6: [1-6]$`},
+		{"/roll 3d4", `^SERVER: fun-player rolled 3d4: ([1-4]) \+ ([1-4]) \+ ([1-4]) = \d+$`},
+		{"/flip", `^SERVER: fun-player flipped a coin: (heads|tails)$`},
+		{"/8ball will it ship?", `^SERVER: fun-player asked the magic 8-ball "will it ship\?": [A-Z].+$`},
+	}
+	for _, tt := range tests {
+		resetFunBuffers(playerConn, watcherConn)
+		if !handleCommand(player, tt.message, time.Now()) {
+			t.Fatalf("Expected %q to be handled", tt.message)
+		}
+		want := regexp.MustCompile(tt.want)
+		for _, conn := range []*mockConn{playerConn, watcherConn} {
+			if !slices.ContainsFunc(funLines(conn), want.MatchString) {
+				t.Errorf("%q showed %q, want a line matching %s", tt.message, conn.writeBuffer.String(), tt.want)
+			}
+		}
+	}
+
+	resetFunBuffers(playerConn)
+	handleCommand(player, "/roll 100d6", time.Now())
+	if !slices.ContainsFunc(funLines(playerConn), func(line string) bool { return strings.HasPrefix(line, "Usage: /roll [NdM]") }) {
+		t.Errorf("Expected the usage, got %q", playerConn.writeBuffer.String())
+	}
+	resetFunBuffers(playerConn)
+	handleCommand(player, "/8ball", time.Now())
+	if !slices.Contains(funLines(playerConn), "Usage: /8ball <question>") {
+		t.Errorf("Expected the usage, got %q", playerConn.writeBuffer.String())
+	}
+
+	// The question is a chat message as far as the checks go
+	resetFunBuffers(playerConn, watcherConn)
+	handleCommand(player, "/8ball is this forbidden?", time.Now())
+	if !slices.Contains(funLines(playerConn), "Message rejected by the #fun-test filter") {
+		t.Errorf("Expected the room filter to apply, got %q", playerConn.writeBuffer.String())
+	}
+	if slices.ContainsFunc(funLines(watcherConn), announcedBy(player.name)) {
+		t.Errorf("Expected nothing announced, got %q", watcherConn.writeBuffer.String())
+	}
+
+	muteClient(player, "test", 0)
+	defer unmuteClient(player)
+	resetFunBuffers(watcherConn)
+	for _, message := range []string{"/roll", "/flip", "/8ball will it ship?"} {
+		resetFunBuffers(playerConn)
+		handleCommand(player, message, time.Now())
+		if !slices.Contains(funLines(playerConn), "You are muted and cannot send messages") {
+			t.Errorf("Expected %q to be refused to muted users, got %q", message, playerConn.writeBuffer.String())
+		}
+	}
+	if slices.ContainsFunc(funLines(watcherConn), announcedBy(player.name)) {
+		t.Errorf("Expected nothing announced, got %q", watcherConn.writeBuffer.String())
+	}
+}
+
+// resetFunBuffers lets the broadcasts already queued go out, then empties
+// the buffers of conns. Sessions of other tests may still announce their
+// departure to everyone later, so checks look for their own lines only.
+func resetFunBuffers(conns ...*mockConn) {
+	broadcasts.submit(func() {})
+	for _, conn := range conns {
+		conn.writeBuffer.Reset()
+	}
+}
+
+// funLines returns the lines written to conn.
+func funLines(conn *mockConn) []string {
+	return strings.Split(strings.TrimSuffix(conn.writeBuffer.String(), "\n"), "\n")
+}
+
+// announcedBy matches the fun command announcements of name.
+func announcedBy(name string) func(string) bool {
+	return func(line string) bool {
+		return strings.HasPrefix(line, "SERVER: "+name+" ")
+	}
+}
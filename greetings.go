@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// greetingInterval is the minimum time between two greetings of the same
+// user in the same room.
+const greetingInterval = 24 * time.Hour
+
+// maxGreeting bounds the length of a room greeting.
+const maxGreeting = 512
+
+// sendGreeting sends the greeting of the named room to c as a private
+// message, at most once per user per greetingInterval.
+func sendGreeting(c *client, name string) {
+	mutex.Lock()
+	r, ok := rooms[name]
+	if !ok || r.settings.Greeting == "" {
+		mutex.Unlock()
+		return
+	}
+	key := strings.ToLower(c.name)
+	if last, ok := r.greeted[key]; ok && time.Since(last) < greetingInterval {
+		mutex.Unlock()
+		return
+	}
+	if r.greeted == nil {
+		r.greeted = make(map[string]time.Time)
+	}
+	r.greeted[key] = time.Now()
+	greeting := r.settings.Greeting
+	mutex.Unlock()
+
+	c.send(fmt.Sprintf("[PM from %s]: %s", name, greeting))
+}
+
+// handleGreetingCommand processes "/greeting [text|off]" for the current
+// room. Anyone can read the greeting; the room owner and operators can
+// change it.
+func handleGreetingCommand(c *client, call commandCall) {
+	mutex.Lock()
+	r, ok := rooms[c.room]
+	var current, owner string
+	if ok {
+		current, owner = r.settings.Greeting, r.owner
+	}
+	mutex.Unlock()
+	if !ok {
+		c.send(errUnknownRoom.Error())
+		return
+	}
+
+	text := call.text
+	if text == "" {
+		if current == "" {
+			c.send(fmt.Sprintf("%s has no greeting", r.name))
+		} else {
+			c.send(fmt.Sprintf("Greeting of %s: %s", r.name, current))
+		}
+		return
+	}
+
+	if !c.operator && (owner == "" || owner != c.name) {
+		c.send("Permission denied: only the room owner or an operator can set the greeting")
+		return
+	}
+	if len(text) > maxGreeting {
+		c.send(fmt.Sprintf("Greeting too long (max %d characters)", maxGreeting))
+		return
+	}
+	if text == "off" {
+		text = ""
+	}
+
+	mutex.Lock()
+	r.settings.Greeting = text
+	r.greeted = nil
+	mutex.Unlock()
+	log.Printf("%s set the greeting of %s to %q", c.name, r.name, text)
+	if text == "" {
+		c.send(fmt.Sprintf("Greeting of %s removed", r.name))
+	} else {
+		c.send(fmt.Sprintf("Greeting of %s set", r.name))
+	}
+}
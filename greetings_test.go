@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoomGreeting(t *testing.T) {
+	r, err := createRoom("#greet-support", "", "owner")
+	if err != nil {
+		t.Fatalf("createRoom: %v", err)
+	}
+	defer func() {
+		mutex.Lock()
+		delete(rooms, r.name)
+		mutex.Unlock()
+	}()
+
+	ownerConn, otherConn := newMockConn(), newMockConn()
+	owner := &client{conn: ownerConn, name: "owner", room: r.name}
+	other := &client{conn: otherConn, name: "visitor", room: r.name}
+
+	handleGreetingCommand(other, callOf("/greeting Hello"))
+	if !strings.Contains(otherConn.writeBuffer.String(), "Permission denied") {
+		t.Errorf("Expected non-owners to be refused, got %q", otherConn.writeBuffer.String())
+	}
+
+	handleGreetingCommand(owner, callOf("/greeting Welcome, please state your issue"))
+	if !strings.Contains(ownerConn.writeBuffer.String(), "Greeting of #greet-support set") {
+		t.Fatalf("Expected the owner to set the greeting, got %q", ownerConn.writeBuffer.String())
+	}
+
+	otherConn.writeBuffer.Reset()
+	sendGreeting(other, r.name)
+	sendGreeting(other, r.name)
+	if got := otherConn.writeBuffer.String(); strings.Count(got, "[PM from #greet-support]: Welcome, please state your issue") != 1 {
+		t.Errorf("Expected exactly one greeting per day, got %q", got)
+	}
+
+	handleGreetingCommand(owner, callOf("/greeting off"))
+	otherConn.writeBuffer.Reset()
+	handleGreetingCommand(other, callOf("/greeting"))
+	if got := otherConn.writeBuffer.String(); !strings.Contains(got, "has no greeting") {
+		t.Errorf("Expected the greeting to be removed, got %q", got)
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxPendingNotices caps the offline notices queued for a single user.
+const maxPendingNotices = 50
+
+// groupStore holds the admin-defined user groups along with the mention
+// notices waiting for members who were offline, mirrored to a JSON file.
+type groupStore struct {
+	mu      sync.Mutex
+	path    string              // Empty path keeps the store in memory only
+	Groups  map[string][]string `json:"groups"`  // Members by group name
+	Pending map[string][]string `json:"pending"` // Queued notices by user name
+}
+
+var groups = newGroupStore("") // Active groups, replaced by the persisted store on startup
+
+func newGroupStore(path string) *groupStore {
+	return &groupStore{
+		path:    path,
+		Groups:  make(map[string][]string),
+		Pending: make(map[string][]string),
+	}
+}
+
+// loadGroupStore reads the groups stored at path. A missing file yields an
+// empty store that will be created on the first change.
+func loadGroupStore(path string) (*groupStore, error) {
+	store := newGroupStore(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if store.Groups == nil {
+		store.Groups = make(map[string][]string)
+	}
+	if store.Pending == nil {
+		store.Pending = make(map[string][]string)
+	}
+	return store, nil
+}
+
+// save writes the store to disk. The caller must hold g.mu.
+func (g *groupStore) save() error {
+	if g.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(g.path, data, 0o600)
+}
+
+// validGroupName reports whether name can be used as a group, which is
+// mentioned as @name.
+func validGroupName(name string) bool {
+	if name == "" || len(name) > 32 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *groupStore) create(name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !validGroupName(name) {
+		return fmt.Errorf("Invalid group name %q", name)
+	}
+	if _, exists := g.Groups[name]; exists {
+		return fmt.Errorf("Group @%s already exists", name)
+	}
+	g.Groups[name] = []string{}
+	return g.save()
+}
+
+func (g *groupStore) remove(name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.Groups[name]; !exists {
+		return fmt.Errorf("No such group @%s", name)
+	}
+	delete(g.Groups, name)
+	return g.save()
+}
+
+func (g *groupStore) addMember(name, member string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	members, exists := g.Groups[name]
+	if !exists {
+		return fmt.Errorf("No such group @%s", name)
+	}
+	for _, m := range members {
+		if m == member {
+			return fmt.Errorf("%s is already in @%s", member, name)
+		}
+	}
+	g.Groups[name] = append(members, member)
+	return g.save()
+}
+
+func (g *groupStore) removeMember(name, member string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	members, exists := g.Groups[name]
+	if !exists {
+		return fmt.Errorf("No such group @%s", name)
+	}
+	for i, m := range members {
+		if m == member {
+			g.Groups[name] = append(members[:i], members[i+1:]...)
+			return g.save()
+		}
+	}
+	return fmt.Errorf("%s is not in @%s", member, name)
+}
+
+// renameMember replaces old by name in every group it belongs to. Groups
+// that already list name just lose old.
+func (g *groupStore) renameMember(old, name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	renamed := false
+	for group, members := range g.Groups {
+		i := slices.Index(members, old)
+		if i < 0 {
+			continue
+		}
+		if slices.Contains(members, name) {
+			g.Groups[group] = slices.Delete(members, i, i+1)
+		} else {
+			members[i] = name
+		}
+		renamed = true
+	}
+	if !renamed {
+		return nil
+	}
+	return g.save()
+}
+
+// members returns a copy of the members of the group and whether it exists.
+func (g *groupStore) members(name string) ([]string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	members, exists := g.Groups[name]
+	return append([]string(nil), members...), exists
+}
+
+// names returns the group names in sorted order.
+func (g *groupStore) names() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var names []string
+	for name := range g.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// queue stores a notice for an offline user, keeping only the most recent
+// maxPendingNotices entries.
+func (g *groupStore) queue(user, notice string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending := append(g.Pending[user], notice)
+	if len(pending) > maxPendingNotices {
+		pending = pending[len(pending)-maxPendingNotices:]
+	}
+	g.Pending[user] = pending
+	return g.save()
+}
+
+// takePending removes and returns the notices queued for user.
+func (g *groupStore) takePending(user string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending, ok := g.Pending[user]
+	if !ok {
+		return nil
+	}
+	delete(g.Pending, user)
+	if err := g.save(); err != nil {
+		log.Printf("Error saving groups: %v", err)
+	}
+	return pending
+}
+
+// mentionedGroups returns the existing groups mentioned as @name in message,
+// each at most once.
+func mentionedGroups(message string) []string {
+	var found []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(message) {
+		name, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		name = strings.TrimRightFunc(name, unicode.IsPunct)
+		if seen[name] {
+			continue
+		}
+		if _, exists := groups.members(name); exists {
+			seen[name] = true
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// notifyGroupMentions tells the members of every group mentioned in message
+// about it. Online members get the notice right away. Offline members find
+// it queued the next time they connect if they are authenticated
+// identities, as offline private messages are; anyone could claim any other
+// name and read the notices queued for it.
+func notifyGroupMentions(sender *client, room, message string) {
+	for _, name := range mentionedGroups(message) {
+		members, _ := groups.members(name)
+		notice := fmt.Sprintf("[@%s] %s in %s: %s", name, sender.name, room, message)
+		for _, member := range members {
+			if member == sender.name {
+				continue
+			}
+			if target := findClientByName(member); target != nil {
+				target.send(notice)
+				continue
+			}
+			name, ok := authenticatedName(member)
+			if !ok {
+				continue
+			}
+			if err := groups.queue(name, notice); err != nil {
+				log.Printf("Error saving groups: %v", err)
+			}
+		}
+	}
+}
+
+// deliverPendingNotices sends the group notices queued while c was offline.
+// Only clients that logged in with the identity's credential get them.
+func deliverPendingNotices(c *client) {
+	if !c.bot {
+		return
+	}
+	pending := groups.takePending(c.name)
+	if len(pending) == 0 {
+		return
+	}
+	c.send(fmt.Sprintf("While you were away you were mentioned %s time(s):", c.locale().number(len(pending))))
+	for _, notice := range pending {
+		c.send(notice)
+	}
+}
+
+// handleGroupCommand processes /group. Listing groups is open to everyone,
+// managing them requires operator rights.
+func handleGroupCommand(c *client, call commandCall) {
+	args := call.args
+	usage := "Usage: /group list [name] | create <name> | delete <name> | add <name> <user> | remove <name> <user>"
+	if len(args) == 0 {
+		c.send(usage)
+		return
+	}
+
+	if args[0] == "list" {
+		switch len(args) {
+		case 1:
+			names := groups.names()
+			if len(names) == 0 {
+				c.send("No groups defined")
+				return
+			}
+			c.send("Groups: @" + strings.Join(names, ", @"))
+		case 2:
+			name := strings.TrimPrefix(args[1], "@")
+			members, exists := groups.members(name)
+			if !exists {
+				c.send(fmt.Sprintf("No such group @%s", name))
+				return
+			}
+			c.send(fmt.Sprintf("@%s: %s", name, strings.Join(members, ", ")))
+		default:
+			c.send(usage)
+		}
+		return
+	}
+
+	var err error
+	switch {
+	case args[0] == "create" && len(args) == 2:
+		err = groups.create(strings.TrimPrefix(args[1], "@"))
+	case args[0] == "delete" && len(args) == 2:
+		err = groups.remove(strings.TrimPrefix(args[1], "@"))
+	case args[0] == "add" && len(args) == 3:
+		err = groups.addMember(strings.TrimPrefix(args[1], "@"), args[2])
+	case args[0] == "remove" && len(args) == 3:
+		err = groups.removeMember(strings.TrimPrefix(args[1], "@"), args[2])
+	default:
+		c.send(usage)
+		return
+	}
+	if err != nil {
+		c.send(err.Error())
+		return
+	}
+	log.Printf("%s ran %s %s", c.name, call.name, call.text)
+	c.send("Groups updated")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGroupStore(t *testing.T) {
+	t.Parallel()
+	t.Run("Membership", func(t *testing.T) {
+		t.Parallel()
+		store := newGroupStore("")
+		if err := store.create("ops"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := store.create("ops"); err == nil {
+			t.Error("Expected duplicate group to be rejected")
+		}
+		store.addMember("ops", "alice")
+		store.addMember("ops", "bob")
+		if err := store.addMember("ops", "bob"); err == nil {
+			t.Error("Expected duplicate member to be rejected")
+		}
+		store.removeMember("ops", "alice")
+
+		members, exists := store.members("ops")
+		if !exists || strings.Join(members, ",") != "bob" {
+			t.Errorf("Expected only bob in @ops, got %v", members)
+		}
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		t.Parallel()
+		store := newGroupStore("")
+		for _, name := range []string{"", "on call", "ops!"} {
+			if err := store.create(name); err == nil {
+				t.Errorf("Expected %q to be rejected", name)
+			}
+		}
+	})
+
+	t.Run("PendingNotices", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "groups.json")
+		store, _ := loadGroupStore(path)
+		for i := 0; i < maxPendingNotices+5; i++ {
+			store.queue("carol", "notice")
+		}
+
+		reloaded, err := loadGroupStore(path)
+		if err != nil {
+			t.Fatalf("Unexpected error reloading: %v", err)
+		}
+		if got := len(reloaded.takePending("carol")); got != maxPendingNotices {
+			t.Errorf("Expected %d queued notices, got %d", maxPendingNotices, got)
+		}
+		if len(reloaded.takePending("carol")) != 0 {
+			t.Error("Expected notices to be delivered only once")
+		}
+	})
+}
+
+func TestGroupMentions(t *testing.T) {
+	oldGroups := groups
+	defer func() { groups = oldGroups }()
+	groups = newGroupStore("")
+	groups.create("oncall")
+	groups.addMember("oncall", "offlineuser")
+	groups.addMember("oncall", "OnCall-Bot")
+	savedBots := config.Bots
+	t.Cleanup(func() { config.Bots = savedBots })
+	config.Bots = map[string]BotConfig{"oncall-bot": {Token: "secret"}}
+
+	if got := mentionedGroups("ping @oncall, @oncall and @nobody"); strings.Join(got, ",") != "oncall" {
+		t.Errorf("Expected a single @oncall mention, got %v", got)
+	}
+
+	sender := &client{conn: newMockConn(), name: "alice"}
+	notifyGroupMentions(sender, "#general", "disk full @oncall")
+
+	if pending := groups.takePending("offlineuser"); len(pending) != 0 {
+		t.Errorf("Expected nothing queued for a name without a credential, got %v", pending)
+	}
+
+	// Notices are only handed to clients that logged in with the credential
+	botConn := newMockConn()
+	deliverPendingNotices(&client{conn: botConn, name: "oncall-bot"})
+	if got := botConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected nothing for a client without the credential, got %q", got)
+	}
+	deliverPendingNotices(&client{conn: botConn, name: "oncall-bot", bot: true})
+	if got := botConn.writeBuffer.String(); !strings.Contains(got, "[@oncall] alice in #general: disk full") {
+		t.Errorf("Expected the queued notice for the authenticated member, got %q", got)
+	}
+}
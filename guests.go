@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// guestHandshake is the handshake of clients asking to join as a guest.
+const guestHandshake = protocolName + "/" + protocolVersion + " GUEST"
+
+const (
+	guestPrefix    = "guest-" // Prefix of generated guest names
+	guestNameTries = 50       // Random guest numbers tried before scanning
+	minGuestNumber = 1000
+	maxGuestNumber = 9999
+)
+
+// GuestSettings configures guest mode, where clients skip the name prompt
+// and get a generated name such as guest-4821.
+type GuestSettings struct {
+	Enabled bool     `json:"enabled"` // Accept guest handshakes
+	Rooms   []string `json:"rooms"`   // Rooms guests may join besides the default room
+}
+
+var (
+	errGuestsDisabled = errors.New("Guest access is disabled on this server")
+	errGuestsFull     = errors.New("No guest names are left, please try again later")
+	errGuestRoom      = errors.New("Guests cannot enter this room")
+)
+
+// isGuestName reports whether name lies in the namespace of generated
+// guest names, which registered clients cannot use.
+func isGuestName(name string) bool {
+	return len(name) > len(guestPrefix) && strings.EqualFold(name[:len(guestPrefix)], guestPrefix)
+}
+
+// newGuestName picks an unused guest name, or returns "" when every guest
+// number is taken. Names are free again once their guest disconnects. The
+// caller must hold mutex.
+func newGuestName() string {
+	for i := 0; i < guestNameTries; i++ {
+		name := fmt.Sprintf("%s%d", guestPrefix, minGuestNumber+rand.Intn(maxGuestNumber-minGuestNumber+1))
+		if !nameInUse(name) {
+			return name
+		}
+	}
+	for n := minGuestNumber; n <= maxGuestNumber; n++ {
+		if name := fmt.Sprintf("%s%d", guestPrefix, n); !nameInUse(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// guestMayEnter reports whether c may join or watch room. Guests are
+// limited to the default room and the configured guest rooms.
+func guestMayEnter(c *client, room string) bool {
+	if !c.guest || room == defaultRoom {
+		return true
+	}
+	for _, allowed := range config.Guests.Rooms {
+		if allowed == room {
+			return true
+		}
+	}
+	return false
+}
+
+// displayName is the name of c as shown in notices and user lists, with
+// guests marked as such.
+func (c *client) displayName() string {
+	if c.guest {
+		return c.name + " (guest)"
+	}
+	return c.name
+}
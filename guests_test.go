@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsGuestName(t *testing.T) {
+	t.Parallel()
+	for name, want := range map[string]bool{
+		"guest-4821": true,
+		"Guest-1":    true,
+		"guest-":     false,
+		"guest":      false,
+		"guesthouse": false,
+	} {
+		if got := isGuestName(name); got != want {
+			t.Errorf("isGuestName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNewGuestName(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	name := newGuestName()
+	if !isGuestName(name) {
+		t.Fatalf("Expected a guest name, got %q", name)
+	}
+	if nameInUse(name) {
+		t.Errorf("Expected %q to be free", name)
+	}
+}
+
+func TestGuestRooms(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.Guests = GuestSettings{Enabled: true, Rooms: []string{"#lobby"}}
+
+	for _, name := range []string{"#lobby", "#staff"} {
+		if _, err := createRoom(name, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn := newMockConn()
+	guest := &client{conn: conn, name: "guest-1234", room: defaultRoom, guest: true}
+	defer func() {
+		mutex.Lock()
+		delete(rooms, "#lobby")
+		delete(rooms, "#staff")
+		mutex.Unlock()
+	}()
+
+	if err := joinRoom(guest, "#staff"); err != errGuestRoom {
+		t.Errorf("Expected %v, got %v", errGuestRoom, err)
+	}
+	if err := joinRoom(guest, "#lobby"); err != nil {
+		t.Errorf("Expected guests to enter #lobby, got %v", err)
+	}
+	if err := joinRoom(guest, defaultRoom); err != nil {
+		t.Errorf("Expected guests to enter %s, got %v", defaultRoom, err)
+	}
+	if !guestMayEnter(&client{name: "alice"}, "#staff") {
+		t.Error("Expected registered users to enter any room")
+	}
+
+	handleRoomCommand(guest, callOf("/create #mine"))
+	if got := conn.writeBuffer.String(); !strings.Contains(got, "Guests cannot create rooms") {
+		t.Errorf("Expected guests to be refused /create, got %q", got)
+	}
+	if got := guest.displayName(); got != "guest-1234 (guest)" {
+		t.Errorf("Expected guests to be marked, got %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeartbeatSettings controls the PING messages the server sends to
+// clients that enabled the ping capability.
+type HeartbeatSettings struct {
+	Interval    int `json:"interval"`     // Seconds between pings, 0 disables them
+	MissedPongs int `json:"missed_pongs"` // Unanswered pings after which the client is dropped, 0 never drops
+}
+
+// Heartbeat lines, "PING <token>" answered by "PONG <token>" in either
+// direction.
+const (
+	pingLine = "PING"
+	pongLine = "PONG"
+)
+
+// heartbeatToken returns the token of a PING or PONG line, or false for
+// other lines.
+func heartbeatToken(line, kind string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == kind {
+		return "", true
+	}
+	return strings.CutPrefix(line, kind+" ")
+}
+
+// handleHeartbeat answers a client's PING and takes note of its PONG. It
+// reports whether line was either; heartbeats keep the connection alive
+// but do not count as activity of the user.
+func handleHeartbeat(c *client, line string) bool {
+	if token, ok := heartbeatToken(line, pingLine); ok {
+		c.send(strings.TrimSpace(pongLine + " " + token))
+		return true
+	}
+	if _, ok := heartbeatToken(line, pongLine); ok {
+		c.unansweredPings.Store(0)
+		return true
+	}
+	return false
+}
+
+// readAnswer reads the next line of a client that is not logged in yet.
+// Clients may start pinging right after the handshake, so pings are
+// answered and skipped rather than taken for the answer.
+func readAnswer(conn net.Conn, lines *lineReader) (string, error) {
+	for {
+		line, err := lines.readLine()
+		if err != nil {
+			return line, err
+		}
+		if token, ok := heartbeatToken(line, pingLine); ok {
+			if _, err := conn.Write([]byte(strings.TrimSpace(pongLine+" "+token) + "\n")); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if _, ok := heartbeatToken(line, pongLine); !ok {
+			return line, nil
+		}
+	}
+}
+
+// runHeartbeat pings c every interval while it has the ping capability,
+// and drops it once limit pings in a row go unanswered. It returns when
+// done is closed.
+func runHeartbeat(c *client, interval time.Duration, limit int, done <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if !c.caps.has(capPing) {
+				c.unansweredPings.Store(0)
+				continue
+			}
+			missed := c.unansweredPings.Add(1) - 1
+			if limit > 0 && int(missed) >= limit {
+				c.send(fmt.Sprintf("Disconnected: no PONG to the last %d PINGs", missed))
+				c.conn.Close()
+				return
+			}
+			c.send(pingLine + " " + strconv.FormatInt(now.UnixMilli(), 10))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		line    string
+		handled bool
+		reply   string
+	}{
+		{"ping with token", "PING 42", true, "PONG 42\n"},
+		{"bare ping", "PING", true, "PONG\n"},
+		{"pong", "PONG 17", true, ""},
+		{"chat", "PINGS are fun", false, ""},
+		{"lower case", "ping 1", false, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			conn := newMockConn()
+			c := &client{conn: conn, name: "heartbeat"}
+			c.unansweredPings.Store(2)
+			if got := handleHeartbeat(c, tt.line); got != tt.handled {
+				t.Fatalf("handleHeartbeat(%q) = %v, want %v", tt.line, got, tt.handled)
+			}
+			if got := conn.writeBuffer.String(); got != tt.reply {
+				t.Errorf("Expected reply %q, got %q", tt.reply, got)
+			}
+			if reset := c.unansweredPings.Load() == 0; reset != strings.HasPrefix(tt.line, "PONG") {
+				t.Errorf("Unanswered pings after %q: %d", tt.line, c.unansweredPings.Load())
+			}
+		})
+	}
+}
+
+func TestRunHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missed pongs", func(t *testing.T) {
+		t.Parallel()
+		conn := newMockConn()
+		c := &client{conn: conn, name: "heartbeat-missed"}
+		c.caps.set(capPing, true)
+		stopped := make(chan struct{})
+		go func() {
+			runHeartbeat(c, 10*time.Millisecond, 2, make(chan struct{}))
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the client to be dropped")
+		}
+		lines := strings.Split(strings.TrimSuffix(conn.writeBuffer.String(), "\n"), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "PING ") || !strings.HasPrefix(lines[1], "PING ") {
+			t.Fatalf("Expected two pings before the disconnect, got %q", lines)
+		}
+		if !strings.HasPrefix(lines[2], "Disconnected:") || !conn.closed {
+			t.Errorf("Expected a disconnect, got %q", lines[2])
+		}
+	})
+
+	t.Run("without capability", func(t *testing.T) {
+		t.Parallel()
+		conn := newMockConn()
+		c := &client{conn: conn, name: "heartbeat-plain"}
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			runHeartbeat(c, 5*time.Millisecond, 1, done)
+			close(stopped)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		close(done)
+		<-stopped
+		if conn.writeBuffer.Len() != 0 || conn.closed {
+			t.Errorf("Expected no pings, got %q", conn.writeBuffer.String())
+		}
+	})
+}
+
+func TestReadAnswer(t *testing.T) {
+	t.Parallel()
+	conn := newMockConn()
+	conn.readBuffer.WriteString("PING 5\nPONG 1\nalice\n")
+	answer, err := readAnswer(conn, newLineReader(bufio.NewReader(conn), maxLineBytes))
+	if err != nil || answer != "alice\n" {
+		t.Fatalf("readAnswer() = %q, %v, want \"alice\\n\"", answer, err)
+	}
+	if got := conn.writeBuffer.String(); got != "PONG 5\n" {
+		t.Errorf("Expected the ping to be answered, got %q", got)
+	}
+}
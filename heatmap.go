@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// heatmap counts chat messages by weekday and hour of the day, in server
+// time. Index [0] is Sunday, as in time.Weekday.
+type heatmap [7][24]int
+
+// heatmapShades draws busier hours with denser characters; '.' is an hour
+// without messages.
+const heatmapShades = ".:-=+*#@"
+
+// activity holds the heatmaps of the rooms since the server started.
+var activity = struct {
+	sync.Mutex
+	rooms map[string]*heatmap
+}{rooms: make(map[string]*heatmap)}
+
+// recordActivity counts a chat message posted in room at t.
+func recordActivity(room string, t time.Time) {
+	activity.Lock()
+	defer activity.Unlock()
+
+	h, ok := activity.rooms[room]
+	if !ok {
+		h = &heatmap{}
+		activity.rooms[room] = h
+	}
+	t = t.Local()
+	h[t.Weekday()][t.Hour()]++
+}
+
+// roomHeatmap returns a copy of the heatmap of room, or of all rooms
+// together when room is empty.
+func roomHeatmap(room string) heatmap {
+	activity.Lock()
+	defer activity.Unlock()
+
+	var total heatmap
+	for name, h := range activity.rooms {
+		if room != "" && name != room {
+			continue
+		}
+		for day := range h {
+			for hour, n := range h[day] {
+				total[day][hour] += n
+			}
+		}
+	}
+	return total
+}
+
+// heatmapRooms returns the rooms with recorded activity in sorted order.
+func heatmapRooms() []string {
+	activity.Lock()
+	defer activity.Unlock()
+
+	names := make([]string, 0, len(activity.rooms))
+	for name := range activity.rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// total returns the number of messages counted in h.
+func (h *heatmap) total() int {
+	n := 0
+	for day := range h {
+		for _, count := range h[day] {
+			n += count
+		}
+	}
+	return n
+}
+
+// render draws h as a header line and one line per day, Monday first,
+// with one character per hour shaded relative to the busiest hour.
+func (h *heatmap) render() []string {
+	busiest := 0
+	for day := range h {
+		for _, n := range h[day] {
+			busiest = max(busiest, n)
+		}
+	}
+
+	header := []byte(strings.Repeat(" ", 5+24))
+	for hour := 0; hour < 24; hour += 3 {
+		copy(header[5+hour:], fmt.Sprintf("%02d", hour))
+	}
+	lines := []string{strings.TrimRight(string(header), " ")}
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		var row strings.Builder
+		row.WriteString(day.String()[:3] + "  ")
+		for _, n := range h[day] {
+			level := 0
+			if n > 0 {
+				// Rounded up so any activity shows; the busiest hour gets the densest shade
+				level = (n*(len(heatmapShades)-1) + busiest - 1) / busiest
+			}
+			row.WriteByte(heatmapShades[level])
+		}
+		lines = append(lines, row.String())
+	}
+	return lines
+}
+
+// handleHeatmapCommand processes the operator command "/heatmap [#room]",
+// which draws when a room, or the whole server, is busy.
+func handleHeatmapCommand(c *client, call commandCall) {
+	if len(call.args) > 1 {
+		c.send("Usage: /heatmap [#room]")
+		return
+	}
+
+	scope, room := "all rooms", ""
+	if len(call.args) == 1 {
+		scope, room = call.args[0], call.args[0]
+	}
+	h := roomHeatmap(room)
+	c.send(fmt.Sprintf("Messages in %s by hour of the week (server time, %s), %s in total; busier hours are denser (%s)",
+		scope, time.Now().Format("MST"), c.locale().number(h.total()), heatmapShades))
+	for _, line := range h.render() {
+		c.send(line)
+	}
+}
+
+// heatmapResponse is the JSON served at /heatmap.
+type heatmapResponse struct {
+	Timezone string             `json:"timezone"` // Zone of the hours, the server's local time
+	Days     []string           `json:"days"`     // Names of the rows of each heatmap
+	Rooms    map[string]heatmap `json:"rooms"`    // Message counts by day and hour, per room
+}
+
+// serveHeatmap handles GET /heatmap, optionally limited to one room with
+// ?room=#name.
+func serveHeatmap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := heatmapResponse{Timezone: time.Now().Format("MST"), Rooms: make(map[string]heatmap)}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		resp.Days = append(resp.Days, day.String())
+	}
+	if room := r.URL.Query().Get("room"); room != "" {
+		resp.Rooms[room] = roomHeatmap(room)
+	} else {
+		for _, name := range heatmapRooms() {
+			resp.Rooms[name] = roomHeatmap(name)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeatmapRender(t *testing.T) {
+	t.Parallel()
+	var h heatmap
+	h[time.Monday][9] = 70
+	h[time.Monday][10] = 1
+	h[time.Sunday][23] = 35
+
+	lines := h.render()
+	if len(lines) != 8 {
+		t.Fatalf("Expected a header and seven days, got %d lines", len(lines))
+	}
+	if lines[0] != "     00 03 06 09 12 15 18 21" {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	if want := "Mon  .........@:............."; lines[1] != want {
+		t.Errorf("Expected %q, got %q", want, lines[1])
+	}
+	if want := "Sun  .......................+"; lines[7] != want {
+		t.Errorf("Expected Sunday last as %q, got %q", want, lines[7])
+	}
+	if h.total() != 106 {
+		t.Errorf("Expected 106 messages, got %d", h.total())
+	}
+}
+
+func TestServeHeatmap(t *testing.T) {
+	t.Parallel()
+	const room = "#heatmap-test"
+	at := time.Date(2024, 5, 6, 14, 30, 0, 0, time.Local) // A Monday
+	recordActivity(room, at)
+	recordActivity(room, at.Add(time.Minute))
+
+	rec := httptest.NewRecorder()
+	serveHeatmap(rec, httptest.NewRequest(http.MethodGet, "/heatmap?room=%23heatmap-test", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var resp heatmapResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Rooms[room][time.Monday][14]; got != 2 {
+		t.Errorf("Expected 2 messages on Monday at 14h, got %d", got)
+	}
+	if len(resp.Rooms) != 1 || resp.Days[1] != "Monday" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	serveHeatmap(rec, httptest.NewRequest(http.MethodPost, "/heatmap", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestHeatmapCommand(t *testing.T) {
+	t.Parallel()
+	conn := newMockConn()
+	handleCommand(&client{conn: conn, name: "alice"}, "/heatmap", time.Now())
+	if !strings.Contains(conn.writeBuffer.String(), "Permission denied") {
+		t.Error("Expected regular users to be refused")
+	}
+
+	conn = newMockConn()
+	handleCommand(&client{conn: conn, name: "op", operator: true}, "/heatmap #nowhere", time.Now())
+	got := conn.writeBuffer.String()
+	if !strings.Contains(got, "Messages in #nowhere") || !strings.Contains(got, "Mon  ........................") {
+		t.Errorf("Expected an empty heatmap, got %q", got)
+	}
+}
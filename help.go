@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lookupCommandSpec returns the description of a command, named with or
+// without the leading slash.
+func lookupCommandSpec(name string) (commandSpec, bool) {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	if cmd, ok := commands[name]; ok {
+		return cmd.commandSpec, true
+	}
+	return commandSpec{}, false
+}
+
+// helpLines describes the commands c may use, one per line, in the order
+// of the registry.
+func helpLines(c *client) []string {
+	lines := []string{"Commands:"}
+	for _, spec := range commandSpecs() {
+		if c.mayUse(spec.Permission) {
+			lines = append(lines, fmt.Sprintf("  %s - %s", spec.Syntax, spec.Description))
+		}
+	}
+	return append(lines, "Type /help <command> for details")
+}
+
+// handleHelpCommand processes "/help [command]". The listing comes from
+// the command registry, so it always matches what the server handles.
+func handleHelpCommand(c *client, call commandCall) {
+	switch len(call.args) {
+	case 0:
+		for _, line := range helpLines(c) {
+			c.send(line)
+		}
+	case 1:
+		spec, ok := lookupCommandSpec(call.args[0])
+		if !ok {
+			c.send(fmt.Sprintf("Unknown command %s, type /help for the list", call.args[0]))
+			return
+		}
+		c.send("Usage: " + spec.Syntax)
+		c.send(spec.Description)
+		if spec.Permission != permUser {
+			c.send(fmt.Sprintf("Requires %s rights", spec.Permission))
+		}
+	default:
+		c.send("Usage: /help [command]")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHelpCommand(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		client   *client
+		message  string
+		want     []string
+		dontWant []string
+	}{
+		{"user listing", &client{name: "help-user"}, "/help",
+			[]string{"Commands:\n", "  /msg <user> <message> - Send a private message\n", "  /help [command] - ", "Type /help <command> for details\n"},
+			[]string{"/mute", "/ban"}},
+		{"moderator listing", &client{name: "help-mod", moderator: true}, "/help",
+			[]string{"  /mute <name> [duration] - "}, []string{"/ban"}},
+		{"operator listing", &client{name: "help-op", operator: true}, "/help",
+			[]string{"  /mute <name> [duration] - ", "  /ban <name|ip> [duration] [--ip] - "}, nil},
+		{"command without slash", &client{name: "help-user"}, "/help msg",
+			[]string{"Usage: /msg <user> <message>\nSend a private message\n"}, []string{"Requires"}},
+		{"restricted command", &client{name: "help-user"}, "/help /ban",
+			[]string{"Usage: /ban <name|ip> [duration] [--ip]\n", "Requires operator rights\n"}, nil},
+		{"unknown command", &client{name: "help-user"}, "/help frobnicate",
+			[]string{"Unknown command frobnicate, type /help for the list\n"}, nil},
+		{"too many arguments", &client{name: "help-user"}, "/help msg list",
+			[]string{"Usage: /help [command]\n"}, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			conn := newMockConn()
+			tt.client.conn = conn
+			handleHelpCommand(tt.client, callOf(tt.message))
+			got := conn.writeBuffer.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Expected %q in %q", want, got)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(got, dontWant) {
+					t.Errorf("Expected no %q in %q", dontWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHelpCoversSpec(t *testing.T) {
+	t.Parallel()
+	op := &client{name: "help-all", operator: true}
+	if got, want := len(helpLines(op)), len(commandOrder)+2; got != want {
+		t.Errorf("Expected every registered command, got %d lines, want %d", got, want)
+	}
+}
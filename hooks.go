@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var errHookRejected = errors.New("Message rejected by a server filter")
+
+// Message is a chat message on its way to a room, as seen by hooks.
+type Message struct {
+	Sender string
+	Room   string
+	Text   string
+	Time   time.Time
+}
+
+// Hook inspects a chat message before it is broadcast. It returns false to
+// drop the message, and a non-empty modified text to deliver instead of
+// msg.Text; an empty one leaves the text as it is. Hooks run in the order
+// they were registered and each sees the text left by the previous one.
+type Hook func(msg *Message) (allow bool, modified string)
+
+// HookConfig configures a regular expression hook under message_hooks.
+type HookConfig struct {
+	Pattern string `json:"pattern"` // Regular expression matched against the text
+	Replace string `json:"replace"` // Replacement for matches, empty rejects the message instead
+}
+
+var hooks struct {
+	sync.RWMutex
+	chain []Hook
+}
+
+// RegisterHook appends h to the chain run on every chat message.
+func RegisterHook(h Hook) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.chain = append(hooks.chain, h)
+}
+
+// runHookChain passes msg through chain, updating msg.Text as hooks modify
+// it. It reports whether every hook allowed the message.
+func runHookChain(chain []Hook, msg *Message) bool {
+	for _, h := range chain {
+		allow, modified := h(msg)
+		if !allow {
+			return false
+		}
+		if modified != "" {
+			msg.Text = modified
+		}
+	}
+	return true
+}
+
+// applyHooks runs the registered hooks on a chat message c posts to room
+// and returns the text to deliver, or errHookRejected if a hook dropped it.
+func applyHooks(c *client, room, text string, received time.Time) (string, error) {
+	hooks.RLock()
+	chain := hooks.chain
+	hooks.RUnlock()
+	if len(chain) == 0 {
+		return text, nil
+	}
+
+	msg := &Message{Sender: c.name, Room: room, Text: text, Time: received}
+	if !runHookChain(chain, msg) {
+		log.Printf("Rejected message from %s in %s by a hook", c.name, room)
+		return "", errHookRejected
+	}
+	return msg.Text, nil
+}
+
+// regexHook builds the hook described by cfg: it rewrites matches with
+// cfg.Replace, or rejects matching messages when no replacement is given.
+func regexHook(cfg HookConfig) (Hook, error) {
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("message hook %q: %w", cfg.Pattern, err)
+	}
+	return func(msg *Message) (bool, string) {
+		if !pattern.MatchString(msg.Text) {
+			return true, ""
+		}
+		if cfg.Replace == "" {
+			return false, ""
+		}
+		return true, pattern.ReplaceAllString(msg.Text, cfg.Replace)
+	}, nil
+}
+
+// registerConfiguredHooks registers a hook for each message_hooks entry.
+func registerConfiguredHooks(configs []HookConfig) error {
+	for _, cfg := range configs {
+		h, err := regexHook(cfg)
+		if err != nil {
+			return err
+		}
+		RegisterHook(h)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRunHookChain(t *testing.T) {
+	t.Parallel()
+	upper := func(msg *Message) (bool, string) { return true, msg.Text + "!" }
+	keep := func(msg *Message) (bool, string) { return true, "" }
+	block := func(msg *Message) (bool, string) { return false, "" }
+
+	tests := []struct {
+		name      string
+		chain     []Hook
+		wantAllow bool
+		wantText  string
+	}{
+		{"Empty chain", nil, true, "hi"},
+		{"Empty modification keeps the text", []Hook{keep}, true, "hi"},
+		{"Modifications accumulate", []Hook{upper, keep, upper}, true, "hi!!"},
+		{"Block stops the chain", []Hook{upper, block, upper}, false, "hi!"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			msg := &Message{Sender: "alice", Room: defaultRoom, Text: "hi"}
+			if allow := runHookChain(tt.chain, msg); allow != tt.wantAllow {
+				t.Errorf("Expected allow=%t, got %t", tt.wantAllow, allow)
+			}
+			if msg.Text != tt.wantText {
+				t.Errorf("Expected text %q, got %q", tt.wantText, msg.Text)
+			}
+		})
+	}
+}
+
+func TestRegexHook(t *testing.T) {
+	t.Parallel()
+	scrub, err := regexHook(HookConfig{Pattern: `[\w.+-]+@[\w-]+\.[\w.]+`, Replace: "[email]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocker, err := regexHook(HookConfig{Pattern: `(?i)\bbuy now\b`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		hook      Hook
+		text      string
+		wantAllow bool
+		wantText  string
+	}{
+		{"Scrub match", scrub, "mail me at bob@example.com", true, "mail me at [email]"},
+		{"Scrub no match", scrub, "no address here", true, ""},
+		{"Block match", blocker, "BUY NOW cheap", false, ""},
+		{"Block no match", blocker, "buying later", true, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			allow, modified := tt.hook(&Message{Text: tt.text})
+			if allow != tt.wantAllow || modified != tt.wantText {
+				t.Errorf("Expected (%t, %q), got (%t, %q)", tt.wantAllow, tt.wantText, allow, modified)
+			}
+		})
+	}
+
+	if _, err := regexHook(HookConfig{Pattern: "("}); err == nil {
+		t.Error("Expected an invalid pattern to be refused")
+	}
+}
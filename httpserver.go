@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// startHTTPServer serves the HTTP endpoints on addr in the background.
+func startHTTPServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
+	})
+	mux.HandleFunc("/protocol", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeProtocolSpec(w); err != nil {
+			log.Printf("Error writing protocol spec: %v", err)
+		}
+	})
+	mux.HandleFunc("/download/", serveDownload)
+	mux.HandleFunc("/bots/hold", serveBotHold)
+	mux.HandleFunc("/heatmap", serveHeatmap)
+
+	go func() {
+		log.Printf("HTTP endpoints listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Error serving HTTP: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// idleWarning is how long before the idle kick the client is warned.
+const idleWarning = time.Minute
+
+// idleTimeout returns the configured idle timeout, zero when disabled.
+func idleTimeout() time.Duration {
+	return time.Duration(config.IdleTimeout) * time.Second
+}
+
+// checkIdle decides what to do with a client that has been silent since
+// lastActivity: warn it once shortly before the timeout, then kick it.
+// Timeouts shorter than twice the warning lead warn halfway instead.
+func checkIdle(lastActivity, now time.Time, warned bool) (warn, kick bool) {
+	timeout := idleTimeout()
+	if timeout <= 0 {
+		return false, false
+	}
+	idle := now.Sub(lastActivity)
+	if idle >= timeout {
+		return false, true
+	}
+	lead := idleWarning
+	if timeout < 2*lead {
+		lead = timeout / 2
+	}
+	return !warned && idle >= timeout-lead, false
+}
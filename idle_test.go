@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckIdle(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	start := time.Now()
+
+	tests := []struct {
+		name     string
+		timeout  int
+		idle     time.Duration
+		warned   bool
+		wantWarn bool
+		wantKick bool
+	}{
+		{"Disabled", 0, 24 * time.Hour, false, false, false},
+		{"Active", 600, time.Minute, false, false, false},
+		{"Warn a minute before", 600, 9 * time.Minute, false, true, false},
+		{"Warn only once", 600, 9*time.Minute + 30*time.Second, true, false, false},
+		{"Kick", 600, 10 * time.Minute, true, false, true},
+		{"Short timeout warns halfway", 60, 30 * time.Second, false, true, false},
+		{"Short timeout before halfway", 60, 29 * time.Second, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.IdleTimeout = tt.timeout
+			warn, kick := checkIdle(start, start.Add(tt.idle), tt.warned)
+			if warn != tt.wantWarn || kick != tt.wantKick {
+				t.Errorf("Expected warn=%t kick=%t, got warn=%t kick=%t", tt.wantWarn, tt.wantKick, warn, kick)
+			}
+		})
+	}
+}
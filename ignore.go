@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+)
+
+// maxIgnores is the number of users a client may ignore at once.
+const maxIgnores = 100
+
+// ignoresLocked reports whether c ignores name. The caller must hold
+// mutex.
+func (c *client) ignoresLocked(name string) bool {
+	_, ok := c.ignored[strings.ToLower(name)]
+	return ok
+}
+
+// ignores reports whether c ignores name.
+func (c *client) ignores(name string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return c.ignoresLocked(name)
+}
+
+// skipIgnorersLocked drops the recipients of a broadcast who ignore its
+// sender. The caller must hold mutex.
+func skipIgnorersLocked(recipients []*client, sender net.Conn) []*client {
+	from, ok := clients[sender]
+	if !ok {
+		return recipients
+	}
+	kept := recipients[:0]
+	for _, c := range recipients {
+		if !c.ignoresLocked(from.name) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// skipIgnoredHistory drops the chat lines of the users c ignores from a
+// room history.
+func skipIgnoredHistory(c *client, history []historyEntry) []historyEntry {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(c.ignored) == 0 {
+		return history
+	}
+	var kept []historyEntry
+	for _, entry := range history {
+		if author, _, ok := splitAuthor(entry.line); ok && c.ignoresLocked(author) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// ignoredNames lists the names c ignores, sorted.
+func ignoredNames(c *client) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	names := make([]string, 0, len(c.ignored))
+	for _, name := range c.ignored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handleIgnoreCommand processes "/ignore <user>", "/unignore <user>" and
+// "/ignorelist". The chat lines, typing notices and private messages of
+// an ignored user are not delivered to the client for the rest of its
+// session.
+func handleIgnoreCommand(c *client, call commandCall) {
+	switch call.name {
+	case "/ignorelist":
+		if names := ignoredNames(c); len(names) == 0 {
+			c.send("You are not ignoring anyone")
+		} else {
+			c.send("Ignoring: " + strings.Join(names, ", "))
+		}
+	case "/ignore":
+		if len(call.args) != 1 {
+			c.send("Usage: /ignore <user>")
+			return
+		}
+		ignoreUser(c, call.args[0])
+	case "/unignore":
+		if len(call.args) != 1 {
+			c.send("Usage: /unignore <user>")
+			return
+		}
+		name := call.args[0]
+		key := strings.ToLower(name)
+		mutex.Lock()
+		_, ok := c.ignored[key]
+		delete(c.ignored, key)
+		mutex.Unlock()
+		if !ok {
+			c.send(fmt.Sprintf("You are not ignoring %s", name))
+			return
+		}
+		c.send(fmt.Sprintf("No longer ignoring %s", name))
+	}
+}
+
+// ignoreUser adds name to the users c ignores.
+func ignoreUser(c *client, name string) {
+	if strings.EqualFold(name, c.name) {
+		c.send("You cannot ignore yourself")
+		return
+	}
+	key := strings.ToLower(name)
+	mutex.Lock()
+	if _, renewing := c.ignored[key]; !renewing && len(c.ignored) >= maxIgnores {
+		mutex.Unlock()
+		c.send(fmt.Sprintf("You can ignore at most %d users", maxIgnores))
+		return
+	}
+	if c.ignored == nil {
+		c.ignored = make(map[string]string)
+	}
+	c.ignored[key] = name
+	mutex.Unlock()
+
+	log.Printf("%s ignores %s", c.name, name)
+	c.send(fmt.Sprintf("Ignoring %s, /unignore %s to see their messages again", name, name))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIgnoreCommand(t *testing.T) {
+	const name = "#ignore-test"
+	ignorerConn, pestConn, otherConn := newMockConn(), newMockConn(), newMockConn()
+	ignorer := &client{conn: ignorerConn, name: "ignorer", room: name}
+	pest := &client{conn: pestConn, name: "Pest", room: name}
+	other := &client{conn: otherConn, name: "ignore-other", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[ignorerConn], clients[pestConn], clients[otherConn] = ignorer, pest, other
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, ignorerConn)
+		delete(clients, pestConn)
+		delete(clients, otherConn)
+		mutex.Unlock()
+	}()
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"/ignorelist", "You are not ignoring anyone"},
+		{"/ignore", "Usage: /ignore <user>"},
+		{"/ignore IGNORER", "You cannot ignore yourself"},
+		{"/ignore pest", "Ignoring pest, /unignore pest to see their messages again"},
+		{"/ignore ignore-gone", "Ignoring ignore-gone"},
+		{"/ignorelist", "Ignoring: ignore-gone, pest"},
+		{"/unignore ignore-gone", "No longer ignoring ignore-gone"},
+		{"/unignore ignore-gone", "You are not ignoring ignore-gone"},
+	}
+	for _, tt := range tests {
+		ignorerConn.writeBuffer.Reset()
+		handleIgnoreCommand(ignorer, callOf(tt.message))
+		if got := ignorerConn.writeBuffer.String(); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%q answered %q, want %q", tt.message, got, tt.want)
+		}
+	}
+
+	ignorerConn.writeBuffer.Reset()
+	postToRoom(name, "Pest: buy my stuff", pestConn, time.Now())
+	postToRoom(name, "ignore-other: hello", otherConn, time.Now())
+	broadcastMessage(formatSystemMessage("Pest is now known as Pest2"), pestConn)
+	handleTyping(pest, time.Now())
+	handlePrivateMessage(pest, callOf("/msg ignorer psst"))
+	got := ignorerConn.writeBuffer.String()
+	if strings.Contains(got, "Pest") {
+		t.Errorf("Expected nothing from the ignored user, got %q", got)
+	}
+	if !strings.Contains(got, "ignore-other: hello") {
+		t.Errorf("Expected the others' messages, got %q", got)
+	}
+	if echo := pestConn.writeBuffer.String(); !strings.Contains(echo, "[PM to ignorer]: psst") {
+		t.Errorf("Expected the ignored sender to get the usual confirmation, got %q", echo)
+	}
+	if got := otherConn.writeBuffer.String(); !strings.Contains(got, "Pest: buy my stuff") {
+		t.Errorf("Expected the others to see the message, got %q", got)
+	}
+
+	// The history is replayed without the ignored user's lines
+	ignorerConn.writeBuffer.Reset()
+	sendHistory(ignorer, name)
+	if got := ignorerConn.writeBuffer.String(); strings.Contains(got, "Pest:") || !strings.Contains(got, "ignore-other: hello") {
+		t.Errorf("Expected the history without the ignored user, got %q", got)
+	}
+
+	handleIgnoreCommand(ignorer, callOf("/unignore PEST"))
+	ignorerConn.writeBuffer.Reset()
+	postToRoom(name, "Pest: still here", pestConn, time.Now())
+	if got := ignorerConn.writeBuffer.String(); !strings.Contains(got, "Pest: still here") {
+		t.Errorf("Expected messages again after /unignore, got %q", got)
+	}
+}
+
+func TestIgnoreFollowsRename(t *testing.T) {
+	const name = "#ignore-nick"
+	ignorerConn, pestConn := newMockConn(), newMockConn()
+	ignorer := &client{conn: ignorerConn, name: "nick-ignorer", room: name}
+	pest := &client{conn: pestConn, name: "nick-pest", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[ignorerConn], clients[pestConn] = ignorer, pest
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, ignorerConn)
+		delete(clients, pestConn)
+		mutex.Unlock()
+	}()
+
+	handleIgnoreCommand(ignorer, callOf("/ignore nick-pest"))
+	handleNickCommand(pest, callOf("/nick nick-fresh"))
+	ignorerConn.writeBuffer.Reset()
+	postToRoom(name, "nick-fresh: new name, who dis", pestConn, time.Now())
+	handlePrivateMessage(pest, callOf("/msg nick-ignorer psst"))
+	if got := ignorerConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected the ignore to follow the rename, got %q", got)
+	}
+	if got := ignoredNames(ignorer); len(got) != 1 || got[0] != "nick-fresh" {
+		t.Errorf("Expected the ignore list to show the new name, got %v", got)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+)
+
+var errInvalidJSON = errors.New("Invalid JSON message")
+
+// jsonCodec is the JSON-lines encoding: one JSON object per line, with
+// the fields of wireMessage.
+type jsonCodec struct {
+	lines *lineReader
+}
+
+func newJSONCodec(r *bufio.Reader) *jsonCodec {
+	return &jsonCodec{lines: newLineReader(r, maxLineBytes)}
+}
+
+func (j *jsonCodec) encode(msg wireMessage) ([]byte, error) {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return append(encoded, '\n'), nil
+}
+
+func (j *jsonCodec) decode() (wireMessage, error) {
+	raw, err := j.lines.readLine()
+	if err == errLineTooLong {
+		return wireMessage{}, badMessage{err}
+	}
+	if err != nil {
+		return wireMessage{}, err
+	}
+	var msg wireMessage
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return wireMessage{}, badMessage{errInvalidJSON}
+	}
+	return msg, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestJSONCodecDecode(t *testing.T) {
+	t.Parallel()
+	input := `{"type":"private","to":"bob","body":"psst"}` + "\n" +
+		"hello\n" +
+		`{"type":"command","body":"/list"}` + "\n" +
+		strings.Repeat("x", maxLineBytes+1) + "\n"
+	codec := newJSONCodec(bufio.NewReader(strings.NewReader(input)))
+
+	tests := []struct {
+		want    wireMessage
+		wantErr error
+	}{
+		{wireMessage{Type: "private", To: "bob", Body: "psst"}, nil},
+		{wireMessage{}, badMessage{errInvalidJSON}},
+		{wireMessage{Type: "command", Body: "/list"}, nil},
+		{wireMessage{}, badMessage{errLineTooLong}},
+	}
+	for i, tt := range tests {
+		got, err := codec.decode()
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("Message %d: decode() = %+v, %v, want %+v, %v", i, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
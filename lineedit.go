@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"unicode"
+)
+
+// Keys the line editor handles, as read in raw mode.
+const (
+	keyCtrlA     = 0x01
+	keyCtrlD     = 0x04
+	keyCtrlE     = 0x05
+	keyBackspace = 0x08
+	keyTab       = '\t'
+	keyCtrlK     = 0x0b
+	keyCtrlU     = 0x15
+	keyEscape    = 0x1b
+	keyDelete    = 0x7f
+)
+
+// lineEditor reads lines from a terminal in raw mode and edits them in
+// place: the cursor moves with the arrow keys, Home and End, Up and Down
+// recall earlier lines and Tab completes the words before the cursor.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	prompt   string
+	history  func() []string              // Lines to recall, oldest first
+	complete func(prefix string) []string // Full replacements of prefix
+
+	line   []rune
+	cursor int // Position in line
+	recall int // History entry shown, len(history) for the line being typed
+	draft  []rune
+}
+
+// readLine returns the next line. Ctrl-D on an empty line ends the input
+// with io.EOF.
+func (e *lineEditor) readLine() (string, error) {
+	e.line, e.cursor, e.draft = nil, 0, nil
+	e.recall = len(e.history())
+	fmt.Fprint(e.out, e.prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(e.line) > 0 {
+				fmt.Fprintln(e.out)
+				return string(e.line), nil
+			}
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprintln(e.out)
+			return string(e.line), nil
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprintln(e.out)
+				return "", io.EOF
+			}
+			e.deleteAt(e.cursor)
+		case keyBackspace, keyDelete:
+			if e.cursor > 0 {
+				e.cursor--
+				e.deleteAt(e.cursor)
+			}
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlK:
+			e.line = e.line[:e.cursor]
+		case keyCtrlU:
+			e.line = append([]rune(nil), e.line[e.cursor:]...)
+			e.cursor = 0
+		case keyTab:
+			e.completeLine()
+		case keyEscape:
+			e.escape()
+		default:
+			if unicode.IsPrint(r) {
+				e.line = append(e.line[:e.cursor], append([]rune{r}, e.line[e.cursor:]...)...)
+				e.cursor++
+			}
+		}
+		e.redraw()
+	}
+}
+
+// deleteAt removes the rune at i, if there is one.
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.line) {
+		e.line = append(e.line[:i], e.line[i+1:]...)
+	}
+}
+
+// escape handles the rest of an escape sequence: the cursor and Home, End
+// and Delete keys in both the CSI ("ESC [") and SS3 ("ESC O") forms.
+// Other sequences are read and ignored.
+func (e *lineEditor) escape() {
+	lead, _, err := e.in.ReadRune()
+	if err != nil || (lead != '[' && lead != 'O') {
+		return
+	}
+	var params strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return
+		}
+		if r < 0x40 || r > 0x7e {
+			params.WriteRune(r)
+			continue
+		}
+		switch {
+		case r == 'A':
+			e.recallEntry(e.recall - 1)
+		case r == 'B':
+			e.recallEntry(e.recall + 1)
+		case r == 'C':
+			e.cursor = min(e.cursor+1, len(e.line))
+		case r == 'D':
+			e.cursor = max(e.cursor-1, 0)
+		case r == 'H' || (r == '~' && (params.String() == "1" || params.String() == "7")):
+			e.cursor = 0
+		case r == 'F' || (r == '~' && (params.String() == "4" || params.String() == "8")):
+			e.cursor = len(e.line)
+		case r == '~' && params.String() == "3":
+			e.deleteAt(e.cursor)
+		}
+		return
+	}
+}
+
+// recallEntry shows history entry i in place of the line. Going past the
+// newest entry brings back the line that was being typed.
+func (e *lineEditor) recallEntry(i int) {
+	history := e.history()
+	if i < 0 || i > len(history) {
+		return
+	}
+	if e.recall == len(history) {
+		e.draft = append([]rune(nil), e.line...)
+	}
+	e.recall = i
+	if i == len(history) {
+		e.line = append([]rune(nil), e.draft...)
+	} else {
+		e.line = []rune(history[i])
+	}
+	e.cursor = len(e.line)
+}
+
+// completeLine completes the text before the cursor. A single candidate
+// replaces it, several extend it as far as they agree and are listed when
+// they do not agree any further.
+func (e *lineEditor) completeLine() {
+	prefix := string(e.line[:e.cursor])
+	matches := e.complete(prefix)
+	if len(matches) == 0 {
+		return
+	}
+	rest := e.line[e.cursor:]
+	replacement := matches[0]
+	if len(rest) == 0 || rest[0] != ' ' {
+		replacement += " "
+	}
+	if len(matches) > 1 {
+		replacement = commonPrefix(matches)
+		// Candidates come with the words before them joined by one space
+		typed := strings.Join(strings.Fields(prefix), " ")
+		if typed != "" && strings.HasSuffix(prefix, " ") {
+			typed += " "
+		}
+		if len([]rune(replacement)) <= len([]rune(typed)) {
+			fmt.Fprintln(e.out)
+			fmt.Fprintln(e.out, strings.Join(matches, "  "))
+			return
+		}
+	}
+	e.line = append([]rune(replacement), rest...)
+	e.cursor = len([]rune(replacement))
+}
+
+// commonPrefix returns the longest prefix shared by all of words.
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, word := range words[1:] {
+		w := []rune(word)
+		n := 0
+		for n < len(prefix) && n < len(w) && prefix[n] == w[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
+// redraw writes the prompt and the line over the current terminal line
+// and puts the cursor back in place.
+func (e *lineEditor) redraw() {
+	fmt.Fprintf(e.out, "\r\x1b[K%s%s", e.prompt, string(e.line))
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// makeRaw turns off line buffering and echo on tty, so the line editor
+// sees every key, and returns the previous settings for restoreTerminal.
+// Signal keys stay on so that Ctrl-C still stops the server.
+func makeRaw(tty *os.File) (string, error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return "", fmt.Errorf("reading terminal settings: %w", err)
+	}
+	if _, err := stty(tty, "-icanon", "-echo", "min", "1", "time", "0"); err != nil {
+		return "", fmt.Errorf("setting raw mode: %w", err)
+	}
+	return saved, nil
+}
+
+// restoreTerminal puts back the settings makeRaw returned.
+func restoreTerminal(tty *os.File, saved string) error {
+	_, err := stty(tty, saved)
+	return err
+}
+
+// stty runs stty on the terminal tty and returns its output.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// restoreOnSignal puts the terminal settings back before SIGINT or
+// SIGTERM end the server, then lets the signal take its course, until
+// stop is called.
+func restoreOnSignal(tty *os.File, saved string) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		restoreTerminal(tty, saved)
+		signal.Stop(signals)
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Signal(sig)
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	t.Parallel()
+	history := []string{"stats", "kick alice"}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "list\r", "list"},
+		{"backspace", "lisx\x7ft\r", "list"},
+		{"cursor left and insert", "lst\x1b[D\x1b[Di\r", "list"},
+		{"home and end", "ist\x1b[Hl\x1b[F!\r", "list!"},
+		{"delete key", "list\x01\x1b[3~\r", "ist"},
+		{"kill to end", "list all\x1b[D\x1b[D\x1b[D\x1b[D\x0b\r", "list"},
+		{"kill to start", "junk list\x1b[D\x1b[D\x1b[D\x1b[D\x15\x05\r", "list"},
+		{"up recalls newest", "\x1b[A\r", "kick alice"},
+		{"up twice", "\x1b[A\x1b[A\r", "stats"},
+		{"up past oldest", "\x1b[A\x1b[A\x1b[A\r", "stats"},
+		{"down restores draft", "li\x1b[A\x1b[B\r", "li"},
+		{"ss3 arrows", "\x1bOA\r", "kick alice"},
+		{"single completion", "sta\t\r", "stats "},
+		{"common prefix", "ba\t\r", "ban"},
+		{"completion keeps the rest", "ki alice\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\t\r", "kick alice"},
+		{"eof ends a partial line", "list", "list"},
+	}
+	for _, tt := range tests {
+		e := &lineEditor{
+			in:       bufio.NewReader(strings.NewReader(tt.input)),
+			out:      io.Discard,
+			history:  func() []string { return history },
+			complete: completions,
+		}
+		got, err := e.readLine()
+		if err != nil || got != tt.want {
+			t.Errorf("%s: readLine() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestLineEditorListsCompletions(t *testing.T) {
+	t.Parallel()
+	var out strings.Builder
+	e := &lineEditor{
+		in:       bufio.NewReader(strings.NewReader("un\t\r")),
+		out:      &out,
+		history:  func() []string { return nil },
+		complete: completions,
+	}
+	if got, _ := e.readLine(); got != "un" {
+		t.Errorf("Expected ambiguous completion to leave the line alone, got %q", got)
+	}
+	if !strings.Contains(out.String(), "unban  undrain  unmute\n") {
+		t.Errorf("Expected the candidates to be listed, got %q", out.String())
+	}
+}
+
+func TestLineEditorCtrlD(t *testing.T) {
+	t.Parallel()
+	e := &lineEditor{
+		in:       bufio.NewReader(strings.NewReader("\x04")),
+		out:      io.Discard,
+		history:  func() []string { return nil },
+		complete: completions,
+	}
+	if _, err := e.readLine(); err != io.EOF {
+		t.Errorf("Expected Ctrl-D on an empty line to return io.EOF, got %v", err)
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"ban", "banlist"}, "ban"},
+		{[]string{"unban", "undrain", "unmute"}, "un"},
+		{[]string{"kick alice", "kick bob"}, "kick "},
+		{[]string{"stats"}, "stats"},
+		{[]string{"list", "stats"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonPrefix(tt.words); got != tt.want {
+			t.Errorf("commonPrefix(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+)
+
+// maxLineBytes is the longest line accepted from a client, newline
+// excluded. Chat messages have their own, lower limit.
+const maxLineBytes = 4096
+
+var errLineTooLong = errors.New("[line_too_long] Line too long, discarded")
+
+// lineReader reads newline-terminated lines of bounded length. The rest
+// of an oversized line is consumed and dropped so the connection stays in
+// sync, and a partial line survives read timeouts.
+type lineReader struct {
+	r          *bufio.Reader
+	max        int
+	partial    []byte
+	discarding bool
+}
+
+func newLineReader(r *bufio.Reader, max int) *lineReader {
+	return &lineReader{r: r, max: max}
+}
+
+// readLine returns the next line including its newline. It returns
+// errLineTooLong once the end of an oversized line has been read; other
+// errors come from the underlying reader.
+func (l *lineReader) readLine() (string, error) {
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		if !l.discarding {
+			l.partial = append(l.partial, chunk...)
+			length := len(l.partial)
+			if err == nil {
+				length-- // The newline does not count
+			}
+			if length > l.max {
+				l.discarding = true
+				l.partial = l.partial[:0]
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if l.discarding {
+			l.discarding = false
+			return "", errLineTooLong
+		}
+		line := string(l.partial)
+		l.partial = l.partial[:0]
+		return line, nil
+	}
+}
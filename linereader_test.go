@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	t.Parallel()
+	input := "short\n" + strings.Repeat("x", 100000) + "\nafter\n" + strings.Repeat("y", 10) + "\nend"
+	// A small buffer makes the oversized line span many reads
+	lines := newLineReader(bufio.NewReaderSize(strings.NewReader(input), 16), 10)
+
+	steps := []struct {
+		line string
+		err  error
+	}{
+		{"short\n", nil},
+		{"", errLineTooLong},
+		{"after\n", nil},
+		{strings.Repeat("y", 10) + "\n", nil},
+		{"", io.EOF},
+	}
+	for i, step := range steps {
+		line, err := lines.readLine()
+		if line != step.line || err != step.err {
+			t.Errorf("Step %d: expected (%q, %v), got (%q, %v)", i+1, step.line, step.err, line, err)
+		}
+	}
+}
+
+func TestLineReaderKeepsPartialLine(t *testing.T) {
+	t.Parallel()
+	r, w := io.Pipe()
+	lines := newLineReader(bufio.NewReader(&failOnce{r: r}), 100)
+
+	go w.Write([]byte("hel"))
+	if _, err := lines.readLine(); err != errTemporary {
+		t.Fatalf("Expected the injected error, got %v", err)
+	}
+	go w.Write([]byte("lo\n"))
+	if line, err := lines.readLine(); line != "hello\n" || err != nil {
+		t.Errorf("Expected the partial line to survive the error, got (%q, %v)", line, err)
+	}
+}
+
+var errTemporary = io.ErrNoProgress
+
+// failOnce returns errTemporary after its first successful read, like a
+// read deadline expiring in the middle of a line.
+type failOnce struct {
+	r      io.Reader
+	failed bool
+}
+
+func (f *failOnce) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if !f.failed {
+		f.failed = true
+		return n, errTemporary
+	}
+	return n, err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// LinkPolicy restricts the links that may be posted in a room.
+type LinkPolicy struct {
+	Block       bool     `json:"block"`        // Reject every link
+	BlockGuests bool     `json:"block_guests"` // Reject links from guests
+	Allow       []string `json:"allow"`        // Domains links may point to, empty allows any domain not denied
+	Deny        []string `json:"deny"`         // Domains links may not point to
+}
+
+// Rejection codes of the link policy.
+const (
+	codeLinksBlocked = "links_blocked"
+	codeLinkDomain   = "link_domain"
+)
+
+// linkPattern finds links written with a scheme or starting with "www.".
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// linkError is a link policy rejection, sent as "[code] explanation".
+type linkError struct {
+	code, reason string
+}
+
+func (e *linkError) Error() string {
+	return fmt.Sprintf("[%s] %s", e.code, e.reason)
+}
+
+// isGuest reports whether c talks without moderator or operator rights.
+func (c *client) isGuest() bool {
+	return !c.isModerator()
+}
+
+// linkHosts returns the lower-case host names of the links in message.
+func linkHosts(message string) []string {
+	var hosts []string
+	for _, link := range linkPattern.FindAllString(message, -1) {
+		link = strings.TrimRight(link, ".,;:!?)'") // Sentence punctuation
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, strings.ToLower(strings.TrimSuffix(u.Hostname(), ".")))
+	}
+	return hosts
+}
+
+// matchesDomain reports whether host is one of domains or a subdomain of one.
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// check applies the policy of the named room to a message from c.
+func (p LinkPolicy) check(c *client, room, message string) error {
+	if !p.Block && !p.BlockGuests && len(p.Allow) == 0 && len(p.Deny) == 0 {
+		return nil
+	}
+	hosts := linkHosts(message)
+	if len(hosts) == 0 {
+		return nil
+	}
+	if p.Block {
+		return &linkError{codeLinksBlocked, fmt.Sprintf("Links are not allowed in %s", room)}
+	}
+	if p.BlockGuests && c.isGuest() {
+		return &linkError{codeLinksBlocked, fmt.Sprintf("Only moderators and operators may post links in %s", room)}
+	}
+	for _, host := range hosts {
+		if matchesDomain(host, p.Deny) || (len(p.Allow) > 0 && !matchesDomain(host, p.Allow)) {
+			reason := fmt.Sprintf("Links to %s are not allowed in %s", host, room)
+			if len(p.Allow) > 0 {
+				reason += fmt.Sprintf(" (allowed: %s)", strings.Join(p.Allow, ", "))
+			}
+			return &linkError{codeLinkDomain, reason}
+		}
+	}
+	return nil
+}
+
+// describe summarises the policy for /links.
+func (p LinkPolicy) describe() string {
+	var parts []string
+	switch {
+	case p.Block:
+		parts = append(parts, "all links blocked")
+	case p.BlockGuests:
+		parts = append(parts, "links blocked for guests")
+	}
+	if len(p.Allow) > 0 {
+		parts = append(parts, "allowed domains: "+strings.Join(p.Allow, ", "))
+	}
+	if len(p.Deny) > 0 {
+		parts = append(parts, "denied domains: "+strings.Join(p.Deny, ", "))
+	}
+	if len(parts) == 0 {
+		return "no restrictions"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// handleLinksCommand processes "/links [off|block|guests|allow <domain>...|
+// deny <domain>...]" for the current room. Showing the policy is open to
+// everyone, changing it requires operator rights.
+func handleLinksCommand(c *client, call commandCall) {
+	mutex.Lock()
+	r, ok := rooms[c.room]
+	mutex.Unlock()
+	if !ok {
+		c.send(errUnknownRoom.Error())
+		return
+	}
+	args := call.args
+	if len(args) == 0 {
+		mutex.Lock()
+		policy := r.settings.Links
+		mutex.Unlock()
+		c.send(fmt.Sprintf("Link policy of %s: %s", r.name, policy.describe()))
+		return
+	}
+
+	var policy LinkPolicy
+	switch {
+	case args[0] == "off" && len(args) == 1:
+	case args[0] == "block" && len(args) == 1:
+		policy.Block = true
+	case args[0] == "guests" && len(args) == 1:
+		policy.BlockGuests = true
+	case args[0] == "allow" && len(args) > 1:
+		policy.Allow = args[1:]
+	case args[0] == "deny" && len(args) > 1:
+		policy.Deny = args[1:]
+	default:
+		c.send("Usage: /links [off|block|guests|allow <domain>...|deny <domain>...]")
+		return
+	}
+
+	mutex.Lock()
+	r.settings.Links = policy
+	mutex.Unlock()
+	log.Printf("%s set the link policy of %s: %s", c.name, r.name, policy.describe())
+	c.send(fmt.Sprintf("Link policy of %s: %s", r.name, policy.describe()))
+}
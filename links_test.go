@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkHosts(t *testing.T) {
+	t.Parallel()
+	got := linkHosts("see https://Docs.Example.com/x?y=1 and www.golang.org, not example.net")
+	want := []string{"docs.example.com", "www.golang.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestLinkPolicyCheck(t *testing.T) {
+	t.Parallel()
+	guest := &client{name: "guest"}
+	moderator := &client{name: "mod", moderator: true}
+
+	tests := []struct {
+		name     string
+		policy   LinkPolicy
+		sender   *client
+		message  string
+		wantCode string
+	}{
+		{"No policy", LinkPolicy{}, guest, "https://evil.example", ""},
+		{"No links", LinkPolicy{Block: true}, guest, "plain text", ""},
+		{"Block all", LinkPolicy{Block: true}, moderator, "https://example.com", codeLinksBlocked},
+		{"Block guests", LinkPolicy{BlockGuests: true}, guest, "http://example.com", codeLinksBlocked},
+		{"Guests rule spares moderators", LinkPolicy{BlockGuests: true}, moderator, "http://example.com", ""},
+		{"Allowed subdomain", LinkPolicy{Allow: []string{"golang.org"}}, guest, "https://pkg.golang.org/x", ""},
+		{"Not allowed", LinkPolicy{Allow: []string{"golang.org"}}, guest, "https://notgolang.org", codeLinkDomain},
+		{"Denied", LinkPolicy{Deny: []string{"bit.ly"}}, guest, "www.bit.ly/abc", codeLinkDomain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.check(tt.sender, "#room", tt.message)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("Expected no rejection, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), "["+tt.wantCode+"]") {
+				t.Errorf("Expected rejection %s, got %v", tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestHandleLinksCommand(t *testing.T) {
+	r, err := createRoom("#links-test", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mutex.Lock()
+		delete(rooms, r.name)
+		mutex.Unlock()
+	}()
+
+	userConn, operConn := newMockConn(), newMockConn()
+	user := &client{conn: userConn, name: "user", room: r.name}
+	oper := &client{conn: operConn, name: "oper", room: r.name, operator: true}
+
+	handleCommand(user, "/links block", time.Now())
+	if !strings.Contains(userConn.writeBuffer.String(), "Permission denied") {
+		t.Errorf("Expected users to be refused, got %q", userConn.writeBuffer.String())
+	}
+	handleCommand(oper, "/links allow golang.org", time.Now())
+	if err := checkRoomPolicy(user, r.name, "https://example.com"); err == nil {
+		t.Error("Expected the new policy to apply to the room")
+	}
+	handleCommand(user, "/links", time.Now())
+	if got := userConn.writeBuffer.String(); !strings.Contains(got, "allowed domains: golang.org") {
+		t.Errorf("Expected the policy to be shown, got %q", got)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// locale describes how counts and timestamps are written in notices.
+type locale struct {
+	thousands string // Separator between digit groups, empty for none
+	clock     string // Layout of dates with times
+}
+
+// defaultLocale keeps the notices as they were before locales existed.
+var defaultLocale = locale{clock: "2006-01-02 15:04:05"}
+
+// locales are the supported locales by lower-case tag. Tags with a region
+// fall back to their language when only the language is listed.
+var locales = map[string]locale{
+	"en":    {thousands: ",", clock: "01/02/2006 3:04:05 PM"},
+	"en-gb": {thousands: ",", clock: "02/01/2006 15:04:05"},
+	"de":    {thousands: ".", clock: "02.01.2006 15:04:05"},
+	"es":    {thousands: ".", clock: "02/01/2006 15:04:05"},
+	"fr":    {thousands: " ", clock: "02/01/2006 15:04:05"},
+	"ja":    {thousands: ",", clock: "2006/01/02 15:04:05"},
+}
+
+// lookupLocale finds the locale for a tag such as "de-AT" or "en_US".
+func lookupLocale(tag string) (locale, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if l, ok := locales[tag]; ok {
+		return l, true
+	}
+	language, _, _ := strings.Cut(tag, "-")
+	l, ok := locales[language]
+	return l, ok
+}
+
+// number writes n with the locale's digit grouping.
+func (l locale) number(n int) string {
+	digits := strconv.Itoa(n)
+	if l.thousands == "" {
+		return digits
+	}
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(l.thousands)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// time writes t as a local date and time.
+func (l locale) time(t time.Time) string {
+	return t.Local().Format(l.clock)
+}
+
+// locale returns the locale chosen by c, or the default one.
+func (c *client) locale() locale {
+	if l, ok := lookupLocale(c.localeTag); ok {
+		return l
+	}
+	return defaultLocale
+}
+
+// handleLocaleCommand processes "/locale [tag|default]", which shows or
+// sets how the server writes numbers and times for the client.
+func handleLocaleCommand(c *client, call commandCall) {
+	switch {
+	case len(call.args) == 0:
+		current := c.localeTag
+		if current == "" {
+			current = "default"
+		}
+		c.send(fmt.Sprintf("Locale: %s (available: %s)", current, strings.Join(localeTags(), ", ")))
+	case len(call.args) == 1 && call.args[0] == "default":
+		c.localeTag = ""
+		c.send("Locale reset to the server default")
+	case len(call.args) == 1:
+		if _, ok := lookupLocale(call.args[0]); !ok {
+			c.send(fmt.Sprintf("Unknown locale %q (available: %s)", call.args[0], strings.Join(localeTags(), ", ")))
+			return
+		}
+		c.localeTag = call.args[0]
+		c.send(fmt.Sprintf("Locale set to %s, e.g. %s", call.args[0], c.locale().time(time.Now())))
+	default:
+		c.send("Usage: /locale [tag|default]")
+	}
+}
+
+// localeTags lists the supported locale tags in sorted order.
+func localeTags() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocaleNumber(t *testing.T) {
+	t.Parallel()
+	de, _ := lookupLocale("de")
+	en, _ := lookupLocale("en")
+	tests := []struct {
+		name string
+		loc  locale
+		n    int
+		want string
+	}{
+		{"Default has no grouping", defaultLocale, 1234567, "1234567"},
+		{"English", en, 1234567, "1,234,567"},
+		{"German", de, 1234, "1.234"},
+		{"Small", de, 999, "999"},
+		{"Negative", en, -12345, "-12,345"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.loc.number(tt.n); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLocaleTime(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+	for tag, want := range map[string]string{
+		"en-US": "05/01/2024 3:04:05 PM",
+		"en_GB": "01/05/2024 15:04:05",
+		"de-AT": "01.05.2024 15:04:05",
+	} {
+		loc, ok := lookupLocale(tag)
+		if !ok {
+			t.Fatalf("Expected %s to be supported", tag)
+		}
+		if got := loc.time(at); got != want {
+			t.Errorf("%s: expected %q, got %q", tag, want, got)
+		}
+	}
+	if got := defaultLocale.time(at); got != "2024-05-01 15:04:05" {
+		t.Errorf("Expected the default layout to stay unchanged, got %q", got)
+	}
+	if _, ok := lookupLocale("xx"); ok {
+		t.Error("Expected unknown locales to be refused")
+	}
+}
+
+func TestHandleLocaleCommand(t *testing.T) {
+	t.Parallel()
+	conn := newMockConn()
+	c := &client{conn: conn, name: "alice"}
+
+	handleLocaleCommand(c, callOf("/locale xx"))
+	if c.localeTag != "" || !strings.Contains(conn.writeBuffer.String(), "Unknown locale") {
+		t.Errorf("Expected unknown locales to be refused, got %q", conn.writeBuffer.String())
+	}
+	handleLocaleCommand(c, callOf("/locale de-DE"))
+	if got := c.locale().number(1000); got != "1.000" {
+		t.Errorf("Expected German grouping, got %q", got)
+	}
+	handleLocaleCommand(c, callOf("/locale default"))
+	if c.locale() != defaultLocale {
+		t.Error("Expected the default locale after reset")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -95,13 +97,70 @@ const (
 	maxConnections = 10
 )
 
+// client holds the state kept for each registered connection.
+type client struct {
+	conn      net.Conn
+	name      string     // Changed by /nick holding mutex and nameMu, see currentName
+	nameMu    sync.Mutex // Lets other goroutines read name without taking mutex
+	ip        string
+	operator  bool   // Set after a successful /oper
+	moderator bool   // Set after /oper with the moderator password
+	bot       bool   // Logged in with the token of a registered bot
+	guest     bool   // Joined in guest mode with a generated name
+	localeTag string // Locale chosen with /locale, empty for the default
+	version   string // Protocol version negotiated in the handshake
+	caps      capSet // Capabilities enabled with /cap
+
+	room        string            // Room the client is talking in, protected by mutex
+	away        string            // Away message set with /away, protected by mutex
+	ignored     map[string]string // Names ignored with /ignore by lowercase name, protected by mutex
+	replyTo     string            // Sender of the latest private message, for /r, protected by mutex
+	quit        bool              // Set when the client left with /quit, protected by mutex
+	quitMessage string            // Farewell given with /quit, protected by mutex
+	lastMessage time.Time         // Time of the last chat message, for room slow mode
+	lastTyping  time.Time         // Time of the last relayed typing notice
+	flood       floodState
+	repeat      repeatState
+	writeMu     sync.Mutex        // Keeps the lines of concurrent senders whole
+	slowWrites  atomic.Int32      // Consecutive writes that hit the deadline
+	slowKicked  atomic.Bool       // Set once the client is dropped as too slow
+	outbox      chan outboundLine // Broadcast lines for the session's writer, nil for clients without one
+	dnd         atomic.Bool       // Set with /dnd while private messages are turned away
+
+	unansweredPings atomic.Int32 // PINGs sent since the last PONG
+
+	session     string        // Token for resuming the session, empty without the resume capability
+	cursor      atomic.Uint64 // Latest message ID sent to the client
+	resumeAfter uint64        // Message ID after which history is replayed while resuming, 0 for all
+}
+
 var (
-	clients   = make(map[net.Conn]string) // Map to store client connections and names
-	mutex     sync.Mutex                  // Mutex to protect access to the clients map
-	messages  []string                    // Slice to store chat messages
-	connCount int                         // Counter for active connections
+	clients   = make(map[net.Conn]*client) // Map to store client connections and their state
+	mutex     sync.Mutex                   // Mutex to protect access to the clients and rooms maps
+	connCount int                          // Counter for active connections
 )
 
+// currentName returns the name of the client. Only /nick changes it, on
+// the client's own goroutine, so other goroutines not holding mutex must
+// read it through here.
+func (c *client) currentName() string {
+	c.nameMu.Lock()
+	defer c.nameMu.Unlock()
+	return c.name
+}
+
+// send writes a single line to the client, logging failed writes.
+func (c *client) send(message string) error {
+	c.writeMu.Lock()
+	err := writeFull(c.conn, []byte(message+"\n"))
+	c.writeMu.Unlock()
+	c.noteWrite(err)
+	if err != nil {
+		log.Printf("Error sending message to %s: %v", c.currentName(), err)
+	}
+	return err
+}
+
 // GetClients returns a copy of the clients map for testing purposes
 func GetClients() map[net.Conn]string {
 	mutex.Lock()
@@ -110,7 +169,7 @@ func GetClients() map[net.Conn]string {
 	// Create a new map and copy all entries
 	clientsCopy := make(map[net.Conn]string)
 	for k, v := range clients {
-		clientsCopy[k] = v
+		clientsCopy[k] = v.name
 	}
 	return clientsCopy
 }
@@ -125,6 +184,89 @@ func main() {
 		port = os.Args[1]
 	}
 
+	cfg, err := loadConfig(configPath())
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	config = cfg
+
+	bans, err = loadBanList(config.BanFile)
+	if err != nil {
+		log.Fatalf("Error loading ban list: %v", err)
+	}
+	mutes, err = loadMuteList(config.MuteFile)
+	if err != nil {
+		log.Fatalf("Error loading mute list: %v", err)
+	}
+
+	groups, err = loadGroupStore(config.GroupFile)
+	if err != nil {
+		log.Fatalf("Error loading groups: %v", err)
+	}
+
+	canned, err = loadCannedStore(config.CannedFile)
+	if err != nil {
+		log.Fatalf("Error loading canned replies: %v", err)
+	}
+
+	seen, err = loadSeenStore(config.SeenFile)
+	if err != nil {
+		log.Fatalf("Error loading last-seen records: %v", err)
+	}
+
+	offline, err = loadOfflineStore(config.OfflineFile)
+	if err != nil {
+		log.Fatalf("Error loading offline messages: %v", err)
+	}
+
+	audit, err = openAuditLog(config.AuditFile)
+	if err != nil {
+		log.Fatalf("Error opening audit log: %v", err)
+	}
+	events, err = openAuditLog(config.EventFile)
+	if err != nil {
+		log.Fatalf("Error opening event log: %v", err)
+	}
+
+	profanity, err = loadWordFilter(config.ProfanityFile)
+	if err != nil {
+		log.Fatalf("Error loading profanity wordlist: %v", err)
+	}
+	reloadOnHangup()
+	if err := registerConfiguredHooks(config.MessageHooks); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
+	if err := startScheduledMessages(config.ScheduledMessages); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
+
+	general, err := newRoom(defaultRoom, "")
+	if err != nil {
+		log.Fatalf("Error creating %s: %v", defaultRoom, err)
+	}
+	rooms[defaultRoom] = general
+
+	if config.HTTPAddr != "" {
+		startHTTPServer(config.HTTPAddr)
+	}
+	if config.AdminAddr != "" {
+		if err := startAdminConsole(config.AdminAddr); err != nil {
+			log.Fatalf("Error starting admin console: %v", err)
+		}
+	}
+	if config.WebSocketAddr != "" {
+		if err := startWebSocketGateway(config.WebSocketAddr); err != nil {
+			log.Fatalf("Error starting WebSocket gateway: %v", err)
+		}
+	}
+	if isTerminal(os.Stdin) {
+		startStdinConsole()
+	}
+
+	setupAcceptThrottle()
+	if connFilter, err = newAddressFilter(config.AllowCIDRs, config.DenyCIDRs); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
 	ln, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
@@ -132,47 +274,171 @@ func main() {
 	defer ln.Close()
 
 	fmt.Println("Listening on the port :" + port)
+	serve(ln)
+}
 
+// serve accepts clients on ln until it is closed. The server must be
+// configured beforehand, as main does.
+func serve(ln net.Listener) {
 	for {
 		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			log.Printf("Error accepting connection: %v", err)
 			continue
 		}
-
-		// Validate connection by checking first bytes
-		buf := make([]byte, 1024)
-		n, err := conn.Read(buf)
-		if err != nil || !strings.HasPrefix(string(buf[:n]), "CHAT/1.0") {
-			conn.Write([]byte("Invalid protocol. Please use TCP chat client.\n"))
-			conn.Close()
+		if !admissible(conn) {
 			continue
 		}
+		go admitConnection(conn)
+	}
+}
 
-		mutex.Lock()
-		if connCount >= maxConnections {
-			mutex.Unlock()
-			conn.Write([]byte("Server is full. Please try again later.\n"))
-			conn.Close()
-			continue
+// admissible runs the checks made right after accepting conn, before any
+// work is spent on it. A refused connection is closed.
+func admissible(conn net.Conn) bool {
+	// Refuse addresses outside the configured ranges before anything else
+	if !connFilter.permits(remoteIP(conn)) {
+		conn.Close()
+		return false
+	}
+
+	// Throttle connection floods before any further work
+	if !throttleAccept(conn) {
+		return false
+	}
+
+	// Refuse new connections while draining
+	if draining.Load() {
+		conn.Write([]byte("Server is not accepting new connections. Please try again later.\n"))
+		conn.Close()
+		return false
+	}
+
+	// Addresses that keep failing are dropped without a word
+	if failures.isBlocked(remoteIP(conn), time.Now()) {
+		conn.Close()
+		return false
+	}
+	return true
+}
+
+// refuseBanned closes conn with a notice when its address is banned and
+// reports whether it did.
+func refuseBanned(conn net.Conn) bool {
+	if !bans.isBanned("", remoteIP(conn)) {
+		return false
+	}
+	conn.Write([]byte("You are banned from this server.\n"))
+	conn.Close()
+	return true
+}
+
+// admitConnection runs the checks between accepting a connection and
+// starting its session. It runs on its own goroutine so that a slow or
+// silent peer cannot stall the accept loop.
+func admitConnection(conn net.Conn) {
+	ip := remoteIP(conn)
+
+	// Refuse banned addresses before any protocol exchange
+	if refuseBanned(conn) {
+		return
+	}
+
+	// Validate connection by checking first bytes
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	n, err := conn.Read(buf)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil || !strings.HasPrefix(string(buf[:n]), "CHAT/1.0") {
+		delay, blocked := failures.record(ip, failureHandshake, time.Now())
+		if blocked {
+			log.Printf("Blocking %s after repeated failed handshakes", ip)
 		}
-		connCount++
-		mutex.Unlock()
-		go handleConnection(conn)
+		time.Sleep(delay)
+		conn.Write([]byte("Invalid protocol. Please use TCP chat client.\n"))
+		conn.Close()
+		return
 	}
+	openSession(conn, string(buf[:n]))
 }
 
+// openSession answers the handshake line of conn, which starts with the
+// CHAT/1.0 prefix, and serves the session it asks for.
+func openSession(conn net.Conn, line string) {
+	hs, err := parseHandshake(line)
+	if err == errUnsupportedEncoding {
+		conn.Write([]byte(fmt.Sprintf("%s. Server supports: %s\n", err, strings.Join(encodings, ","))))
+		conn.Close()
+		return
+	}
+	if err != nil {
+		conn.Write([]byte(unsupportedVersionReply() + "\n"))
+		conn.Close()
+		return
+	}
+	if hs.compress != "" {
+		// The answer to the offer is the last uncompressed line
+		conn.Write([]byte(compressionReply(hs.compress) + "\n"))
+		if hs.compress != compressNone {
+			conn = newCompressedConn(conn, hs.compress)
+		}
+	}
+	if hs.encoding != encodingText {
+		// Everything from here on, replies included, is encoded
+		conn = newEncodedConn(conn, hs.encoding)
+	}
+	if hs.guest && !config.Guests.Enabled {
+		conn.Write([]byte(errGuestsDisabled.Error() + "\n"))
+		conn.Close()
+		return
+	}
+	if hs.negotiated {
+		conn.Write([]byte(versionReply(hs.version) + "\n" + capListLine() + "\n"))
+	}
+	if len(hs.caps) > 0 {
+		conn.Write([]byte("CAP ACK " + strings.Join(hs.caps, " ") + "\n"))
+	}
+
+	if err := acquireConnection(remoteIP(conn)); err != nil {
+		conn.Write([]byte(err.Error() + "\n"))
+		conn.Close()
+		return
+	}
+	serveConnection(conn, hs)
+}
+
+// handleConnection runs the session of a client that picks its own name.
 func handleConnection(conn net.Conn) {
+	serveConnection(conn, handshake{version: protocolVersion, encoding: encodingText})
+}
+
+// serveConnection runs a client session from the logo to the disconnect.
+// Guests skip the name prompt and get a generated name.
+func serveConnection(conn net.Conn, hs handshake) {
 	defer func() {
 		conn.Close()
+		releaseConnection(remoteIP(conn))
 		mutex.Lock()
-		connCount--
+		c, ok := clients[conn]
+		delete(clients, conn)
 		mutex.Unlock()
-		if _, ok := clients[conn]; ok {
-			clientName := clients[conn]
-			delete(clients, conn)
-			broadcastMessage(fmt.Sprintf("%s has left our chat...", clientName), conn)
-			log.Printf("Client disconnected: %s", clientName)
+		if ok {
+			mutex.Lock()
+			quit := c.quit
+			mutex.Unlock()
+			if !quit {
+				suspendSession(c)
+			}
+			recordSeen(c.name, c.room, c.guest)
+			forgetPresenceWatches(c)
+			forgetReminders(c)
+			notifyPresence(c.name, "went offline")
+			broadcastMessage(leaveNotice(c), conn)
+			events.record(auditEntry{Action: "leave", Actor: c.name, Room: c.room})
+			log.Printf("Client disconnected: %s", c.name)
 		}
 	}()
 
@@ -186,9 +452,10 @@ func handleConnection(conn net.Conn) {
 		reader = bufio.NewReader(conn)
 	}
 
-	// Send welcome messages
-	if !isMock {
-		_, err := conn.Write([]byte("Welcome to TCP-Chat!\n"))
+	// Send welcome messages; programs on a structured encoding skip the logo
+	encoded := hs.encoding != encodingText
+	if !isMock && !encoded {
+		_, err := conn.Write([]byte(expandBanner(config.Banner, "") + "\n"))
 		if err != nil {
 			log.Printf("Error sending welcome message: %v", err)
 			return
@@ -216,6 +483,9 @@ func handleConnection(conn net.Conn) {
 	}
 
 	// Send logo with slight delay between lines for proper rendering
+	if encoded {
+		logo = nil
+	}
 	for _, line := range logo {
 		_, err := conn.Write([]byte(line + "\n"))
 		if err != nil {
@@ -224,64 +494,142 @@ func handleConnection(conn net.Conn) {
 		}
 		time.Sleep(50 * time.Millisecond) // Add slight delay between lines
 	}
-	
+
 	// Add extra newline after logo for better spacing
 	conn.Write([]byte("\n"))
 
-	// Prompt for the client's name
 	var err error
-	if !isMock {
-		_, err = conn.Write([]byte("[ENTER YOUR NAME]: "))
+	var clientName, botToken string
+	lines := newLineReader(reader, maxLineBytes)
+	if !hs.guest {
+		// Prompt for the client's name
+		if !isMock {
+			_, err = conn.Write([]byte("[ENTER YOUR NAME]: "))
+			if err != nil {
+				log.Printf("Error sending name prompt: %v", err)
+				return
+			}
+		}
+
+		// Read client name
+		answer, err := readAnswer(conn, lines)
 		if err != nil {
-			log.Printf("Error sending name prompt: %v", err)
+			log.Printf("Error reading client name: %v", err)
 			return
 		}
+		clientName, botToken = splitBotLogin(strings.TrimSpace(answer))
 	}
 
-	// Read client name
-	clientName, err := reader.ReadString('\n')
-	if err != nil {
-		log.Printf("Error reading client name: %v", err)
-		return
-	}
-	clientName = strings.TrimSpace(clientName)
+	// admitName runs the name checks and refuses the connection when one
+	// fails. It reports whether the name may be used and whether it logs
+	// in as a registered bot.
+	admitName := func(name, token string) (ok, isBot bool) {
+		if err := validateName(name); err != nil {
+			_, err := conn.Write([]byte(err.Error() + ". Please reconnect.\n"))
+			if err != nil {
+				log.Printf("Error sending invalid name message: %v", err)
+			}
+			conn.Close()
+			return false, false
+		}
 
-	// Validate name
-	if clientName == "" {
-		_, err := conn.Write([]byte("Name cannot be empty. Please reconnect.\n"))
+		isBot, err := checkBotName(name, token, time.Now())
 		if err != nil {
-			log.Printf("Error sending empty name message: %v", err)
+			if err == errBotToken {
+				failures.record(remoteIP(conn), failureAuth, time.Now())
+			}
+			conn.Write([]byte(err.Error() + ". Please reconnect.\n"))
+			conn.Close()
+			return false, false
 		}
-		conn.Close()
-		return
-	}
 
-	// Check for duplicate names and add client
-	mutex.Lock()
-	defer mutex.Unlock()
-	
-	// First check if connection already exists
-	if _, exists := clients[conn]; exists {
-		return
+		if bans.isBanned(name, "") {
+			_, err := conn.Write([]byte("You are banned from this server.\n"))
+			if err != nil {
+				log.Printf("Error sending ban message: %v", err)
+			}
+			conn.Close()
+			return false, false
+		}
+		return true, isBot
 	}
 
-	// Check for duplicate names
-	for _, name := range clients {
-		if name == clientName {
-			_, err := conn.Write([]byte("Name is already in use. Please choose a different name.\n"))
+	var c *client
+	if hs.guest {
+		mutex.Lock()
+		clientName = newGuestName()
+		if clientName == "" {
+			mutex.Unlock()
+			conn.Write([]byte(errGuestsFull.Error() + "\n"))
+			return
+		}
+		c = &client{conn: conn, name: clientName, ip: remoteIP(conn), room: defaultRoom, guest: true, version: hs.version, outbox: newOutbox()}
+		clients[conn] = c
+	} else {
+		// Check for duplicate names and add client. A taken name gets a
+		// few more tries, with a numbered alternative offered each time.
+		for attempt := 1; ; attempt++ {
+			ok, isBot := admitName(clientName, botToken)
+			if !ok {
+				return
+			}
+
+			mutex.Lock()
+
+			// First check if connection already exists
+			if _, exists := clients[conn]; exists {
+				mutex.Unlock()
+				return
+			}
+
+			if !nameInUse(clientName) {
+				// Add client to map
+				c = &client{conn: conn, name: clientName, ip: remoteIP(conn), room: defaultRoom, bot: isBot, version: hs.version, outbox: newOutbox()}
+				clients[conn] = c
+				break
+			}
+			suggestion := suggestName(clientName, nameInUse)
+			mutex.Unlock()
+
+			if attempt >= maxNamePrompts {
+				_, err := conn.Write([]byte(errNameTaken.Error() + ". Please choose a different name.\n"))
+				if err != nil {
+					log.Printf("Error sending duplicate name message: %v", err)
+				}
+				conn.Close()
+				return
+			}
+
+			_, err := conn.Write([]byte(fmt.Sprintf("%s. Press Enter to use %s or type another name: ", errNameTaken, suggestion)))
 			if err != nil {
 				log.Printf("Error sending duplicate name message: %v", err)
+				return
+			}
+			answer, err := readAnswer(conn, lines)
+			if err != nil {
+				log.Printf("Error reading client name: %v", err)
+				return
+			}
+			clientName, botToken = splitBotLogin(strings.TrimSpace(answer))
+			if clientName == "" {
+				clientName = suggestion
 			}
-			conn.Close()
-			return
 		}
 	}
-	
-	// Add client to map
-	clients[conn] = clientName
+	mutex.Unlock()
+	for _, name := range hs.caps {
+		c.caps.set(name, true)
+	}
+	writerDone := make(chan struct{})
+	defer close(writerDone)
+	go c.writeOutbox(writerDone)
 
 	// Send confirmation message and wait for it to complete
-	_, err = conn.Write([]byte(fmt.Sprintf("Welcome, %s!\n", clientName)))
+	welcome := expandBanner(config.Welcome, clientName)
+	if hs.guest {
+		welcome += " You are connected as a guest."
+	}
+	_, err = conn.Write([]byte(welcome + "\n"))
 	if err != nil {
 		log.Printf("Error sending welcome message: %v", err)
 		mutex.Lock()
@@ -290,114 +638,241 @@ func handleConnection(conn net.Conn) {
 		return
 	}
 
-	// Send previous messages to the new client
-	for _, msg := range messages {
-		_, err := conn.Write([]byte(msg + "\n"))
-		if err != nil {
-			log.Printf("Error sending previous message: %v", err)
-		}
+	sendMOTD(c)
+
+	// Send previous messages to the new client, or the ones it missed
+	// when it resumes a session
+	startSession(c)
+	if !resumeSession(c, hs) {
+		sendHistory(c, defaultRoom)
 	}
 
+	sendGreeting(c, defaultRoom)
+	sendPins(c, defaultRoom)
+
+	// Deliver group mentions and private messages queued while the
+	// client was offline
+	deliverPendingNotices(c)
+	deliverOfflinePMs(c)
+
 	// Notify other clients about the new connection
-	broadcastMessage(fmt.Sprintf("%s has joined our chat...", clientName), conn)
+	broadcastMessage(formatSystemMessage(fmt.Sprintf("%s has joined our chat...", c.displayName())), conn)
+	events.record(auditEntry{Action: "join", Actor: clientName, Room: defaultRoom})
+	notifyPresence(clientName, "is online")
 
 	log.Printf("Client connected: %s", clientName)
 
 	// Handle incoming messages from the client
 	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	lastActivity, idleWarned := time.Now(), false
+	heartbeatDone := make(chan struct{})
+	defer close(heartbeatDone)
+	go runHeartbeat(c, time.Duration(config.Heartbeat.Interval)*time.Second, config.Heartbeat.MissedPongs, heartbeatDone)
 	for {
-		message, err := reader.ReadString('\n')
+		message, err := lines.readLine()
+		if err == nil && handleHeartbeat(c, message) {
+			continue
+		}
+		if err == nil || err == errLineTooLong {
+			lastActivity, idleWarned = time.Now(), false
+		}
+		if err == errLineTooLong {
+			log.Printf("Discarded oversized line from %s", c.name)
+			c.send(fmt.Sprintf("%s (max %d bytes)", errLineTooLong, maxLineBytes))
+			continue
+		}
 		if err != nil {
 			// Handle client disconnection
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				// Continue on timeout
+				// Continue on timeout unless the client has gone quiet for too long
+				warn, kick := checkIdle(lastActivity, time.Now(), idleWarned)
+				if kick {
+					log.Printf("Disconnecting idle client %s", c.name)
+					c.send("Disconnected after being idle for too long")
+					return
+				}
+				if warn {
+					idleWarned = true
+					c.send(fmt.Sprintf("You have been idle for a while and will be disconnected in %s seconds unless you send something",
+						c.locale().number(int((idleTimeout() - time.Since(lastActivity)).Seconds()))))
+				}
 				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
 				continue
 			}
-			mutex.Lock()
-			delete(clients, conn)
-			mutex.Unlock()
-			broadcastMessage(fmt.Sprintf("%s has left our chat...", clientName), conn)
-			log.Printf("Client disconnected: %s", clientName)
 			return
 		}
+		received := time.Now()
 
+		// Nothing a client sends may reach other terminals as control codes
+		if message, err = sanitizeMessage(message); err != nil {
+			c.send(err.Error())
+			continue
+		}
 		message = strings.TrimSpace(message)
 		if message == "" {
 			continue
 		}
 
-		// Handle private messages
-		if strings.HasPrefix(message, "/msg ") {
-			parts := strings.SplitN(message, " ", 3)
-			if len(parts) == 3 {
-				recipient := parts[1]
-				privateMessage := parts[2]
-				if targetConn := findConnectionByName(recipient); targetConn != nil {
-					privateMsg := fmt.Sprintf("[PM from %s]: %s", clientName, privateMessage)
-					targetConn.Write([]byte(privateMsg + "\n"))
-					conn.Write([]byte(fmt.Sprintf("[PM to %s]: %s\n", recipient, privateMessage)))
-					continue
-				} else {
-					conn.Write([]byte(fmt.Sprintf("User %s not found\n", recipient)))
-					continue
-				}
+		// Lines of a code block are its text, never commands
+		inBlock := continueBlock(c, message)
+
+		// Muted clients can still read and use commands but cannot talk
+		if (inBlock || !strings.HasPrefix(message, "/")) && isMuted(c) {
+			c.send("You are muted and cannot send messages")
+			continue
+		}
+		// Typing notices are throttled on their own rather than by the flood
+		// limit, and code blocks were counted by their opening fence
+		if message != typingCommand && !inBlock {
+			if err := checkFlood(c, message, received); err != nil {
+				c.send(err.Error())
+				continue
 			}
 		}
 
-		// Handle /list command
-		if message == "/list" {
-			mutex.Lock()
-			var userList []string
-			for _, name := range clients {
-				userList = append(userList, name)
-			}
-			mutex.Unlock()
-			conn.Write([]byte(fmt.Sprintf("Connected users: %s\n", strings.Join(userList, ", "))))
+		// Handle commands
+		if !inBlock && handleCommand(c, message, received) {
 			continue
 		}
 
-		// Enforce message size limit
-		if len(message) > 1024 {
-			conn.Write([]byte("Message too long (max 1024 characters)\n"))
+		message, ok := screenChatMessage(c, message, received)
+		if !ok {
 			continue
 		}
 
-		// Broadcast regular message
-		fullMessage := fmt.Sprintf("%s: %s", clientName, message)
-		messages = append(messages, fullMessage)
-		broadcastMessage(fullMessage, conn)
+		// Broadcast regular message to the room
+		fullMessage, err := formatChatMessage(c.name, message)
+		if err != nil {
+			log.Printf("Refusing message from %s: %v", c.name, err)
+			continue
+		}
+		publishChatMessage(c, fullMessage, message, received)
+	}
+}
+
+// screenChatMessage runs the checks a chat message goes through before it
+// is posted to the room of c, see screenRoomMessage.
+func screenChatMessage(c *client, message string, received time.Time) (string, bool) {
+	return screenRoomMessage(c, c.room, message, received)
+}
+
+// screenRoomMessage runs the checks a chat message goes through before c
+// posts it to room and returns its text after the filters. Refusals are
+// explained to the client and ok is false.
+func screenRoomMessage(c *client, room, message string, received time.Time) (string, bool) {
+	// Enforce message size limit
+	if len(message) > 1024 {
+		c.send("Message too long (max 1024 characters)")
+		return "", false
+	}
+
+	if err := checkRepeat(c, message, received); err != nil {
+		c.send(err.Error())
+		return "", false
+	}
+
+	// Apply the settings of the client's room
+	if err := checkRoomPolicy(c, room, message); err != nil {
+		c.send(err.Error())
+		return "", false
 	}
+	message, err := applyProfanityFilter(c, room, message)
+	if err != nil {
+		c.send(err.Error())
+		return "", false
+	}
+	if message, err = applyHooks(c, room, message, received); err != nil {
+		c.send(err.Error())
+		return "", false
+	}
+	return message, true
+}
+
+// publishChatMessage posts line, the formatted chat message with text, to
+// the room of c and records it.
+func publishChatMessage(c *client, line, text string, received time.Time) {
+	if isShadowBanned(c) {
+		// The sender sees their own line as usual, nobody else does
+		return
+	}
+	postToRoom(c.room, line, c.conn, received)
+	events.record(auditEntry{Time: received, Action: "message", Actor: c.name, Room: c.room, Text: text})
+	resolveEscalations(c, c.room)
+	notifyGroupMentions(c, c.room, text)
+	scheduleEscalations(c, c.room, text)
 }
 
 func findConnectionByName(name string) net.Conn {
-	for conn, clientName := range clients {
-		if clientName == name {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for conn, c := range clients {
+		if c.name == name {
 			return conn
 		}
 	}
 	return nil
 }
 
-func broadcastMessage(message string, sender net.Conn) {
-	mutex.Lock()
-	clientsCopy := make(map[net.Conn]string)
-	for k, v := range clients {
-		clientsCopy[k] = v
+// handleOperCommand grants operator or moderator rights when the matching
+// configured password is given.
+func handleOperCommand(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send("Usage: /oper <password>")
+		return
 	}
-	mutex.Unlock()
+	if config.OperPassword == "" && config.ModeratorPassword == "" {
+		c.send("Operator access is not configured on this server")
+		return
+	}
+	switch {
+	case config.OperPassword != "" && call.args[0] == config.OperPassword:
+		c.operator = true
+		log.Printf("%s is now an operator", c.name)
+		c.send("You are now an operator")
+	case config.ModeratorPassword != "" && call.args[0] == config.ModeratorPassword:
+		c.moderator = true
+		log.Printf("%s is now a moderator", c.name)
+		c.send("You are now a moderator")
+	default:
+		log.Printf("Failed operator login from %s (%s)", c.name, c.ip)
+		delay, blocked := failures.record(c.ip, failureAuth, time.Now())
+		time.Sleep(delay)
+		if blocked {
+			log.Printf("Blocking %s after repeated failed logins", c.ip)
+			c.send("Too many failed attempts")
+			c.conn.Close()
+			return
+		}
+		c.send("Invalid operator password")
+	}
+}
 
-	for conn, name := range clientsCopy {
-		if conn != sender {
-			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-			_, err := conn.Write([]byte(message + "\n"))
-			if err != nil {
-				log.Printf("Error broadcasting message to %s: %v", name, err)
-				// Remove disconnected client
-				mutex.Lock()
-				delete(clients, conn)
-				mutex.Unlock()
+// isModerator reports whether the client may use moderation commands.
+// Operators are always moderators.
+func (c *client) isModerator() bool {
+	return c.operator || c.moderator
+}
+
+func broadcastMessage(message string, sender net.Conn) {
+	received := time.Now()
+	broadcasts.submit(func() {
+		mutex.Lock()
+		recipients := make([]*client, 0, len(clients))
+		for conn, c := range clients {
+			if conn != sender {
+				recipients = append(recipients, c)
 			}
 		}
-	}
+		recipients = skipIgnorersLocked(recipients, sender)
+		mutex.Unlock()
+
+		// Failed recipients are unregistered by their own read loop; slow
+		// ones are disconnected by their writer or a full outbox
+		id := nextMessageID()
+		for _, c := range recipients {
+			name := c.currentName()
+			c.queueID(id, message, func() { recordWrite(classSystem, received, name) })
+		}
+	})
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maintenanceJobKey     = "maintenance" // Scheduler key of the countdown
+	maxMaintenanceMinutes = 24 * 60       // Furthest maintenance can be scheduled ahead
+)
+
+// maintenanceWarnings are the times before maintenance at which everyone
+// is warned, longest first.
+var maintenanceWarnings = []time.Duration{
+	60 * time.Minute, 30 * time.Minute, 15 * time.Minute, 10 * time.Minute,
+	5 * time.Minute, 2 * time.Minute, time.Minute, 30 * time.Second,
+}
+
+// maintenance is the scheduled maintenance window, if any.
+var maintenance struct {
+	sync.Mutex
+	at     time.Time // Start of the maintenance, zero when none is scheduled
+	reason string
+}
+
+// nextMaintenanceWarning returns the first warning due after remaining time
+// is left, and false when only the start itself is left.
+func nextMaintenanceWarning(remaining time.Duration) (time.Duration, bool) {
+	for _, warning := range maintenanceWarnings {
+		if warning < remaining {
+			return warning, true
+		}
+	}
+	return 0, false
+}
+
+// countdown writes a time left until maintenance, such as "5 minutes".
+func countdown(left time.Duration) string {
+	left = left.Round(time.Second)
+	n, unit := int(left.Round(time.Minute).Minutes()), "minute"
+	if left < time.Minute {
+		n, unit = int(left.Seconds()), "second"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// maintenanceNotice is the warning broadcast while left time remains.
+func maintenanceNotice(left time.Duration, reason string) string {
+	notice := fmt.Sprintf("Server maintenance in %s", countdown(left))
+	if reason != "" {
+		notice += ": " + reason
+	}
+	return formatSystemMessage(notice)
+}
+
+// scheduleMaintenance announces maintenance at at and schedules the
+// countdown, replacing any maintenance already scheduled.
+func scheduleMaintenance(at time.Time, reason string) {
+	maintenance.Lock()
+	maintenance.at, maintenance.reason = at, reason
+	maintenance.Unlock()
+
+	broadcastMessage(maintenanceNotice(time.Until(at), reason), nil)
+	scheduleMaintenanceStep(at, reason)
+}
+
+// scheduleMaintenanceStep schedules the next warning before at, or the
+// start of the maintenance when no warning is left.
+func scheduleMaintenanceStep(at time.Time, reason string) {
+	warning, ok := nextMaintenanceWarning(time.Until(at))
+	if !ok {
+		jobs.schedule(maintenanceJobKey, at, func() { startMaintenance(at) })
+		return
+	}
+	jobs.schedule(maintenanceJobKey, at.Add(-warning), func() {
+		if !maintenanceScheduled(at) {
+			return
+		}
+		broadcastMessage(maintenanceNotice(warning, reason), nil)
+		scheduleMaintenanceStep(at, reason)
+	})
+}
+
+// maintenanceScheduled reports whether the maintenance at at is still
+// scheduled, so steps racing with a cancel or reschedule stop.
+func maintenanceScheduled(at time.Time) bool {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	return maintenance.at.Equal(at)
+}
+
+// startMaintenance drains the server for the maintenance at at: clients
+// already connected stay, new connections are refused until the admin
+// console "undrain" command.
+func startMaintenance(at time.Time) {
+	maintenance.Lock()
+	if !maintenance.at.Equal(at) {
+		maintenance.Unlock()
+		return
+	}
+	maintenance.at, maintenance.reason = time.Time{}, ""
+	maintenance.Unlock()
+
+	draining.Store(true)
+	log.Printf("Maintenance started, new connections are refused")
+	broadcastMessage(formatSystemMessage("Server maintenance has started, new connections are refused"), nil)
+}
+
+// cancelMaintenance drops the scheduled maintenance and reports whether
+// there was one.
+func cancelMaintenance() bool {
+	maintenance.Lock()
+	maintenance.at, maintenance.reason = time.Time{}, ""
+	maintenance.Unlock()
+	return jobs.cancel(maintenanceJobKey)
+}
+
+// handleMaintenanceCommand processes the operator command
+// "/maintenance [minutes [reason]|cancel]".
+func handleMaintenanceCommand(c *client, call commandCall) {
+	switch {
+	case len(call.args) == 0:
+		maintenance.Lock()
+		at, reason := maintenance.at, maintenance.reason
+		maintenance.Unlock()
+		switch {
+		case !at.IsZero():
+			status := fmt.Sprintf("Maintenance at %s, in %s", c.locale().time(at), countdown(time.Until(at)))
+			if reason != "" {
+				status += ": " + reason
+			}
+			c.send(status)
+		case draining.Load():
+			c.send("Maintenance in progress, new connections are refused")
+		default:
+			c.send("No maintenance is scheduled")
+		}
+	case call.args[0] == "cancel":
+		if !cancelMaintenance() {
+			c.send("No maintenance is scheduled")
+			return
+		}
+		log.Printf("%s cancelled the scheduled maintenance", c.name)
+		broadcastMessage(formatSystemMessage("The scheduled server maintenance has been cancelled"), nil)
+		c.send("Maintenance cancelled")
+	default:
+		minutes, err := strconv.Atoi(call.args[0])
+		if err != nil || minutes <= 0 || minutes > maxMaintenanceMinutes {
+			c.send(fmt.Sprintf("Usage: /maintenance [minutes [reason]|cancel], with 1 to %d minutes", maxMaintenanceMinutes))
+			return
+		}
+		reason := strings.Join(call.args[1:], " ")
+		at := time.Now().Add(time.Duration(minutes) * time.Minute)
+		log.Printf("%s scheduled maintenance in %d minutes: %s", c.name, minutes, reason)
+		scheduleMaintenance(at, reason)
+		c.send(fmt.Sprintf("Maintenance scheduled at %s", c.locale().time(at)))
+	}
+}
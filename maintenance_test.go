@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextMaintenanceWarning(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		remaining time.Duration
+		want      time.Duration
+		wantOK    bool
+	}{
+		{3 * time.Hour, 60 * time.Minute, true},
+		{45 * time.Minute, 30 * time.Minute, true},
+		{30 * time.Minute, 15 * time.Minute, true},
+		{90 * time.Second, time.Minute, true},
+		{time.Minute, 30 * time.Second, true},
+		{30 * time.Second, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := nextMaintenanceWarning(tt.remaining)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("nextMaintenanceWarning(%s) = %s, %t, want %s, %t", tt.remaining, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	t.Parallel()
+	tests := map[time.Duration]string{
+		10 * time.Minute:                      "10 minutes",
+		time.Minute:                           "1 minute",
+		9*time.Minute + 50*time.Second:        "10 minutes",
+		59*time.Second + 900*time.Millisecond: "1 minute",
+		30 * time.Second:                      "30 seconds",
+		time.Second + time.Millisecond/2:      "1 second",
+	}
+	for left, want := range tests {
+		if got := countdown(left); got != want {
+			t.Errorf("countdown(%s) = %q, want %q", left, got, want)
+		}
+	}
+}
+
+func TestMaintenanceCommand(t *testing.T) {
+	defer cancelMaintenance()
+
+	user := &client{conn: newMockConn(), name: "alice"}
+	handleCommand(user, "/maintenance 5", time.Now())
+	if !strings.Contains(user.conn.(*mockConn).writeBuffer.String(), "Permission denied") {
+		t.Error("Expected regular users to be refused")
+	}
+
+	conn := newMockConn()
+	op := &client{conn: conn, name: "op", operator: true}
+	for _, message := range []string{"/maintenance 0", "/maintenance soon"} {
+		handleCommand(op, message, time.Now())
+		if !strings.Contains(conn.writeBuffer.String(), "Usage") {
+			t.Errorf("Expected usage for %q", message)
+		}
+		conn.writeBuffer.Reset()
+	}
+
+	handleCommand(op, "/maintenance 20 database upgrade", time.Now())
+	if !jobs.pending(maintenanceJobKey) {
+		t.Fatal("Expected the countdown to be scheduled")
+	}
+	handleCommand(op, "/maintenance", time.Now())
+	if got := conn.writeBuffer.String(); !strings.Contains(got, "in 20 minutes: database upgrade") {
+		t.Errorf("Expected the status to show the countdown, got %q", got)
+	}
+
+	handleCommand(op, "/maintenance cancel", time.Now())
+	if jobs.pending(maintenanceJobKey) {
+		t.Error("Expected cancel to drop the countdown")
+	}
+	conn.writeBuffer.Reset()
+	handleCommand(op, "/maintenance cancel", time.Now())
+	if !strings.Contains(conn.writeBuffer.String(), "No maintenance is scheduled") {
+		t.Error("Expected a second cancel to find nothing scheduled")
+	}
+}
+
+func TestStartMaintenanceDrains(t *testing.T) {
+	defer draining.Store(false)
+
+	at := time.Now()
+	maintenance.Lock()
+	maintenance.at = at
+	maintenance.Unlock()
+
+	startMaintenance(at.Add(-time.Minute))
+	if draining.Load() {
+		t.Fatal("Expected a stale start to be ignored")
+	}
+	startMaintenance(at)
+	if !draining.Load() {
+		t.Error("Expected maintenance to stop new connections")
+	}
+	if maintenanceScheduled(at) {
+		t.Error("Expected the maintenance to be cleared once started")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// mentionTag starts the lines of chat messages that mention the
+// recipient by @name, once the mentions capability is on. It follows the
+// ID tag: "@id=42 @mention alice: lunch, @bob?".
+const mentionTag = "@mention "
+
+// mentions reports whether message mentions name as @name. Trailing
+// punctuation is ignored and names match regardless of case.
+func mentions(message, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, word := range strings.Fields(message) {
+		mentioned, ok := strings.CutPrefix(word, "@")
+		if ok && strings.EqualFold(strings.TrimRightFunc(mentioned, unicode.IsPunct), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// markMention flags the chat message for c when it mentions c, which
+// structured encodings and clients with the mentions capability get as
+// the mention tag. The mentioned user exists by definition, being the
+// recipient.
+func (c *client) markMention(message string) string {
+	if _, encoded := c.conn.(*encodedConn); !encoded && !c.caps.has(capMentions) {
+		return message
+	}
+	if _, text, ok := splitAuthor(message); ok && mentions(text, c.currentName()) {
+		return mentionTag + message
+	}
+	return message
+}
+
+// splitMentionTag separates the mention tag from a line.
+func splitMentionTag(line string) (bool, string) {
+	rest, ok := strings.CutPrefix(line, mentionTag)
+	if !ok {
+		return false, line
+	}
+	return true, rest
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMentions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		message string
+		name    string
+		want    bool
+	}{
+		{"hey @bob", "bob", true},
+		{"@Bob, lunch?", "bob", true},
+		{"ask @bob.", "bob", true},
+		{"hey @bobby", "bob", false},
+		{"mail bob@example.com", "bob", false},
+		{"hey bob", "bob", false},
+		{"hey @", "", false},
+	}
+	for _, tt := range tests {
+		if got := mentions(tt.message, tt.name); got != tt.want {
+			t.Errorf("mentions(%q, %q) = %t, want %t", tt.message, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMentionFlag(t *testing.T) {
+	const name = "#mention-test"
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	flaggedConn, plainConn, otherConn := newMockConn(), newMockConn(), newMockConn()
+	flagged := &client{conn: flaggedConn, name: "mentioned", room: name}
+	flagged.caps.set(capMentions, true)
+	clients[flaggedConn] = flagged
+	clients[plainConn] = &client{conn: plainConn, name: "mentioned-plain", room: name}
+	other := &client{conn: otherConn, name: "bystander", room: name}
+	other.caps.set(capMentions, true)
+	clients[otherConn] = other
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, flaggedConn)
+		delete(clients, plainConn)
+		delete(clients, otherConn)
+		mutex.Unlock()
+	}()
+
+	postToRoom(name, "alice: @mentioned and @mentioned-plain, stand-up", nil, time.Now())
+	flaggedLine := flaggedConn.writeBuffer.String()
+	if mentioned, line := splitMentionTag(flaggedLine); !mentioned || !strings.HasSuffix(line, "] alice: @mentioned and @mentioned-plain, stand-up\n") {
+		t.Errorf("Expected a flagged line, got %q", flaggedLine)
+	}
+	if got := plainConn.writeBuffer.String(); strings.Contains(got, mentionTag) {
+		t.Errorf("Expected no flag without the capability, got %q", got)
+	}
+	if got := otherConn.writeBuffer.String(); strings.Contains(got, mentionTag) {
+		t.Errorf("Expected no flag for someone not mentioned, got %q", got)
+	}
+
+	// The history is flagged for the reader too
+	flaggedConn.writeBuffer.Reset()
+	sendHistory(flagged, name)
+	if got := flaggedConn.writeBuffer.String(); !strings.HasPrefix(got, mentionTag) {
+		t.Errorf("Expected the replayed line flagged, got %q", got)
+	}
+}
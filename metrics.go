@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message classes tracked by the latency metrics.
+const (
+	classChat   = "chat"
+	classPM     = "pm"
+	classSystem = "system"
+)
+
+// latencySamples is the number of recent writes kept per class to
+// compute percentiles.
+const latencySamples = 1024
+
+// breachLogInterval is the minimum time between two SLO breach log lines
+// about the same recipient; the breaches in between are counted in the
+// next line.
+const breachLogInterval = time.Minute
+
+// latencyTracker records latencies per message class in fixed size rings
+// and counts SLO breaches.
+type latencyTracker struct {
+	mu       sync.Mutex
+	samples  map[string][]time.Duration
+	next     map[string]int
+	count    map[string]uint64
+	sum      map[string]time.Duration
+	breaches map[string]uint64
+	logged   map[string]time.Time // Last breach logged per recipient
+	quiet    map[string]int       // Breaches not logged since, per recipient
+}
+
+// writeLatency tracks the time from server receive until a message is
+// written to the recipient's connection. It does not wait for the client
+// to read or acknowledge it.
+var writeLatency = newLatencyTracker()
+
+// ackLatency tracks the time from server receive until the recipient
+// acknowledges a private message, which only clients with the acks
+// capability do.
+var ackLatency = newLatencyTracker()
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		samples:  make(map[string][]time.Duration),
+		next:     make(map[string]int),
+		count:    make(map[string]uint64),
+		sum:      make(map[string]time.Duration),
+		breaches: make(map[string]uint64),
+		logged:   make(map[string]time.Time),
+		quiet:    make(map[string]int),
+	}
+}
+
+// record adds a latency sample for class and reports whether it breached
+// the given objective. A zero objective never breaches.
+func (l *latencyTracker) record(class string, latency, objective time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ring := l.samples[class]
+	if len(ring) < latencySamples {
+		l.samples[class] = append(ring, latency)
+	} else {
+		ring[l.next[class]] = latency
+		l.next[class] = (l.next[class] + 1) % latencySamples
+	}
+	l.count[class]++
+	l.sum[class] += latency
+	breached := objective > 0 && latency > objective
+	if breached {
+		l.breaches[class]++
+	}
+	return breached
+}
+
+// percentile returns the p-th percentile (0-100) of the recent samples of
+// class, or zero when there are none.
+func (l *latencyTracker) percentile(class string, p float64) time.Duration {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples[class]...)
+	l.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(p/100*float64(len(sorted))+0.5) - 1
+	index = max(0, min(index, len(sorted)-1))
+	return sorted[index]
+}
+
+// counters returns the number of samples of class, their total latency and
+// the number of SLO breaches among them.
+func (l *latencyTracker) counters(class string) (samples uint64, sum time.Duration, breaches uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.count[class], l.sum[class], l.breaches[class]
+}
+
+// logBreach reports whether a breach for recipient at now may be logged,
+// at most once per breachLogInterval, and how many breaches were left out
+// since the last logged one.
+func (l *latencyTracker) logBreach(recipient string, now time.Time) (skipped int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := strings.ToLower(recipient)
+	if last, seen := l.logged[key]; seen && now.Sub(last) < breachLogInterval {
+		l.quiet[key]++
+		return 0, false
+	}
+	// Forget recipients that have been quiet for a while
+	if len(l.logged) >= latencySamples {
+		for name, last := range l.logged {
+			if now.Sub(last) >= breachLogInterval {
+				delete(l.logged, name)
+				delete(l.quiet, name)
+			}
+		}
+	}
+	skipped = l.quiet[key]
+	l.logged[key] = now
+	delete(l.quiet, key)
+	return skipped, true
+}
+
+// classes returns the classes with recorded samples in sorted order.
+func (l *latencyTracker) classes() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var classes []string
+	for class := range l.count {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// recordWrite tracks a message of class received at received that has
+// just been written to the connection of recipient, logging it when it
+// missed the SLO.
+func recordWrite(class string, received time.Time, recipient string) {
+	recordLatency(writeLatency, "to write", class, received, recipient)
+}
+
+// recordAck tracks a message of class received at received that recipient
+// has just acknowledged, logging it when it missed the SLO.
+func recordAck(class string, received time.Time, recipient string) {
+	recordLatency(ackLatency, "to be acknowledged", class, received, recipient)
+}
+
+// recordLatency adds the time since received to tracker and logs breaches
+// of the delivery SLO of class, describing the wait as what.
+func recordLatency(tracker *latencyTracker, what, class string, received time.Time, recipient string) {
+	now := time.Now()
+	latency := now.Sub(received)
+	objective := time.Duration(config.DeliverySLO[class]) * time.Millisecond
+	if !tracker.record(class, latency, objective) {
+		return
+	}
+	skipped, ok := tracker.logBreach(recipient, now)
+	if !ok {
+		return
+	}
+	if skipped > 0 {
+		log.Printf("SLO breach: %s message to %s took %v %s (objective %v), %d more since the last notice", class, recipient, latency, what, objective, skipped)
+		return
+	}
+	log.Printf("SLO breach: %s message to %s took %v %s (objective %v)", class, recipient, latency, what, objective)
+}
+
+// writeMetrics renders the server metrics in the Prometheus text format.
+func writeMetrics(w io.Writer) {
+	mutex.Lock()
+	connected := len(clients)
+	mutex.Unlock()
+
+	fmt.Fprintln(w, "# HELP tcp_chat_connected_clients Registered clients.")
+	fmt.Fprintln(w, "# TYPE tcp_chat_connected_clients gauge")
+	fmt.Fprintf(w, "tcp_chat_connected_clients %d\n", connected)
+
+	writeLatencyMetrics(w, writeLatency, "write", "Latency from server receive until the message is written to the recipient's connection.", "Writes slower than the configured objective.")
+	writeLatencyMetrics(w, ackLatency, "ack", "Latency from server receive until the recipient acknowledges the message, for clients with the acks capability.", "Acknowledgements slower than the configured objective.")
+
+	totals, refused := failures.counters()
+	fmt.Fprintln(w, "# HELP tcp_chat_failed_attempts_total Failed handshakes and logins.")
+	fmt.Fprintln(w, "# TYPE tcp_chat_failed_attempts_total counter")
+	for _, kind := range []string{failureHandshake, failureAuth} {
+		fmt.Fprintf(w, "tcp_chat_failed_attempts_total{kind=%q} %d\n", kind, totals[kind])
+	}
+	fmt.Fprintln(w, "# HELP tcp_chat_blocked_connections_total Connections dropped because their address was blocked.")
+	fmt.Fprintln(w, "# TYPE tcp_chat_blocked_connections_total counter")
+	fmt.Fprintf(w, "tcp_chat_blocked_connections_total %d\n", refused)
+}
+
+// writeLatencyMetrics renders the summary tcp_chat_<name>_latency_seconds
+// and the counter tcp_chat_<name>_slo_breaches_total of tracker.
+func writeLatencyMetrics(w io.Writer, tracker *latencyTracker, name, latencyHelp, breachHelp string) {
+	classes := tracker.classes()
+	latency := "tcp_chat_" + name + "_latency_seconds"
+	fmt.Fprintf(w, "# HELP %s %s\n", latency, latencyHelp)
+	fmt.Fprintf(w, "# TYPE %s summary\n", latency)
+	for _, class := range classes {
+		for _, q := range []float64{50, 95, 99} {
+			fmt.Fprintf(w, "%s{class=%q,quantile=\"%g\"} %g\n",
+				latency, class, q/100, tracker.percentile(class, q).Seconds())
+		}
+		count, sum, _ := tracker.counters(class)
+		fmt.Fprintf(w, "%s_sum{class=%q} %g\n", latency, class, sum.Seconds())
+		fmt.Fprintf(w, "%s_count{class=%q} %d\n", latency, class, count)
+	}
+
+	breaches := "tcp_chat_" + name + "_slo_breaches_total"
+	fmt.Fprintf(w, "# HELP %s %s\n", breaches, breachHelp)
+	fmt.Fprintf(w, "# TYPE %s counter\n", breaches)
+	for _, class := range classes {
+		_, _, count := tracker.counters(class)
+		fmt.Fprintf(w, "%s{class=%q} %d\n", breaches, class, count)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	t.Parallel()
+	t.Run("Percentiles", func(t *testing.T) {
+		t.Parallel()
+		tracker := newLatencyTracker()
+		for i := 1; i <= 100; i++ {
+			tracker.record(classChat, time.Duration(i)*time.Millisecond, 0)
+		}
+
+		if got := tracker.percentile(classChat, 50); got != 50*time.Millisecond {
+			t.Errorf("Expected p50 of 50ms, got %v", got)
+		}
+		if got := tracker.percentile(classChat, 99); got != 99*time.Millisecond {
+			t.Errorf("Expected p99 of 99ms, got %v", got)
+		}
+		if got := tracker.percentile(classPM, 50); got != 0 {
+			t.Errorf("Expected zero for a class without samples, got %v", got)
+		}
+	})
+
+	t.Run("Breaches", func(t *testing.T) {
+		t.Parallel()
+		tracker := newLatencyTracker()
+		if tracker.record(classPM, 10*time.Millisecond, 20*time.Millisecond) {
+			t.Error("Expected fast delivery to meet the objective")
+		}
+		if !tracker.record(classPM, 30*time.Millisecond, 20*time.Millisecond) {
+			t.Error("Expected slow delivery to breach the objective")
+		}
+		if writes, sum, breaches := tracker.counters(classPM); breaches != 1 || writes != 2 || sum != 40*time.Millisecond {
+			t.Errorf("Unexpected counters: %d breaches, %d writes taking %v", breaches, writes, sum)
+		}
+	})
+
+	t.Run("BreachLog", func(t *testing.T) {
+		t.Parallel()
+		tracker := newLatencyTracker()
+		now := time.Now()
+		if _, ok := tracker.logBreach("alice", now); !ok {
+			t.Fatal("Expected the first breach to be logged")
+		}
+		for i := 0; i < 3; i++ {
+			if _, ok := tracker.logBreach("Alice", now.Add(time.Second)); ok {
+				t.Fatal("Expected breaches within the interval to be left out")
+			}
+		}
+		if _, ok := tracker.logBreach("bob", now.Add(time.Second)); !ok {
+			t.Error("Expected other recipients to be logged on their own")
+		}
+		if skipped, ok := tracker.logBreach("alice", now.Add(breachLogInterval)); !ok || skipped != 3 {
+			t.Errorf("Expected a breach after the interval to be logged with 3 left out, got %t, %d", ok, skipped)
+		}
+	})
+
+	t.Run("RingBuffer", func(t *testing.T) {
+		t.Parallel()
+		tracker := newLatencyTracker()
+		for i := 0; i < latencySamples*2; i++ {
+			tracker.record(classSystem, time.Second, 0)
+		}
+		if len(tracker.samples[classSystem]) != latencySamples {
+			t.Errorf("Expected %d kept samples, got %d", latencySamples, len(tracker.samples[classSystem]))
+		}
+	})
+}
+
+func TestWriteMetrics(t *testing.T) {
+	recordWrite(classChat, time.Now().Add(-time.Millisecond), "alice")
+	recordAck(classPM, time.Now().Add(-time.Millisecond), "alice")
+
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+	output := buf.String()
+	for _, expected := range []string{
+		"tcp_chat_connected_clients",
+		`tcp_chat_write_latency_seconds{class="chat",quantile="0.95"}`,
+		`tcp_chat_write_latency_seconds_sum{class="chat"}`,
+		`tcp_chat_write_latency_seconds_count{class="chat"}`,
+		`tcp_chat_write_slo_breaches_total{class="chat"}`,
+		`tcp_chat_ack_latency_seconds{class="pm",quantile="0.5"}`,
+		`tcp_chat_ack_latency_seconds_count{class="pm"}`,
+		`tcp_chat_ack_slo_breaches_total{class="pm"}`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// defaultModlogEntries is how many entries /modlog shows without a count.
+const defaultModlogEntries = 20
+
+// describeModeration renders an audit entry for /modlog.
+func describeModeration(loc locale, entry auditEntry) string {
+	line := fmt.Sprintf("%s %s by %s", loc.time(entry.Time), entry.Action, entry.Actor)
+	if entry.Room != "" {
+		line += " in " + entry.Room
+	}
+	if entry.Duration != "" {
+		line += " for " + entry.Duration
+	}
+	if entry.Reason != "" {
+		line += ": " + entry.Reason
+	}
+	return line
+}
+
+// handleModlogCommand processes the operator command "/modlog <user>
+// [count]", which lists the latest kicks, bans, mutes and reports about a
+// user from the audit log.
+func handleModlogCommand(c *client, call commandCall) {
+	count := defaultModlogEntries
+	if len(call.args) == 2 {
+		count, _ = strconv.Atoi(call.args[1])
+	}
+	if len(call.args) < 1 || len(call.args) > 2 || count <= 0 {
+		c.send("Usage: /modlog <user> [count]")
+		return
+	}
+
+	name := call.args[0]
+	entries, err := audit.entriesAbout(name)
+	if err != nil {
+		c.send(fmt.Sprintf("Cannot read the audit log: %v", err))
+		return
+	}
+	if len(entries) == 0 {
+		c.send(fmt.Sprintf("No moderation history for %s", name))
+		return
+	}
+	loc := c.locale()
+	shown := entries[max(0, len(entries)-count):]
+	c.send(fmt.Sprintf("Moderation history for %s (%s of %s):", name, loc.number(len(shown)), loc.number(len(entries))))
+	for _, entry := range shown {
+		c.send("  " + describeModeration(loc, entry))
+	}
+}
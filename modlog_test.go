@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeModeration(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+	got := describeModeration(defaultLocale, auditEntry{Time: at, Action: "ban", Actor: "oper", Duration: "1h0m0s"})
+	if want := "2024-05-01 15:04:05 ban by oper for 1h0m0s"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	got = describeModeration(defaultLocale, auditEntry{Time: at, Action: "report", Actor: "alice", Room: "#general", Reason: "spam"})
+	if want := "2024-05-01 15:04:05 report by alice in #general: spam"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestHandleModlogCommand(t *testing.T) {
+	defer func(saved *auditLog) { audit = saved }(audit)
+	var err error
+	audit, err = openAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := newMockConn()
+	oper := &client{conn: conn, name: "oper", operator: true}
+	target := &client{conn: newMockConn(), name: "Mallory"}
+	mutex.Lock()
+	clients[target.conn] = target
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, target.conn)
+		mutex.Unlock()
+	}()
+
+	handleMuteCommand(oper, callOf("/mute Mallory 10m"))
+	audit.record(auditEntry{Action: "report", Actor: "alice", Target: "mallory", Room: "#general", Reason: "spam"})
+	audit.record(auditEntry{Action: "report", Actor: "alice", Target: "bob", Reason: "unrelated"})
+
+	conn.writeBuffer.Reset()
+	handleCommand(oper, "/modlog mallory", time.Now())
+	got := conn.writeBuffer.String()
+	for _, want := range []string{"Moderation history for mallory (2 of 2):", "mute by oper for 10m0s", "report by alice in #general: spam"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in %q", want, got)
+		}
+	}
+	if strings.Contains(got, "unrelated") {
+		t.Errorf("Expected entries about other users to be left out, got %q", got)
+	}
+
+	conn.writeBuffer.Reset()
+	handleCommand(oper, "/modlog mallory 1", time.Now())
+	if got := conn.writeBuffer.String(); !strings.Contains(got, "(1 of 2)") || strings.Contains(got, "mute") {
+		t.Errorf("Expected only the latest entry, got %q", got)
+	}
+
+	userConn := newMockConn()
+	handleCommand(&client{conn: userConn, name: "user"}, "/modlog mallory", time.Now())
+	if got := userConn.writeBuffer.String(); !strings.Contains(got, "Permission denied") {
+		t.Errorf("Expected users to be refused, got %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expandBanner fills in the variables of a banner, welcome or MOTD text:
+// {server} is the server name, {users} the number of connected users,
+// {uptime} the time since the server started and {name} the user's name,
+// empty before they have one.
+func expandBanner(text, name string) string {
+	mutex.Lock()
+	users := len(clients)
+	mutex.Unlock()
+	return strings.NewReplacer(
+		"{server}", config.ServerName,
+		"{users}", strconv.Itoa(users),
+		"{uptime}", time.Since(startTime).Round(time.Second).String(),
+		"{name}", name,
+	).Replace(text)
+}
+
+// sendMOTD sends c the message of the day and reports whether one is set.
+func sendMOTD(c *client) bool {
+	if strings.TrimSpace(config.MOTD) == "" {
+		return false
+	}
+	c.send("Message of the day:")
+	for _, line := range strings.Split(expandBanner(config.MOTD, c.name), "\n") {
+		c.send(line)
+	}
+	return true
+}
+
+// handleMOTDCommand processes "/motd", which shows the message of the day
+// again.
+func handleMOTDCommand(c *client, call commandCall) {
+	if len(call.args) != 0 {
+		c.send("Usage: /motd")
+		return
+	}
+	if !sendMOTD(c) {
+		c.send("No message of the day is set")
+	}
+}
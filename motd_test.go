@@ -0,0 +1,46 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestExpandBanner(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.ServerName = "Example Chat"
+
+	got := expandBanner("{server}: hi {name}, {users} online, up {uptime} {unknown}", "alice")
+	if !regexp.MustCompile(`^Example Chat: hi alice, \d+ online, up \d[\dhms.]* \{unknown\}$`).MatchString(got) {
+		t.Errorf("Unexpected expansion %q", got)
+	}
+	if got := expandBanner(defaultConfig().Banner, ""); got != "Welcome to Example Chat!" {
+		t.Errorf("Expected the default banner, got %q", got)
+	}
+	config.ServerName = defaultConfig().ServerName
+	if got := expandBanner(defaultConfig().Welcome, "bob"); got != "Welcome, bob!" {
+		t.Errorf("Expected the default welcome, got %q", got)
+	}
+}
+
+func TestMOTDCommand(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	conn := newMockConn()
+	c := &client{conn: conn, name: "motd-user"}
+
+	config.MOTD = ""
+	if handleMOTDCommand(c, callOf("/motd")); conn.writeBuffer.String() != "No message of the day is set\n" {
+		t.Errorf("Expected no MOTD, got %q", conn.writeBuffer.String())
+	}
+
+	config.MOTD = "Hello {name}!\nMaintenance on {server} tonight"
+	conn.writeBuffer.Reset()
+	handleMOTDCommand(c, callOf("/motd"))
+	if got, want := conn.writeBuffer.String(), "Message of the day:\nHello motd-user!\nMaintenance on TCP-Chat tonight\n"; got != want {
+		t.Errorf("Expected the MOTD, got %q, want %q", got, want)
+	}
+	conn.writeBuffer.Reset()
+	handleMOTDCommand(c, callOf("/motd now"))
+	if got := conn.writeBuffer.String(); got != "Usage: /motd\n" {
+		t.Errorf("Expected the usage, got %q", got)
+	}
+}
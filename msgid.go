@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// idTag starts the line of a broadcast message for clients that see
+// message IDs, as in "@id=42 alice: hello".
+const idTag = "@id="
+
+// lastMessageID is the ID of the latest broadcast message. IDs increase
+// by one for every message server-wide, so they order messages and
+// identify them across reconnects; they start over when the server does.
+var lastMessageID atomic.Uint64
+
+func nextMessageID() uint64 {
+	return lastMessageID.Add(1)
+}
+
+// tagID prefixes message with its ID.
+func tagID(id uint64, message string) string {
+	return idTag + strconv.FormatUint(id, 10) + " " + message
+}
+
+// splitIDTag separates the ID tag from a line written by sendID. Lines
+// without one have ID 0.
+func splitIDTag(line string) (uint64, string) {
+	rest, ok := strings.CutPrefix(line, idTag)
+	if !ok {
+		return 0, line
+	}
+	number, message, ok := strings.Cut(rest, " ")
+	id, err := strconv.ParseUint(number, 10, 64)
+	if !ok || err != nil {
+		return 0, line
+	}
+	return id, message
+}
+
+// sendID sends the broadcast message with the given ID. Clients that
+// enabled the ids capability get it tagged, and structured encodings
+// always carry it in their id field; everyone else gets the plain line.
+func (c *client) sendID(id uint64, message string) error {
+	if _, encoded := c.conn.(*encodedConn); id != 0 && (encoded || c.caps.has(capIDs)) {
+		message = tagID(id, message)
+	}
+	if err := c.send(message); err != nil {
+		return err
+	}
+	c.advanceCursor(id)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSplitIDTag(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line   string
+		wantID uint64
+		want   string
+	}{
+		{tagID(42, "alice: hi"), 42, "alice: hi"},
+		{"alice: hi", 0, "alice: hi"},
+		{"@id=x alice: hi", 0, "@id=x alice: hi"},
+		{"@id=7", 0, "@id=7"},
+	}
+	for _, tt := range tests {
+		id, got := splitIDTag(tt.line)
+		if id != tt.wantID || got != tt.want {
+			t.Errorf("splitIDTag(%q) = %d, %q, want %d, %q", tt.line, id, got, tt.wantID, tt.want)
+		}
+	}
+}
+
+func TestHistoryMessageIDs(t *testing.T) {
+	t.Parallel()
+	const name = "#msgid-test"
+	r := &room{name: name, settings: RoomSettings{Retention: 2}}
+	posted := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	mutex.Lock()
+	first := r.addToHistoryAt("alice: one", posted)
+	second := r.addToHistoryAt("bob: two", posted)
+	third := r.addToHistoryAt("alice: three", posted)
+	rooms[name] = r
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		mutex.Unlock()
+	}()
+
+	if !(first < second && second < third) {
+		t.Fatalf("Expected increasing IDs, got %d, %d, %d", first, second, third)
+	}
+
+	plain := newMockConn()
+	sendHistory(&client{conn: plain, name: "old"}, name)
+	if got := plain.writeBuffer.String(); got != "[2026-03-01 12:30:00] bob: two\n[2026-03-01 12:30:00] alice: three\n" {
+		t.Errorf("Expected the untagged history, got %q", got)
+	}
+
+	tagged := newMockConn()
+	c := &client{conn: tagged, name: "new"}
+	c.caps.set(capIDs, true)
+	sendHistory(c, name)
+	want := fmt.Sprintf("@id=%d [2026-03-01 12:30:00] bob: two\n@id=%d [2026-03-01 12:30:00] alice: three\n", second, third)
+	if got := tagged.writeBuffer.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
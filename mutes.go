@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// mutes holds the active mutes. Like bans they are kept by name, compared
+// case-insensitively, and by address, so reconnecting does not lift them.
+// Replaced by the persisted list on startup.
+var mutes = &banList{kind: "mute", expired: muteExpired}
+
+// loadMuteList reads the mutes stored at path, see loadBanList.
+func loadMuteList(path string) (*banList, error) {
+	list := &banList{path: path, kind: "mute", expired: muteExpired}
+	if err := list.load(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// muteExpired tells a connected user that their timed mute ran out. The
+// entry for the address runs out together with the one for the name.
+func muteExpired(entry ban) {
+	if entry.IP {
+		return
+	}
+	log.Printf("Mute on %s expired", entry.Target)
+	if target := findClientByNameFold(entry.Target); target != nil {
+		target.send("You are no longer muted")
+	}
+}
+
+// isMuted reports whether the client is currently muted.
+func isMuted(c *client) bool {
+	return mutes.isBanned(c.currentName(), c.ip)
+}
+
+// findClientByName returns the registered client using name, or nil.
+func findClientByName(name string) *client {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, c := range clients {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// findClientByNameFold is findClientByName ignoring case. An exact match
+// wins over one differing in case only.
+func findClientByNameFold(name string) *client {
+	if c := findClientByName(name); c != nil {
+		return c
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, c := range clients {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// muteClient silences target by name and address on behalf of by,
+// indefinitely when duration is zero. Timed mutes are lifted automatically
+// by the scheduler.
+func muteClient(target *client, by string, duration time.Duration) {
+	if err := mutes.add(target.currentName(), false, by, duration); err != nil {
+		log.Printf("Error saving mute list: %v", err)
+	}
+	if target.ip == "" {
+		return
+	}
+	if err := mutes.add(target.ip, true, by, duration); err != nil {
+		log.Printf("Error saving mute list: %v", err)
+	}
+}
+
+// unmute lifts the mutes on name and on the address, which may be empty,
+// and reports whether there were any.
+func unmute(name, ip string) bool {
+	removed, err := mutes.remove(name)
+	if err != nil {
+		log.Printf("Error saving mute list: %v", err)
+	}
+	if ip == "" {
+		return removed
+	}
+	removedIP, err := mutes.remove(ip)
+	if err != nil {
+		log.Printf("Error saving mute list: %v", err)
+	}
+	return removed || removedIP
+}
+
+// unmuteClient lifts a mute on target and reports whether it was muted.
+func unmuteClient(target *client) bool {
+	return unmute(target.currentName(), target.ip)
+}
+
+// handleMuteCommand processes the moderator commands /mute and /unmute.
+func handleMuteCommand(c *client, call commandCall) {
+	if call.name == "/unmute" {
+		if len(call.args) != 1 {
+			c.send("Usage: /unmute <name>")
+			return
+		}
+		// Mutes outlive the connection, so offline names can be unmuted too
+		name, ip := call.args[0], ""
+		target := findClientByNameFold(name)
+		if target != nil {
+			name, ip = target.currentName(), target.ip
+		}
+		if !unmute(name, ip) {
+			c.send(fmt.Sprintf("%s is not muted", name))
+			return
+		}
+		log.Printf("%s unmuted %s", c.name, name)
+		audit.record(auditEntry{Action: "unmute", Actor: c.name, Target: name})
+		c.send(fmt.Sprintf("%s has been unmuted", name))
+		if target != nil {
+			target.send("You are no longer muted")
+		}
+		return
+	}
+
+	if len(call.args) < 1 || len(call.args) > 2 || (len(call.args) == 2 && !isDuration(call.args[1])) {
+		c.send("Usage: /mute <name> [duration]")
+		return
+	}
+	target := findClientByNameFold(call.args[0])
+	if target == nil {
+		c.send(fmt.Sprintf("User %s not found", call.args[0]))
+		return
+	}
+
+	name := target.currentName()
+
+	var duration time.Duration
+	if len(call.args) == 2 {
+		duration, _ = parseDuration(call.args[1])
+	}
+	muteClient(target, c.name, duration)
+	entry := auditEntry{Action: "mute", Actor: c.name, Target: name}
+	if duration > 0 {
+		entry.Duration = duration.String()
+	}
+	audit.record(entry)
+	if duration > 0 {
+		log.Printf("%s muted %s for %v", c.name, name, duration)
+		c.send(fmt.Sprintf("%s has been muted for %v", name, duration))
+		target.send(fmt.Sprintf("You have been muted for %v. You can still read the chat.", duration))
+	} else {
+		log.Printf("%s muted %s", c.name, name)
+		c.send(fmt.Sprintf("%s has been muted", name))
+		target.send("You have been muted. You can still read the chat.")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMuteClient(t *testing.T) {
+	t.Parallel()
+	t.Run("TimedMute", func(t *testing.T) {
+		t.Parallel()
+		target := &client{conn: newMockConn(), name: "mutetarget"}
+
+		muteClient(target, "mod", 50*time.Millisecond)
+		if !isMuted(target) {
+			t.Fatal("Expected client to be muted")
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		if isMuted(target) {
+			t.Error("Expected mute to expire")
+		}
+	})
+
+	t.Run("IndefiniteMute", func(t *testing.T) {
+		t.Parallel()
+		target := &client{conn: newMockConn(), name: "indefinite"}
+
+		muteClient(target, "mod", 0)
+		if !isMuted(target) {
+			t.Fatal("Expected client to be muted")
+		}
+		if !unmuteClient(target) {
+			t.Error("Expected unmute to report the client was muted")
+		}
+		if isMuted(target) {
+			t.Error("Expected client to be unmuted")
+		}
+		if unmuteClient(target) {
+			t.Error("Expected second unmute to report nothing to lift")
+		}
+	})
+}
+
+func TestMuteOutlivesConnection(t *testing.T) {
+	t.Parallel()
+	muted := &client{conn: newMockConn(), name: "Mute-Evader", ip: "192.0.2.77"}
+	muteClient(muted, "mod", 0)
+	defer unmuteClient(muted)
+
+	if again := (&client{conn: newMockConn(), name: "mute-evader"}); !isMuted(again) {
+		t.Error("Expected the mute to follow the name after reconnecting")
+	}
+	if renamed := (&client{conn: newMockConn(), name: "someone-else", ip: "192.0.2.77"}); !isMuted(renamed) {
+		t.Error("Expected the mute to follow the address after reconnecting")
+	}
+	if other := (&client{conn: newMockConn(), name: "bystander", ip: "192.0.2.78"}); isMuted(other) {
+		t.Error("Expected other users to stay unmuted")
+	}
+}
+
+func TestMuteListPersists(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "mutes.json")
+	list, err := loadMuteList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := list.add("Persisted", false, "mod", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.add("persist-timed", false, "mod", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := loadMuteList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.isBanned("persisted", "") || !reloaded.isBanned("persist-timed", "") {
+		t.Errorf("Expected the mutes to survive a restart, got %+v", reloaded.list())
+	}
+	if !jobs.pending(reloaded.jobKey(ban{Target: "persist-timed"})) || jobs.pending("ban:name:persist-timed") {
+		t.Error("Expected the timed mute to be lifted by a mute job")
+	}
+	reloaded.remove("persist-timed")
+}
+
+func TestMuteCommandCase(t *testing.T) {
+	modConn, targetConn := newMockConn(), newMockConn()
+	mod := &client{conn: modConn, name: "case-mod", moderator: true}
+	target := &client{conn: targetConn, name: "Case-Target"}
+	mutex.Lock()
+	clients[targetConn] = target
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, targetConn)
+		mutex.Unlock()
+		unmuteClient(target)
+	}()
+
+	handleCommand(mod, "/mute case-target 10m", time.Now())
+	if !isMuted(target) {
+		t.Fatalf("Expected the mute to find Case-Target, got %q", modConn.writeBuffer.String())
+	}
+
+	// Unmuting works once the user is gone
+	mutex.Lock()
+	delete(clients, targetConn)
+	mutex.Unlock()
+	modConn.writeBuffer.Reset()
+	handleCommand(mod, "/unmute CASE-TARGET", time.Now())
+	if got := modConn.writeBuffer.String(); got != "CASE-TARGET has been unmuted\n" || isMuted(target) {
+		t.Errorf("Expected the offline user to be unmuted, got %q", got)
+	}
+}
+
+func TestMuteCommandPermissions(t *testing.T) {
+	t.Parallel()
+	t.Run("RegularUser", func(t *testing.T) {
+		t.Parallel()
+		conn := newMockConn()
+		c := &client{conn: conn, name: "alice"}
+
+		if !handleCommand(c, "/mute bob 10m", time.Now()) {
+			t.Fatal("Expected /mute to be handled")
+		}
+		if !strings.Contains(conn.writeBuffer.String(), "Permission denied") {
+			t.Errorf("Expected permission error, got %q", conn.writeBuffer.String())
+		}
+	})
+
+	t.Run("Moderator", func(t *testing.T) {
+		t.Parallel()
+		conn := newMockConn()
+		c := &client{conn: conn, name: "mod", moderator: true}
+
+		if !handleCommand(c, "/unmute nobody-here", time.Now()) {
+			t.Fatal("Expected /unmute to be handled")
+		}
+		if !strings.Contains(conn.writeBuffer.String(), "nobody-here is not muted") {
+			t.Errorf("Expected nothing to lift, got %q", conn.writeBuffer.String())
+		}
+	})
+}
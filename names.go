@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// systemSender is the sender shown on every notice generated by the server.
+const systemSender = "SERVER"
+
+// reservedNames lists the sender names that belong to the server itself.
+// No client may register one of them, so a chat line can never pass for a
+// system notice.
+var reservedNames = []string{systemSender, "Services", "*"}
+
+var (
+	errEmptyName    = errors.New("Name cannot be empty")
+	errReservedName = errors.New("Name is reserved for the server")
+	errNameTooLong  = errors.New("Name is too long")
+	errNameChars    = errors.New("Name cannot contain spaces, control or invisible characters or '/'")
+	errNameTaken    = errors.New("Name is already in use")
+)
+
+// maxNamePrompts is how many names a client may try at the prompt before
+// a taken name closes the connection.
+const maxNamePrompts = 3
+
+// normalizeName drops the characters that do not show when name is
+// printed, such as zero-width spaces and joiners, bidi overrides and byte
+// order marks, so a name compares equal to the one it looks like.
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) || !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// isReservedName reports whether name falls into the system sender
+// namespace. The comparison ignores case and invisible characters, and any
+// name starting with '*' is reserved as well since that prefix marks
+// server-side action lines.
+func isReservedName(name string) bool {
+	name = normalizeName(name)
+	if strings.HasPrefix(name, "*") {
+		return true
+	}
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfiguredReservedName reports whether name is on the reserved_names
+// list of the configuration, ignoring case and invisible characters.
+func isConfiguredReservedName(name string) bool {
+	name = normalizeName(name)
+	for _, reserved := range config.ReservedNames {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// validNameRune reports whether r may appear in a client name. Spaces
+// would split the name in commands and '/' breaks /msg targets. Invisible
+// format characters would let a name pass for another one.
+func validNameRune(r rune) bool {
+	return r != '/' && r != utf8.RuneError && !unicode.IsSpace(r) &&
+		unicode.IsPrint(r) && !unicode.Is(unicode.Cf, r)
+}
+
+// validateName checks a requested client name before it is registered.
+func validateName(name string) error {
+	if name == "" {
+		return errEmptyName
+	}
+	if config.MaxNameLength > 0 && utf8.RuneCountInString(name) > config.MaxNameLength {
+		return errNameTooLong
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return !validNameRune(r) }) >= 0 {
+		return errNameChars
+	}
+	if isReservedName(name) || isConfiguredReservedName(name) || isGuestName(name) {
+		return errReservedName
+	}
+	return nil
+}
+
+// nameInUse reports whether a connected client already uses name. The
+// caller must hold mutex.
+func nameInUse(name string) bool {
+	for _, other := range clients {
+		if other.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestName returns the first free alternative to a taken name, of the
+// form name_2, name_3 and so on. The base name is shortened when needed
+// to keep the suggestion within the configured maximum length.
+func suggestName(name string, taken func(string) bool) string {
+	base := []rune(name)
+	for n := 2; ; n++ {
+		suffix := fmt.Sprintf("_%d", n)
+		if limit := config.MaxNameLength - len(suffix); config.MaxNameLength > 0 && len(base) > limit && limit > 0 {
+			base = base[:limit]
+		}
+		if candidate := string(base) + suffix; !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// formatSystemMessage builds a notice attributed to the system sender.
+func formatSystemMessage(text string) string {
+	return fmt.Sprintf("%s: %s", systemSender, text)
+}
+
+// formatChatMessage builds a chat line sent by a client. It refuses senders
+// from the reserved namespace so user content cannot be constructed as a
+// system notice, even if a name slipped past validation.
+func formatChatMessage(sender, text string) (string, error) {
+	if isReservedName(sender) {
+		return "", errReservedName
+	}
+	return fmt.Sprintf("%s: %s", sender, text), nil
+}
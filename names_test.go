@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		input    string
+		expected error
+	}{
+		{"Regular name", "alice", nil},
+		{"Empty name", "", errEmptyName},
+		{"Server name", "SERVER", errReservedName},
+		{"Server name lowercase", "server", errReservedName},
+		{"Services name", "services", errReservedName},
+		{"Star prefix", "*alice", errReservedName},
+		{"Configured reserved name", "Admin", errReservedName},
+		{"Guest namespace", "guest-4821", errReservedName},
+		{"Unicode name", "zoë", nil},
+		{"Longest name", strings.Repeat("a", 32), nil},
+		{"Too long", strings.Repeat("a", 33), errNameTooLong},
+		{"Too long in bytes only", strings.Repeat("é", 32), nil},
+		{"Space", "alice smith", errNameChars},
+		{"Tab", "alice\tsmith", errNameChars},
+		{"Control character", "alice\x07", errNameChars},
+		{"Slash", "alice/bob", errNameChars},
+		{"Invalid UTF-8", "alice\xff", errNameChars},
+		{"Zero-width suffix", "SERVER\u200b", errNameChars},
+		{"Zero-width joiner", "ali\u200dce", errNameChars},
+		{"Word joiner", "alice\u2060", errNameChars},
+		{"Bidi override", "\u202ealice", errNameChars},
+		{"Byte order mark", "\ufeffalice", errNameChars},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := validateName(tt.input); err != tt.expected {
+				t.Errorf("Expected %v for %q, got %v", tt.expected, tt.input, err)
+			}
+		})
+	}
+}
+
+func TestMessageConstruction(t *testing.T) {
+	t.Parallel()
+	t.Run("SystemMessage", func(t *testing.T) {
+		t.Parallel()
+		if got := formatSystemMessage("alice has joined our chat..."); got != "SERVER: alice has joined our chat..." {
+			t.Errorf("Unexpected system message %q", got)
+		}
+	})
+
+	t.Run("ChatMessage", func(t *testing.T) {
+		t.Parallel()
+		got, err := formatChatMessage("alice", "hello")
+		if err != nil || got != "alice: hello" {
+			t.Errorf("Expected 'alice: hello', got %q (%v)", got, err)
+		}
+	})
+
+	t.Run("ReservedSender", func(t *testing.T) {
+		t.Parallel()
+		for _, sender := range []string{"Server", "SERVER\u200b", "\u2060server\ufeff"} {
+			if _, err := formatChatMessage(sender, "maintenance now"); err != errReservedName {
+				t.Errorf("Expected reserved sender %q to be refused, got %v", sender, err)
+			}
+		}
+	})
+}
+
+func TestSuggestName(t *testing.T) {
+	t.Parallel()
+	takenSet := func(names ...string) func(string) bool {
+		return func(name string) bool {
+			for _, n := range names {
+				if n == name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		taken    []string
+		expected string
+	}{
+		{"First free suffix", "alice", []string{"alice"}, "alice_2"},
+		{"Skips taken suffixes", "alice", []string{"alice", "alice_2", "alice_3"}, "alice_4"},
+		{"Shortens long names", strings.Repeat("a", 32), nil, strings.Repeat("a", 30) + "_2"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := suggestName(tt.input, takenSet(tt.taken...))
+			if got != tt.expected {
+				t.Errorf("Expected %q for %q, got %q", tt.expected, tt.input, got)
+			}
+			if err := validateName(got); err != nil {
+				t.Errorf("Suggestion %q does not validate: %v", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// handleNickCommand processes "/nick <name>", which renames the client for
+// the rest of the session. The new name goes through the same checks as
+// the name prompt.
+func handleNickCommand(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send("Usage: /nick <name>")
+		return
+	}
+	if c.guest {
+		c.send("Guests cannot change their name")
+		return
+	}
+	name := call.args[0]
+	if name == c.name {
+		c.send(fmt.Sprintf("You are already known as %s", name))
+		return
+	}
+
+	if err := validateName(name); err != nil {
+		c.send(err.Error())
+		return
+	}
+	if _, err := checkBotName(name, "", time.Now()); err != nil {
+		c.send(err.Error())
+		return
+	}
+	if bans.isBanned(name, "") {
+		c.send(fmt.Sprintf("The name %s is banned", name))
+		return
+	}
+	// Mutes and unconfirmed private messages are kept by name
+	if isMuted(c) {
+		c.send("You cannot change your name while muted")
+		return
+	}
+	if pmsPending(c.name) {
+		c.send("You cannot change your name while private messages to or from you await delivery")
+		return
+	}
+
+	shadowed := isShadowBanned(c)
+	mutex.Lock()
+	if nameInUse(name) {
+		mutex.Unlock()
+		c.send(errNameTaken.Error())
+		return
+	}
+	old, room, wasBot := c.name, c.room, c.bot
+	c.nameMu.Lock()
+	c.name = name
+	c.nameMu.Unlock()
+	c.bot = false
+	for _, r := range rooms {
+		if r.owner == old {
+			r.owner = name
+		}
+	}
+	// Replies with /r and ignores follow the rename
+	for _, other := range clients {
+		if other.replyTo == old {
+			other.replyTo = name
+		}
+		if other.ignoresLocked(old) {
+			delete(other.ignored, strings.ToLower(old))
+			other.ignored[strings.ToLower(name)] = name
+		}
+	}
+	mutex.Unlock()
+
+	renamePrivateMessages(old, name)
+	// A registered bot's groups belong to its identity, not the session
+	if !wasBot {
+		if err := groups.renameMember(old, name); err != nil {
+			log.Printf("Error saving groups: %v", err)
+		}
+	}
+	recordSeen(old, room, c.guest)
+	if shadowed {
+		// Keep the shadow ban on the new name without telling anyone
+		shadowBan(name, "")
+		c.send(fmt.Sprintf("You are now known as %s", name))
+		return
+	}
+
+	log.Printf("%s is now known as %s", old, name)
+	events.record(auditEntry{Action: "nick", Actor: old, Target: name})
+	c.send(fmt.Sprintf("You are now known as %s", name))
+	broadcastMessage(formatSystemMessage(fmt.Sprintf("%s is now known as %s", old, name)), c.conn)
+	notifyPresence(old, "is now known as "+name)
+	notifyPresence(name, "is online")
+}
+
+// renamePrivateMessages moves the private conversations and the export
+// preference of old over to name, so /exportpm keeps working after a
+// rename. Logged messages keep the names used when they were sent.
+func renamePrivateMessages(old, name string) {
+	// Both are keyed by lower-case name
+	if strings.EqualFold(old, name) {
+		return
+	}
+
+	pmLog.Lock()
+	for key, entries := range pmLog.conversations {
+		a, b, _ := strings.Cut(key, "\x00")
+		switch strings.ToLower(old) {
+		case a:
+			a = name
+		case b:
+			b = name
+		default:
+			continue
+		}
+		delete(pmLog.conversations, key)
+		pmLog.conversations[conversationKey(a, b)] = entries
+	}
+	pmLog.Unlock()
+
+	if exportRefused(old) {
+		setExportAllowed(old, true)
+		setExportAllowed(name, false)
+	}
+}
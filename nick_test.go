@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleNickCommand(t *testing.T) {
+	r, err := createRoom("#nick-test", "", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceConn, bobConn := newMockConn(), newMockConn()
+	alice := &client{conn: aliceConn, name: "alice", room: r.name}
+	bob := &client{conn: bobConn, name: "bob", room: r.name}
+	mutex.Lock()
+	clients[aliceConn], clients[bobConn] = alice, bob
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, r.name)
+		delete(clients, aliceConn)
+		delete(clients, bobConn)
+		mutex.Unlock()
+	}()
+
+	t.Run("Refuses taken and invalid names", func(t *testing.T) {
+		for _, tt := range []struct{ command, want string }{
+			{"/nick bob", errNameTaken.Error()},
+			{"/nick a/b", errNameChars.Error()},
+			{"/nick server", errReservedName.Error()},
+			{"/nick", "Usage: /nick <name>"},
+		} {
+			aliceConn.writeBuffer.Reset()
+			handleNickCommand(alice, callOf(tt.command))
+			if got := aliceConn.writeBuffer.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("%s: expected %q, got %q", tt.command, tt.want, got)
+			}
+		}
+		if alice.name != "alice" {
+			t.Errorf("Expected the name to stay alice, got %q", alice.name)
+		}
+	})
+
+	t.Run("Refuses while state is kept by name", func(t *testing.T) {
+		muteClient(alice, "mod", 0)
+		aliceConn.writeBuffer.Reset()
+		handleNickCommand(alice, callOf("/nick alice-free"))
+		unmuteClient(alice)
+		if got := aliceConn.writeBuffer.String(); got != "You cannot change your name while muted\n" {
+			t.Errorf("Expected a muted user to be refused, got %q", got)
+		}
+
+		pm := &pmDelivery{id: nextMessageID(), from: "bob", to: "alice", text: "ack me"}
+		pms.Lock()
+		pms.unacked[pm.id] = pm
+		pms.Unlock()
+		aliceConn.writeBuffer.Reset()
+		handleNickCommand(alice, callOf("/nick alice-free"))
+		pms.Lock()
+		delete(pms.unacked, pm.id)
+		pms.Unlock()
+		if got := aliceConn.writeBuffer.String(); !strings.HasPrefix(got, "You cannot change your name while private messages") {
+			t.Errorf("Expected a user with unconfirmed messages to be refused, got %q", got)
+		}
+		if alice.name != "alice" {
+			t.Errorf("Expected the name to stay alice, got %q", alice.name)
+		}
+	})
+
+	t.Run("Renames", func(t *testing.T) {
+		storePrivateMessage(pmEntry{From: "alice", To: "bob", Text: "psst", Time: time.Now()})
+		setExportAllowed("alice", false)
+		defer setExportAllowed("alice2", true)
+		if err := groups.create("nick-test"); err != nil {
+			t.Fatal(err)
+		}
+		defer groups.remove("nick-test")
+		groups.addMember("nick-test", "alice")
+		addReminder(alice, &reminder{text: "stretch", due: time.Now().Add(time.Hour)})
+		defer forgetReminders(alice)
+
+		handleNickCommand(alice, callOf("/nick alice2"))
+		if alice.name != "alice2" {
+			t.Fatalf("Expected the name alice2, got %q", alice.name)
+		}
+		if got := bobConn.writeBuffer.String(); !strings.Contains(got, "SERVER: alice is now known as alice2") {
+			t.Errorf("Expected the rename to be announced, got %q", got)
+		}
+		if got := aliceConn.writeBuffer.String(); !strings.Contains(got, "You are now known as alice2") {
+			t.Errorf("Expected a confirmation, got %q", got)
+		}
+		if r.owner != "alice2" {
+			t.Errorf("Expected room ownership to follow, got %q", r.owner)
+		}
+		if entries := conversation("alice2", "bob"); len(entries) != 1 || entries[0].Text != "psst" {
+			t.Errorf("Expected the conversation to follow, got %v", entries)
+		}
+		if !exportRefused("alice2") || exportRefused("alice") {
+			t.Error("Expected the export preference to follow")
+		}
+		if members, _ := groups.members("nick-test"); len(members) != 1 || members[0] != "alice2" {
+			t.Errorf("Expected the group membership to follow, got %q", members)
+		}
+		if pending := pendingReminders(alice); len(pending) != 1 || pending[0].text != "stretch" {
+			t.Errorf("Expected the reminders to stay with the session, got %+v", pending)
+		}
+		if findConnectionByName("alice") != nil {
+			t.Error("Expected the old name to be free")
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxOfflineMessages caps the private messages waiting for a single user.
+const maxOfflineMessages = 50
+
+var errInboxFull = errors.New("inbox full")
+
+// offlineMessage is a private message waiting for its recipient to log in.
+type offlineMessage struct {
+	ID   uint64    `json:"id,omitempty"` // Message ID when live delivery failed first, see parkPM
+	From string    `json:"from"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"` // When the server received it
+}
+
+// offlineStore keeps the private messages sent to authenticated identities
+// while they were offline, mirrored to a JSON file.
+type offlineStore struct {
+	mu     sync.Mutex
+	path   string                      // Empty path keeps the store in memory only
+	Queues map[string][]offlineMessage `json:"queues"` // Waiting messages by lowercase recipient name
+}
+
+var offline = newOfflineStore("") // Queued private messages, replaced by the persisted store on startup
+
+func newOfflineStore(path string) *offlineStore {
+	return &offlineStore{path: path, Queues: make(map[string][]offlineMessage)}
+}
+
+// loadOfflineStore reads the messages stored at path. A missing file
+// yields an empty store that will be created on the first message.
+func loadOfflineStore(path string) (*offlineStore, error) {
+	store := newOfflineStore(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if store.Queues == nil {
+		store.Queues = make(map[string][]offlineMessage)
+	}
+	return store, nil
+}
+
+// save writes the store to disk. The caller must hold s.mu.
+func (s *offlineStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0o600)
+}
+
+// queue adds msg to the messages waiting for to. Full queues refuse it
+// with errInboxFull rather than dropping older messages.
+func (s *offlineStore) queue(to string, msg offlineMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(to)
+	if len(s.Queues[key]) >= maxOfflineMessages {
+		return errInboxFull
+	}
+	s.Queues[key] = append(s.Queues[key], msg)
+	return s.save()
+}
+
+// take removes and returns the messages waiting for name.
+func (s *offlineStore) take(name string) []offlineMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(name)
+	queued, ok := s.Queues[key]
+	if !ok {
+		return nil
+	}
+	delete(s.Queues, key)
+	if err := s.save(); err != nil {
+		log.Printf("Error saving offline messages: %v", err)
+	}
+	return queued
+}
+
+// purge drops the messages waiting for name and the ones name sent to
+// others, and saves the store. It returns the number of messages removed.
+func (s *offlineStore) purge(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(name)
+	removed := len(s.Queues[key])
+	delete(s.Queues, key)
+	for user, queued := range s.Queues {
+		kept := queued[:0]
+		for _, msg := range queued {
+			if strings.EqualFold(msg.From, name) {
+				removed++
+				continue
+			}
+			kept = append(kept, msg)
+		}
+		if len(kept) == 0 {
+			delete(s.Queues, user)
+		} else {
+			s.Queues[user] = kept
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save()
+}
+
+// queueOfflinePM keeps a private message from c for an authenticated
+// identity that is offline, see authenticatedName, and tells c so.
+func queueOfflinePM(c *client, to, text string, received time.Time) {
+	msg := offlineMessage{From: c.name, Text: text, Time: received}
+	// Shadow-banned senders get the usual confirmation only
+	if !isShadowBanned(c) {
+		if err := offline.queue(to, msg); errors.Is(err, errInboxFull) {
+			c.send(fmt.Sprintf("%s is offline and has too many messages waiting, please try again later", to))
+			return
+		} else if err != nil {
+			log.Printf("Error saving offline messages: %v", err)
+		}
+	}
+	c.send(fmt.Sprintf("[PM to %s]: %s", to, text))
+	c.send(fmt.Sprintf("%s is offline; your message will be delivered when they log in", to))
+}
+
+// deliverOfflinePMs sends c the private messages that arrived while it
+// was offline, oldest first. Only clients that logged in with the
+// identity's credential get them.
+func deliverOfflinePMs(c *client) {
+	if !c.bot {
+		return
+	}
+	queued := offline.take(c.name)
+	if len(queued) == 0 {
+		return
+	}
+	loc := c.locale()
+	c.send(fmt.Sprintf("While you were away you received %s private message(s):", loc.number(len(queued))))
+	for _, msg := range queued {
+		c.send(fmt.Sprintf("[%s] [PM from %s]: %s", loc.time(msg.Time), msg.From, msg.Text))
+		logPrivateMessage(msg.From, c.name, msg.Text, msg.Time)
+		if msg.ID != 0 {
+			// The sender was told it could not be delivered live
+			pm := &pmDelivery{id: msg.ID, from: msg.From, to: c.name}
+			notifyPMSender(pm, "DELIVERED", fmt.Sprintf("Your message to %s was delivered", c.name))
+		}
+	}
+	c.setReplyTo(queued[len(queued)-1].From)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOfflineStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "offline.json")
+	store, err := loadOfflineStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	for i := 0; i < maxOfflineMessages; i++ {
+		if err := store.queue("Bob", offlineMessage{From: "alice", Text: fmt.Sprint(i), Time: at}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := store.queue("bob", offlineMessage{From: "alice", Text: "one too many", Time: at}); !errors.Is(err, errInboxFull) {
+		t.Errorf("Expected a full inbox, got %v", err)
+	}
+	store.queue("carol", offlineMessage{From: "mallory", Text: "spam", Time: at})
+	store.queue("carol", offlineMessage{From: "alice", Text: "hi", Time: at})
+	store.queue("mallory", offlineMessage{From: "alice", Text: "hi", Time: at})
+	if removed, err := store.purge("Mallory"); err != nil || removed != 2 {
+		t.Errorf("Expected 2 messages purged, got %d, %v", removed, err)
+	}
+
+	reloaded, err := loadOfflineStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error reloading: %v", err)
+	}
+	queued := reloaded.take("BOB")
+	if len(queued) != maxOfflineMessages || queued[0].Text != "0" || !queued[0].Time.Equal(at) {
+		t.Errorf("Expected Bob's messages in order, got %d starting with %+v", len(queued), queued)
+	}
+	if again := reloaded.take("bob"); again != nil {
+		t.Errorf("Expected the messages to be taken once, got %+v", again)
+	}
+	if queued := reloaded.take("carol"); len(queued) != 1 || queued[0].From != "alice" {
+		t.Errorf("Expected only the message by others for carol, got %+v", queued)
+	}
+}
+
+func TestOfflinePrivateMessage(t *testing.T) {
+	defer func(saved Config, savedSeen *seenStore, savedOffline *offlineStore) {
+		config, seen, offline = saved, savedSeen, savedOffline
+	}(config, seen, offline)
+	config.Bots = map[string]BotConfig{"Offline-Bob": {Token: "secret"}, "offline-tokenless": {}}
+	seen, offline = newSeenStore(""), newOfflineStore("")
+	seen.record("offline-carol", "#general", time.Now(), false)
+
+	senderConn := newMockConn()
+	sender := &client{conn: senderConn, name: "offline-alice"}
+	received := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	handlePrivateMessage(sender, parseCommandCall("/msg offline-bob are you there?", received))
+	if got, want := senderConn.writeBuffer.String(), "[PM to Offline-Bob]: are you there?\nOffline-Bob is offline; your message will be delivered when they log in\n"; got != want {
+		t.Errorf("Expected the message to be kept, got %q, want %q", got, want)
+	}
+	senderConn.writeBuffer.Reset()
+	handlePrivateMessage(sender, parseCommandCall("/msg offline-carol hi", received))
+	handlePrivateMessage(sender, parseCommandCall("/msg offline-tokenless hi", received))
+	if got := senderConn.writeBuffer.String(); got != "User offline-carol not found\nUser offline-tokenless not found\n" {
+		t.Errorf("Expected names without a credential not to be found, got %q", got)
+	}
+
+	// Anyone may connect as Offline-Bob without the token while the bot is away
+	impostorConn := newMockConn()
+	deliverOfflinePMs(&client{conn: impostorConn, name: "Offline-Bob"})
+	if got := impostorConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected nothing for a client without the token, got %q", got)
+	}
+
+	bobConn := newMockConn()
+	bob := &client{conn: bobConn, name: "Offline-Bob", bot: true}
+	deliverOfflinePMs(bob)
+	want := "While you were away you received 1 private message(s):\n[2026-03-01 12:30:00] [PM from offline-alice]: are you there?\n"
+	if got := bobConn.writeBuffer.String(); got != want {
+		t.Errorf("Expected the waiting message, got %q, want %q", got, want)
+	}
+	bobConn.writeBuffer.Reset()
+	deliverOfflinePMs(bob)
+	if got := bobConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected the messages to be delivered once, got %q", got)
+	}
+}
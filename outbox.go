@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+)
+
+// outboxSize is how many broadcast lines a client may fall behind by
+// before it is disconnected as a slow consumer.
+const outboxSize = 256
+
+// outboundLine is a broadcast line waiting in the outbox of a client.
+type outboundLine struct {
+	id   uint64
+	line string
+	sent func() // Run once the line is written, may be nil
+}
+
+func newOutbox() chan outboundLine {
+	return make(chan outboundLine, outboxSize)
+}
+
+// writeOutbox writes the lines queued for c until done is closed. It runs
+// on a goroutine of its own per session, so a client that does not read
+// only holds up its own lines.
+func (c *client) writeOutbox(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case out := <-c.outbox:
+			if c.sendID(out.id, out.line) == nil && out.sent != nil {
+				out.sent()
+			}
+		}
+	}
+}
+
+// queueID hands a broadcast line with message ID id to the writer of c
+// without waiting for the write; sent runs once it is written. Clients
+// without an outbox get the line directly. A full outbox means the client
+// has fallen too far behind, and it is disconnected.
+func (c *client) queueID(id uint64, line string, sent func()) {
+	if c.outbox == nil {
+		if c.sendID(id, line) == nil && sent != nil {
+			sent()
+		}
+		return
+	}
+	select {
+	case c.outbox <- outboundLine{id: id, line: line, sent: sent}:
+	default:
+		// The writer may be stuck in a write, which disconnectSlow waits for
+		if !c.slowKicked.Load() {
+			go c.disconnectSlow(fmt.Sprintf("with %d lines waiting", outboxSize))
+		}
+	}
+}
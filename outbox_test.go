@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStalledClientDoesNotHoldUpBroadcasts(t *testing.T) {
+	const name = "#outbox-test"
+	// Nobody reads the far end of the pipe, so every write to it blocks
+	stalled, far := net.Pipe()
+	defer far.Close()
+	stuck := &client{conn: stalled, name: "outbox-stuck", room: name, outbox: newOutbox()}
+	readerConn, readerFar := net.Pipe()
+	defer readerFar.Close()
+	reader := &client{conn: readerConn, name: "outbox-reader", room: name, outbox: newOutbox()}
+	done := make(chan struct{})
+	defer close(done)
+	go stuck.writeOutbox(done)
+	go reader.writeOutbox(done)
+
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[stalled], clients[readerConn] = stuck, reader
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, stalled)
+		delete(clients, readerConn)
+		mutex.Unlock()
+	}()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		postToRoom(name, "outbox-sender: hello", nil, time.Now())
+	}
+	if elapsed := time.Since(start); elapsed > writeTimeout()/2 {
+		t.Errorf("Expected broadcasts not to wait for the stalled client, took %v", elapsed)
+	}
+
+	readerFar.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if line, err := bufio.NewReader(readerFar).ReadString('\n'); err != nil || !strings.Contains(line, "outbox-sender: hello") {
+		t.Errorf("Expected the reading client to get the message, got %q, %v", line, err)
+	}
+}
+
+func TestOutboxOverflowDisconnects(t *testing.T) {
+	t.Parallel()
+	conn, far := net.Pipe()
+	defer far.Close()
+	// No writer drains the outbox
+	c := &client{conn: conn, name: "outbox-overflow", outbox: newOutbox()}
+
+	for i := 0; i < outboxSize; i++ {
+		c.queueID(0, "line", nil)
+	}
+	if c.slowKicked.Load() {
+		t.Fatal("Expected a full outbox to be tolerated")
+	}
+	c.queueID(0, "one too many", nil)
+
+	// Read the goodbye until the connection is closed
+	far.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 128)
+	for {
+		if _, err := far.Read(buf); err != nil {
+			break
+		}
+	}
+	if !c.slowKicked.Load() {
+		t.Error("Expected the client to be disconnected once its outbox overflowed")
+	}
+}
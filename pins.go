@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// maxPins is the number of messages a room can have pinned at once.
+const maxPins = 10
+
+// pin is a message pinned in a room. It keeps a copy of the line so it
+// outlives the room's history retention.
+type pin struct {
+	id     uint64
+	line   string
+	posted time.Time
+	by     string
+}
+
+// pinnedLines writes the pins of r for display, oldest pin first. The
+// caller must hold mutex.
+func (r *room) pinnedLines() []string {
+	lines := make([]string, len(r.pins))
+	for i, p := range r.pins {
+		lines[i] = fmt.Sprintf("  %d %s (pinned by %s)", p.id, stampMessage(p.posted, p.line), p.by)
+	}
+	return lines
+}
+
+// sendPins shows c the pinned messages of the named room and reports
+// whether it has any.
+func sendPins(c *client, name string) bool {
+	mutex.Lock()
+	var lines []string
+	if r, ok := rooms[name]; ok {
+		lines = r.pinnedLines()
+	}
+	mutex.Unlock()
+	if len(lines) == 0 {
+		return false
+	}
+	c.send(fmt.Sprintf("Pinned in %s:", name))
+	for _, line := range lines {
+		c.send(line)
+	}
+	return true
+}
+
+// handlePinCommand processes "/pin <id>", "/unpin <id>" and "/pins" for
+// the current room. Anyone can list the pins; the room owner and
+// operators can change them. Message IDs come with the ids capability.
+func handlePinCommand(c *client, call commandCall) {
+	if call.name == "/pins" {
+		mutex.Lock()
+		room := c.room
+		mutex.Unlock()
+		if !sendPins(c, room) {
+			c.send(fmt.Sprintf("No messages are pinned in %s", room))
+		}
+		return
+	}
+
+	var id uint64
+	var err error
+	if len(call.args) == 1 {
+		id, err = strconv.ParseUint(call.args[0], 10, 64)
+	}
+	if len(call.args) != 1 || err != nil {
+		c.send(fmt.Sprintf("Usage: %s <id>", call.name))
+		return
+	}
+
+	mutex.Lock()
+	r, ok := rooms[c.room]
+	if !ok {
+		mutex.Unlock()
+		c.send(errUnknownRoom.Error())
+		return
+	}
+	if !c.operator && (r.owner == "" || r.owner != c.name) {
+		mutex.Unlock()
+		c.send("Permission denied: only the room owner or an operator can change the pins")
+		return
+	}
+	if call.name == "/unpin" {
+		line, ok := r.unpin(id)
+		mutex.Unlock()
+		if !ok {
+			c.send(fmt.Sprintf("Message %d is not pinned in %s", id, r.name))
+			return
+		}
+		log.Printf("%s unpinned message %d in %s", c.name, id, r.name)
+		broadcastToRoom(r.name, formatSystemMessage(fmt.Sprintf("%s unpinned: %s", c.name, line)), nil)
+		return
+	}
+	line, err := r.pin(id, c.name)
+	mutex.Unlock()
+	if err != nil {
+		c.send(err.Error())
+		return
+	}
+	log.Printf("%s pinned message %d in %s", c.name, id, r.name)
+	broadcastToRoom(r.name, formatSystemMessage(fmt.Sprintf("%s pinned: %s", c.name, line)), nil)
+}
+
+// pin pins the history entry with the given ID and returns its line. The
+// caller must hold mutex.
+func (r *room) pin(id uint64, by string) (string, error) {
+	for _, p := range r.pins {
+		if p.id == id {
+			return "", fmt.Errorf("Message %d is already pinned", id)
+		}
+	}
+	if len(r.pins) >= maxPins {
+		return "", fmt.Errorf("%s already has %d pinned messages, unpin one first", r.name, maxPins)
+	}
+	for i, entryID := range r.ids {
+		if entryID != id || i >= len(r.history) {
+			continue
+		}
+		var posted time.Time
+		if i < len(r.times) {
+			posted = r.times[i]
+		}
+		r.pins = append(r.pins, pin{id: id, line: r.history[i], posted: posted, by: by})
+		return r.history[i], nil
+	}
+	return "", fmt.Errorf("No message %d in the history of %s", id, r.name)
+}
+
+// unpin removes the pin of the message with the given ID and returns its
+// line. The caller must hold mutex.
+func (r *room) unpin(id uint64) (string, bool) {
+	for i, p := range r.pins {
+		if p.id == id {
+			r.pins = append(r.pins[:i], r.pins[i+1:]...)
+			return p.line, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPinCommand(t *testing.T) {
+	const name = "#pins-test"
+	ownerConn, userConn := newMockConn(), newMockConn()
+	owner := &client{conn: ownerConn, name: "pins-owner", room: name}
+	user := &client{conn: userConn, name: "pins-user", room: name}
+	r := &room{name: name, owner: owner.name}
+	posted := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	mutex.Lock()
+	rooms[name] = r
+	rulesID := r.addToHistoryAt("pins-owner: Rules: be kind", posted)
+	chatID := r.addToHistoryAt("pins-user: hello", posted)
+	clients[ownerConn], clients[userConn] = owner, user
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, ownerConn)
+		delete(clients, userConn)
+		mutex.Unlock()
+	}()
+
+	tests := []struct {
+		client  *client
+		message string
+		want    string
+	}{
+		{user, "/pins", "No messages are pinned in #pins-test\n"},
+		{owner, "/pin", "Usage: /pin <id>\n"},
+		{user, fmt.Sprintf("/pin %d", rulesID), "Permission denied: only the room owner or an operator can change the pins\n"},
+		{owner, "/pin 999999999", "No message 999999999 in the history of #pins-test\n"},
+		{owner, fmt.Sprintf("/pin %d", rulesID), "SERVER: pins-owner pinned: pins-owner: Rules: be kind\n"},
+		{owner, fmt.Sprintf("/pin %d", rulesID), fmt.Sprintf("Message %d is already pinned\n", rulesID)},
+		{owner, fmt.Sprintf("/pin %d", chatID), "SERVER: pins-owner pinned: pins-user: hello\n"},
+		{owner, fmt.Sprintf("/unpin %d", chatID), "SERVER: pins-owner unpinned: pins-user: hello\n"},
+		{owner, fmt.Sprintf("/unpin %d", chatID), fmt.Sprintf("Message %d is not pinned in #pins-test\n", chatID)},
+		{user, "/pins", fmt.Sprintf("Pinned in #pins-test:\n  %d [2026-03-01 09:00:00] pins-owner: Rules: be kind (pinned by pins-owner)\n", rulesID)},
+	}
+	for _, tt := range tests {
+		conn := tt.client.conn.(*mockConn)
+		ownerConn.writeBuffer.Reset()
+		userConn.writeBuffer.Reset()
+		handlePinCommand(tt.client, callOf(tt.message))
+		if got := conn.writeBuffer.String(); got != tt.want {
+			t.Errorf("%q answered %q, want %q", tt.message, got, tt.want)
+		}
+	}
+
+	// Pins outlive the history and are shown to joiners
+	mutex.Lock()
+	r.history, r.ids, r.times = nil, nil, nil
+	mutex.Unlock()
+	joinerConn := newMockConn()
+	joiner := &client{conn: joinerConn, name: "pins-joiner", room: defaultRoom}
+	mutex.Lock()
+	clients[joinerConn] = joiner
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, joinerConn)
+		mutex.Unlock()
+	}()
+	if err := joinRoom(joiner, name); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := joinerConn.writeBuffer.String(); !strings.HasSuffix(got, "Pinned in #pins-test:\n  "+fmt.Sprint(rulesID)+" [2026-03-01 09:00:00] pins-owner: Rules: be kind (pinned by pins-owner)\n") {
+		t.Errorf("Expected the pins after joining, got %q", got)
+	}
+
+	purgeRoomMessages("pins-owner")
+	if got := sendPins(joiner, name); got {
+		t.Error("Expected purging the author to drop their pinned messages")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pmAckTimeout is how long a recipient with the acks capability has to
+	// acknowledge a private message before it is sent again.
+	pmAckTimeout = 10 * time.Second
+	// pmRetryDelay is the wait before sending again after a failed write.
+	pmRetryDelay = 2 * time.Second
+	// maxPMAttempts is how often a private message is sent before it is
+	// given up on, or kept for the recipient's next login, see parkPM.
+	maxPMAttempts = 3
+)
+
+// pmDelivery is a private message on its way to the recipient.
+type pmDelivery struct {
+	id       uint64
+	from, to string
+	text     string
+	received time.Time
+	attempts int // Sends so far, protected by pms
+}
+
+// pms tracks private messages sent to acks clients that are not
+// acknowledged yet.
+var pms = struct {
+	sync.Mutex
+	unacked map[uint64]*pmDelivery
+}{unacked: make(map[uint64]*pmDelivery)}
+
+func (pm *pmDelivery) line() string {
+	return fmt.Sprintf("[PM from %s]: %s", pm.from, pm.text)
+}
+
+// pmJobKey names the scheduler job that sends pm again.
+func pmJobKey(pm *pmDelivery) string {
+	return "pm:" + strconv.FormatUint(pm.id, 10)
+}
+
+// sendPM sends a private message line with its ID, which clients that
+// acknowledge private messages always get.
+func sendPM(c *client, id uint64, line string) error {
+	if c.caps.has(capAcks) {
+		return c.send(tagID(id, line))
+	}
+	return c.sendID(id, line)
+}
+
+// deliverPM sends pm to its recipient. Failed writes are retried, and
+// recipients with the acks capability get it again until they acknowledge
+// it; once the attempts run out, or the recipient is gone, it is handed to
+// parkPM.
+func deliverPM(pm *pmDelivery) {
+	target := findClientByName(pm.to)
+	pms.Lock()
+	if target == nil || pm.attempts >= maxPMAttempts {
+		delete(pms.unacked, pm.id)
+		pms.Unlock()
+		parkPM(pm)
+		return
+	}
+	pm.attempts++
+	acks := target.caps.has(capAcks)
+	if acks {
+		pms.unacked[pm.id] = pm
+	}
+	pms.Unlock()
+
+	if err := sendPM(target, pm.id, pm.line()); err != nil {
+		log.Printf("Error delivering private message %d to %s: %v", pm.id, pm.to, err)
+		jobs.schedule(pmJobKey(pm), time.Now().Add(pmRetryDelay), func() { deliverPM(pm) })
+		return
+	}
+	recordWrite(classPM, pm.received, pm.to)
+	if acks {
+		jobs.schedule(pmJobKey(pm), time.Now().Add(pmAckTimeout), func() { deliverPM(pm) })
+		return
+	}
+	confirmPM(pm)
+}
+
+// parkPM keeps pm, which could not be delivered live, in the offline
+// store for the recipient's next login. Like the messages sent while the
+// recipient was offline, only authenticated identities get it kept, up to
+// the same limit; it is dropped otherwise.
+func parkPM(pm *pmDelivery) {
+	if holdPM(pm) {
+		notifyPMSender(pm, "QUEUED", fmt.Sprintf("%s could not be reached; your message will be delivered when they are back", pm.to))
+		return
+	}
+	name, ok := authenticatedName(pm.to)
+	if !ok {
+		notifyPMSender(pm, "FAILED", fmt.Sprintf("%s could not be reached; your message was not delivered", pm.to))
+		return
+	}
+	msg := offlineMessage{ID: pm.id, From: pm.from, Text: pm.text, Time: pm.received}
+	if err := offline.queue(name, msg); errors.Is(err, errInboxFull) {
+		notifyPMSender(pm, "FAILED", fmt.Sprintf("%s could not be reached and has too many messages waiting; your message was not delivered", pm.to))
+		return
+	} else if err != nil {
+		log.Printf("Error saving offline messages: %v", err)
+	}
+	notifyPMSender(pm, "QUEUED", fmt.Sprintf("%s could not be reached; your message will be delivered when they are back", pm.to))
+}
+
+// confirmPM logs the delivered pm and tells the sender.
+func confirmPM(pm *pmDelivery) {
+	logPrivateMessage(pm.from, pm.to, pm.text, pm.received)
+	notifyPMSender(pm, "DELIVERED", "")
+}
+
+// notifyPMSender tells the sender of pm what became of it: clients with
+// the acks capability get "PM <status> <id> <recipient>", others the
+// notice unless it is empty.
+func notifyPMSender(pm *pmDelivery, status, notice string) {
+	sender := findClientByName(pm.from)
+	switch {
+	case sender == nil:
+	case sender.caps.has(capAcks):
+		sender.send(fmt.Sprintf("PM %s %d %s", status, pm.id, pm.to))
+	case notice != "":
+		sender.send(notice)
+	}
+}
+
+// pmsPending reports whether private messages to or from name are still
+// waiting to be acknowledged.
+func pmsPending(name string) bool {
+	pms.Lock()
+	defer pms.Unlock()
+
+	for _, pm := range pms.unacked {
+		if strings.EqualFold(pm.to, name) || strings.EqualFold(pm.from, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAckCommand processes "/ack <id>", with which clients that enabled
+// the acks capability confirm a private message.
+func handleAckCommand(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send("Usage: /ack <id>")
+		return
+	}
+	id, err := strconv.ParseUint(call.args[0], 10, 64)
+	if err != nil {
+		c.send("Usage: /ack <id>")
+		return
+	}
+
+	pms.Lock()
+	pm, ok := pms.unacked[id]
+	if ok && pm.to == c.name {
+		delete(pms.unacked, id)
+	}
+	pms.Unlock()
+	if !ok || pm.to != c.name {
+		// Late or repeated acknowledgements of resent messages end up here
+		c.send(fmt.Sprintf("No private message %d is waiting for your acknowledgement", id))
+		return
+	}
+	jobs.cancel(pmJobKey(pm))
+	recordAck(classPM, pm.received, pm.to)
+	confirmPM(pm)
+}
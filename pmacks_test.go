@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrivateMessageAcks(t *testing.T) {
+	senderConn, plainConn, ackConn := newMockConn(), newMockConn(), newMockConn()
+	sender := &client{conn: senderConn, name: "acks-sender", room: defaultRoom}
+	sender.caps.set(capAcks, true)
+	plain := &client{conn: plainConn, name: "acks-plain", room: defaultRoom}
+	acking := &client{conn: ackConn, name: "acks-acking", room: defaultRoom}
+	acking.caps.set(capAcks, true)
+	mutex.Lock()
+	clients[senderConn], clients[plainConn], clients[ackConn] = sender, plain, acking
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, senderConn)
+		delete(clients, plainConn)
+		delete(clients, ackConn)
+		mutex.Unlock()
+	}()
+
+	t.Run("written", func(t *testing.T) {
+		handlePrivateMessage(sender, callOf("/msg acks-plain hello"))
+		if got := plainConn.writeBuffer.String(); got != "[PM from acks-sender]: hello\n" {
+			t.Errorf("Recipient got %q", got)
+		}
+		id, echo := splitIDTag(strings.SplitN(senderConn.writeBuffer.String(), "\n", 2)[0])
+		if echo != "[PM to acks-plain]: hello" {
+			t.Fatalf("Unexpected echo %q", echo)
+		}
+		if want := fmt.Sprintf("PM DELIVERED %d acks-plain", id); !strings.Contains(senderConn.writeBuffer.String(), want) {
+			t.Errorf("Expected %q, got %q", want, senderConn.writeBuffer.String())
+		}
+	})
+
+	t.Run("acknowledged", func(t *testing.T) {
+		senderConn.writeBuffer.Reset()
+		handlePrivateMessage(sender, callOf("/msg acks-acking are you there?"))
+		id, line := splitIDTag(strings.TrimSuffix(ackConn.writeBuffer.String(), "\n"))
+		if id == 0 || line != "[PM from acks-sender]: are you there?" {
+			t.Fatalf("Recipient got %q", ackConn.writeBuffer.String())
+		}
+		key := fmt.Sprintf("pm:%d", id)
+		if !jobs.pending(key) {
+			t.Fatal("Expected the message to be sent again without an acknowledgement")
+		}
+		if strings.Contains(senderConn.writeBuffer.String(), "DELIVERED") {
+			t.Fatal("Expected no delivery before the acknowledgement")
+		}
+
+		acked, _, _ := ackLatency.counters(classPM)
+		handleAckCommand(acking, callOf(fmt.Sprintf("/ack %d", id)))
+		if jobs.pending(key) {
+			t.Error("Expected the acknowledgement to stop the resends")
+		}
+		if got, _, _ := ackLatency.counters(classPM); got <= acked {
+			t.Error("Expected the acknowledgement latency recorded")
+		}
+		if want := fmt.Sprintf("PM DELIVERED %d acks-acking", id); !strings.Contains(senderConn.writeBuffer.String(), want) {
+			t.Errorf("Expected %q, got %q", want, senderConn.writeBuffer.String())
+		}
+		ackConn.writeBuffer.Reset()
+		handleAckCommand(acking, callOf(fmt.Sprintf("/ack %d", id)))
+		if got := ackConn.writeBuffer.String(); !strings.Contains(got, "No private message") {
+			t.Errorf("Expected a repeated acknowledgement to be refused, got %q", got)
+		}
+	})
+
+	t.Run("queued", func(t *testing.T) {
+		defer func(saved Config, savedOffline *offlineStore) { config, offline = saved, savedOffline }(config, offline)
+		config.Bots = map[string]BotConfig{"acks-gone": {Token: "secret"}}
+		offline = newOfflineStore("")
+		senderConn.writeBuffer.Reset()
+		sender.caps.set(capAcks, false)
+		pm := &pmDelivery{id: nextMessageID(), from: "acks-sender", to: "acks-gone", text: "later", received: time.Now()}
+		deliverPM(pm)
+		if got := senderConn.writeBuffer.String(); !strings.Contains(got, "acks-gone could not be reached; your message will be delivered") {
+			t.Errorf("Expected the sender to learn the message was queued, got %q", got)
+		}
+
+		goneConn := newMockConn()
+		gone := &client{conn: goneConn, name: "acks-gone", room: defaultRoom, bot: true}
+		deliverOfflinePMs(gone)
+		if got := goneConn.writeBuffer.String(); !strings.Contains(got, "[PM from acks-sender]: later\n") {
+			t.Errorf("Returning recipient got %q", got)
+		}
+		if got := senderConn.writeBuffer.String(); !strings.Contains(got, "Your message to acks-gone was delivered") {
+			t.Errorf("Expected the sender to learn of the late delivery, got %q", got)
+		}
+
+		// Queued messages share the limit of the offline store
+		for i := 0; i < maxOfflineMessages; i++ {
+			offline.queue("acks-gone", offlineMessage{From: "acks-sender", Text: "spam"})
+		}
+		senderConn.writeBuffer.Reset()
+		sender.caps.set(capAcks, true)
+		pm = &pmDelivery{id: nextMessageID(), from: "acks-sender", to: "acks-gone", text: "one too many", received: time.Now()}
+		deliverPM(pm)
+		if want := fmt.Sprintf("PM FAILED %d acks-gone", pm.id); !strings.Contains(senderConn.writeBuffer.String(), want) {
+			t.Errorf("Expected %q for a full inbox, got %q", want, senderConn.writeBuffer.String())
+		}
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		senderConn.writeBuffer.Reset()
+		sender.caps.set(capAcks, false)
+		defer sender.caps.set(capAcks, true)
+		deliverPM(&pmDelivery{id: nextMessageID(), from: "acks-sender", to: "acks-anyone", text: "later", received: time.Now()})
+		if got := senderConn.writeBuffer.String(); got != "acks-anyone could not be reached; your message was not delivered\n" {
+			t.Errorf("Expected messages to names without a credential to be dropped, got %q", got)
+		}
+		if queued := offline.take("acks-anyone"); queued != nil {
+			t.Errorf("Expected nothing kept, got %+v", queued)
+		}
+	})
+}
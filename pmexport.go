@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxConversationLog bounds the private messages kept per conversation.
+	maxConversationLog = 500
+	// maxConversations bounds the conversations kept at once. A new one
+	// replaces the conversation that has been quiet the longest.
+	maxConversations = 1000
+)
+
+// pmEntry is one private message kept for /exportpm and /pmhistory.
+type pmEntry struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// pmLog keeps recent private messages per pair of users in memory.
+var pmLog = struct {
+	sync.Mutex
+	conversations map[string][]pmEntry
+}{conversations: make(map[string][]pmEntry)}
+
+// noExport holds the users who refuse to have their conversations
+// exported by the other party, by lower-case name.
+var noExport = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// conversationKey identifies the conversation between a and b regardless
+// of who wrote first.
+func conversationKey(a, b string) string {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a > b {
+		a, b = b, a
+	}
+	return a + "\x00" + b
+}
+
+// logPrivateMessage records a delivered private message for /pmhistory and
+// /exportpm. Only conversations with an authenticated identity are kept,
+// since no one else can read them back, and only while neither participant
+// turned the history off.
+func logPrivateMessage(from, to, text string, at time.Time) {
+	if !historyAllowed(from) || !historyAllowed(to) {
+		return
+	}
+	if _, ok := authenticatedName(from); !ok {
+		if _, ok := authenticatedName(to); !ok {
+			return
+		}
+	}
+	storePrivateMessage(pmEntry{From: from, To: to, Text: text, Time: at})
+}
+
+// storePrivateMessage adds e to its conversation, within maxConversationLog
+// messages per conversation and maxConversations conversations.
+func storePrivateMessage(e pmEntry) {
+	pmLog.Lock()
+	defer pmLog.Unlock()
+
+	key := conversationKey(e.From, e.To)
+	entries, ok := pmLog.conversations[key]
+	if !ok && len(pmLog.conversations) >= maxConversations {
+		var quietest string
+		var last time.Time
+		for k, kept := range pmLog.conversations {
+			if at := kept[len(kept)-1].Time; quietest == "" || at.Before(last) {
+				quietest, last = k, at
+			}
+		}
+		delete(pmLog.conversations, quietest)
+	}
+	entries = append(entries, e)
+	if len(entries) > maxConversationLog {
+		entries = append([]pmEntry(nil), entries[len(entries)-maxConversationLog:]...)
+	}
+	pmLog.conversations[key] = entries
+}
+
+// conversation returns a copy of the private messages between a and b.
+func conversation(a, b string) []pmEntry {
+	pmLog.Lock()
+	defer pmLog.Unlock()
+	return append([]pmEntry(nil), pmLog.conversations[conversationKey(a, b)]...)
+}
+
+// exportAllowed reports whether name lets others export conversations.
+// Names without a credential could not have agreed to it, so they never do.
+func exportAllowed(name string) bool {
+	if _, ok := authenticatedName(name); !ok {
+		return false
+	}
+	return !exportRefused(name)
+}
+
+// exportRefused reports whether name turned conversation export off.
+func exportRefused(name string) bool {
+	noExport.Lock()
+	defer noExport.Unlock()
+	return noExport.names[strings.ToLower(name)]
+}
+
+// setExportAllowed records the export preference of name.
+func setExportAllowed(name string, allowed bool) {
+	noExport.Lock()
+	defer noExport.Unlock()
+	if allowed {
+		delete(noExport.names, strings.ToLower(name))
+	} else {
+		noExport.names[strings.ToLower(name)] = true
+	}
+}
+
+// renderConversation formats entries as plain text or JSON.
+func renderConversation(entries []pmEntry, format string) ([]byte, error) {
+	if format == "json" {
+		return json.MarshalIndent(entries, "", "  ")
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "[%s] %s -> %s: %s\n", e.Time.Format(time.RFC3339), e.From, e.To, e.Text)
+	}
+	return []byte(b.String()), nil
+}
+
+// handlePMExportCommand processes "/exportpm <user> [text|json]" and
+// "/privacy [export|history on|off]".
+func handlePMExportCommand(c *client, call commandCall) {
+	switch call.name {
+	case "/privacy":
+		onOff := func(on bool) string {
+			if on {
+				return "on"
+			}
+			return "off"
+		}
+		if len(call.args) == 0 {
+			c.send(fmt.Sprintf("Conversation export by others: %s", onOff(exportAllowed(c.name))))
+			c.send(fmt.Sprintf("Conversation history: %s", onOff(historyAllowed(c.name))))
+			return
+		}
+		if len(call.args) != 2 || (call.args[0] != "export" && call.args[0] != "history") || (call.args[1] != "on" && call.args[1] != "off") {
+			c.send("Usage: /privacy [export|history on|off]")
+			return
+		}
+		// The settings belong to the name, which anyone could have taken
+		if !c.bot {
+			c.send("Only registered bots logged in with their token can change privacy settings; conversations with other names are never exported")
+			return
+		}
+		if call.args[0] == "history" {
+			setHistoryAllowed(c.name, call.args[1] == "on")
+			c.send(fmt.Sprintf("Conversation history: %s", call.args[1]))
+			return
+		}
+		setExportAllowed(c.name, call.args[1] == "on")
+		c.send(fmt.Sprintf("Conversation export by others: %s", call.args[1]))
+		return
+	case "/exportpm":
+		if len(call.args) < 1 || len(call.args) > 2 {
+			c.send("Usage: /exportpm <user> [text|json]")
+			return
+		}
+		format := "text"
+		if len(call.args) == 2 {
+			format = call.args[1]
+		}
+		if format != "text" && format != "json" {
+			c.send("Usage: /exportpm <user> [text|json]")
+			return
+		}
+		exportConversation(c, call.args[0], format)
+	}
+}
+
+// exportConversation offers c a download of its conversation with other.
+// Like /pmhistory it needs a client that logged in with a credential.
+func exportConversation(c *client, other, format string) {
+	if !c.bot {
+		c.send(errNoIdentity.Error())
+		return
+	}
+	if _, ok := authenticatedName(other); !ok {
+		c.send(fmt.Sprintf("%s is not a registered bot, so conversations with them cannot be exported", other))
+		return
+	}
+	if !exportAllowed(other) {
+		c.send(fmt.Sprintf("%s does not allow exporting conversations", other))
+		return
+	}
+	entries := conversation(c.name, other)
+	if len(entries) == 0 {
+		c.send(fmt.Sprintf("No private messages with %s", other))
+		return
+	}
+	data, err := renderConversation(entries, format)
+	if err != nil {
+		log.Printf("Error rendering conversation export: %v", err)
+		c.send("Export failed")
+		return
+	}
+
+	ext, contentType := "txt", "text/plain; charset=utf-8"
+	if format == "json" {
+		ext, contentType = "json", "application/json"
+	}
+	url, err := offerDownload(fmt.Sprintf("pm-%s-%s.%s", c.name, other, ext), contentType, data)
+	if err != nil {
+		c.send(err.Error())
+		return
+	}
+	c.send(fmt.Sprintf("Export of %s message(s) with %s ready for %d minutes: %s",
+		c.locale().number(len(entries)), other, int(downloadTTL.Minutes()), url))
+}
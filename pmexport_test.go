@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogPrivateMessage(t *testing.T) {
+	saved := config.Bots
+	t.Cleanup(func() { config.Bots = saved })
+	config.Bots = map[string]BotConfig{"Log-Bot": {Token: "secret"}, "log-tokenless": {}}
+
+	logPrivateMessage("logcarol", "logdave", "between humans", time.Now())
+	logPrivateMessage("logcarol", "log-tokenless", "no identity", time.Now())
+	logPrivateMessage("logcarol", "log-bot", "to a bot", time.Now())
+	if entries := conversation("logcarol", "logdave"); len(entries) != 0 {
+		t.Errorf("Expected conversations between humans not to be kept, got %+v", entries)
+	}
+	if entries := conversation("logcarol", "log-tokenless"); len(entries) != 0 {
+		t.Errorf("Expected conversations with a tokenless bot not to be kept, got %+v", entries)
+	}
+	if entries := conversation("Log-Bot", "logcarol"); len(entries) != 1 || entries[0].Text != "to a bot" {
+		t.Errorf("Expected the conversation with an authenticated identity to be kept, got %+v", entries)
+	}
+}
+
+func TestStorePrivateMessage(t *testing.T) {
+	t.Parallel()
+	for i := 0; i < maxConversationLog+5; i++ {
+		storePrivateMessage(pmEntry{From: "LogAlice", To: "logbob", Text: "hello", Time: time.Now()})
+	}
+	storePrivateMessage(pmEntry{From: "logbob", To: "logalice", Text: "last", Time: time.Now()})
+
+	entries := conversation("logBob", "logAlice")
+	if len(entries) != maxConversationLog {
+		t.Fatalf("Expected %d entries, got %d", maxConversationLog, len(entries))
+	}
+	if entries[len(entries)-1].Text != "last" {
+		t.Errorf("Expected both directions in one conversation, got %+v", entries[len(entries)-1])
+	}
+	if len(conversation("logalice", "nobody")) != 0 {
+		t.Error("Expected no entries for an unknown conversation")
+	}
+}
+
+func TestStorePrivateMessageEvicts(t *testing.T) {
+	pmLog.Lock()
+	saved := pmLog.conversations
+	pmLog.conversations = make(map[string][]pmEntry)
+	pmLog.Unlock()
+	t.Cleanup(func() {
+		pmLog.Lock()
+		pmLog.conversations = saved
+		pmLog.Unlock()
+	})
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < maxConversations; i++ {
+		storePrivateMessage(pmEntry{From: "evictbot", To: fmt.Sprintf("user%d", i), Text: "hi", Time: at.Add(time.Duration(i) * time.Second)})
+	}
+	// user0 writes again, so user1 is now the quietest
+	storePrivateMessage(pmEntry{From: "user0", To: "evictbot", Text: "still here", Time: at.Add(time.Hour)})
+	storePrivateMessage(pmEntry{From: "evictbot", To: "newcomer", Text: "hi", Time: at.Add(2 * time.Hour)})
+
+	pmLog.Lock()
+	kept := len(pmLog.conversations)
+	pmLog.Unlock()
+	if kept != maxConversations {
+		t.Errorf("Expected %d conversations, got %d", maxConversations, kept)
+	}
+	if len(conversation("evictbot", "user1")) != 0 {
+		t.Error("Expected the quietest conversation to be dropped")
+	}
+	if len(conversation("evictbot", "user0")) != 2 || len(conversation("evictbot", "newcomer")) != 1 {
+		t.Error("Expected the active and the new conversation to be kept")
+	}
+}
+
+func TestRenderConversation(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := []pmEntry{{From: "alice", To: "bob", Text: "hi", Time: at}}
+
+	text, _ := renderConversation(entries, "text")
+	if want := "[2024-05-01T12:00:00Z] alice -> bob: hi\n"; string(text) != want {
+		t.Errorf("Expected %q, got %q", want, text)
+	}
+
+	data, err := renderConversation(entries, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []pmEntry
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded) != 1 || decoded[0].Text != "hi" {
+		t.Errorf("Expected JSON round trip, got %s (%v)", data, err)
+	}
+}
+
+func TestHandlePMExportCommand(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.HTTPAddr = "127.0.0.1:8990"
+	config.Bots = map[string]BotConfig{"expalice": {Token: "a"}, "expbob": {Token: "b"}, "expdave": {Token: "d"}}
+	storePrivateMessage(pmEntry{From: "expalice", To: "expbob", Text: "secret plans", Time: time.Now()})
+
+	tests := []struct {
+		name    string
+		sender  string
+		message string
+		want    string
+	}{
+		{"Usage", "expalice", "/exportpm", "Usage: /exportpm"},
+		{"Bad format", "expalice", "/exportpm expbob xml", "Usage: /exportpm"},
+		{"No conversation", "expalice", "/exportpm expdave", "No private messages with expdave"},
+		{"Other without identity", "expalice", "/exportpm expcarol", "expcarol is not a registered bot"},
+		{"Export", "expalice", "/exportpm expbob json", "http://127.0.0.1:8990/download/"},
+		{"Opt out", "expbob", "/privacy export off", "Conversation export by others: off"},
+		{"Refused", "expalice", "/exportpm expbob", "expbob does not allow exporting conversations"},
+		{"Own export still allowed", "expbob", "/exportpm expalice", "/download/"},
+		{"Show setting", "expbob", "/privacy", "off"},
+		{"Export without identity", "expcarol", "/exportpm expbob", errNoIdentity.Error()},
+		{"Setting without identity", "expcarol", "/privacy export off", "Only registered bots logged in with their token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newMockConn()
+			c := &client{conn: conn, name: tt.sender, bot: tt.sender != "expcarol"}
+			handlePMExportCommand(c, callOf(tt.message))
+			if got := conn.writeBuffer.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("Expected reply containing %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errNoIdentity refuses access to the conversations kept under a name to
+// clients that did not prove they own it.
+var errNoIdentity = errors.New("Only registered bots logged in with their token can read kept conversations")
+
+const (
+	defaultPMHistory = 20  // Messages /pmhistory replays without a count
+	maxPMHistory     = 100 // Most messages one /pmhistory replays
+)
+
+// noHistory holds the users who do not want their private conversations
+// kept, by lower-case name.
+var noHistory = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// historyAllowed reports whether the private messages of name are kept.
+func historyAllowed(name string) bool {
+	noHistory.Lock()
+	defer noHistory.Unlock()
+	return !noHistory.names[strings.ToLower(name)]
+}
+
+// setHistoryAllowed records whether the private messages of name are
+// kept. Turning it off also forgets the conversations kept so far, for
+// both participants.
+func setHistoryAllowed(name string, allowed bool) {
+	noHistory.Lock()
+	if allowed {
+		delete(noHistory.names, strings.ToLower(name))
+	} else {
+		noHistory.names[strings.ToLower(name)] = true
+	}
+	noHistory.Unlock()
+	if !allowed {
+		forgetConversations(name)
+	}
+}
+
+// forgetConversations drops every private conversation of name and
+// returns how many there were.
+func forgetConversations(name string) int {
+	pmLog.Lock()
+	defer pmLog.Unlock()
+
+	lower := strings.ToLower(name)
+	removed := 0
+	for key := range pmLog.conversations {
+		a, b, _ := strings.Cut(key, "\x00")
+		if a == lower || b == lower {
+			delete(pmLog.conversations, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// handlePMHistoryCommand processes "/pmhistory <user> [count]", which
+// replays the latest private messages between the client and the user.
+// Only the two participants can read a conversation since it is looked
+// up by the client's own name, and only clients that logged in with a
+// credential may do so: anyone else could have taken a name whose
+// conversations are kept.
+func handlePMHistoryCommand(c *client, call commandCall) {
+	usage := fmt.Sprintf("Usage: /pmhistory <user> [count], at most %d messages", maxPMHistory)
+	if len(call.args) < 1 || len(call.args) > 2 {
+		c.send(usage)
+		return
+	}
+	if !c.bot {
+		c.send(errNoIdentity.Error())
+		return
+	}
+	count := defaultPMHistory
+	if len(call.args) == 2 {
+		n, err := strconv.Atoi(call.args[1])
+		if err != nil || n < 1 || n > maxPMHistory {
+			c.send(usage)
+			return
+		}
+		count = n
+	}
+
+	other := call.args[0]
+	if !historyAllowed(c.name) {
+		c.send("Your private messages are not kept, /privacy history on keeps them from now on")
+		return
+	}
+	entries := conversation(c.name, other)
+	if len(entries) == 0 {
+		c.send(fmt.Sprintf("No private messages with %s", other))
+		return
+	}
+	if len(entries) > count {
+		entries = entries[len(entries)-count:]
+	}
+	loc := c.locale()
+	c.send(fmt.Sprintf("Last %s private message(s) with %s:", loc.number(len(entries)), other))
+	for _, e := range entries {
+		if strings.EqualFold(e.From, c.name) {
+			c.send(fmt.Sprintf("[%s] [PM to %s]: %s", loc.time(e.Time), e.To, e.Text))
+		} else {
+			c.send(fmt.Sprintf("[%s] [PM from %s]: %s", loc.time(e.Time), e.From, e.Text))
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPMHistoryCommand(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		from, to := "histalice", "histbob"
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		storePrivateMessage(pmEntry{From: from, To: to, Text: fmt.Sprintf("message %d", i), Time: at.Add(time.Duration(i) * time.Minute)})
+	}
+	storePrivateMessage(pmEntry{From: "histcarol", To: "histbob", Text: "not for alice", Time: at})
+
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{"not authenticated", "/pmhistory histbob", []string{errNoIdentity.Error()}},
+		{"usage", "/pmhistory", []string{"Usage: /pmhistory <user> [count], at most 100 messages"}},
+		{"bad count", "/pmhistory histbob 0", []string{"Usage: /pmhistory <user> [count], at most 100 messages"}},
+		{"no conversation", "/pmhistory histcarol", []string{"No private messages with histcarol"}},
+		{"latest", "/pmhistory HISTBOB 2", []string{
+			"Last 2 private message(s) with HISTBOB:",
+			"[2026-03-01 12:23:00] [PM from histbob]: message 23",
+			"[2026-03-01 12:24:00] [PM to histbob]: message 24",
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			conn := newMockConn()
+			c := &client{conn: conn, name: "histalice", bot: tt.name != "not authenticated"}
+			handlePMHistoryCommand(c, callOf(tt.message))
+			if got, want := conn.writeBuffer.String(), strings.Join(tt.want, "\n")+"\n"; got != want {
+				t.Errorf("%q answered %q, want %q", tt.message, got, want)
+			}
+		})
+	}
+
+	t.Run("default count", func(t *testing.T) {
+		t.Parallel()
+		conn := newMockConn()
+		handlePMHistoryCommand(&client{conn: conn, name: "histbob", bot: true}, callOf("/pmhistory histalice"))
+		lines := strings.Split(strings.TrimSuffix(conn.writeBuffer.String(), "\n"), "\n")
+		if len(lines) != defaultPMHistory+1 || !strings.HasSuffix(lines[1], "[PM to histalice]: message 5") {
+			t.Errorf("Expected the latest %d messages, got %q", defaultPMHistory, lines)
+		}
+	})
+}
+
+func TestPMHistoryPrivacy(t *testing.T) {
+	saved := config.Bots
+	t.Cleanup(func() { config.Bots = saved })
+	config.Bots = map[string]BotConfig{"quietbob": {Token: "secret"}}
+	logPrivateMessage("quietalice", "quietbob", "kept", time.Now())
+	conn := newMockConn()
+	bob := &client{conn: conn, name: "quietbob", bot: true}
+	handlePMExportCommand(bob, callOf("/privacy history off"))
+	logPrivateMessage("quietalice", "quietbob", "not kept", time.Now())
+	if entries := conversation("quietalice", "quietbob"); len(entries) != 0 {
+		t.Errorf("Expected the conversation to be forgotten and not kept, got %+v", entries)
+	}
+
+	conn.writeBuffer.Reset()
+	handlePMHistoryCommand(bob, callOf("/pmhistory quietalice"))
+	if got := conn.writeBuffer.String(); !strings.HasPrefix(got, "Your private messages are not kept") {
+		t.Errorf("Expected to be told the history is off, got %q", got)
+	}
+
+	handlePMExportCommand(bob, callOf("/privacy history on"))
+	logPrivateMessage("quietalice", "quietbob", "kept again", time.Now())
+	if entries := conversation("quietalice", "quietbob"); len(entries) != 1 || entries[0].Text != "kept again" {
+		t.Errorf("Expected messages to be kept again, got %+v", entries)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pollDuration   = 10 * time.Minute // Time a poll stays open unless closed earlier
+	maxPollOptions = 10
+	maxPollLength  = 200 // Longest question or option in characters
+)
+
+// poll is the open poll of a room.
+type poll struct {
+	room     string
+	creator  string
+	question string
+	options  []string
+	votes    map[string]int // Option index by lowercase voter name
+	closes   time.Time
+}
+
+// polls holds the open poll of each room by room name.
+var polls = struct {
+	sync.Mutex
+	rooms map[string]*poll
+}{rooms: make(map[string]*poll)}
+
+func pollJobKey(room string) string {
+	return "poll:" + room
+}
+
+// splitQuoted splits s into words, keeping "quoted phrases" together
+// without their quotes. It reports false when a quote is left open.
+func splitQuoted(s string) ([]string, bool) {
+	var words []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return words, true
+		}
+		if rest, ok := strings.CutPrefix(s, `"`); ok {
+			phrase, after, closed := strings.Cut(rest, `"`)
+			if !closed {
+				return nil, false
+			}
+			words = append(words, phrase)
+			s = after
+			continue
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		words = append(words, s[:end])
+		s = s[end:]
+	}
+}
+
+// tally writes the votes for each option of p, in option order. The
+// caller must hold polls.
+func (p *poll) tally() string {
+	counts := make([]int, len(p.options))
+	for _, choice := range p.votes {
+		counts[choice]++
+	}
+	parts := make([]string, len(p.options))
+	for i, option := range p.options {
+		parts[i] = fmt.Sprintf("%d) %s: %d", i+1, option, counts[i])
+	}
+	return fmt.Sprintf("%s (%d vote(s))", strings.Join(parts, ", "), len(p.votes))
+}
+
+// handlePollCommand processes "/poll" in its forms: `/poll "Question?"
+// <option> <option>...` opens a poll in the client's room, "/poll" shows
+// the open one and "/poll close" ends it early, which its creator and
+// moderators can do. Polls close by themselves after pollDuration.
+func handlePollCommand(c *client, call commandCall) {
+	mutex.Lock()
+	room := c.room
+	mutex.Unlock()
+
+	switch call.text {
+	case "":
+		polls.Lock()
+		p, ok := polls.rooms[room]
+		var status string
+		if ok {
+			status = fmt.Sprintf("Poll by %s: %s %s, closes in %s", p.creator, p.question, p.tally(),
+				time.Until(p.closes).Round(time.Second))
+		}
+		polls.Unlock()
+		if !ok {
+			c.send(fmt.Sprintf("No poll is open in %s", room))
+			return
+		}
+		c.send(status)
+	case "close":
+		polls.Lock()
+		p, ok := polls.rooms[room]
+		polls.Unlock()
+		if !ok {
+			c.send(fmt.Sprintf("No poll is open in %s", room))
+			return
+		}
+		if p.creator != c.name && !c.isModerator() {
+			c.send("Permission denied: only the poll creator or a moderator can close the poll")
+			return
+		}
+		jobs.cancel(pollJobKey(room))
+		closePoll(p)
+	default:
+		openPoll(c, room, call.text)
+	}
+}
+
+// openPoll starts the poll described by args, the question and options of
+// /poll, in room.
+func openPoll(c *client, room, args string) {
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+	usage := fmt.Sprintf(`Usage: /poll "Question?" <option> <option>... (2 to %d options)`, maxPollOptions)
+	words, ok := splitQuoted(args)
+	if !ok || len(words) < 3 || len(words) > maxPollOptions+1 {
+		c.send(usage)
+		return
+	}
+	for _, word := range words {
+		if strings.TrimSpace(word) == "" {
+			c.send(usage)
+			return
+		}
+		if len(word) > maxPollLength {
+			c.send(fmt.Sprintf("Poll question and options are limited to %d characters", maxPollLength))
+			return
+		}
+	}
+	// The poll is posted by the server on the creator's behalf, so its
+	// text passes the checks of chat messages
+	received := time.Now()
+	for i, word := range words {
+		screened, ok := screenChatMessage(c, word, received)
+		if !ok {
+			return
+		}
+		words[i] = screened
+	}
+
+	p := &poll{
+		room:     room,
+		creator:  c.name,
+		question: words[0],
+		options:  words[1:],
+		votes:    make(map[string]int),
+		closes:   time.Now().Add(pollDuration),
+	}
+	choices := make([]string, len(p.options))
+	for i, option := range p.options {
+		choices[i] = fmt.Sprintf("%d) %s", i+1, option)
+	}
+	announcement := formatSystemMessage(fmt.Sprintf("Poll by %s: %s %s, /vote <n> within %d minutes",
+		c.name, p.question, strings.Join(choices, " "), int(pollDuration.Minutes())))
+	// Shadow-banned creators see their poll, nobody else does
+	if isShadowBanned(c) {
+		c.send(announcement)
+		return
+	}
+
+	polls.Lock()
+	if _, busy := polls.rooms[room]; busy {
+		polls.Unlock()
+		c.send(fmt.Sprintf("A poll is already open in %s, see /poll", room))
+		return
+	}
+	polls.rooms[room] = p
+	polls.Unlock()
+	jobs.schedule(pollJobKey(room), p.closes, func() { closePoll(p) })
+
+	log.Printf("%s opened a poll in %s: %s", c.name, room, p.question)
+	broadcastToRoom(room, announcement, nil)
+}
+
+// closePoll ends p, unless it was closed already, and announces the
+// results to its room.
+func closePoll(p *poll) {
+	polls.Lock()
+	if polls.rooms[p.room] != p {
+		polls.Unlock()
+		return
+	}
+	delete(polls.rooms, p.room)
+	results := p.tally()
+	polls.Unlock()
+
+	log.Printf("Poll in %s closed: %s %s", p.room, p.question, results)
+	broadcastToRoom(p.room, formatSystemMessage(fmt.Sprintf("Poll closed: %s %s", p.question, results)), nil)
+}
+
+// handleVoteCommand processes "/vote <n>", a vote for option n of the poll
+// open in the client's room. Everyone votes once per poll.
+func handleVoteCommand(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send("Usage: /vote <n>")
+		return
+	}
+
+	mutex.Lock()
+	room := c.room
+	mutex.Unlock()
+
+	polls.Lock()
+	p, ok := polls.rooms[room]
+	if !ok {
+		polls.Unlock()
+		c.send(fmt.Sprintf("No poll is open in %s", room))
+		return
+	}
+	n, err := strconv.Atoi(call.args[0])
+	if err != nil || n < 1 || n > len(p.options) {
+		polls.Unlock()
+		c.send(fmt.Sprintf("Usage: /vote <n>, with n from 1 to %d", len(p.options)))
+		return
+	}
+	voter := strings.ToLower(c.name)
+	if _, voted := p.votes[voter]; voted {
+		polls.Unlock()
+		c.send("You already voted in this poll")
+		return
+	}
+	p.votes[voter] = n - 1
+	option := p.options[n-1]
+	polls.Unlock()
+
+	c.send(fmt.Sprintf("Vote recorded for %s", option))
+}
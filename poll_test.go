@@ -0,0 +1,160 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitQuoted(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input  string
+		want   []string
+		wantOK bool
+	}{
+		{`"Lunch where?" pizza sushi`, []string{"Lunch where?", "pizza", "sushi"}, true},
+		{`  a   "b c"d  `, []string{"a", "b c", "d"}, true},
+		{`"open`, nil, false},
+		{``, nil, true},
+	}
+	for _, tt := range tests {
+		got, ok := splitQuoted(tt.input)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOK {
+			t.Errorf("splitQuoted(%q) = %q, %t, want %q, %t", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestPollCommand(t *testing.T) {
+	const name = "#poll-test"
+	aliceConn, bobConn, modConn := newMockConn(), newMockConn(), newMockConn()
+	alice := &client{conn: aliceConn, name: "poll-alice", room: name}
+	bob := &client{conn: bobConn, name: "poll-bob", room: name}
+	mod := &client{conn: modConn, name: "poll-mod", room: name, moderator: true}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[aliceConn], clients[bobConn], clients[modConn] = alice, bob, mod
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, aliceConn)
+		delete(clients, bobConn)
+		delete(clients, modConn)
+		mutex.Unlock()
+		jobs.cancel(pollJobKey(name))
+	}()
+
+	tests := []struct {
+		client  *client
+		message string
+		want    string
+	}{
+		{bob, "/vote 1", "No poll is open in #poll-test"},
+		{alice, `/poll "Lunch?" pizza`, "Usage: /poll"},
+		{alice, `/poll "Lunch? pizza sushi`, "Usage: /poll"},
+		{alice, `/poll "Lunch?" pizza "sushi bar"`, "SERVER: Poll by poll-alice: Lunch? 1) pizza 2) sushi bar, /vote <n> within 10 minutes"},
+		{bob, `/poll "Dinner?" a b`, "A poll is already open in #poll-test"},
+		{bob, "/vote 3", "Usage: /vote <n>, with n from 1 to 2"},
+		{bob, "/vote 2", "Vote recorded for sushi bar"},
+		{bob, "/vote 1", "You already voted in this poll"},
+		{alice, "/vote 2", "Vote recorded for sushi bar"},
+		{mod, "/vote 1", "Vote recorded for pizza"},
+		{bob, "/poll", "Poll by poll-alice: Lunch? 1) pizza: 1, 2) sushi bar: 2 (3 vote(s)), closes in "},
+		{bob, "/poll close", "Permission denied: only the poll creator or a moderator can close the poll"},
+	}
+	for _, tt := range tests {
+		conn := tt.client.conn.(*mockConn)
+		conn.writeBuffer.Reset()
+		if !handleCommand(tt.client, tt.message, time.Now()) {
+			t.Fatalf("Expected %q to be handled", tt.message)
+		}
+		if got := conn.writeBuffer.String(); !strings.Contains(got, tt.want) {
+			t.Errorf("%s: %q answered %q, want %q", tt.client.name, tt.message, got, tt.want)
+		}
+	}
+	if !jobs.pending(pollJobKey(name)) {
+		t.Error("Expected the poll to time out on its own")
+	}
+
+	bobConn.writeBuffer.Reset()
+	handlePollCommand(mod, callOf("/poll close"))
+	if got, want := bobConn.writeBuffer.String(), "SERVER: Poll closed: Lunch? 1) pizza: 1, 2) sushi bar: 2 (3 vote(s))\n"; got != want {
+		t.Errorf("Expected the results, got %q, want %q", got, want)
+	}
+	if jobs.pending(pollJobKey(name)) {
+		t.Error("Expected closing the poll to cancel the timeout")
+	}
+
+	// Polls close by themselves with the same announcement
+	handlePollCommand(alice, callOf(`/poll "Again?" yes no`))
+	polls.Lock()
+	p := polls.rooms[name]
+	polls.Unlock()
+	bobConn.writeBuffer.Reset()
+	closePoll(p)
+	closePoll(p)
+	if got := bobConn.writeBuffer.String(); got != "SERVER: Poll closed: Again? 1) yes: 0, 2) no: 0 (0 vote(s))\n" {
+		t.Errorf("Expected the results once, got %q", got)
+	}
+}
+
+func TestPollScreening(t *testing.T) {
+	const name = "#poll-screen"
+	aliceConn, bobConn := newMockConn(), newMockConn()
+	alice := &client{conn: aliceConn, name: "screen-alice", room: name}
+	bob := &client{conn: bobConn, name: "screen-bob", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[aliceConn], clients[bobConn] = alice, bob
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, aliceConn)
+		delete(clients, bobConn)
+		mutex.Unlock()
+		jobs.cancel(pollJobKey(name))
+		polls.Lock()
+		delete(polls.rooms, name)
+		polls.Unlock()
+	}()
+	filter, err := loadWordFilter(writeWordlist(t, "darn\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved *wordFilter) { profanity = saved }(profanity)
+	profanity = filter
+
+	open := func() bool {
+		polls.Lock()
+		defer polls.Unlock()
+		_, ok := polls.rooms[name]
+		return ok
+	}
+
+	muteClient(alice, "test", 0)
+	handlePollCommand(alice, callOf(`/poll "Lunch?" pizza sushi`))
+	if got := aliceConn.writeBuffer.String(); !strings.Contains(got, "You are muted") || open() {
+		t.Errorf("Expected a muted user's poll to be refused, got %q", got)
+	}
+	unmuteClient(alice)
+
+	shadowBan(alice.name, "")
+	aliceConn.writeBuffer.Reset()
+	handlePollCommand(alice, callOf(`/poll "Lunch?" pizza sushi`))
+	if got := bobConn.writeBuffer.String(); got != "" || open() {
+		t.Errorf("Expected a shadow-banned user's poll to reach nobody, got %q", got)
+	}
+	if got := aliceConn.writeBuffer.String(); !strings.Contains(got, "Poll by screen-alice") {
+		t.Errorf("Expected the shadow-banned creator to see the poll, got %q", got)
+	}
+	liftShadowBan(alice.name, "")
+
+	handlePollCommand(alice, callOf(`/poll "Darn lunch?" pizza "darn sushi"`))
+	if got := bobConn.writeBuffer.String(); !strings.Contains(got, "**** lunch? 1) pizza 2) **** sushi") {
+		t.Errorf("Expected the poll text to pass the profanity filter, got %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxPresenceWatches is the number of users a client may watch at once.
+const maxPresenceWatches = 20
+
+// presenceTag starts the notices sent to users watching someone's
+// presence: "[PRESENCE] bob is online".
+const presenceTag = "[PRESENCE] "
+
+// presenceWatches holds the users each client watches with /watch <user>, by
+// lowercase name with the name as typed. Subscriptions last for the
+// session of the watching client.
+var presenceWatches = struct {
+	sync.Mutex
+	watching map[*client]map[string]string
+}{watching: make(map[*client]map[string]string)}
+
+// notifyPresence tells the clients watching name that change happened to
+// them, such as "is online".
+func notifyPresence(name, change string) {
+	key := strings.ToLower(name)
+	var watchers []*client
+	presenceWatches.Lock()
+	for c, names := range presenceWatches.watching {
+		if _, ok := names[key]; ok && c.name != name {
+			watchers = append(watchers, c)
+		}
+	}
+	presenceWatches.Unlock()
+
+	for _, c := range watchers {
+		c.send(presenceTag + name + " " + change)
+	}
+}
+
+// forgetPresenceWatches ends the subscriptions of a disconnected client.
+func forgetPresenceWatches(c *client) {
+	presenceWatches.Lock()
+	delete(presenceWatches.watching, c)
+	presenceWatches.Unlock()
+}
+
+// watchedUsers lists the users c watches, sorted by name.
+func watchedUsers(c *client) []string {
+	presenceWatches.Lock()
+	defer presenceWatches.Unlock()
+
+	names := make([]string, 0, len(presenceWatches.watching[c]))
+	for _, name := range presenceWatches.watching[c] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// watchUser subscribes c to the presence of name and tells whether name
+// is online now.
+func watchUser(c *client, name string) {
+	key := strings.ToLower(name)
+	presenceWatches.Lock()
+	names := presenceWatches.watching[c]
+	if _, renewing := names[key]; !renewing && len(names) >= maxPresenceWatches {
+		presenceWatches.Unlock()
+		c.send(fmt.Sprintf("You can watch at most %d users", maxPresenceWatches))
+		return
+	}
+	if names == nil {
+		names = make(map[string]string)
+		presenceWatches.watching[c] = names
+	}
+	names[key] = name
+	presenceWatches.Unlock()
+
+	log.Printf("%s watches the presence of %s", c.name, name)
+	status := "offline"
+	if target := findClientByName(name); target != nil {
+		status = "online"
+		if target.dnd.Load() {
+			status = "online, do not disturb"
+		} else if target.awayMessage() != "" {
+			status = "online, away"
+		}
+	}
+	c.send(fmt.Sprintf("Watching %s, who is %s now", name, status))
+}
+
+// unwatchUser ends the subscription of c to the presence of name.
+func unwatchUser(c *client, name string) {
+	key := strings.ToLower(name)
+	presenceWatches.Lock()
+	_, ok := presenceWatches.watching[c][key]
+	delete(presenceWatches.watching[c], key)
+	presenceWatches.Unlock()
+	if !ok {
+		c.send(fmt.Sprintf("You are not watching %s", name))
+		return
+	}
+	c.send(fmt.Sprintf("Stopped watching %s", name))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresenceWatch(t *testing.T) {
+	watcherConn, bobConn := newMockConn(), newMockConn()
+	watcher := &client{conn: watcherConn, name: "presence-watcher", room: defaultRoom}
+	bob := &client{conn: bobConn, name: "presence-bob", room: defaultRoom}
+	mutex.Lock()
+	clients[watcherConn], clients[bobConn] = watcher, bob
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, watcherConn)
+		delete(clients, bobConn)
+		mutex.Unlock()
+		forgetPresenceWatches(watcher)
+	}()
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"/watch presence-bob", "Watching presence-bob, who is online now"},
+		{"/watch presence-carol", "Watching presence-carol, who is offline now"},
+		{"/watch", "Watching: presence-bob, presence-carol"},
+		{"/watch presence-bob 1h", "Usage: /watch [#room [interval] | <user>]"},
+		{"/unwatch presence-carol", "Stopped watching presence-carol"},
+		{"/unwatch presence-carol", "You are not watching presence-carol"},
+	}
+	for _, tt := range tests {
+		watcherConn.writeBuffer.Reset()
+		handleWatchCommand(watcher, callOf(tt.message))
+		if got := watcherConn.writeBuffer.String(); got != tt.want+"\n" {
+			t.Errorf("%q answered %q, want %q", tt.message, got, tt.want)
+		}
+	}
+
+	watcherConn.writeBuffer.Reset()
+	handleDNDCommand(bob, callOf("/dnd on"))
+	notifyPresence("Presence-Bob", "went offline")
+	notifyPresence("presence-carol", "is online")
+	want := presenceTag + "presence-bob turned on do not disturb\n" + presenceTag + "Presence-Bob went offline\n"
+	if got := watcherConn.writeBuffer.String(); got != want {
+		t.Errorf("Watcher got %q, want %q", got, want)
+	}
+
+	forgetPresenceWatches(watcher)
+	watcherConn.writeBuffer.Reset()
+	notifyPresence("presence-bob", "is online")
+	if got := watcherConn.writeBuffer.String(); strings.Contains(got, presenceTag) {
+		t.Errorf("Expected no notices after the watcher left, got %q", got)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf8"
+)
+
+// Profanity filter actions, set server-wide with profanity_action and per
+// room with the profanity room setting.
+const (
+	profanityMask   = "mask"   // Replace banned words with asterisks
+	profanityReject = "reject" // Refuse the message
+	profanityFlag   = "flag"   // Deliver the message and alert moderators
+	profanityOff    = "off"    // Disable the filter
+)
+
+var errProfanity = errors.New("Message rejected: it contains a banned word")
+
+// wordFilter matches whole words from a wordlist file, ignoring case. The
+// list can be reloaded while the server runs.
+type wordFilter struct {
+	mu      sync.RWMutex
+	path    string
+	pattern *regexp.Regexp // nil when the list is empty
+	words   int
+}
+
+var profanity = &wordFilter{} // Profanity wordlist, empty until loaded
+
+// loadWordFilter reads the wordlist at path. An empty path gives an empty
+// filter that matches nothing.
+func loadWordFilter(path string) (*wordFilter, error) {
+	f := &wordFilter{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// reload rereads the wordlist: one word or phrase per line, blank lines
+// and lines starting with '#' are ignored.
+func (f *wordFilter) reload() error {
+	if f.path == "" {
+		return nil
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, regexp.QuoteMeta(strings.ToLower(word)))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var pattern *regexp.Regexp
+	if len(words) > 0 {
+		// Longest first so that phrases win over the words they contain
+		sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+		pattern, err = regexp.Compile(`(?i)\b(?:` + strings.Join(words, "|") + `)\b`)
+		if err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pattern, f.words = pattern, len(words)
+	return nil
+}
+
+// size returns the number of words in the list.
+func (f *wordFilter) size() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.words
+}
+
+// matches reports whether message contains a listed word.
+func (f *wordFilter) matches(message string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.pattern != nil && f.pattern.MatchString(message)
+}
+
+// mask replaces every listed word in message with asterisks.
+func (f *wordFilter) mask(message string) string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.pattern == nil {
+		return message
+	}
+	return f.pattern.ReplaceAllStringFunc(message, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	})
+}
+
+// roomProfanityAction returns the filter action for the named room: its
+// own setting when present, the server-wide one otherwise.
+func roomProfanityAction(name string) string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if r, ok := rooms[name]; ok && r.settings.Profanity != "" {
+		return r.settings.Profanity
+	}
+	if config.ProfanityAction == "" {
+		return profanityMask
+	}
+	return config.ProfanityAction
+}
+
+// applyProfanityFilter checks a chat message c posts to room against the
+// wordlist and returns the message to deliver, or errProfanity if it is
+// refused.
+func applyProfanityFilter(c *client, room, message string) (string, error) {
+	action := roomProfanityAction(room)
+	if action == profanityOff || !profanity.matches(message) {
+		return message, nil
+	}
+	switch action {
+	case profanityReject:
+		log.Printf("Rejected message from %s in %s by the profanity filter", c.name, room)
+		return "", errProfanity
+	case profanityFlag:
+		log.Printf("Flagged message from %s in %s: %s", c.name, room, message)
+		notifyModerators(fmt.Sprintf("[flagged] %s in %s: %s", c.name, room, message))
+		return message, nil
+	default:
+		return profanity.mask(message), nil
+	}
+}
+
+// notifyModerators sends notice to every connected moderator and operator.
+func notifyModerators(notice string) {
+	mutex.Lock()
+	var moderators []*client
+	for _, c := range clients {
+		if c.isModerator() {
+			moderators = append(moderators, c)
+		}
+	}
+	mutex.Unlock()
+
+	for _, c := range moderators {
+		c.send(notice)
+	}
+}
+
+// reloadProfanityFilter rereads the wordlist, keeping the old one on error.
+func reloadProfanityFilter() error {
+	if err := profanity.reload(); err != nil {
+		return err
+	}
+	log.Printf("Profanity filter loaded %d word(s)", profanity.size())
+	return nil
+}
+
+// reloadOnHangup reloads the profanity wordlist whenever the server
+// receives SIGHUP.
+func reloadOnHangup() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloadProfanityFilter(); err != nil {
+				log.Printf("Error reloading profanity filter: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeWordlist(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWordFilter(t *testing.T) {
+	t.Parallel()
+	path := writeWordlist(t, "# comment\ndarn\n\nheck off\n")
+	f, err := loadWordFilter(path)
+	if err != nil {
+		t.Fatalf("loadWordFilter: %v", err)
+	}
+	if f.size() != 2 {
+		t.Errorf("Expected 2 words, got %d", f.size())
+	}
+
+	tests := []struct {
+		message string
+		match   bool
+		masked  string
+	}{
+		{"well DARN it", true, "well **** it"},
+		{"darned socks", false, "darned socks"},
+		{"oh heck off", true, "oh ********"},
+		{"heck yes", false, "heck yes"},
+	}
+	for _, tt := range tests {
+		if got := f.matches(tt.message); got != tt.match {
+			t.Errorf("matches(%q) = %t, want %t", tt.message, got, tt.match)
+		}
+		if got := f.mask(tt.message); got != tt.masked {
+			t.Errorf("mask(%q) = %q, want %q", tt.message, got, tt.masked)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("socks\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if f.matches("darn") || !f.matches("socks") {
+		t.Error("Expected reload to replace the wordlist")
+	}
+}
+
+func TestLoadWordFilterEmptyPath(t *testing.T) {
+	t.Parallel()
+	f, err := loadWordFilter("")
+	if err != nil {
+		t.Fatalf("loadWordFilter: %v", err)
+	}
+	if f.matches("anything") {
+		t.Error("Expected an empty filter to match nothing")
+	}
+	if _, err := loadWordFilter(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected a missing wordlist to be an error")
+	}
+}
+
+func TestApplyProfanityFilter(t *testing.T) {
+	defer func(saved *wordFilter) { profanity = saved }(profanity)
+	defer func(saved Config) { config = saved }(config)
+
+	var err error
+	profanity, err = loadWordFilter(writeWordlist(t, "darn\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	rooms["#strict"] = &room{name: "#strict", settings: RoomSettings{Profanity: profanityReject}}
+	rooms["#relaxed"] = &room{name: "#relaxed", settings: RoomSettings{Profanity: profanityOff}}
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, "#strict")
+		delete(rooms, "#relaxed")
+		mutex.Unlock()
+	}()
+
+	tests := []struct {
+		name    string
+		room    string
+		action  string
+		want    string
+		wantErr bool
+	}{
+		{"Server default masks", defaultRoom, profanityMask, "oh ****", false},
+		{"Room rejects", "#strict", profanityMask, "", true},
+		{"Room disables", "#relaxed", profanityReject, "oh darn", false},
+		{"Flag delivers", defaultRoom, profanityFlag, "oh darn", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.ProfanityAction = tt.action
+			c := &client{conn: newMockConn(), name: "alice", room: tt.room}
+			got, err := applyProfanityFilter(c, tt.room, "oh darn")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %t, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	c := &client{conn: newMockConn(), name: "alice", room: defaultRoom}
+	if got, _ := applyProfanityFilter(c, defaultRoom, "clean words"); got != "clean words" {
+		t.Errorf("Expected clean message untouched, got %q", got)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// maxFrameBytes is the largest protobuf envelope accepted from a client.
+const maxFrameBytes = maxLineBytes
+
+var (
+	errInvalidProtobuf = errors.New("Invalid protobuf message")
+	errFrameTooLong    = errors.New("Message too large, discarded")
+)
+
+// Field numbers of Envelope in proto/chat.proto. The payload fields form
+// a oneof.
+const (
+	fieldID        = 1
+	fieldTimestamp = 2
+	fieldJoin      = 3
+	fieldLeave     = 4
+	fieldChat      = 5
+	fieldPrivate   = 6
+	fieldCommand   = 7
+	fieldError     = 8
+	fieldNotice    = 9
+	fieldSeq       = 10
+	fieldMention   = 11
+)
+
+// Protobuf wire types used by the schema.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protobufCodec is the protobuf encoding: Envelope messages, each
+// preceded by its length as a varint.
+type protobufCodec struct {
+	r    *bufio.Reader
+	buf  []byte // Bytes read but not decoded yet
+	skip uint64 // Bytes left of an oversized frame being dropped
+	tmp  [512]byte
+}
+
+func newProtobufCodec(r *bufio.Reader) *protobufCodec {
+	return &protobufCodec{r: r}
+}
+
+func (p *protobufCodec) encode(msg wireMessage) ([]byte, error) {
+	envelope := encodeEnvelope(msg)
+	frame := binary.AppendUvarint(nil, uint64(len(envelope)))
+	return append(frame, envelope...), nil
+}
+
+func (p *protobufCodec) decode() (wireMessage, error) {
+	frame, err := p.readFrame()
+	if err != nil {
+		return wireMessage{}, err
+	}
+	msg, err := decodeEnvelope(frame)
+	if err != nil {
+		return wireMessage{}, badMessage{err}
+	}
+	return msg, nil
+}
+
+// readFrame returns the next envelope. A partial frame survives read
+// timeouts, and oversized frames are dropped so the stream stays in sync.
+func (p *protobufCodec) readFrame() ([]byte, error) {
+	for {
+		if p.skip > 0 {
+			n, err := p.r.Discard(int(min(p.skip, uint64(len(p.tmp)))))
+			p.skip -= uint64(n)
+			if err != nil {
+				return nil, err
+			}
+			if p.skip == 0 {
+				return nil, badMessage{errFrameTooLong}
+			}
+			continue
+		}
+
+		size, n := binary.Uvarint(p.buf)
+		if n < 0 {
+			// The length itself is garbage; there is no way to resync
+			return nil, errInvalidProtobuf
+		}
+		if n > 0 {
+			have := uint64(len(p.buf) - n)
+			if size > maxFrameBytes {
+				if have >= size {
+					p.buf = append(p.buf[:0], p.buf[n+int(size):]...)
+					return nil, badMessage{errFrameTooLong}
+				}
+				p.skip, p.buf = size-have, p.buf[:0]
+				continue
+			}
+			if have >= size {
+				frame := append([]byte(nil), p.buf[n:n+int(size)]...)
+				p.buf = append(p.buf[:0], p.buf[n+int(size):]...)
+				return frame, nil
+			}
+		}
+
+		read, err := p.r.Read(p.tmp[:])
+		p.buf = append(p.buf, p.tmp[:read]...)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// encodeEnvelope encodes msg as an Envelope, picking the payload that
+// matches its type.
+func encodeEnvelope(msg wireMessage) []byte {
+	kind := fieldNotice
+	switch msg.Type {
+	case "chat":
+		kind = fieldChat
+	case "private", "private_echo":
+		kind = fieldPrivate
+	case "error":
+		kind = fieldError
+	case "system":
+		if joined, user, room, ok := presence(msg.Body); ok {
+			kind = fieldLeave
+			if joined {
+				kind = fieldJoin
+			}
+			msg = wireMessage{Sender: user, Room: room, ID: msg.ID, Seq: msg.Seq, Timestamp: msg.Timestamp}
+		}
+	}
+
+	var payload []byte
+	for i, value := range payloadFields(kind, &msg) {
+		payload = appendStringField(payload, i+1, *value)
+	}
+	var b []byte
+	b = appendVarintField(b, fieldID, msg.ID)
+	b = appendVarintField(b, fieldSeq, msg.Seq)
+	if msg.Mention {
+		b = appendVarintField(b, fieldMention, 1)
+	}
+	if !msg.Timestamp.IsZero() {
+		b = appendVarintField(b, fieldTimestamp, uint64(msg.Timestamp.UnixMilli()))
+	}
+	return appendBytesField(b, kind, payload)
+}
+
+// decodeEnvelope decodes an Envelope. The type of the message is the name
+// of the payload, as in the JSON encoding, or the type of a Notice.
+func decodeEnvelope(b []byte) (wireMessage, error) {
+	var msg wireMessage
+	kind := 0
+	var payload []byte
+	err := protoFields(b, func(field int, v uint64, data []byte) {
+		switch field {
+		case fieldID:
+			msg.ID = v
+		case fieldSeq:
+			msg.Seq = v
+		case fieldMention:
+			msg.Mention = v != 0
+		case fieldTimestamp:
+			msg.Timestamp = time.UnixMilli(int64(v)).UTC()
+		case fieldJoin, fieldLeave, fieldChat, fieldPrivate, fieldCommand, fieldError, fieldNotice:
+			kind, payload = field, data
+		}
+	})
+	if err != nil {
+		return msg, err
+	}
+	fields := payloadFields(kind, &msg)
+	err = protoFields(payload, func(field int, v uint64, data []byte) {
+		if field >= 1 && field <= len(fields) {
+			*fields[field-1] = string(data)
+		}
+	})
+	if err != nil {
+		return msg, err
+	}
+	if kind != fieldNotice {
+		msg.Type = map[int]string{
+			fieldJoin:    "join",
+			fieldLeave:   "leave",
+			fieldChat:    "chat",
+			fieldPrivate: "private",
+			fieldCommand: "command",
+			fieldError:   "error",
+		}[kind]
+	}
+	return msg, nil
+}
+
+// payloadFields returns the fields of msg that the payload message kind
+// holds, in the order of their field numbers.
+func payloadFields(kind int, msg *wireMessage) []*string {
+	switch kind {
+	case fieldJoin, fieldLeave:
+		return []*string{&msg.Sender, &msg.Room}
+	case fieldChat:
+		return []*string{&msg.Sender, &msg.Room, &msg.Body}
+	case fieldPrivate:
+		return []*string{&msg.Sender, &msg.To, &msg.Body}
+	case fieldCommand, fieldError:
+		return []*string{&msg.Body}
+	case fieldNotice:
+		return []*string{&msg.Type, &msg.Sender, &msg.Room, &msg.Body}
+	}
+	return nil
+}
+
+// presence reads a join or leave notice, "<user> has joined #room" or
+// "<user> has left our chat...". The room is empty for the whole server.
+func presence(body string) (joined bool, user, room string, ok bool) {
+	user, rest, ok := strings.Cut(body, " has joined ")
+	joined = ok
+	if !ok {
+		user, rest, ok = strings.Cut(body, " has left ")
+	}
+	if !ok || user == "" {
+		return false, "", "", false
+	}
+	if rest == "our chat..." {
+		return joined, user, "", true
+	}
+	if strings.HasPrefix(rest, "#") && !strings.Contains(rest, " ") {
+		return joined, user, rest, true
+	}
+	return false, "", "", false
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b // proto3 leaves out default values
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendStringField(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytesField(b, field, []byte(s))
+}
+
+// protoFields calls fn with every field of the encoded message b: varint
+// fields with their value, length-delimited ones with their bytes. Fixed
+// width fields are skipped, as no message of the schema uses them.
+func protoFields(b []byte, fn func(field int, v uint64, data []byte)) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errInvalidProtobuf
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errInvalidProtobuf
+			}
+			b = b[n:]
+			fn(field, v, nil)
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return errInvalidProtobuf
+			}
+			fn(field, 0, b[n:n+int(size)])
+			b = b[n+int(size):]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errInvalidProtobuf
+			}
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errInvalidProtobuf
+			}
+			b = b[4:]
+		default:
+			return errInvalidProtobuf
+		}
+	}
+	return nil
+}
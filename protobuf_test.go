@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	t.Parallel()
+	sent := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		msg  wireMessage
+		want wireMessage
+	}{
+		{"chat", wireMessage{Type: "chat", Sender: "alice", Room: "#general", Body: "hi"},
+			wireMessage{Type: "chat", Sender: "alice", Room: "#general", Body: "hi"}},
+		{"private echo", wireMessage{Type: "private_echo", To: "bob", Body: "psst"},
+			wireMessage{Type: "private", To: "bob", Body: "psst"}},
+		{"room join", wireMessage{Type: "system", Sender: "SERVER", Room: "#dev", Body: "bob has joined #dev"},
+			wireMessage{Type: "join", Sender: "bob", Room: "#dev"}},
+		{"server leave", wireMessage{Type: "system", Sender: "SERVER", Room: "#dev", Body: "bob (guest) has left our chat..."},
+			wireMessage{Type: "leave", Sender: "bob (guest)"}},
+		{"other system notice", wireMessage{Type: "system", Sender: "SERVER", Body: "Server maintenance in 5 minutes"},
+			wireMessage{Type: "system", Sender: "SERVER", Body: "Server maintenance in 5 minutes"}},
+		{"mention", wireMessage{Type: "chat", Sender: "bob", Room: "#dev", Body: "@alice ping", Mention: true},
+			wireMessage{Type: "chat", Sender: "bob", Room: "#dev", Body: "@alice ping", Mention: true}},
+		{"typing", wireMessage{Type: "typing", Sender: "bob", Room: "#dev"},
+			wireMessage{Type: "typing", Sender: "bob", Room: "#dev"}},
+		{"error", wireMessage{Type: "error", Body: errInvalidProtobuf.Error()},
+			wireMessage{Type: "error", Body: errInvalidProtobuf.Error()}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.msg.ID, tt.msg.Seq, tt.msg.Timestamp = 7, 3, sent
+			got, err := decodeEnvelope(encodeEnvelope(tt.msg))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			tt.want.ID, tt.want.Seq, tt.want.Timestamp = 7, 3, sent
+			if got != tt.want {
+				t.Errorf("Decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEnvelope(t *testing.T) {
+	t.Parallel()
+	// Command{line: "/list"} as another implementation would encode it
+	command := []byte{fieldCommand<<3 | wireBytes, 7, 1<<3 | wireBytes, 5, '/', 'l', 'i', 's', 't'}
+	got, err := decodeEnvelope(command)
+	if err != nil || got != (wireMessage{Type: "command", Body: "/list"}) {
+		t.Errorf("decodeEnvelope(command) = %+v, %v", got, err)
+	}
+
+	// Unknown fields are skipped
+	withExtra := append([]byte{15<<3 | wireFixed32, 1, 2, 3, 4}, command...)
+	if got, err := decodeEnvelope(withExtra); err != nil || got.Body != "/list" {
+		t.Errorf("decodeEnvelope(withExtra) = %+v, %v", got, err)
+	}
+
+	for _, bad := range [][]byte{
+		{fieldCommand<<3 | wireBytes, 9, 1},
+		{fieldCommand<<3 | 3},
+		{0x80},
+	} {
+		if _, err := decodeEnvelope(bad); err != errInvalidProtobuf {
+			t.Errorf("decodeEnvelope(%v) error = %v, want %v", bad, err, errInvalidProtobuf)
+		}
+	}
+}
+
+func TestProtobufCodecDecode(t *testing.T) {
+	t.Parallel()
+	frame := func(envelope []byte) []byte {
+		return append(binary.AppendUvarint(nil, uint64(len(envelope))), envelope...)
+	}
+	var input bytes.Buffer
+	input.Write(frame(encodeEnvelope(wireMessage{Type: "chat", Body: "one"})))
+	input.Write(frame(make([]byte, maxFrameBytes+1)))
+	input.Write(frame([]byte{0xff}))
+	input.Write(frame(encodeEnvelope(wireMessage{Type: "private", To: "bob", Body: "two"})))
+
+	// One byte at a time, as frames arrive split across reads
+	codec := newProtobufCodec(bufio.NewReader(iotest.OneByteReader(&input)))
+	tests := []struct {
+		want    wireMessage
+		wantErr error
+	}{
+		{wireMessage{Type: "chat", Body: "one"}, nil},
+		{wireMessage{}, badMessage{errFrameTooLong}},
+		{wireMessage{}, badMessage{errInvalidProtobuf}},
+		{wireMessage{Type: "private", To: "bob", Body: "two"}, nil},
+	}
+	for i, tt := range tests {
+		got, err := codec.decode()
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("Message %d: decode() = %+v, %v, want %+v, %v", i, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
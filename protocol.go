@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	protocolName    = "CHAT"
+	protocolVersion = "1.0"
+)
+
+// Permission levels required by commands.
+const (
+	permUser      = "user"
+	permModerator = "moderator"
+	permOperator  = "operator"
+	permBot       = "operator or bot" // Operators and registered bots
+)
+
+// protocolSpec is the machine-readable description of the wire protocol
+// served to client authors. It is built from the tables below, which the
+// conformance tests check against the command dispatcher.
+type protocolSpec struct {
+	Name         string        `json:"name"`
+	Version      string        `json:"version"`
+	Handshake    string        `json:"handshake"`
+	Versions     []string      `json:"versions"`
+	Encodings    []string      `json:"encodings"`
+	Framing      string        `json:"framing"`
+	MaxMessage   int           `json:"max_message"`
+	Commands     []commandSpec `json:"commands"`
+	Events       []eventSpec   `json:"events"`
+	Errors       []errorSpec   `json:"errors"`
+	Capabilities []string      `json:"capabilities"`
+}
+
+// commandSpec describes a slash command accepted from clients.
+type commandSpec struct {
+	Name        string `json:"name"`
+	Syntax      string `json:"syntax"`
+	Permission  string `json:"permission"`
+	Description string `json:"description"`
+}
+
+// eventSpec describes a line the server sends without being asked.
+type eventSpec struct {
+	Name        string `json:"name"`
+	Format      string `json:"format"`
+	Description string `json:"description"`
+}
+
+// errorSpec describes an error reply.
+type errorSpec struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var eventSpecs = []eventSpec{
+	{"version", protocolName + "/<version> SUPPORTED <version>,...", "Version of the session, sent first to clients that offered versions"},
+	{"motd", "Message of the day:", "Sent after the welcome when the server has a message of the day and in reply to /motd, followed by its lines"},
+	{"chat", "[<YYYY-MM-DD HH:MM:SS>] <sender>: <message>", "Chat message in the current room, stamped in UTC with the time it was posted, also when replayed"},
+	{"action", "[<YYYY-MM-DD HH:MM:SS>] * <sender> <action>", "Action posted with /me in the current room, stamped like chat messages"},
+	{"private", "[PM from <sender>]: <message>", "Private message"},
+	{"private_echo", "[PM to <recipient>]: <message>", "Confirmation of a sent private message"},
+	{"system", systemSender + ": <notice>", "Server notice such as joins and leaves"},
+	{"group_mention", "[@<group>] <sender> in <room>: <message>", "Mention of a group the user belongs to"},
+	{"reaction", systemSender + ": <user> reacted <reaction> to <author>: <excerpt>", "Reaction to a message in the current room"},
+	{"quit", systemSender + ": <user> has left: <message>", "A user left with /quit and a farewell message"},
+	{"goodbye", "Goodbye!", "Reply to /quit, after which the server closes the connection"},
+	{"nick", systemSender + ": <old> is now known as <new>", "A user changed their name"},
+	{"digest", "[PM from <room>]: <n> new messages in the last <interval>, latest:", "Digest of a watched room, followed by the latest messages indented"},
+	{"presence", presenceTag + "<user> is online|went offline|is away: <message>|is back|turned on do not disturb|is available again|is now known as <name>", "Presence change of a user you watch"},
+	{"report", "[REPORT] <reporter> reported <user> in <room>: <reason>", "Report delivered to moderators, followed by the user's recent messages indented"},
+	{"poll", systemSender + ": Poll by <user>: <question> 1) <option> 2) <option>..., /vote <n> within <n> minutes", "A poll was opened in the current room"},
+	{"poll_results", systemSender + ": Poll closed: <question> 1) <option>: <votes>, ... (<n> vote(s))", "A poll in the current room was closed or timed out"},
+	{"fun", systemSender + ": <user> rolled <N>d<M>: <rolls> = <total>|flipped a coin: heads|tails|asked the magic 8-ball \"<question>\": <answer>", "Outcome of /roll, /flip or /8ball in the current room"},
+	{"reminder", systemSender + ": Reminder: <text>|Reminder from <user>: <text>", "A reminder set with /remind is due, for you alone or for the room"},
+	{"scheduled", systemSender + ": <text>", "Server notice broadcast on a schedule set by the operators, to everyone or to the current room"},
+	{"pins", "Pinned in <room>:", "Sent after joining a room with pinned messages and in reply to /pins, followed by them indented as <id> [<YYYY-MM-DD HH:MM:SS>] <line> (pinned by <user>)"},
+	{"pin", systemSender + ": <user> pinned|unpinned: <line>", "A message of the current room was pinned or unpinned"},
+	{"maintenance", systemSender + ": Server maintenance in <n> minutes: <reason>", "Countdown warning before scheduled maintenance"},
+	{"cap_ls", "CAP LS <cap> ...", "Capabilities offered, sent after the version reply and in reply to /cap"},
+	{"cap_ack", "CAP ACK <cap> ...", "The requested capability changes were applied"},
+	{"cap_nak", "CAP NAK <cap> ...", "The request named an unknown capability and changed nothing"},
+	{"typing", "TYPING <user>", "Someone in the current room is typing, with the typing capability"},
+	{"replay_start", "REPLAY <room> <n>", "The next <n> lines are the history of <room>, with the replay capability"},
+	{"replay_end", "REPLAY END <room>", "End of the history of <room>"},
+	{"replay_pms", "REPLAY PM <n>", "The next <n> lines are private messages that arrived while a resumed session was away, as [<YYYY-MM-DD HH:MM:SS>] [PM from <sender>]: <message>, up to REPLAY END PM"},
+	{"pm_status", "PM DELIVERED|QUEUED|FAILED <id> <user>", "What became of a sent private message, with the acks capability"},
+	{"pm_away", "<user> is away: <message>", "The recipient of a delivered private message is marked as away"},
+	{"pm_queued", "<user> could not be reached; your message will be delivered when they are back", "A private message waits for the recipient's next login"},
+	{"pm_offline", "<user> is offline; your message will be delivered when they log in", "A private message to an offline registered user is kept for them"},
+	{"offline_messages", "While you were away you received <n> private message(s):", "Sent at login, followed by the waiting messages as [<time>] [PM from <sender>]: <message>"},
+	{"message_id", idTag + "<id> <line>", "A broadcast message or history entry and its server-wide ID, with the ids capability"},
+	{"mention", mentionTag + "[<YYYY-MM-DD HH:MM:SS>] <sender>: <message>", "A chat message, live or replayed, that mentions you as @name, with the mentions capability"},
+	{"ping", pingLine + " <token>", "Heartbeat at the configured interval, with the ping capability; answer " + pongLine + " <token>"},
+	{"pong", pongLine + " <token>", "Answer to a " + pingLine + " <token> line sent by the client at any time"},
+	{"session", "SESSION <token>", "Token for resuming the session after a reconnect, with the resume capability"},
+	{"session_expired", "Session expired, sending the full history", "The session named in the handshake cannot be resumed"},
+	{"compression", "COMPRESSION <method>", "Answer to " + compressToken + " in the handshake and the last uncompressed line; none when no offered method is supported"},
+	{"user_list", "Connected users: <name>, ...", "Reply to /list"},
+}
+
+var errorSpecs = []errorSpec{
+	{"invalid_protocol", "Invalid protocol. Please use TCP chat client."},
+	{"no_common_version", errNoCommonVersion.Error() + ". Server supports: <version>,..."},
+	{"unsupported_encoding", errUnsupportedEncoding.Error() + ". Server supports: <encoding>,..."},
+	{"invalid_json", errInvalidJSON.Error()},
+	{"invalid_protobuf", errInvalidProtobuf.Error()},
+	{"frame_too_long", errFrameTooLong.Error()},
+	{"multiline_body", errMultilineBody.Error()},
+	{"invalid_utf8", errInvalidUTF8.Error()},
+	{"message_type", errMessageType.Error()},
+	{"server_full", errServerFull.Error()},
+	{"server_busy", errServerBusy.Error()},
+	{"too_many_connections", errTooManyFromIP.Error()},
+	{"banned", "You are banned from this server."},
+	{"guests_disabled", errGuestsDisabled.Error()},
+	{"guests_full", errGuestsFull.Error()},
+	{"guest_room", errGuestRoom.Error()},
+	{"name_empty", errEmptyName.Error()},
+	{"name_reserved", errReservedName.Error()},
+	{"name_too_long", errNameTooLong.Error()},
+	{"name_chars", errNameChars.Error()},
+	{"name_bot_reserved", errBotNameReserved.Error()},
+	{"bot_token", errBotToken.Error()},
+	{"name_taken", errNameTaken.Error() + ". Please choose a different name."},
+	{"name_suggestion", errNameTaken.Error() + ". Press Enter to use <name>_<n> or type another name: "},
+	{"permission_denied", "Permission denied: <level> only command"},
+	{"user_not_found", "User <name> not found"},
+	{"inbox_full", "<user> is offline and has too many messages waiting, please try again later"},
+	{"do_not_disturb", "<user> is not taking private messages right now (do not disturb), please try again later"},
+	{"line_too_long", errLineTooLong.Error() + " (max <n> bytes)"},
+	{"message_too_long", "Message too long (max 1024 characters)"},
+	{"muted", "You are muted and cannot send messages"},
+	{"unknown_room", errUnknownRoom.Error()},
+	{"profanity", errProfanity.Error()},
+	{"hook_rejected", errHookRejected.Error()},
+	{"flood", "Slow down: at most <n> messages per <seconds> seconds, message dropped"},
+	{codeLinksBlocked, "[" + codeLinksBlocked + "] <explanation>"},
+	{codeLinkDomain, "[" + codeLinkDomain + "] Links to <host> are not allowed in <room>"},
+	{"repeated_message", errRepeatedMessage.Error()},
+	{"reactions_off", errReactionsOff.Error()},
+	{"reaction_invalid", errReactionInvalid.Error()},
+	{"reaction_not_allowed", "<room> only allows these reactions: <reaction> ..."},
+	{"react_no_target", errNoReactTarget.Error()},
+	{"flood_muted", "You are muted for <seconds> seconds for flooding"},
+}
+
+// currentProtocolSpec assembles the protocol description.
+func currentProtocolSpec() protocolSpec {
+	return protocolSpec{
+		Name:         protocolName,
+		Version:      protocolVersion,
+		Handshake:    protocolName + "/" + protocolVersion,
+		Versions:     supportedVersions,
+		Encodings:    encodings,
+		Framing:      "newline-delimited UTF-8 lines",
+		MaxMessage:   1024,
+		Commands:     commandSpecs(),
+		Events:       eventSpecs,
+		Errors:       errorSpecs,
+		Capabilities: capabilityNames(),
+	}
+}
+
+// writeProtocolSpec renders the protocol description as indented JSON.
+func writeProtocolSpec(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(currentProtocolSpec())
+}
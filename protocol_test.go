@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProtocolConformance checks the served protocol description against
+// the command dispatcher so the spec cannot drift from the server.
+func TestProtocolConformance(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	if err := writeProtocolSpec(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var spec protocolSpec
+	if err := json.Unmarshal(buf.Bytes(), &spec); err != nil {
+		t.Fatalf("Protocol spec is not valid JSON: %v", err)
+	}
+
+	if spec.Handshake != "CHAT/1.0" {
+		t.Errorf("Expected CHAT/1.0 handshake, got %q", spec.Handshake)
+	}
+	for _, cmd := range spec.Commands {
+		cmd := cmd
+		t.Run(cmd.Name, func(t *testing.T) {
+			t.Parallel()
+			if !strings.HasPrefix(cmd.Syntax, cmd.Name) {
+				t.Errorf("Syntax %q does not start with %s", cmd.Syntax, cmd.Name)
+			}
+			switch cmd.Permission {
+			case permUser, permModerator, permOperator, permBot:
+			default:
+				t.Errorf("Unknown permission %q", cmd.Permission)
+			}
+
+			// A bare command must be recognised and never broadcast as chat
+			c := &client{conn: newMockConn(), name: "conformance", room: defaultRoom}
+			if !handleCommand(c, cmd.Name, time.Now()) {
+				t.Errorf("%s is in the spec but not handled by the server", cmd.Name)
+			}
+		})
+	}
+}
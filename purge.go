@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// purgeReport counts what a purge removed.
+type purgeReport struct {
+	messages      int // Chat lines removed from room histories and the event log
+	conversations int // Private conversations dropped
+	groups        int // Groups the user was removed from
+	notices       int // Queued mention notices sent by the user
+	offline       int // Private messages waiting for the user or sent by them to offline users
+}
+
+// purgeRoomMessages removes the chat and action lines of name from every room history
+// and pins, and forgets the rooms' records of greeting them. Rooms they own lose
+// their owner.
+func purgeRoomMessages(name string) int {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	removed := 0
+	for _, r := range rooms {
+		kept, keptIDs, keptTimes := r.history[:0], r.ids[:0], r.times[:0]
+		for i, msg := range r.history {
+			if writtenBy(msg, name) {
+				removed++
+				continue
+			}
+			kept = append(kept, msg)
+			if len(r.ids) == len(r.history) {
+				keptIDs = append(keptIDs, r.ids[i])
+			}
+			if len(r.times) == len(r.history) {
+				keptTimes = append(keptTimes, r.times[i])
+			}
+		}
+		r.history, r.ids, r.times = kept, keptIDs, keptTimes
+		keptPins := r.pins[:0]
+		for _, pinned := range r.pins {
+			if !writtenBy(pinned.line, name) {
+				keptPins = append(keptPins, pinned)
+			}
+		}
+		r.pins = keptPins
+		delete(r.greeted, strings.ToLower(name))
+		if r.owner == name {
+			r.owner = ""
+		}
+	}
+	return removed
+}
+
+// purgePrivateMessages drops every logged conversation of name along with
+// their export preference.
+func purgePrivateMessages(name string) int {
+	removed := forgetConversations(name)
+	setExportAllowed(name, true)
+	setHistoryAllowed(name, true)
+	return removed
+}
+
+// purge removes name from every group, drops the notices queued for them
+// and the ones they caused for others, and saves the store. It returns the
+// number of groups left and notices removed.
+func (g *groupStore) purge(name string) (int, int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	left := 0
+	for group, members := range g.Groups {
+		for i, m := range members {
+			if m == name {
+				g.Groups[group] = append(members[:i], members[i+1:]...)
+				left++
+				break
+			}
+		}
+	}
+	delete(g.Pending, name)
+
+	removed := 0
+	for user, pending := range g.Pending {
+		kept := pending[:0]
+		for _, notice := range pending {
+			// Notices read "[@group] <sender> in <room>: <message>"
+			if _, rest, ok := strings.Cut(notice, "] "); ok && strings.HasPrefix(rest, name+" in ") {
+				removed++
+				continue
+			}
+			kept = append(kept, notice)
+		}
+		if len(kept) == 0 {
+			delete(g.Pending, user)
+		} else {
+			g.Pending[user] = kept
+		}
+	}
+	return left, removed, g.save()
+}
+
+// purgeUser erases the data kept about name: their chat lines, private
+// conversations, group memberships, queued notices and messages, and
+// last-seen record. Bans and mutes stay in place since they protect the
+// server rather than describe the user.
+func purgeUser(name string) (purgeReport, error) {
+	report := purgeReport{
+		messages:      purgeRoomMessages(name),
+		conversations: purgePrivateMessages(name),
+	}
+	var errs []error
+	logged, err := events.purgeMessages(name)
+	report.messages += logged
+	if err != nil {
+		errs = append(errs, fmt.Errorf("purging event log: %w", err))
+	}
+	report.groups, report.notices, err = groups.purge(name)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("saving groups: %w", err))
+	}
+	report.offline, err = offline.purge(name)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("saving offline messages: %w", err))
+	}
+	if err := seen.forget(name); err != nil {
+		errs = append(errs, fmt.Errorf("saving last-seen records: %w", err))
+	}
+	return report, errors.Join(errs...)
+}
+
+// handlePurgeCommand processes the operator command "/purge <user>", which
+// serves deletion requests.
+func handlePurgeCommand(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send("Usage: /purge <user>")
+		return
+	}
+
+	name := call.args[0]
+	report, err := purgeUser(name)
+	if err != nil {
+		log.Printf("Error purging %s: %v", name, err)
+	}
+	audit.record(auditEntry{Action: "purge", Actor: c.name, Target: name})
+	log.Printf("%s purged the data of %s: %d messages, %d conversations, %d groups, %d notices, %d offline messages",
+		c.name, name, report.messages, report.conversations, report.groups, report.notices, report.offline)
+	loc := c.locale()
+	c.send(fmt.Sprintf("Purged %s: %s messages, %s private conversations, removed from %s groups, %s queued notices, %s offline messages",
+		name, loc.number(report.messages), loc.number(report.conversations), loc.number(report.groups), loc.number(report.notices), loc.number(report.offline)))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurgeUser(t *testing.T) {
+	defer func(saved *groupStore) { groups = saved }(groups)
+	groups = newGroupStore("")
+	groups.create("ops")
+	groups.addMember("ops", "mallory")
+	groups.addMember("ops", "bob")
+	groups.queue("mallory", "[@ops] bob in #general: hi")
+	groups.queue("bob", "[@ops] mallory in #general: ping")
+	groups.queue("bob", "[@ops] carol in #general: pong")
+
+	r, err := createRoom("#purge-test", "", "mallory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mutex.Lock()
+		delete(rooms, r.name)
+		mutex.Unlock()
+	}()
+	mutex.Lock()
+	for _, msg := range []string{"mallory: secret", "bob: reply", "mallory: more", "mallory2: other user"} {
+		r.addToHistory(msg)
+	}
+	mutex.Unlock()
+
+	storePrivateMessage(pmEntry{From: "mallory", To: "bob", Text: "psst", Time: time.Now()})
+	storePrivateMessage(pmEntry{From: "Bob", To: "carol", Text: "hello", Time: time.Now()})
+	setExportAllowed("mallory", false)
+
+	report, err := purgeUser("mallory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := purgeReport{messages: 2, conversations: 1, groups: 1, notices: 1}
+	if report != want {
+		t.Errorf("Expected report %+v, got %+v", want, report)
+	}
+
+	if got := roomHistory(r.name); !reflect.DeepEqual(got, []string{"bob: reply", "mallory2: other user"}) {
+		t.Errorf("Unexpected history after purge: %q", got)
+	}
+	if r.owner != "" {
+		t.Errorf("Expected the room to lose its owner, got %q", r.owner)
+	}
+	if len(conversation("mallory", "bob")) != 0 || len(conversation("bob", "carol")) != 1 {
+		t.Error("Expected only the conversations of mallory to be dropped")
+	}
+	if exportRefused("mallory") {
+		t.Error("Expected the export preference to be dropped")
+	}
+	if members, _ := groups.members("ops"); !reflect.DeepEqual(members, []string{"bob"}) {
+		t.Errorf("Expected mallory to leave @ops, got %q", members)
+	}
+	if pending := groups.takePending("bob"); !reflect.DeepEqual(pending, []string{"[@ops] carol in #general: pong"}) {
+		t.Errorf("Expected only notices by others to stay queued, got %q", pending)
+	}
+	if pending := groups.takePending("mallory"); pending != nil {
+		t.Errorf("Expected the notices for mallory to be dropped, got %q", pending)
+	}
+}
+
+func TestHandlePurgeCommand(t *testing.T) {
+	conn := newMockConn()
+	user := &client{conn: conn, name: "user"}
+	handleCommand(user, "/purge someone", time.Now())
+	if got := conn.writeBuffer.String(); !strings.Contains(got, "Permission denied") {
+		t.Errorf("Expected users to be refused, got %q", got)
+	}
+
+	conn.writeBuffer.Reset()
+	oper := &client{conn: conn, name: "oper", operator: true}
+	handleCommand(oper, "/purge nobody-here", time.Now())
+	if got := conn.writeBuffer.String(); !strings.Contains(got, "Purged nobody-here: 0 messages") {
+		t.Errorf("Expected a purge report, got %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// maxQuitMessage is the longest farewell /quit accepts, in bytes.
+const maxQuitMessage = 200
+
+// quitCommand processes "/quit [message]": the server says goodbye and
+// closes the connection, and the others see the message in the leave
+// notice. A session ended this way cannot be resumed.
+func quitCommand(c *client, call commandCall) {
+	message := call.text
+	if len(message) > maxQuitMessage {
+		c.send(fmt.Sprintf("Quit message too long (max %d characters)", maxQuitMessage))
+		return
+	}
+	mutex.Lock()
+	c.quit = true
+	c.quitMessage = message
+	mutex.Unlock()
+	log.Printf("%s quit", c.name)
+	c.send("Goodbye!")
+	c.conn.Close()
+}
+
+// leaveNotice is the notice broadcast when c disconnects. The farewell of
+// /quit is left out for muted and shadow-banned users, who could
+// otherwise still speak through it.
+func leaveNotice(c *client) string {
+	mutex.Lock()
+	message := c.quitMessage
+	mutex.Unlock()
+	if message == "" || isMuted(c) || isShadowBanned(c) {
+		return formatSystemMessage(fmt.Sprintf("%s has left our chat...", c.name))
+	}
+	return formatSystemMessage(fmt.Sprintf("%s has left: %s", c.name, message))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuitCommand(t *testing.T) {
+	t.Run("farewell", func(t *testing.T) {
+		conn := newMockConn()
+		c := &client{conn: conn, name: "quit-alice", room: defaultRoom}
+		handleCommand(c, "/quit  see you tomorrow ", time.Now())
+		if got := conn.writeBuffer.String(); got != "Goodbye!\n" {
+			t.Errorf("Expected a goodbye, got %q", got)
+		}
+		if !conn.closed {
+			t.Error("Expected the connection to be closed")
+		}
+		if !c.quit {
+			t.Error("Expected the client to be marked as quit")
+		}
+		if got := leaveNotice(c); got != "SERVER: quit-alice has left: see you tomorrow" {
+			t.Errorf("Expected the farewell in the leave notice, got %q", got)
+		}
+	})
+
+	t.Run("without a message", func(t *testing.T) {
+		conn := newMockConn()
+		c := &client{conn: conn, name: "quit-bob", room: defaultRoom}
+		handleCommand(c, "/quit", time.Now())
+		if !conn.closed || !c.quit {
+			t.Error("Expected /quit alone to disconnect")
+		}
+		if got := leaveNotice(c); got != "SERVER: quit-bob has left our chat..." {
+			t.Errorf("Expected the usual leave notice, got %q", got)
+		}
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		conn := newMockConn()
+		c := &client{conn: conn, name: "quit-carol", room: defaultRoom}
+		handleCommand(c, "/quit "+strings.Repeat("x", maxQuitMessage+1), time.Now())
+		if conn.closed || c.quit {
+			t.Error("Expected an overlong farewell to keep the client connected")
+		}
+		if got := conn.writeBuffer.String(); !strings.HasPrefix(got, "Quit message too long") {
+			t.Errorf("Expected a length error, got %q", got)
+		}
+	})
+
+	t.Run("muted", func(t *testing.T) {
+		c := &client{conn: newMockConn(), name: "quit-dave", room: defaultRoom}
+		muteClient(c, "test", 0)
+		defer unmuteClient(c)
+		handleCommand(c, "/quit buy cheap watches", time.Now())
+		if got := leaveNotice(c); got != "SERVER: quit-dave has left our chat..." {
+			t.Errorf("Expected muted users' farewells to be dropped, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	maxReaction        = 32 // Longest reaction in bytes
+	reactionExcerptLen = 40 // Characters of the target message quoted in the notice
+)
+
+var (
+	errReactionsOff    = errors.New("Reactions are disabled in this room")
+	errReactionInvalid = errors.New("A reaction is a single emoji or :shortcode:")
+	errNoReactTarget   = errors.New("No recent message from that user in this room")
+)
+
+// ReactionPolicy restricts the reactions used in a room. The zero value
+// allows any reaction.
+type ReactionPolicy struct {
+	Off   bool     `json:"off"`   // Reactions are disabled
+	Allow []string `json:"allow"` // Only these reactions may be used, empty allows any
+}
+
+// permits returns why reaction may not be used under the policy, if so.
+func (p ReactionPolicy) permits(room, reaction string) error {
+	if p.Off {
+		return errReactionsOff
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Allow {
+		if strings.EqualFold(allowed, reaction) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s only allows these reactions: %s", room, strings.Join(p.Allow, " "))
+}
+
+// describe summarizes the policy for /reactions.
+func (p ReactionPolicy) describe() string {
+	switch {
+	case p.Off:
+		return "off"
+	case len(p.Allow) > 0:
+		return "allowed: " + strings.Join(p.Allow, " ")
+	}
+	return "any"
+}
+
+// validReaction reports whether reaction is one short token.
+func validReaction(reaction string) bool {
+	return reaction != "" && len(reaction) <= maxReaction && strings.IndexFunc(reaction, func(r rune) bool { return !validNameRune(r) }) < 0
+}
+
+// lastMessageFrom returns the most recent chat line of sender in history.
+func lastMessageFrom(history []string, sender string) (string, bool) {
+	prefix := sender + ": "
+	for i := len(history) - 1; i >= 0; i-- {
+		if strings.HasPrefix(history[i], prefix) {
+			return strings.TrimPrefix(history[i], prefix), true
+		}
+	}
+	return "", false
+}
+
+// excerpt shortens text to at most n characters for quoting.
+func excerpt(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// handleReactionCommand processes "/react <user> <reaction>", which reacts
+// to the user's latest message in the current room, and "/reactions
+// [off|any|allow <reaction>...]", which shows the room's reaction policy
+// and lets the room owner and operators change it.
+func handleReactionCommand(c *client, call commandCall) {
+	mutex.Lock()
+	r, ok := rooms[c.room]
+	mutex.Unlock()
+	if !ok {
+		c.send(errUnknownRoom.Error())
+		return
+	}
+
+	if call.name == "/reactions" {
+		setReactionPolicy(c, r, call.args)
+		return
+	}
+
+	if len(call.args) != 2 {
+		c.send("Usage: /react <user> <reaction>")
+		return
+	}
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+	target, reaction := call.args[0], call.args[1]
+	if !validReaction(reaction) {
+		c.send(errReactionInvalid.Error())
+		return
+	}
+
+	mutex.Lock()
+	policy := r.settings.Reactions
+	text, found := lastMessageFrom(r.history, target)
+	mutex.Unlock()
+	if err := policy.permits(r.name, reaction); err != nil {
+		c.send(err.Error())
+		return
+	}
+	if !found {
+		c.send(errNoReactTarget.Error())
+		return
+	}
+
+	notice := formatSystemMessage(fmt.Sprintf("%s reacted %s to %s: %s", c.name, reaction, target, excerpt(text, reactionExcerptLen)))
+	// Shadow-banned users only see their own reactions
+	if isShadowBanned(c) {
+		c.send(notice)
+		return
+	}
+	for _, member := range roomMembers(r.name, nil) {
+		member.send(notice)
+	}
+}
+
+// setReactionPolicy shows or, given args, changes the reaction policy of r.
+func setReactionPolicy(c *client, r *room, args []string) {
+	mutex.Lock()
+	policy, owner := r.settings.Reactions, r.owner
+	mutex.Unlock()
+	if len(args) == 0 {
+		c.send(fmt.Sprintf("Reactions in %s: %s", r.name, policy.describe()))
+		return
+	}
+	if !c.operator && (owner == "" || owner != c.name) {
+		c.send("Permission denied: only the room owner or an operator can change reactions")
+		return
+	}
+
+	policy = ReactionPolicy{}
+	switch {
+	case args[0] == "off" && len(args) == 1:
+		policy.Off = true
+	case args[0] == "any" && len(args) == 1:
+	case args[0] == "allow" && len(args) > 1:
+		for _, reaction := range args[1:] {
+			if !validReaction(reaction) {
+				c.send(errReactionInvalid.Error())
+				return
+			}
+		}
+		policy.Allow = args[1:]
+	default:
+		c.send("Usage: /reactions [off|any|allow <reaction>...]")
+		return
+	}
+
+	mutex.Lock()
+	r.settings.Reactions = policy
+	mutex.Unlock()
+	log.Printf("%s set the reactions of %s: %s", c.name, r.name, policy.describe())
+	c.send(fmt.Sprintf("Reactions in %s: %s", r.name, policy.describe()))
+}
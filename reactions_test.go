@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReactionPolicyPermits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		policy   ReactionPolicy
+		reaction string
+		allowed  bool
+	}{
+		{"Any", ReactionPolicy{}, "🎉", true},
+		{"Off", ReactionPolicy{Off: true}, "👍", false},
+		{"Allowed", ReactionPolicy{Allow: []string{"👍", ":check:"}}, "👍", true},
+		{"Allowed shortcode ignores case", ReactionPolicy{Allow: []string{":check:"}}, ":CHECK:", true},
+		{"Not allowed", ReactionPolicy{Allow: []string{"👍"}}, "🎉", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.policy.permits("#room", tt.reaction); (err == nil) != tt.allowed {
+				t.Errorf("Expected allowed=%v for %q, got %v", tt.allowed, tt.reaction, err)
+			}
+		})
+	}
+}
+
+func TestValidReaction(t *testing.T) {
+	t.Parallel()
+	for _, reaction := range []string{"👍", ":+1:", "+1"} {
+		if !validReaction(reaction) {
+			t.Errorf("Expected %q to be valid", reaction)
+		}
+	}
+	for _, reaction := range []string{"", "two words", strings.Repeat("x", maxReaction+1), "a/b"} {
+		if validReaction(reaction) {
+			t.Errorf("Expected %q to be invalid", reaction)
+		}
+	}
+}
+
+func TestHandleReactionCommand(t *testing.T) {
+	r, err := createRoom("#react-test", "", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	r.addToHistory("bob: first")
+	r.addToHistory("alice: hi")
+	r.addToHistory("bob: ship it")
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, r.name)
+		mutex.Unlock()
+	}()
+
+	userConn, ownerConn := newMockConn(), newMockConn()
+	user := &client{conn: userConn, name: "user", room: r.name}
+	owner := &client{conn: ownerConn, name: "owner", room: r.name}
+	mutex.Lock()
+	clients[userConn], clients[ownerConn] = user, owner
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, userConn)
+		delete(clients, ownerConn)
+		mutex.Unlock()
+	}()
+
+	t.Run("Reacts to latest message", func(t *testing.T) {
+		handleReactionCommand(user, callOf("/react bob 👍"))
+		want := "SERVER: user reacted 👍 to bob: ship it"
+		for _, conn := range []*mockConn{userConn, ownerConn} {
+			if got := conn.writeBuffer.String(); !strings.Contains(got, want) {
+				t.Errorf("Expected %q to be delivered, got %q", want, got)
+			}
+		}
+	})
+
+	t.Run("Unknown target", func(t *testing.T) {
+		userConn.writeBuffer.Reset()
+		handleReactionCommand(user, callOf("/react carol 👍"))
+		if got := userConn.writeBuffer.String(); !strings.Contains(got, errNoReactTarget.Error()) {
+			t.Errorf("Expected %q, got %q", errNoReactTarget, got)
+		}
+	})
+
+	t.Run("Muted", func(t *testing.T) {
+		muteClient(user, "test", 0)
+		defer unmuteClient(user)
+		userConn.writeBuffer.Reset()
+		ownerConn.writeBuffer.Reset()
+		handleReactionCommand(user, callOf("/react bob 👍"))
+		if got := userConn.writeBuffer.String(); got != "You are muted and cannot send messages\n" {
+			t.Errorf("Expected muted users to be refused, got %q", got)
+		}
+		if got := ownerConn.writeBuffer.String(); got != "" {
+			t.Errorf("Expected nothing sent to the room, got %q", got)
+		}
+	})
+
+	t.Run("Only owner changes policy", func(t *testing.T) {
+		userConn.writeBuffer.Reset()
+		handleReactionCommand(user, callOf("/reactions off"))
+		if got := userConn.writeBuffer.String(); !strings.Contains(got, "Permission denied") {
+			t.Errorf("Expected users to be refused, got %q", got)
+		}
+		handleReactionCommand(owner, callOf("/reactions allow 👍 ✅"))
+		userConn.writeBuffer.Reset()
+		handleReactionCommand(user, callOf("/react bob 🎉"))
+		if got := userConn.writeBuffer.String(); !strings.Contains(got, "only allows these reactions: 👍 ✅") {
+			t.Errorf("Expected the allowlist to apply, got %q", got)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		handleReactionCommand(owner, callOf("/reactions off"))
+		userConn.writeBuffer.Reset()
+		handleReactionCommand(user, callOf("/react bob 👍"))
+		if got := userConn.writeBuffer.String(); !strings.Contains(got, errReactionsOff.Error()) {
+			t.Errorf("Expected %q, got %q", errReactionsOff, got)
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxReminders    = 10                 // Reminders a client may have pending at once
+	maxReminderWait = 7 * 24 * time.Hour // Longest time ahead a reminder may be set
+)
+
+// reminder is a message the server sends back at a later time.
+type reminder struct {
+	id   uint64
+	room string // Room to post to, empty for a personal reminder
+	text string
+	due  time.Time
+}
+
+func (r *reminder) jobKey() string {
+	return "remind:" + strconv.FormatUint(r.id, 10)
+}
+
+// reminders holds the pending reminders of each client in the order they
+// are due. They last for the session of the client that set them.
+var reminders = struct {
+	sync.Mutex
+	next    uint64
+	pending map[*client][]*reminder
+}{pending: make(map[*client][]*reminder)}
+
+// addReminder schedules r for c. It reports false when c has too many
+// reminders pending.
+func addReminder(c *client, r *reminder) bool {
+	reminders.Lock()
+	list := reminders.pending[c]
+	if len(list) >= maxReminders {
+		reminders.Unlock()
+		return false
+	}
+	reminders.next++
+	r.id = reminders.next
+	i := len(list)
+	for i > 0 && list[i-1].due.After(r.due) {
+		i--
+	}
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = r
+	reminders.pending[c] = list
+	reminders.Unlock()
+
+	jobs.schedule(r.jobKey(), r.due, func() { fireReminder(c, r) })
+	return true
+}
+
+// takeReminder removes r from the reminders of c and reports whether it
+// was still pending.
+func takeReminder(c *client, r *reminder) bool {
+	reminders.Lock()
+	defer reminders.Unlock()
+
+	list := reminders.pending[c]
+	for i, pending := range list {
+		if pending == r {
+			list = append(list[:i], list[i+1:]...)
+			if len(list) == 0 {
+				delete(reminders.pending, c)
+			} else {
+				reminders.pending[c] = list
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// fireReminder delivers r, set by c, when it is due.
+func fireReminder(c *client, r *reminder) {
+	if !takeReminder(c, r) {
+		return
+	}
+	if r.room == "" {
+		c.send(formatSystemMessage("Reminder: " + r.text))
+		return
+	}
+	line := formatSystemMessage(fmt.Sprintf("Reminder from %s: %s", c.currentName(), r.text))
+	// Bans and mutes since the reminder was set apply as well
+	if isShadowBanned(c) || isMuted(c) {
+		c.send(line)
+		return
+	}
+	broadcastToRoom(r.room, line, nil)
+}
+
+// forgetReminders cancels the reminders of a disconnected client.
+func forgetReminders(c *client) {
+	reminders.Lock()
+	list := reminders.pending[c]
+	delete(reminders.pending, c)
+	reminders.Unlock()
+
+	for _, r := range list {
+		jobs.cancel(r.jobKey())
+	}
+}
+
+// pendingReminders returns a copy of the reminders of c, soonest first.
+func pendingReminders(c *client) []*reminder {
+	reminders.Lock()
+	defer reminders.Unlock()
+	return append([]*reminder(nil), reminders.pending[c]...)
+}
+
+// handleRemindCommand processes "/remind [here] <duration> <text>", which
+// sends the text back to the client, or to its current room with "here",
+// once the duration has passed. "/remind" lists the pending reminders and
+// "/remind cancel <n>" drops one of them.
+func handleRemindCommand(c *client, call commandCall) {
+	usage := "Usage: /remind [here] <duration> <text> | cancel <n>"
+
+	if len(call.args) == 0 {
+		listReminders(c)
+		return
+	}
+	if call.args[0] == "cancel" {
+		list := pendingReminders(c)
+		n := 0
+		if len(call.args) == 2 {
+			n, _ = strconv.Atoi(call.args[1])
+		}
+		if n < 1 || n > len(list) {
+			c.send("Usage: /remind cancel <n>, with n from /remind")
+			return
+		}
+		r := list[n-1]
+		if takeReminder(c, r) {
+			jobs.cancel(r.jobKey())
+		}
+		c.send(fmt.Sprintf("Cancelled the reminder %q", r.text))
+		return
+	}
+
+	args := call.args
+	room := ""
+	if args[0] == "here" {
+		mutex.Lock()
+		room = c.room
+		mutex.Unlock()
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		c.send(usage)
+		return
+	}
+	wait, err := parseDuration(args[0])
+	if err != nil || wait > maxReminderWait {
+		c.send("Durations look like 90s, 10m, 2h or 1d, up to 7d")
+		return
+	}
+	// Keep the text as typed, spacing included
+	text := call.textAfter(len(call.args) - len(args) + 1)
+	if len(text) > 1024 {
+		c.send("Message too long (max 1024 characters)")
+		return
+	}
+	// Reminders for the room are posted on the client's behalf, so they
+	// pass the checks of chat messages when they are set
+	if room != "" {
+		if isMuted(c) {
+			c.send("You are muted and cannot send messages")
+			return
+		}
+		screened, ok := screenChatMessage(c, text, time.Now())
+		if !ok {
+			return
+		}
+		text = screened
+	}
+
+	r := &reminder{room: room, text: text, due: time.Now().Add(wait)}
+	if !addReminder(c, r) {
+		c.send(fmt.Sprintf("You can have at most %d reminders pending", maxReminders))
+		return
+	}
+	log.Printf("%s set a reminder in %s", c.name, wait)
+	if room != "" {
+		c.send(fmt.Sprintf("Reminder for %s set for %s from now", room, args[0]))
+	} else {
+		c.send(fmt.Sprintf("Reminder set for %s from now", args[0]))
+	}
+}
+
+// listReminders sends c its pending reminders, numbered for /remind cancel.
+func listReminders(c *client) {
+	list := pendingReminders(c)
+	if len(list) == 0 {
+		c.send("You have no reminders pending")
+		return
+	}
+	c.send("Reminders:")
+	for i, r := range list {
+		where := ""
+		if r.room != "" {
+			where = " in " + r.room
+		}
+		c.send(fmt.Sprintf("  %d) in %s%s: %s", i+1, time.Until(r.due).Round(time.Second), where, r.text))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemindCommand(t *testing.T) {
+	const name = "#remind-test"
+	ownerConn, memberConn := newMockConn(), newMockConn()
+	owner := &client{conn: ownerConn, name: "remind-owner", room: name}
+	member := &client{conn: memberConn, name: "remind-member", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[ownerConn], clients[memberConn] = owner, member
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, ownerConn)
+		delete(clients, memberConn)
+		mutex.Unlock()
+		forgetReminders(owner)
+	}()
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"/remind", "You have no reminders pending\n"},
+		{"/remind 10m", "Usage: /remind [here] <duration> <text> | cancel <n>\n"},
+		{"/remind soon stand up", "Durations look like 90s, 10m, 2h or 1d, up to 7d\n"},
+		{"/remind 8d stand up", "Durations look like 90s, 10m, 2h or 1d, up to 7d\n"},
+		{"/remind 2h stand  up", "Reminder set for 2h from now\n"},
+		{"/remind here 10m deploy window", "Reminder for #remind-test set for 10m from now\n"},
+		{"/remind 1d water plants", "Reminder set for 1d from now\n"},
+		{"/remind cancel 4", "Usage: /remind cancel <n>, with n from /remind\n"},
+		{"/remind cancel 3", "Cancelled the reminder \"water plants\"\n"},
+	}
+	for _, tt := range tests {
+		ownerConn.writeBuffer.Reset()
+		handleRemindCommand(owner, callOf(tt.message))
+		if got := ownerConn.writeBuffer.String(); got != tt.want {
+			t.Errorf("%q answered %q, want %q", tt.message, got, tt.want)
+		}
+	}
+
+	ownerConn.writeBuffer.Reset()
+	handleRemindCommand(owner, callOf("/remind"))
+	lines := strings.Split(ownerConn.writeBuffer.String(), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[1], " in #remind-test: deploy window") || !strings.HasSuffix(lines[2], ": stand  up") {
+		t.Errorf("Expected the reminders soonest first, got %q", lines)
+	}
+
+	list := pendingReminders(owner)
+	for _, r := range list {
+		if !jobs.pending(r.jobKey()) {
+			t.Errorf("Expected %q to be scheduled", r.text)
+		}
+	}
+	ownerConn.writeBuffer.Reset()
+	fireReminder(owner, list[0])
+	fireReminder(owner, list[1])
+	fireReminder(owner, list[1])
+	if got, want := memberConn.writeBuffer.String(), "SERVER: Reminder from remind-owner: deploy window\n"; got != want {
+		t.Errorf("Expected the room reminder, got %q, want %q", got, want)
+	}
+	if got, want := ownerConn.writeBuffer.String(), "SERVER: Reminder from remind-owner: deploy window\nSERVER: Reminder: stand  up\n"; got != want {
+		t.Errorf("Expected both reminders once, got %q, want %q", got, want)
+	}
+}
+
+func TestForgetReminders(t *testing.T) {
+	t.Parallel()
+	c := &client{conn: newMockConn(), name: "remind-gone"}
+	r := &reminder{text: "never", due: time.Now().Add(time.Hour)}
+	addReminder(c, r)
+	forgetReminders(c)
+	if jobs.pending(r.jobKey()) || len(pendingReminders(c)) != 0 {
+		t.Error("Expected the reminders of a disconnected client to be cancelled")
+	}
+}
+
+func TestRemindHereScreening(t *testing.T) {
+	const name = "#remind-screen"
+	ownerConn, memberConn := newMockConn(), newMockConn()
+	owner := &client{conn: ownerConn, name: "remind-screen-owner", room: name}
+	member := &client{conn: memberConn, name: "remind-screen-member", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[ownerConn], clients[memberConn] = owner, member
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, ownerConn)
+		delete(clients, memberConn)
+		mutex.Unlock()
+		forgetReminders(owner)
+	}()
+	filter, err := loadWordFilter(writeWordlist(t, "darn\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved *wordFilter) { profanity = saved }(profanity)
+	profanity = filter
+
+	muteClient(owner, "test", 0)
+	handleRemindCommand(owner, callOf("/remind here 10m hear me"))
+	if got := ownerConn.writeBuffer.String(); got != "You are muted and cannot send messages\n" || len(pendingReminders(owner)) != 0 {
+		t.Errorf("Expected a muted user's room reminder to be refused, got %q", got)
+	}
+	unmuteClient(owner)
+
+	handleRemindCommand(owner, callOf("/remind here 10m darn standup"))
+	list := pendingReminders(owner)
+	if len(list) != 1 || list[0].text != "**** standup" {
+		t.Fatalf("Expected the reminder text screened when set, got %v", list)
+	}
+
+	// A shadow ban since the reminder was set keeps it from the room
+	shadowBan(owner.name, "")
+	defer liftShadowBan(owner.name, "")
+	fireReminder(owner, list[0])
+	if got := memberConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected the reminder kept from the room, got %q", got)
+	}
+	if got := ownerConn.writeBuffer.String(); !strings.Contains(got, "Reminder from remind-screen-owner: **** standup") {
+		t.Errorf("Expected the owner to get the reminder, got %q", got)
+	}
+}
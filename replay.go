@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+// sendHistory replays the history of room to c. Clients with the replay
+// capability get it framed, so they can tell it apart from live messages
+// and count what they missed. A resuming client gets only the messages
+// after the last one it saw.
+func sendHistory(c *client, room string) {
+	history := roomHistoryEntries(room)
+	if c.resumeAfter != 0 {
+		history = historyAfter(history, c.resumeAfter)
+	}
+	history = skipIgnoredHistory(c, history)
+	framed := c.caps.has(capReplay)
+	if framed {
+		c.send(fmt.Sprintf("REPLAY %s %d", room, len(history)))
+	}
+	for _, entry := range history {
+		c.sendID(entry.id, c.markMention(stampMessage(entry.posted, entry.line)))
+	}
+	if framed {
+		c.send("REPLAY END " + room)
+	}
+}
+
+// historyAfter returns the entries of a room history with a message ID
+// above after. Entries without an ID are kept, as there is no telling
+// whether they were seen.
+func historyAfter(history []historyEntry, after uint64) []historyEntry {
+	var missed []historyEntry
+	for _, entry := range history {
+		if entry.id == 0 || entry.id > after {
+			missed = append(missed, entry)
+		}
+	}
+	return missed
+}
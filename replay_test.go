@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestSendHistory(t *testing.T) {
+	t.Parallel()
+	const name = "#replay-test"
+	mutex.Lock()
+	rooms[name] = &room{name: name, history: []string{"alice: one", "bob: two"}}
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		mutex.Unlock()
+	}()
+
+	plain := newMockConn()
+	sendHistory(&client{conn: plain, name: "old"}, name)
+	if got := plain.writeBuffer.String(); got != "alice: one\nbob: two\n" {
+		t.Errorf("Expected the bare history, got %q", got)
+	}
+
+	framed := newMockConn()
+	c := &client{conn: framed, name: "new"}
+	c.caps.set(capReplay, true)
+	sendHistory(c, name)
+	want := "REPLAY #replay-test 2\nalice: one\nbob: two\nREPLAY END #replay-test\n"
+	if got := framed.writeBuffer.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+// setReplyTo remembers name as the sender /r answers.
+func (c *client) setReplyTo(name string) {
+	mutex.Lock()
+	c.replyTo = name
+	mutex.Unlock()
+}
+
+// handleReplyCommand processes "/r <message>", which sends a private
+// message to the last user who sent the client one, as /msg would.
+func handleReplyCommand(c *client, call commandCall) {
+	mutex.Lock()
+	to := c.replyTo
+	mutex.Unlock()
+	if to == "" {
+		c.send("Nobody has sent you a private message yet")
+		return
+	}
+	sendPrivateMessage(c, to, call.text, call.received)
+}
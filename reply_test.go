@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplyCommand(t *testing.T) {
+	aliceConn, bobConn, carolConn := newMockConn(), newMockConn(), newMockConn()
+	alice := &client{conn: aliceConn, name: "reply-alice"}
+	bob := &client{conn: bobConn, name: "reply-bob"}
+	carol := &client{conn: carolConn, name: "reply-carol"}
+	mutex.Lock()
+	clients[aliceConn], clients[bobConn], clients[carolConn] = alice, bob, carol
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, aliceConn)
+		delete(clients, bobConn)
+		delete(clients, carolConn)
+		mutex.Unlock()
+	}()
+
+	handleCommand(bob, "/r hello?", time.Now())
+	if got := bobConn.writeBuffer.String(); got != "Nobody has sent you a private message yet\n" {
+		t.Errorf("Expected nobody to reply to, got %q", got)
+	}
+
+	handleCommand(alice, "/msg reply-bob lunch?", time.Now())
+	handleCommand(carol, "/msg reply-bob ping", time.Now())
+	bobConn.writeBuffer.Reset()
+	handleCommand(bob, "/r pong", time.Now())
+	if got := carolConn.writeBuffer.String(); !strings.Contains(got, "[PM from reply-bob]: pong") {
+		t.Errorf("Expected the reply to go to the latest sender, got %q", got)
+	}
+	if got := aliceConn.writeBuffer.String(); strings.Contains(got, "pong") {
+		t.Errorf("Expected earlier senders not to get the reply, got %q", got)
+	}
+	if got := bobConn.writeBuffer.String(); !strings.Contains(got, "[PM to reply-carol]: pong") {
+		t.Errorf("Expected the usual confirmation, got %q", got)
+	}
+
+	// Replying does not change who the replier answers next
+	handleCommand(bob, "/r still there?", time.Now())
+	if got := carolConn.writeBuffer.String(); !strings.Contains(got, "still there?") {
+		t.Errorf("Expected the second reply to go to the same sender, got %q", got)
+	}
+
+	handleNickCommand(carol, callOf("/nick reply-carol2"))
+	carolConn.writeBuffer.Reset()
+	handleCommand(bob, "/r renamed", time.Now())
+	if got := carolConn.writeBuffer.String(); !strings.Contains(got, "[PM from reply-bob]: renamed") {
+		t.Errorf("Expected the reply to follow the rename, got %q", got)
+	}
+}
+
+func TestReplyCommandWhileMuted(t *testing.T) {
+	aliceConn, bobConn := newMockConn(), newMockConn()
+	alice := &client{conn: aliceConn, name: "reply-muted-alice"}
+	bob := &client{conn: bobConn, name: "reply-muted-bob"}
+	muteClient(bob, "test", 0)
+	defer unmuteClient(bob)
+	mutex.Lock()
+	clients[aliceConn], clients[bobConn] = alice, bob
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, aliceConn)
+		delete(clients, bobConn)
+		mutex.Unlock()
+	}()
+
+	handleCommand(alice, "/msg reply-muted-bob still quiet?", time.Now())
+	handleCommand(bob, "/r not anymore", time.Now())
+	if got := aliceConn.writeBuffer.String(); strings.Contains(got, "not anymore") {
+		t.Errorf("Expected a muted user's reply to be refused, got %q", got)
+	}
+	if got := bobConn.writeBuffer.String(); !strings.Contains(got, "You are muted and cannot send messages") {
+		t.Errorf("Expected the muted notice, got %q", got)
+	}
+}
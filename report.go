@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	reportContextLines = 5   // Messages of the reported user attached to a report
+	maxReportReason    = 300 // Longest report reason in characters
+)
+
+// recentMessagesFrom returns up to n of the latest chat and action lines
+// of sender in history, oldest first.
+func recentMessagesFrom(history []string, sender string, n int) []string {
+	var found []string
+	for i := len(history) - 1; i >= 0 && len(found) < n; i-- {
+		if writtenBy(history[i], sender) {
+			found = append(found, history[i])
+		}
+	}
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	return found
+}
+
+// handleReportCommand processes "/report <user> <reason>". The report goes
+// privately to the online moderators and into the audit log, together with
+// the reported user's latest messages in the reporter's room.
+func handleReportCommand(c *client, call commandCall) {
+	if len(call.args) < 2 {
+		c.send("Usage: /report <user> <reason>")
+		return
+	}
+	target, reason := call.args[0], call.textAfter(1)
+	if target == c.name {
+		c.send("You cannot report yourself")
+		return
+	}
+	if len([]rune(reason)) > maxReportReason {
+		c.send(fmt.Sprintf("Reason too long (max %d characters)", maxReportReason))
+		return
+	}
+
+	context := recentMessagesFrom(roomHistory(c.room), target, reportContextLines)
+	audit.record(auditEntry{Action: "report", Actor: c.name, Target: target, Room: c.room, Reason: reason, Context: context})
+	log.Printf("%s reported %s in %s: %s", c.name, target, c.room, reason)
+
+	// Shadow-banned users get the confirmation without bothering anyone
+	if !isShadowBanned(c) {
+		notice := fmt.Sprintf("[REPORT] %s reported %s in %s: %s", c.name, target, c.room, reason)
+		if len(context) == 0 {
+			notice += " (no recent messages)"
+		}
+		notifyModerators(notice)
+		for _, line := range context {
+			notifyModerators("[REPORT]   " + line)
+		}
+	}
+	c.send(fmt.Sprintf("Thank you, your report about %s was sent to the moderators", target))
+}
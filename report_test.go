@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecentMessagesFrom(t *testing.T) {
+	t.Parallel()
+	history := []string{"bob: 1", "alice: x", "bob: 2", "bobby: y", "bob: 3"}
+	if got := recentMessagesFrom(history, "bob", 2); !reflect.DeepEqual(got, []string{"bob: 2", "bob: 3"}) {
+		t.Errorf("Unexpected messages %q", got)
+	}
+	if got := recentMessagesFrom(history, "carol", 2); got != nil {
+		t.Errorf("Expected no messages, got %q", got)
+	}
+}
+
+func TestHandleReportCommand(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(saved *auditLog) { audit = saved }(audit)
+	audit = &auditLog{w: &buf}
+
+	r, err := createRoom("#report-test", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	r.addToHistory("spammer: buy now")
+	mutex.Unlock()
+
+	reporterConn, modConn := newMockConn(), newMockConn()
+	reporter := &client{conn: reporterConn, name: "reporter", room: r.name}
+	mod := &client{conn: modConn, name: "mod", room: defaultRoom, moderator: true}
+	mutex.Lock()
+	clients[reporterConn], clients[modConn] = reporter, mod
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, r.name)
+		delete(clients, reporterConn)
+		delete(clients, modConn)
+		mutex.Unlock()
+	}()
+
+	handleReportCommand(reporter, callOf("/report reporter myself"))
+	if got := reporterConn.writeBuffer.String(); !strings.Contains(got, "cannot report yourself") {
+		t.Errorf("Expected self reports to be refused, got %q", got)
+	}
+
+	handleReportCommand(reporter, callOf("/report spammer selling things"))
+	if got := reporterConn.writeBuffer.String(); !strings.Contains(got, "report about spammer was sent") {
+		t.Errorf("Expected a confirmation, got %q", got)
+	}
+	want := "[REPORT] reporter reported spammer in #report-test: selling things\n[REPORT]   spammer: buy now\n"
+	if got := modConn.writeBuffer.String(); got != want {
+		t.Errorf("Expected moderators to get %q, got %q", want, got)
+	}
+
+	var entry auditEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Invalid audit entry %q: %v", buf.String(), err)
+	}
+	if entry.Action != "report" || entry.Target != "spammer" || entry.Reason != "selling things" ||
+		!reflect.DeepEqual(entry.Context, []string{"spammer: buy now"}) {
+		t.Errorf("Unexpected audit entry %+v", entry)
+	}
+}
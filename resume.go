@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resumeWindow is how long the session of a disconnected client can be
+// resumed.
+const resumeWindow = 10 * time.Minute
+
+// pmReplay stands in for the room in the frames of replayed private
+// messages.
+const pmReplay = "PM"
+
+// resumeToken is the handshake token of a reconnecting client,
+// "RESUME=<session token>:<last seen message ID>". The ID is optional;
+// without it the server's cursor of the session is used.
+const resumeToken = "RESUME="
+
+// suspended is what the server keeps of a disconnected session.
+type suspended struct {
+	name   string
+	room   string
+	cursor uint64           // Latest message ID sent to the session
+	pms    []offlineMessage // Private messages held for the session, see holdPM
+}
+
+// resumable holds the sessions of disconnected clients by session token.
+var resumable = struct {
+	sync.Mutex
+	sessions map[string]suspended
+}{sessions: make(map[string]suspended)}
+
+// parseResume reads the value of a RESUME= handshake token.
+func parseResume(value string) (token string, lastID uint64, ok bool) {
+	token, id, hasID := strings.Cut(value, ":")
+	if token == "" {
+		return "", 0, false
+	}
+	if hasID {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return "", 0, false
+		}
+		lastID = n
+	}
+	return token, lastID, true
+}
+
+// advanceCursor records that the message with the given ID was sent to c.
+func (c *client) advanceCursor(id uint64) {
+	for {
+		cursor := c.cursor.Load()
+		if id <= cursor || c.cursor.CompareAndSwap(cursor, id) {
+			return
+		}
+	}
+}
+
+// startSession gives c a session token, which clients with the resume
+// capability get as "SESSION <token>" to resume the session after losing
+// the connection.
+func startSession(c *client) {
+	if !c.caps.has(capResume) || c.guest {
+		return
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Error creating session token for %s: %v", c.name, err)
+		return
+	}
+	c.session = hex.EncodeToString(buf)
+	c.send("SESSION " + c.session)
+}
+
+// suspendSession keeps the session of the disconnecting client c for the
+// resume window.
+func suspendSession(c *client) {
+	if c.session == "" {
+		return
+	}
+	mutex.Lock()
+	room := c.room
+	mutex.Unlock()
+	token := c.session
+	resumable.Lock()
+	resumable.sessions[token] = suspended{name: c.name, room: room, cursor: c.cursor.Load()}
+	resumable.Unlock()
+	jobs.schedule("resume:"+token, time.Now().Add(resumeWindow), func() { expireSession(token) })
+}
+
+// expireSession drops the session suspended under token once the resume
+// window has passed.
+func expireSession(token string) {
+	if s, ok := takeSession(token); ok {
+		releasePMs(s)
+	}
+}
+
+// isSuspended reports whether a session of name waits to be resumed.
+func isSuspended(name string) bool {
+	resumable.Lock()
+	defer resumable.Unlock()
+
+	for _, s := range resumable.sessions {
+		if strings.EqualFold(s.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdPM keeps pm for the suspended session of its recipient, up to
+// maxOfflineMessages, to be replayed when the session is resumed. It
+// reports whether there was room in such a session.
+func holdPM(pm *pmDelivery) bool {
+	resumable.Lock()
+	defer resumable.Unlock()
+
+	for token, s := range resumable.sessions {
+		if strings.EqualFold(s.name, pm.to) && len(s.pms) < maxOfflineMessages {
+			s.pms = append(s.pms, offlineMessage{ID: pm.id, From: pm.from, Text: pm.text, Time: pm.received})
+			resumable.sessions[token] = s
+			return true
+		}
+	}
+	return false
+}
+
+// releasePMs hands the private messages held for a session that will not
+// be resumed to parkPM, as if their delivery had just failed.
+func releasePMs(s suspended) {
+	for _, msg := range s.pms {
+		parkPM(&pmDelivery{id: msg.ID, from: msg.From, to: s.name, text: msg.Text, received: msg.Time})
+	}
+}
+
+// replayPMs sends c the private messages held for its session, framed as
+// "REPLAY PM <n>" ... "REPLAY END PM" with the replay capability, and
+// tells their senders.
+func replayPMs(c *client, held []offlineMessage) {
+	if len(held) == 0 {
+		return
+	}
+	framed := c.caps.has(capReplay)
+	if framed {
+		c.send(fmt.Sprintf("REPLAY %s %d", pmReplay, len(held)))
+	}
+	for _, msg := range held {
+		c.sendID(msg.ID, stampMessage(msg.Time, fmt.Sprintf("[PM from %s]: %s", msg.From, msg.Text)))
+		logPrivateMessage(msg.From, c.name, msg.Text, msg.Time)
+		notifyPMSender(&pmDelivery{id: msg.ID, from: msg.From, to: c.name}, "DELIVERED", fmt.Sprintf("Your message to %s was delivered", c.name))
+	}
+	if framed {
+		c.send("REPLAY END " + pmReplay)
+	}
+	c.setReplyTo(held[len(held)-1].From)
+}
+
+// takeSession removes and returns the session suspended under token.
+func takeSession(token string) (suspended, bool) {
+	resumable.Lock()
+	defer resumable.Unlock()
+
+	s, ok := resumable.sessions[token]
+	delete(resumable.sessions, token)
+	return s, ok
+}
+
+// resumeSession picks up the session c asked for in the handshake: the
+// history replays leave out the messages it saw before the connection
+// dropped, and it goes back to the room it was in. It reports whether
+// there was such a session; otherwise c gets the full history as usual.
+// Back in another room, the missed messages of #general are not replayed.
+func resumeSession(c *client, hs handshake) bool {
+	if hs.resume == "" {
+		return false
+	}
+	s, ok := takeSession(hs.resume)
+	if !ok || !strings.EqualFold(s.name, c.name) {
+		if ok {
+			releasePMs(s)
+		}
+		c.send("Session expired, sending the full history")
+		return false
+	}
+	jobs.cancel("resume:" + hs.resume)
+	// Ahead of the room history, so the client can count them in
+	replayPMs(c, s.pms)
+	c.resumeAfter = s.cursor
+	if hs.lastID != 0 {
+		c.resumeAfter = hs.lastID
+	}
+	defer func() { c.resumeAfter = 0 }()
+
+	if s.room != defaultRoom {
+		err := joinRoom(c, s.room)
+		if err == nil {
+			return true
+		}
+		c.send(fmt.Sprintf("Could not rejoin %s: %v", s.room, err))
+	}
+	sendHistory(c, defaultRoom)
+	return true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseResume(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value      string
+		wantToken  string
+		wantLastID uint64
+		wantOK     bool
+	}{
+		{"abc:42", "abc", 42, true},
+		{"abc", "abc", 0, true},
+		{"abc:", "", 0, false},
+		{":42", "", 0, false},
+		{"abc:-1", "", 0, false},
+	}
+	for _, tt := range tests {
+		token, lastID, ok := parseResume(tt.value)
+		if token != tt.wantToken || lastID != tt.wantLastID || ok != tt.wantOK {
+			t.Errorf("parseResume(%q) = %q, %d, %t, want %q, %d, %t", tt.value, token, lastID, ok, tt.wantToken, tt.wantLastID, tt.wantOK)
+		}
+	}
+}
+
+func TestHistoryAfter(t *testing.T) {
+	t.Parallel()
+	history := []historyEntry{{line: "alice: one", id: 7}, {line: "bob: two"}, {line: "carol: three", id: 9}}
+	missed := historyAfter(history, 7)
+	if len(missed) != 2 || missed[0].line != "bob: two" || missed[1].id != 9 {
+		t.Errorf("historyAfter() = %+v", missed)
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	const name = "#resume-test"
+	mutex.Lock()
+	r := &room{name: name}
+	rooms[name] = r
+	r.addToHistory("alice: seen")
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		mutex.Unlock()
+	}()
+
+	old := &client{conn: newMockConn(), name: "resumer", room: name}
+	old.caps.set(capResume, true)
+	startSession(old)
+	token, ok := strings.CutPrefix(strings.TrimSpace(old.conn.(*mockConn).writeBuffer.String()), "SESSION ")
+	if !ok || len(token) != 32 {
+		t.Fatalf("Expected a session token, got %q", old.conn.(*mockConn).writeBuffer.String())
+	}
+	mutex.Lock()
+	seen := r.ids[0]
+	r.addToHistory("bob: missed")
+	mutex.Unlock()
+	suspendSession(old)
+
+	t.Run("other name", func(t *testing.T) {
+		c := &client{conn: newMockConn(), name: "someone-else", room: defaultRoom}
+		if resumeSession(c, handshake{resume: token, lastID: seen}) {
+			t.Fatal("Expected the session to stay with its owner")
+		}
+	})
+
+	suspendSession(old)
+	conn := newMockConn()
+	c := &client{conn: conn, name: "Resumer", room: defaultRoom}
+	if !resumeSession(c, handshake{resume: token, lastID: seen}) {
+		t.Fatal("Expected the session to be resumed")
+	}
+	got := conn.writeBuffer.String()
+	if !strings.Contains(got, "Now talking in "+name) || !strings.Contains(got, "bob: missed") || strings.Contains(got, "alice: seen") {
+		t.Errorf("Expected only the missed message in %s, got %q", name, got)
+	}
+	if c.room != name || c.resumeAfter != 0 {
+		t.Errorf("Expected to be back in %s with the cursor cleared, got %s after %d", name, c.room, c.resumeAfter)
+	}
+	if resumeSession(&client{conn: newMockConn(), name: "resumer"}, handshake{resume: token}) {
+		t.Error("Expected a session to be resumed only once")
+	}
+}
+
+func TestResumeSessionPMs(t *testing.T) {
+	senderConn := newMockConn()
+	sender := &client{conn: senderConn, name: "held-sender"}
+	mutex.Lock()
+	clients[senderConn] = sender
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, senderConn)
+		mutex.Unlock()
+	}()
+
+	away := &client{conn: newMockConn(), name: "held-away", room: defaultRoom}
+	away.caps.set(capResume, true)
+	startSession(away)
+	token := strings.TrimPrefix(strings.TrimSpace(away.conn.(*mockConn).writeBuffer.String()), "SESSION ")
+	suspendSession(away)
+
+	handlePrivateMessage(sender, callOf("/msg held-away are you back?"))
+	if got := senderConn.writeBuffer.String(); !strings.Contains(got, "held-away could not be reached; your message will be delivered when they are back") {
+		t.Fatalf("Expected the message to be held for the session, got %q", got)
+	}
+
+	senderConn.writeBuffer.Reset()
+	conn := newMockConn()
+	c := &client{conn: conn, name: "held-away", room: defaultRoom}
+	c.caps.set(capReplay, true)
+	if !resumeSession(c, handshake{resume: token}) {
+		t.Fatal("Expected the session to be resumed")
+	}
+	lines := strings.Split(conn.writeBuffer.String(), "\n")
+	if len(lines) < 4 || lines[0] != "REPLAY PM 1" || !strings.HasSuffix(lines[1], "[PM from held-sender]: are you back?") || lines[2] != "REPLAY END PM" || !strings.HasPrefix(lines[3], "REPLAY "+defaultRoom) {
+		t.Errorf("Expected the held message ahead of the room history, got %q", lines)
+	}
+	if got := senderConn.writeBuffer.String(); got != "Your message to held-away was delivered\n" {
+		t.Errorf("Expected the sender to be told, got %q", got)
+	}
+}
+
+func TestExpireSessionPMs(t *testing.T) {
+	senderConn := newMockConn()
+	sender := &client{conn: senderConn, name: "expired-sender"}
+	mutex.Lock()
+	clients[senderConn] = sender
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, senderConn)
+		mutex.Unlock()
+	}()
+
+	away := &client{conn: newMockConn(), name: "expired-away", room: defaultRoom}
+	away.caps.set(capResume, true)
+	startSession(away)
+	token := strings.TrimPrefix(strings.TrimSpace(away.conn.(*mockConn).writeBuffer.String()), "SESSION ")
+	suspendSession(away)
+	defer jobs.cancel("resume:" + token)
+
+	handlePrivateMessage(sender, callOf("/msg expired-away hello?"))
+	senderConn.writeBuffer.Reset()
+	expireSession(token)
+	if got := senderConn.writeBuffer.String(); got != "expired-away could not be reached; your message was not delivered\n" {
+		t.Errorf("Expected the sender to learn the message was lost, got %q", got)
+	}
+	if isSuspended("expired-away") {
+		t.Error("Expected the session to be gone")
+	}
+}
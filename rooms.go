@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRoom     = "#general" // Room every client joins after connecting
+	defaultTemplate = "default"  // Template applied when none is requested
+	maxRoomName     = 32
+)
+
+// RoomSettings controls how a room behaves. Room templates in the
+// configuration are sets of these settings applied when a room is created.
+type RoomSettings struct {
+	ReadOnly  bool           `json:"read_only"`  // Only operators may post
+	Retention int            `json:"retention"`  // Messages kept in history, 0 keeps all
+	RateLimit int            `json:"rate_limit"` // Seconds a member must wait between messages, 0 disables
+	Filters   []string       `json:"filters"`    // Words that get a message rejected
+	Profanity string         `json:"profanity"`  // Profanity filter action overriding profanity_action, "off" disables it
+	Greeting  string         `json:"greeting"`   // Private message sent to users joining the room
+	Links     LinkPolicy     `json:"links"`      // Restrictions on posted links
+	Reactions ReactionPolicy `json:"reactions"`  // Reactions allowed with /react
+}
+
+// room is a named channel with its own members, history and settings.
+type room struct {
+	name     string
+	owner    string // Creator of the room, empty for rooms created by the server
+	settings RoomSettings
+	history  []string
+	ids      []uint64             // Message IDs of the history entries
+	times    []time.Time          // When the history entries were posted
+	posted   int                  // Messages posted since the room was created
+	greeted  map[string]time.Time // Last greeting by lower-case user name
+	pins     []pin                // Pinned messages, oldest pin first
+}
+
+var rooms = map[string]*room{defaultRoom: {name: defaultRoom}} // Rooms by name, protected by mutex
+
+var (
+	errInvalidRoomName = errors.New("Room names must start with '#' and contain no spaces")
+	errRoomExists      = errors.New("Room already exists")
+	errUnknownRoom     = errors.New("No such room")
+)
+
+// validRoomName reports whether name can be used for a room.
+func validRoomName(name string) bool {
+	return len(name) > 1 && len(name) <= maxRoomName && strings.HasPrefix(name, "#") &&
+		!strings.ContainsAny(name, " \t,")
+}
+
+// newRoom builds a room from the named template. An empty template name
+// selects the default template, which may be absent from the configuration.
+func newRoom(name, template string) (*room, error) {
+	if !validRoomName(name) {
+		return nil, errInvalidRoomName
+	}
+	if template == "" {
+		settings := config.RoomTemplates[defaultTemplate]
+		return &room{name: name, settings: settings}, nil
+	}
+	settings, ok := config.RoomTemplates[template]
+	if !ok {
+		return nil, fmt.Errorf("Unknown room template %q", template)
+	}
+	return &room{name: name, settings: settings}, nil
+}
+
+// createRoom registers a new room built from template and owned by owner.
+func createRoom(name, template, owner string) (*room, error) {
+	r, err := newRoom(name, template)
+	if err != nil {
+		return nil, err
+	}
+	r.owner = owner
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, exists := rooms[name]; exists {
+		return nil, errRoomExists
+	}
+	rooms[name] = r
+	return r, nil
+}
+
+// addToHistory records message, posted now, in the room history under a
+// new message ID and returns the ID. The caller must hold mutex.
+func (r *room) addToHistory(message string) uint64 {
+	return r.addToHistoryAt(message, time.Now())
+}
+
+// addToHistoryAt records message, posted at the given time, in the room
+// history under a new message ID, dropping the oldest entries beyond the
+// room's retention, and returns the ID. The caller must hold mutex.
+func (r *room) addToHistoryAt(message string, at time.Time) uint64 {
+	id := nextMessageID()
+	r.history = append(r.history, message)
+	r.ids = append(r.ids, id)
+	r.times = append(r.times, at)
+	r.posted++
+	if limit := r.settings.Retention; limit > 0 && len(r.history) > limit {
+		r.history = append([]string(nil), r.history[len(r.history)-limit:]...)
+		r.ids = append([]uint64(nil), r.ids[max(len(r.ids)-limit, 0):]...)
+		r.times = append([]time.Time(nil), r.times[max(len(r.times)-limit, 0):]...)
+	}
+	return id
+}
+
+// roomHistory returns a copy of the history of the named room.
+func roomHistory(name string) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if r, ok := rooms[name]; ok {
+		return append([]string(nil), r.history...)
+	}
+	return nil
+}
+
+// historyEntry is a line of a room history with what is known about it.
+type historyEntry struct {
+	line   string
+	id     uint64    // Message ID, 0 where it is not known
+	posted time.Time // Zero where it is not known
+}
+
+// roomHistoryEntries returns a copy of the history of the named room.
+func roomHistoryEntries(name string) []historyEntry {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	r, ok := rooms[name]
+	if !ok {
+		return nil
+	}
+	entries := make([]historyEntry, len(r.history))
+	for i, line := range r.history {
+		entries[i].line = line
+		if len(r.ids) == len(r.history) {
+			entries[i].id = r.ids[i]
+		}
+		if len(r.times) == len(r.history) {
+			entries[i].posted = r.times[i]
+		}
+	}
+	return entries
+}
+
+// checkRoomPolicy applies the settings of room to a chat message c posts
+// there and returns the reason for rejecting it, if any.
+func checkRoomPolicy(c *client, room, message string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	r, ok := rooms[room]
+	if !ok {
+		return errUnknownRoom
+	}
+	if r.settings.ReadOnly && !c.operator {
+		return fmt.Errorf("%s is read-only", r.name)
+	}
+	lower := strings.ToLower(message)
+	for _, word := range r.settings.Filters {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			return fmt.Errorf("Message rejected by the %s filter", r.name)
+		}
+	}
+	if err := r.settings.Links.check(c, r.name, message); err != nil {
+		return err
+	}
+	if limit := time.Duration(r.settings.RateLimit) * time.Second; limit > 0 {
+		if wait := limit - time.Since(c.lastMessage); wait > 0 {
+			return fmt.Errorf("Slow mode is on in %s, wait %d seconds", r.name, int(wait.Seconds())+1)
+		}
+	}
+	c.lastMessage = time.Now()
+	return nil
+}
+
+// postToRoom stores a chat message received at received in the room
+// history and delivers it to the other members of the room, stamped with
+// the time it goes out.
+func postToRoom(name, message string, sender net.Conn, received time.Time) {
+	broadcasts.submit(func() {
+		sent := time.Now()
+		mutex.Lock()
+		var id uint64
+		if r, ok := rooms[name]; ok {
+			id = r.addToHistoryAt(message, sent)
+		} else {
+			id = nextMessageID()
+		}
+		mutex.Unlock()
+
+		stamped := stampMessage(sent, message)
+		for _, c := range roomMembers(name, sender) {
+			name := c.currentName()
+			c.queueID(id, c.markMention(stamped), func() { recordWrite(classChat, received, name) })
+		}
+	})
+	recordActivity(name, received)
+}
+
+// broadcastToRoom sends message to every member of the room except sender.
+func broadcastToRoom(name, message string, sender net.Conn) {
+	broadcasts.submit(func() {
+		id := nextMessageID()
+		for _, c := range roomMembers(name, sender) {
+			c.queueID(id, message, nil)
+		}
+	})
+}
+
+// roomMembers returns the clients in the room other than exclude, who
+// sends to them, and those ignoring exclude.
+func roomMembers(name string, exclude net.Conn) []*client {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var members []*client
+	for conn, c := range clients {
+		if conn != exclude && c.room == name {
+			members = append(members, c)
+		}
+	}
+	return skipIgnorersLocked(members, exclude)
+}
+
+// joinRoom moves the client into the named room, announcing the change to
+// both rooms and replaying the new room's history.
+func joinRoom(c *client, name string) error {
+	mutex.Lock()
+	if _, ok := rooms[name]; !ok {
+		mutex.Unlock()
+		return errUnknownRoom
+	}
+	if !guestMayEnter(c, name) {
+		mutex.Unlock()
+		return errGuestRoom
+	}
+	previous := c.room
+	c.room = name
+	mutex.Unlock()
+	if ec, ok := c.conn.(*encodedConn); ok {
+		ec.setRoom(name)
+	}
+
+	if previous != "" && previous != name {
+		broadcastToRoom(previous, formatSystemMessage(fmt.Sprintf("%s has left %s", c.name, previous)), c.conn)
+	}
+	broadcastToRoom(name, formatSystemMessage(fmt.Sprintf("%s has joined %s", c.name, name)), c.conn)
+	events.record(auditEntry{Action: "room", Actor: c.name, Room: name, Target: previous})
+	c.send(fmt.Sprintf("Now talking in %s", name))
+	sendHistory(c, name)
+	sendGreeting(c, name)
+	sendPins(c, name)
+	return nil
+}
+
+// handleRoomCommand processes /create, /join and /rooms.
+func handleRoomCommand(c *client, call commandCall) {
+	switch call.name {
+	case "/create":
+		// /create #room [--template name]
+		if len(call.args) != 1 && !(len(call.args) == 3 && call.args[1] == "--template") {
+			c.send("Usage: /create #room [--template name]")
+			return
+		}
+		if c.guest {
+			c.send("Guests cannot create rooms")
+			return
+		}
+		template := ""
+		if len(call.args) == 3 {
+			template = call.args[2]
+		}
+		r, err := createRoom(call.args[0], template, c.name)
+		if err != nil {
+			c.send(err.Error())
+			return
+		}
+		log.Printf("%s created room %s (template %q)", c.name, r.name, template)
+		joinRoom(c, r.name)
+	case "/join":
+		if len(call.args) != 1 {
+			c.send("Usage: /join #room")
+			return
+		}
+		if err := joinRoom(c, call.args[0]); err != nil {
+			c.send(err.Error())
+		}
+	case "/rooms":
+		c.send("Rooms: " + strings.Join(roomSummaries(), ", "))
+	}
+}
+
+// roomSummaries lists the rooms with their member counts, sorted by name.
+func roomSummaries() []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	counts := make(map[string]int)
+	for _, c := range clients {
+		counts[c.room]++
+	}
+	var summaries []string
+	for name := range rooms {
+		summaries = append(summaries, fmt.Sprintf("%s (%d)", name, counts[name]))
+	}
+	sort.Strings(summaries)
+	return summaries
+}
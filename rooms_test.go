@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoomTemplates(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config.RoomTemplates = map[string]RoomSettings{
+		"default": {Retention: 50},
+		"support": {RateLimit: 5, ReadOnly: true, Filters: []string{"spam"}},
+	}
+
+	t.Run("DefaultTemplate", func(t *testing.T) {
+		r, err := newRoom("#random", "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if r.settings.Retention != 50 {
+			t.Errorf("Expected default retention 50, got %d", r.settings.Retention)
+		}
+	})
+
+	t.Run("NamedTemplate", func(t *testing.T) {
+		r, err := newRoom("#help", "support")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !r.settings.ReadOnly || r.settings.RateLimit != 5 {
+			t.Errorf("Expected support settings, got %+v", r.settings)
+		}
+	})
+
+	t.Run("UnknownTemplate", func(t *testing.T) {
+		if _, err := newRoom("#help", "missing"); err == nil {
+			t.Error("Expected an error for an unknown template")
+		}
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		for _, name := range []string{"general", "#", "#two words"} {
+			if _, err := newRoom(name, ""); err != errInvalidRoomName {
+				t.Errorf("Expected %q to be rejected, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestRoomRetention(t *testing.T) {
+	t.Parallel()
+	r := &room{name: "#test", settings: RoomSettings{Retention: 2}}
+	for _, msg := range []string{"one", "two", "three"} {
+		r.addToHistory(msg)
+	}
+	if strings.Join(r.history, ",") != "two,three" {
+		t.Errorf("Expected only the last two messages, got %v", r.history)
+	}
+}
+
+func TestCheckRoomPolicy(t *testing.T) {
+	mutex.Lock()
+	rooms["#policy"] = &room{name: "#policy", settings: RoomSettings{RateLimit: 60, Filters: []string{"Spam"}}}
+	rooms["#news"] = &room{name: "#news", settings: RoomSettings{ReadOnly: true}}
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, "#policy")
+		delete(rooms, "#news")
+		mutex.Unlock()
+	}()
+
+	c := &client{conn: newMockConn(), name: "alice"}
+	if err := checkRoomPolicy(c, "#policy", "buy SPAM now"); err == nil {
+		t.Error("Expected filtered word to be rejected")
+	}
+	if err := checkRoomPolicy(c, "#policy", "hello"); err != nil {
+		t.Errorf("Expected first message to pass, got %v", err)
+	}
+	if err := checkRoomPolicy(c, "#policy", "hello again"); err == nil || !strings.Contains(err.Error(), "Slow mode") {
+		t.Errorf("Expected slow mode rejection, got %v", err)
+	}
+
+	if err := checkRoomPolicy(c, "#news", "hello"); err == nil {
+		t.Error("Expected read-only room to reject non-operators")
+	}
+	c.operator = true
+	if err := checkRoomPolicy(c, "#news", "hello"); err != nil {
+		t.Errorf("Expected operator to post in read-only room, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errInvalidUTF8 = errors.New("Message is not valid UTF-8")
+
+// sanitizeMessage checks that a line from a client is valid UTF-8 and
+// removes what could take over other users' terminals: ANSI escape
+// sequences, including the C1 forms, and other control characters such
+// as the bell. Tabs become spaces.
+func sanitizeMessage(message string) (string, error) {
+	if !utf8.ValidString(message) {
+		return "", errInvalidUTF8
+	}
+	var b strings.Builder
+	runes := []rune(message)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\t':
+			b.WriteByte(' ')
+		case r == '\x1b' && i+1 < len(runes):
+			i = skipEscape(runes, i+1, runes[i+1])
+		case r == '\u009b': // C1 control sequence introducer
+			i = skipEscape(runes, i, '[')
+		case r == '\u009d': // C1 operating system command
+			i = skipEscape(runes, i, ']')
+		case r == '\u0090': // C1 device control string
+			i = skipEscape(runes, i, 'P')
+		case unicode.IsControl(r):
+			// Dropped, as is a lone ESC at the end
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
+
+// skipEscape returns the index of the last rune of the escape sequence
+// whose introducer, the rune after ESC or its C1 equivalent, is at i.
+func skipEscape(runes []rune, i int, kind rune) int {
+	switch kind {
+	case '[':
+		// Control sequence: parameters up to a final byte in @ to ~
+		for i++; i < len(runes); i++ {
+			if runes[i] >= 0x40 && runes[i] <= 0x7e {
+				return i
+			}
+		}
+	case ']', 'P', 'X', '^', '_':
+		// Strings ended by BEL or the string terminator, ESC \
+		for i++; i < len(runes); i++ {
+			if runes[i] == '\a' || runes[i] == '\u009c' {
+				return i
+			}
+			if runes[i] == '\x1b' && i+1 < len(runes) && runes[i+1] == '\\' {
+				return i + 1
+			}
+		}
+	default:
+		// Two-character sequences such as ESC c, which resets the terminal
+		return i
+	}
+	return len(runes) - 1
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestSanitizeMessage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		message string
+		want    string
+		wantErr error
+	}{
+		{"plain", "hello, wörld 👋\n", "hello, wörld 👋", nil},
+		{"colors", "\x1b[31mred\x1b[0m text", "red text", nil},
+		{"clear screen", "\x1b[2J\x1b[Hgotcha", "gotcha", nil},
+		{"window title", "\x1b]0;pwned\atitle", "title", nil},
+		{"title with string terminator", "\x1b]2;pwned\x1b\\ok", "ok", nil},
+		{"terminal reset", "\x1bcreset", "reset", nil},
+		{"C1 sequence", "\u009b31mred", "red", nil},
+		{"bell", "ding\a\a", "ding", nil},
+		{"tab", "a\tb", "a b", nil},
+		{"carriage return", "fake\rline\r\n", "fakeline", nil},
+		{"lone escape", "end\x1b", "end", nil},
+		{"unterminated sequence", "text\x1b[31", "text", nil},
+		{"invalid UTF-8", "bad \xff byte", "", errInvalidUTF8},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := sanitizeMessage(tt.message)
+			if err != tt.wantErr || got != tt.want {
+				t.Errorf("sanitizeMessage(%q) = %q, %v, want %q, %v", tt.message, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
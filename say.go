@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// handleSayCommand processes "/say #room <message>", with which operators
+// and registered bots post a chat message to a room without joining it,
+// as bridges and announcement tools do.
+func handleSayCommand(c *client, call commandCall) {
+	name, text := call.args[0], call.textAfter(1)
+	if !strings.HasPrefix(name, "#") {
+		c.send("Usage: /say #room <message>")
+		return
+	}
+
+	mutex.Lock()
+	_, ok := rooms[name]
+	mutex.Unlock()
+	if !ok {
+		c.send(errUnknownRoom.Error())
+		return
+	}
+	if isMuted(c) {
+		c.send("You are muted and cannot send messages")
+		return
+	}
+	// The message passes the checks of the target room, as if sent there
+	text, ok = screenRoomMessage(c, name, text, call.received)
+	if !ok {
+		return
+	}
+	line, err := formatChatMessage(c.name, text)
+	if err != nil {
+		log.Printf("Refusing /say from %s: %v", c.name, err)
+		return
+	}
+
+	if !isShadowBanned(c) {
+		postToRoom(name, line, c.conn, call.received)
+		events.record(auditEntry{Time: call.received, Action: "message", Actor: c.name, Room: name, Text: text})
+		notifyGroupMentions(c, name, text)
+	}
+	log.Printf("%s said in %s: %s", c.name, name, text)
+	c.send(fmt.Sprintf("Sent to %s", name))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSayCommand(t *testing.T) {
+	const name = "#say-test"
+	memberConn, botConn, userConn := newMockConn(), newMockConn(), newMockConn()
+	member := &client{conn: memberConn, name: "say-member", room: name}
+	bot := &client{conn: botConn, name: "say-bridge", room: defaultRoom, bot: true}
+	user := &client{conn: userConn, name: "say-user", room: defaultRoom}
+	mutex.Lock()
+	rooms[name] = &room{name: name, settings: RoomSettings{Filters: []string{"secret"}}}
+	clients[memberConn], clients[botConn], clients[userConn] = member, bot, user
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, memberConn)
+		delete(clients, botConn)
+		delete(clients, userConn)
+		mutex.Unlock()
+	}()
+	muted := &client{conn: newMockConn(), name: "say-muted", bot: true}
+	muteClient(muted, "test", 0)
+	defer unmuteClient(muted)
+
+	tests := []struct {
+		name    string
+		client  *client
+		message string
+		want    string
+	}{
+		{"users", user, "/say #say-test hi", "Permission denied: operator or bot only command"},
+		{"usage", bot, "/say say-test hi", "Usage: /say #room <message>"},
+		{"unknown room", bot, "/say #say-nowhere hi", "No such room"},
+		{"bot", bot, "/say #say-test deploy finished", "Sent to #say-test"},
+		{"operator", &client{conn: newMockConn(), name: "say-op", operator: true}, "/say #say-test maintenance at noon", "Sent to #say-test"},
+		{"room filter", bot, "/say #say-test the secret plan", "Message rejected by the #say-test filter"},
+		{"muted", muted, "/say #say-test still here", "You are muted and cannot send messages"},
+	}
+	for _, tt := range tests {
+		conn := tt.client.conn.(*mockConn)
+		conn.writeBuffer.Reset()
+		handleCommand(tt.client, tt.message, time.Now())
+		if got := conn.writeBuffer.String(); got != tt.want+"\n" {
+			t.Errorf("%s: %q answered %q, want %q", tt.name, tt.message, got, tt.want)
+		}
+	}
+
+	got := memberConn.writeBuffer.String()
+	for _, want := range []string{"] say-bridge: deploy finished\n", "] say-op: maintenance at noon\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in the room, got %q", want, got)
+		}
+	}
+	if got := userConn.writeBuffer.String(); strings.Contains(got, "deploy finished") {
+		t.Errorf("Expected other rooms not to get the message, got %q", got)
+	}
+	if entries := roomHistoryEntries(name); len(entries) != 2 || entries[0].line != "say-bridge: deploy finished" {
+		t.Errorf("Expected the messages in the room history, got %+v", entries)
+	}
+}
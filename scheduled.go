@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduledMessage configures a server notice broadcast on a cron
+// schedule, such as a nightly backup warning.
+type ScheduledMessage struct {
+	Name     string `json:"name"`     // Name used by /schedule
+	Schedule string `json:"schedule"` // Five-field cron expression in the server's time zone
+	Room     string `json:"room"`     // Room to post to, empty for every connected client
+	Text     string `json:"text"`
+}
+
+// scheduledMessage is an active scheduled message.
+type scheduledMessage struct {
+	ScheduledMessage
+	cron *cronSchedule
+	next time.Time // Next broadcast
+}
+
+// scheduled holds the active scheduled messages by name.
+var scheduled = struct {
+	sync.Mutex
+	messages map[string]*scheduledMessage
+}{messages: make(map[string]*scheduledMessage)}
+
+func scheduledJobKey(name string) string {
+	return "scheduled:" + name
+}
+
+// startScheduledMessages activates the scheduled messages of the
+// configuration.
+func startScheduledMessages(configs []ScheduledMessage) error {
+	for _, cfg := range configs {
+		if err := addScheduledMessage(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addScheduledMessage validates cfg and schedules its first broadcast. A
+// message of the same name is replaced.
+func addScheduledMessage(cfg ScheduledMessage) error {
+	if !validGroupName(cfg.Name) {
+		return fmt.Errorf("invalid scheduled message name %q", cfg.Name)
+	}
+	if strings.TrimSpace(cfg.Text) == "" {
+		return fmt.Errorf("scheduled message %s has no text", cfg.Name)
+	}
+	if cfg.Room != "" && !strings.HasPrefix(cfg.Room, "#") {
+		return fmt.Errorf("scheduled message %s: room %q must start with #", cfg.Name, cfg.Room)
+	}
+	cron, err := parseCron(cfg.Schedule)
+	if err != nil {
+		return fmt.Errorf("scheduled message %s: %w", cfg.Name, err)
+	}
+	msg := &scheduledMessage{ScheduledMessage: cfg, cron: cron}
+	if msg.next = cron.next(time.Now()); msg.next.IsZero() {
+		return fmt.Errorf("scheduled message %s: %q never runs", cfg.Name, cfg.Schedule)
+	}
+
+	scheduled.Lock()
+	scheduled.messages[cfg.Name] = msg
+	scheduled.Unlock()
+	jobs.schedule(scheduledJobKey(cfg.Name), msg.next, func() { broadcastScheduled(msg) })
+	return nil
+}
+
+// removeScheduledMessage stops the scheduled message called name and
+// reports whether there was one.
+func removeScheduledMessage(name string) bool {
+	scheduled.Lock()
+	_, ok := scheduled.messages[name]
+	delete(scheduled.messages, name)
+	scheduled.Unlock()
+	jobs.cancel(scheduledJobKey(name))
+	return ok
+}
+
+// broadcastScheduled sends msg, unless it was replaced or removed since,
+// and schedules the next broadcast.
+func broadcastScheduled(msg *scheduledMessage) {
+	scheduled.Lock()
+	if scheduled.messages[msg.Name] != msg {
+		scheduled.Unlock()
+		return
+	}
+	msg.next = msg.cron.next(time.Now())
+	next := msg.next
+	scheduled.Unlock()
+
+	line := formatSystemMessage(msg.Text)
+	if msg.Room == "" {
+		broadcastMessage(line, nil)
+	} else {
+		broadcastToRoom(msg.Room, line, nil)
+	}
+	if !next.IsZero() {
+		jobs.schedule(scheduledJobKey(msg.Name), next, func() { broadcastScheduled(msg) })
+	}
+}
+
+// scheduledMessages returns the active scheduled messages sorted by name.
+func scheduledMessages() []scheduledMessage {
+	scheduled.Lock()
+	defer scheduled.Unlock()
+
+	list := make([]scheduledMessage, 0, len(scheduled.messages))
+	for _, msg := range scheduled.messages {
+		list = append(list, *msg)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// handleScheduleCommand processes the operator command `/schedule [list |
+// add <name> "<cron>" [#room] <text> | remove <name>]`. Messages added
+// here last until the server restarts; the ones to keep belong under
+// scheduled_messages in the configuration.
+func handleScheduleCommand(c *client, call commandCall) {
+	usage := `Usage: /schedule [list | add <name> "<cron>" [#room] <text> | remove <name>]`
+
+	if len(call.args) == 0 || (call.args[0] == "list" && len(call.args) == 1) {
+		list := scheduledMessages()
+		if len(list) == 0 {
+			c.send("No scheduled messages")
+			return
+		}
+		loc := c.locale()
+		for _, msg := range list {
+			target := "everyone"
+			if msg.Room != "" {
+				target = msg.Room
+			}
+			c.send(fmt.Sprintf("%s [%s] to %s, next %s: %s", msg.Name, msg.Schedule, target, loc.time(msg.next), msg.Text))
+		}
+		return
+	}
+
+	switch call.args[0] {
+	case "remove":
+		if len(call.args) != 2 {
+			c.send(usage)
+			return
+		}
+		if !removeScheduledMessage(call.args[1]) {
+			c.send(fmt.Sprintf("No scheduled message %q", call.args[1]))
+			return
+		}
+		log.Printf("%s removed the scheduled message %s", c.name, call.args[1])
+		c.send(fmt.Sprintf("Removed the scheduled message %s", call.args[1]))
+	case "add":
+		words, ok := splitQuoted(call.text)
+		if !ok || len(words) < 4 {
+			c.send(usage)
+			return
+		}
+		cfg := ScheduledMessage{Name: words[1], Schedule: words[2]}
+		rest := words[3:]
+		if strings.HasPrefix(rest[0], "#") {
+			cfg.Room, rest = rest[0], rest[1:]
+		}
+		cfg.Text = strings.Join(rest, " ")
+		if err := addScheduledMessage(cfg); err != nil {
+			c.send(err.Error())
+			return
+		}
+		log.Printf("%s scheduled the message %s for %q", c.name, cfg.Name, cfg.Schedule)
+		c.send(fmt.Sprintf("Scheduled the message %s", cfg.Name))
+	default:
+		c.send(usage)
+	}
+}
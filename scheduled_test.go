@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleCommand(t *testing.T) {
+	const name = "#schedule-test"
+	opConn, memberConn := newMockConn(), newMockConn()
+	op := &client{conn: opConn, name: "schedule-op", operator: true, room: defaultRoom}
+	member := &client{conn: memberConn, name: "schedule-member", room: name}
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	clients[memberConn] = member
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		delete(clients, memberConn)
+		mutex.Unlock()
+		removeScheduledMessage("schedtest")
+		removeScheduledMessage("schedtest2")
+	}()
+
+	tests := []struct {
+		client  *client
+		message string
+		want    string
+	}{
+		{member, "/schedule", "Permission denied: operator only command"},
+		{op, "/schedule add schedtest", "Usage: /schedule"},
+		{op, `/schedule add schedtest "0 25 * * *" backup soon`, "scheduled message schedtest: cron hour \"25\": values must lie between 0 and 23"},
+		{op, `/schedule add schedtest "0 2 * * *" #schedule-test Backups run at 02:00, expect a short pause`, "Scheduled the message schedtest"},
+		{op, `/schedule add schedtest2 "*/30 * * * *" Please read the rules`, "Scheduled the message schedtest2"},
+		{op, "/schedule list", "schedtest [0 2 * * *] to #schedule-test, next "},
+		{op, "/schedule", "schedtest2 [*/30 * * * *] to everyone, next "},
+		{op, "/schedule remove schedtest2", "Removed the scheduled message schedtest2"},
+		{op, "/schedule remove schedtest2", `No scheduled message "schedtest2"`},
+	}
+	for _, tt := range tests {
+		conn := tt.client.conn.(*mockConn)
+		conn.writeBuffer.Reset()
+		handleCommand(tt.client, tt.message, time.Now())
+		if got := conn.writeBuffer.String(); !strings.Contains(got, tt.want) {
+			t.Errorf("%q answered %q, want %q", tt.message, got, tt.want)
+		}
+	}
+	if jobs.pending(scheduledJobKey("schedtest2")) || !jobs.pending(scheduledJobKey("schedtest")) {
+		t.Error("Expected only the remaining message to be scheduled")
+	}
+
+	scheduled.Lock()
+	msg := scheduled.messages["schedtest"]
+	first := msg.next
+	scheduled.Unlock()
+	memberConn.writeBuffer.Reset()
+	broadcastScheduled(msg)
+	if got, want := memberConn.writeBuffer.String(), "SERVER: Backups run at 02:00, expect a short pause\n"; got != want {
+		t.Errorf("Expected the notice in the room, got %q, want %q", got, want)
+	}
+	if !jobs.pending(scheduledJobKey("schedtest")) || msg.next.Before(first) {
+		t.Errorf("Expected the next broadcast to be scheduled, got %v", msg.next)
+	}
+
+	// Replaced messages stop broadcasting
+	memberConn.writeBuffer.Reset()
+	if err := addScheduledMessage(ScheduledMessage{Name: "schedtest", Schedule: "0 3 * * *", Room: name, Text: "replaced"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	broadcastScheduled(msg)
+	if got := memberConn.writeBuffer.String(); got != "" {
+		t.Errorf("Expected the replaced message to stay quiet, got %q", got)
+	}
+}
+
+func TestStartScheduledMessages(t *testing.T) {
+	t.Parallel()
+	for _, cfg := range []ScheduledMessage{
+		{Name: "bad name!", Schedule: "* * * * *", Text: "x"},
+		{Name: "startnotext", Schedule: "* * * * *"},
+		{Name: "startroom", Schedule: "* * * * *", Room: "general", Text: "x"},
+		{Name: "startnever", Schedule: "0 0 31 4 *", Text: "x"},
+	} {
+		if err := startScheduledMessages([]ScheduledMessage{cfg}); err == nil {
+			t.Errorf("Expected %+v to be refused", cfg)
+			removeScheduledMessage(cfg.Name)
+		}
+	}
+}
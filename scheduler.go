@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scheduler runs delayed jobs identified by a key. Scheduling a key again
+// replaces the pending job, so callers can reschedule or cancel by key.
+type scheduler struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+var jobs = newScheduler() // Scheduler shared by the timed server features
+
+func newScheduler() *scheduler {
+	return &scheduler{timers: make(map[string]*time.Timer)}
+}
+
+// schedule runs fn at the given time on its own goroutine, replacing any
+// job pending under the same key.
+func (s *scheduler) schedule(key string, at time.Time, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending, ok := s.timers[key]; ok {
+		pending.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(at), func() {
+		s.mu.Lock()
+		if s.timers[key] != timer {
+			// Replaced or cancelled after the timer already fired
+			s.mu.Unlock()
+			return
+		}
+		delete(s.timers, key)
+		s.mu.Unlock()
+		fn()
+	})
+	s.timers[key] = timer
+}
+
+// cancel drops the job pending under key and reports whether there was one.
+func (s *scheduler) cancel(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.timers[key]
+	if ok {
+		pending.Stop()
+		delete(s.timers, key)
+	}
+	return ok
+}
+
+// pending reports whether a job is scheduled under key.
+func (s *scheduler) pending(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.timers[key]
+	return ok
+}
+
+// maxDurationDays bounds the durations parseDuration accepts, well past
+// any sensible ban or reminder and well short of overflowing.
+const maxDurationDays = 10 * 365
+
+// parseDuration parses moderation durations such as "90s", "10m", "2h" or
+// "1d". It accepts anything time.ParseDuration does plus whole days, up to
+// maxDurationDays.
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 || n > maxDurationDays {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 || d > maxDurationDays*24*time.Hour {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// isDuration reports whether value parses as a duration.
+func isDuration(value string) bool {
+	_, err := parseDuration(value)
+	return err == nil
+}
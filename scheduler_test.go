@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+	t.Run("RunsJob", func(t *testing.T) {
+		t.Parallel()
+		s := newScheduler()
+		done := make(chan struct{})
+		s.schedule("job", time.Now().Add(10*time.Millisecond), func() { close(done) })
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected scheduled job to run")
+		}
+		if s.pending("job") {
+			t.Error("Expected job to be removed after running")
+		}
+	})
+
+	t.Run("ReplaceAndCancel", func(t *testing.T) {
+		t.Parallel()
+		s := newScheduler()
+		var runs int32
+		s.schedule("job", time.Now().Add(20*time.Millisecond), func() { atomic.AddInt32(&runs, 1) })
+		s.schedule("job", time.Now().Add(20*time.Millisecond), func() { atomic.AddInt32(&runs, 10) })
+		s.schedule("other", time.Now().Add(20*time.Millisecond), func() { atomic.AddInt32(&runs, 100) })
+		if !s.cancel("other") {
+			t.Error("Expected pending job to be cancelled")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		if got := atomic.LoadInt32(&runs); got != 10 {
+			t.Errorf("Expected only the replacement job to run, got %d", got)
+		}
+	})
+}
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"10m", 10 * time.Minute, true},
+		{"2h", 2 * time.Hour, true},
+		{"1d", 24 * time.Hour, true},
+		{"0s", 0, false},
+		{"-5m", 0, false},
+		{"soon", 0, false},
+		{"3650d", 3650 * 24 * time.Hour, true},
+		{"3651d", 0, false},
+		{"106751992d", 0, false}, // Would overflow
+		{"100000h", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDuration(tt.input)
+		if (err == nil) != tt.valid || got != tt.expected {
+			t.Errorf("parseDuration(%q) = %v, %v", tt.input, got, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sighting is when a name was last online and where.
+type sighting struct {
+	Name string    `json:"name"` // Name as it was written
+	Time time.Time `json:"time"` // Time of the disconnect or rename
+	Room string    `json:"room"` // Room the user was in
+}
+
+// seenStore remembers when names were last online for /seen. Sightings of
+// registered users are mirrored to a JSON file; guests are only kept in
+// memory since their generated names are not coming back.
+type seenStore struct {
+	mu     sync.Mutex
+	path   string              // Empty path keeps the store in memory only
+	Names  map[string]sighting `json:"names"` // Sightings by lowercase name
+	guests map[string]sighting // Sightings of guests by lowercase name
+}
+
+var seen = newSeenStore("") // Last-seen records, replaced by the persisted store on startup
+
+func newSeenStore(path string) *seenStore {
+	return &seenStore{path: path, Names: make(map[string]sighting), guests: make(map[string]sighting)}
+}
+
+// loadSeenStore reads the sightings stored at path. A missing file yields
+// an empty store that will be created on the first disconnect.
+func loadSeenStore(path string) (*seenStore, error) {
+	store := newSeenStore(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if store.Names == nil {
+		store.Names = make(map[string]sighting)
+	}
+	return store, nil
+}
+
+// save writes the store to disk. The caller must hold s.mu.
+func (s *seenStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0o600)
+}
+
+// record notes that name left room at at. Guest sightings are not saved.
+func (s *seenStore) record(name, room string, at time.Time, guest bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(name)
+	entry := sighting{Name: name, Time: at, Room: room}
+	if guest {
+		s.guests[key] = entry
+		return nil
+	}
+	delete(s.guests, key)
+	s.Names[key] = entry
+	return s.save()
+}
+
+// lookup returns the latest sighting of name, ignoring case.
+func (s *seenStore) lookup(name string) (sighting, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(name)
+	if entry, ok := s.guests[key]; ok {
+		return entry, true
+	}
+	entry, ok := s.Names[key]
+	return entry, ok
+}
+
+// forget drops the sightings of name and saves the store.
+func (s *seenStore) forget(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(name)
+	delete(s.guests, key)
+	if _, ok := s.Names[key]; !ok {
+		return nil
+	}
+	delete(s.Names, key)
+	return s.save()
+}
+
+// recordSeen notes that name is no longer online, on a disconnect or a
+// rename.
+func recordSeen(name, room string, guest bool) {
+	if err := seen.record(name, room, time.Now(), guest); err != nil {
+		log.Printf("Error saving last-seen records: %v", err)
+	}
+}
+
+// timeAgo writes how long ago something happened in the largest whole
+// unit.
+func timeAgo(elapsed time.Duration) string {
+	plural := func(n time.Duration, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return plural(elapsed/time.Minute, "minute")
+	case elapsed < 24*time.Hour:
+		return plural(elapsed/time.Hour, "hour")
+	default:
+		return plural(elapsed/(24*time.Hour), "day")
+	}
+}
+
+// handleSeenCommand processes "/seen <user>", which tells when the user
+// was last online and in which room.
+func handleSeenCommand(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send("Usage: /seen <user>")
+		return
+	}
+
+	name := call.args[0]
+	if target := findClientByName(name); target != nil {
+		mutex.Lock()
+		name, room := target.name, target.room
+		mutex.Unlock()
+		c.send(fmt.Sprintf("%s is online now in %s", name, room))
+		return
+	}
+	entry, ok := seen.lookup(name)
+	if !ok {
+		c.send(fmt.Sprintf("%s has not been seen", name))
+		return
+	}
+	c.send(fmt.Sprintf("%s was last seen %s (%s) in %s",
+		entry.Name, c.locale().time(entry.Time), timeAgo(time.Since(entry.Time)), entry.Room))
+}
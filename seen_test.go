@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeenStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "seen.json")
+	store, err := loadSeenStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	if err := store.record("Bob", "#dev", at, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.record("Guest-1234", "#general", at, true)
+	store.record("carol", "#general", at, false)
+	store.forget("carol")
+
+	if entry, ok := store.lookup("guest-1234"); !ok || entry.Room != "#general" {
+		t.Errorf("Expected the guest to be seen in memory, got %+v, %t", entry, ok)
+	}
+	reloaded, err := loadSeenStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error reloading: %v", err)
+	}
+	if entry, ok := reloaded.lookup("bob"); !ok || entry.Name != "Bob" || entry.Room != "#dev" || !entry.Time.Equal(at) {
+		t.Errorf("Expected Bob to persist, got %+v, %t", entry, ok)
+	}
+	for _, name := range []string{"Guest-1234", "carol"} {
+		if _, ok := reloaded.lookup(name); ok {
+			t.Errorf("Expected %s not to be saved", name)
+		}
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	t.Parallel()
+	for elapsed, want := range map[time.Duration]string{
+		20 * time.Second: "just now",
+		time.Minute:      "1 minute ago",
+		59 * time.Minute: "59 minutes ago",
+		3 * time.Hour:    "3 hours ago",
+		49 * time.Hour:   "2 days ago",
+	} {
+		if got := timeAgo(elapsed); got != want {
+			t.Errorf("timeAgo(%v) = %q, want %q", elapsed, got, want)
+		}
+	}
+}
+
+func TestSeenCommand(t *testing.T) {
+	defer func(saved *seenStore) { seen = saved }(seen)
+	seen = newSeenStore("")
+
+	askConn, onlineConn := newMockConn(), newMockConn()
+	asker := &client{conn: askConn, name: "seen-asker", room: "#general"}
+	online := &client{conn: onlineConn, name: "seen-online", room: "#seen-dev"}
+	mutex.Lock()
+	clients[askConn], clients[onlineConn] = asker, online
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, askConn)
+		delete(clients, onlineConn)
+		mutex.Unlock()
+	}()
+	recordSeen("seen-gone", "#seen-dev", false)
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"/seen", "Usage: /seen <user>"},
+		{"/seen seen-online", "seen-online is online now in #seen-dev"},
+		{"/seen SEEN-GONE", "seen-gone was last seen"},
+		{"/seen seen-nobody", "seen-nobody has not been seen"},
+	}
+	for _, tt := range tests {
+		askConn.writeBuffer.Reset()
+		handleSeenCommand(asker, callOf(tt.message))
+		if got := askConn.writeBuffer.String(); !strings.Contains(got, tt.want) {
+			t.Errorf("%q answered %q, want %q", tt.message, got, tt.want)
+		}
+	}
+	askConn.writeBuffer.Reset()
+	handleSeenCommand(asker, callOf("/seen seen-gone"))
+	if got := askConn.writeBuffer.String(); !strings.Contains(got, "(just now) in #seen-dev") {
+		t.Errorf("Expected the room and how long ago, got %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+// sequencer runs the deliveries of broadcast messages one after another
+// on a single goroutine, which only orders them. Message IDs are taken and history is appended
+// inside the deliveries, so every client sees broadcasts in the same
+// order, the order of their IDs and of the room history, however many
+// sessions broadcast at once.
+type sequencer struct {
+	deliveries chan sequenced
+}
+
+// sequenced is a delivery waiting for its turn.
+type sequenced struct {
+	deliver func()
+	done    chan struct{}
+}
+
+var broadcasts = newSequencer() // Sequencer of every broadcast message
+
+func newSequencer() *sequencer {
+	s := &sequencer{deliveries: make(chan sequenced)}
+	go s.run()
+	return s
+}
+
+func (s *sequencer) run() {
+	for d := range s.deliveries {
+		d.deliver()
+		close(d.done)
+	}
+}
+
+// submit runs deliver after the deliveries submitted before it and waits
+// until it is done. Deliveries only hand the lines to the outboxes of the
+// recipients, whose writers send them on their own goroutines, so slow
+// clients do not hold up the queue. deliver must not submit another
+// delivery.
+func (s *sequencer) submit(deliver func()) {
+	done := make(chan struct{})
+	s.deliveries <- sequenced{deliver: deliver, done: done}
+	<-done
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequencerOrder(t *testing.T) {
+	t.Parallel()
+	s := newSequencer()
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.submit(func() { order = append(order, i) })
+		}()
+	}
+	wg.Wait()
+	if len(order) != 20 {
+		t.Errorf("Expected every delivery to run once, got %v", order)
+	}
+}
+
+func TestBroadcastTotalOrder(t *testing.T) {
+	const name = "#sequencer-test"
+	mutex.Lock()
+	rooms[name] = &room{name: name}
+	var members []*mockConn
+	for i := 0; i < 3; i++ {
+		conn := newMockConn()
+		members = append(members, conn)
+		clients[conn] = &client{conn: conn, name: fmt.Sprintf("sequenced-%d", i), room: name}
+		clients[conn].caps.set(capIDs, true)
+	}
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, name)
+		for _, conn := range members {
+			delete(clients, conn)
+		}
+		mutex.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	for sender := 0; sender < 4; sender++ {
+		sender := sender
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 25; n++ {
+				postToRoom(name, fmt.Sprintf("user%d: message %d", sender, n), nil, time.Now())
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := members[0].writeBuffer.String()
+	if strings.Count(want, "\n") < 100 {
+		t.Fatalf("Expected 100 messages, got %q", want)
+	}
+	for i, conn := range members[1:] {
+		if got := conn.writeBuffer.String(); got != want {
+			t.Errorf("Member %d saw a different order than member 0", i+1)
+		}
+	}
+	var last uint64
+	for _, line := range strings.Split(strings.TrimSuffix(want, "\n"), "\n") {
+		id, _ := splitIDTag(line)
+		if id <= last {
+			t.Fatalf("Expected increasing message IDs, got %d after %d", id, last)
+		}
+		last = id
+	}
+
+	history := roomHistoryEntries(name)
+	for i := range history {
+		if i > 0 && history[i].id <= history[i-1].id {
+			t.Fatalf("Expected the history in ID order, got %+v", history)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"tcp_chat/chattest"
+)
+
+// startTestServer serves the chat on a loopback port with an event log in
+// a temporary directory, and returns the server and the log's path.
+func startTestServer(t *testing.T) (*chattest.Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	log, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := events
+	events = log
+	t.Cleanup(func() { events = saved })
+	return chattest.NewServer(t, serve), path
+}
+
+func TestServeChat(t *testing.T) {
+	srv, eventLog := startTestServer(t)
+
+	alice := srv.Connect(t, "alice")
+	bob := srv.Connect(t, "bob")
+	alice.Expect("bob has joined our chat")
+
+	alice.Send("hello bob")
+	bob.Expect("alice: hello bob")
+	if e := chattest.ExpectEntry(t, eventLog, "message", "alice"); e.Text != "hello bob" || e.Room != defaultRoom {
+		t.Errorf("Expected the message in the event log, got %+v", e)
+	}
+
+	bob.Run(chattest.Send("/nick robert"), chattest.Expect("You are now known as robert"))
+	alice.Expect("bob is now known as robert")
+
+	bob.Close()
+	alice.Expect("robert has left our chat")
+	chattest.ExpectEntry(t, eventLog, "leave", "robert")
+}
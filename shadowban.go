@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// shadowBans tracks shadow-banned names and addresses. A shadow-banned
+// client keeps chatting as usual from its own point of view, but nothing it
+// says reaches anybody else. The address is recorded as well so that
+// reconnecting under a new name does not escape the shadow ban.
+var shadowBans = struct {
+	sync.Mutex
+	names map[string]bool
+	ips   map[string]bool
+}{names: make(map[string]bool), ips: make(map[string]bool)}
+
+// isShadowBanned reports whether messages from c must be swallowed.
+func isShadowBanned(c *client) bool {
+	shadowBans.Lock()
+	defer shadowBans.Unlock()
+
+	return shadowBans.names[strings.ToLower(c.currentName())] || (c.ip != "" && shadowBans.ips[c.ip])
+}
+
+// shadowBan marks the name and the given address, which may be empty.
+func shadowBan(name, ip string) {
+	shadowBans.Lock()
+	defer shadowBans.Unlock()
+
+	shadowBans.names[strings.ToLower(name)] = true
+	if ip != "" {
+		shadowBans.ips[ip] = true
+	}
+}
+
+// liftShadowBan clears the name and address, reporting whether either was
+// shadow-banned.
+func liftShadowBan(name, ip string) bool {
+	shadowBans.Lock()
+	defer shadowBans.Unlock()
+
+	key := strings.ToLower(name)
+	found := shadowBans.names[key] || (ip != "" && shadowBans.ips[ip])
+	delete(shadowBans.names, key)
+	if ip != "" {
+		delete(shadowBans.ips, ip)
+	}
+	return found
+}
+
+// handleShadowBanCommand processes the moderator commands /shadowban and
+// /unshadowban. The target is never told.
+func handleShadowBanCommand(c *client, call commandCall) {
+	if len(call.args) != 1 {
+		c.send(fmt.Sprintf("Usage: %s <name>", call.name))
+		return
+	}
+
+	name, ip := call.args[0], ""
+	if target := findClientByName(name); target != nil {
+		ip = target.ip
+	}
+	if call.name == "/shadowban" {
+		shadowBan(name, ip)
+		log.Printf("%s shadow-banned %s (%s)", c.name, name, ip)
+		audit.record(auditEntry{Action: "shadowban", Actor: c.name, Target: name})
+		c.send(fmt.Sprintf("%s has been shadow-banned", name))
+		return
+	}
+	if !liftShadowBan(name, ip) {
+		c.send(fmt.Sprintf("%s is not shadow-banned", name))
+		return
+	}
+	log.Printf("%s lifted the shadow ban on %s", c.name, name)
+	audit.record(auditEntry{Action: "unshadowban", Actor: c.name, Target: name})
+	c.send(fmt.Sprintf("%s is no longer shadow-banned", name))
+}
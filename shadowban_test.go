@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShadowBan(t *testing.T) {
+	t.Parallel()
+	spammer := &client{conn: newMockConn(), name: "Spammer1", ip: "192.0.2.10"}
+	if isShadowBanned(spammer) {
+		t.Fatal("Expected client not to be shadow-banned yet")
+	}
+
+	shadowBan("spammer1", "192.0.2.10")
+	if !isShadowBanned(spammer) {
+		t.Error("Expected name match to ignore case")
+	}
+	renamed := &client{conn: newMockConn(), name: "Spammer2", ip: "192.0.2.10"}
+	if !isShadowBanned(renamed) {
+		t.Error("Expected a new name from the same address to stay shadow-banned")
+	}
+
+	if !liftShadowBan("Spammer1", "192.0.2.10") {
+		t.Error("Expected lifting to report an existing shadow ban")
+	}
+	if isShadowBanned(spammer) || isShadowBanned(renamed) {
+		t.Error("Expected shadow ban to be lifted")
+	}
+}
+
+func TestShadowBanCommandRequiresModerator(t *testing.T) {
+	t.Parallel()
+	conn := newMockConn()
+	c := &client{conn: conn, name: "alice"}
+
+	if !handleCommand(c, "/shadowban bob", time.Now()) {
+		t.Fatal("Expected /shadowban to be handled")
+	}
+	if !strings.Contains(conn.writeBuffer.String(), "Permission denied") {
+		t.Errorf("Expected permission error, got %q", conn.writeBuffer.String())
+	}
+}
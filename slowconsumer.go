@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	defaultWriteTimeout = 5 * time.Second // Deadline of a single write when none is configured
+	maxSlowWrites       = 3               // Consecutive timed-out writes before a client is dropped
+	maxPartialWrites    = 4               // Writes of one line that may each make only partial progress
+)
+
+// writeTimeout returns the configured deadline of a single write.
+func writeTimeout() time.Duration {
+	if config.WriteTimeout <= 0 {
+		return defaultWriteTimeout
+	}
+	return time.Duration(config.WriteTimeout) * time.Millisecond
+}
+
+// writeFull writes all of data to conn. A write that times out or returns
+// early after sending part of the data is retried with the rest and a fresh
+// deadline, so congested links that drain slowly do not lose the tail of a
+// line. Writes that make no progress at all fail as before.
+func writeFull(conn net.Conn, data []byte) error {
+	for attempt := 1; ; attempt++ {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout()))
+		n, err := conn.Write(data)
+		data = data[n:]
+		if len(data) == 0 {
+			return nil
+		}
+		if n == 0 || attempt == maxPartialWrites {
+			if err == nil {
+				err = io.ErrShortWrite
+			}
+			return err
+		}
+		var netErr net.Error
+		if err != nil && !errors.Is(err, io.ErrShortWrite) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+			return err
+		}
+	}
+}
+
+// noteWrite updates the slow-write count of c after a write that returned
+// err, disconnecting c once its writes keep hitting the deadline.
+func (c *client) noteWrite(err error) {
+	if err == nil {
+		c.slowWrites.Store(0)
+		return
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && c.slowWrites.Add(1) >= maxSlowWrites {
+		c.disconnectSlow(fmt.Sprintf("after %d timed-out writes", maxSlowWrites))
+	}
+}
+
+// disconnectSlow tells c it is too slow, as far as it still reads, and
+// closes the connection. The read loop then unregisters it as usual.
+// reason completes the log line.
+func (c *client) disconnectSlow(reason string) {
+	if !c.slowKicked.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("Disconnecting slow consumer %s %s", c.name, reason)
+	c.writeMu.Lock()
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.conn.Write([]byte("Disconnected: your connection is too slow to keep up\n"))
+	c.writeMu.Unlock()
+	c.conn.Close()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// timeoutConn fails every write with a deadline error after its first
+// writeOK writes.
+type timeoutConn struct {
+	*mockConn
+	writeOK int
+	writes  int
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	c.writes++
+	if c.writes <= c.writeOK {
+		return c.mockConn.Write(b)
+	}
+	return 0, os.ErrDeadlineExceeded
+}
+
+func TestSlowConsumerDisconnected(t *testing.T) {
+	t.Parallel()
+	conn := &timeoutConn{mockConn: newMockConn()}
+	c := &client{conn: conn, name: "slowpoke"}
+
+	for i := 0; i < maxSlowWrites-1; i++ {
+		c.send("hello")
+	}
+	if conn.closed {
+		t.Fatal("Expected a few timed-out writes to be tolerated")
+	}
+	c.send("hello")
+	if !conn.closed {
+		t.Error("Expected the client to be disconnected after repeated timeouts")
+	}
+}
+
+func TestSlowWritesResetOnSuccess(t *testing.T) {
+	t.Parallel()
+	conn := &timeoutConn{mockConn: newMockConn(), writeOK: 1000}
+	c := &client{conn: conn, name: "recovering"}
+
+	c.noteWrite(os.ErrDeadlineExceeded)
+	c.noteWrite(os.ErrDeadlineExceeded)
+	c.send("ok")
+	c.noteWrite(os.ErrDeadlineExceeded)
+	if conn.closed {
+		t.Error("Expected a successful write to reset the slow-write count")
+	}
+}
+
+// partialConn accepts at most chunk bytes per write, reporting a deadline
+// error for the rest as a congested link would. After stallAfter writes it
+// accepts nothing more.
+type partialConn struct {
+	*mockConn
+	chunk      int
+	stallAfter int
+	writes     int
+}
+
+func (c *partialConn) Write(b []byte) (int, error) {
+	c.writes++
+	if c.writes > c.stallAfter {
+		return 0, os.ErrDeadlineExceeded
+	}
+	if len(b) <= c.chunk {
+		return c.mockConn.Write(b)
+	}
+	n, _ := c.mockConn.Write(b[:c.chunk])
+	return n, os.ErrDeadlineExceeded
+}
+
+func TestWriteFull(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		chunk      int
+		stallAfter int
+		wantErr    bool
+		wantOut    string
+	}{
+		{"Whole line at once", 64, 100, false, "hello world\n"},
+		{"Partial writes are retried", 4, 100, false, "hello world\n"},
+		{"Stalled link fails", 4, 1, true, "hell"},
+		{"Retries are bounded", 1, 100, true, "hell"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			conn := &partialConn{mockConn: newMockConn(), chunk: tt.chunk, stallAfter: tt.stallAfter}
+			err := writeFull(conn, []byte("hello world\n"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %t, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr && !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("Expected the deadline error, got %v", err)
+			}
+			if got := conn.writeBuffer.String(); got != tt.wantOut {
+				t.Errorf("Expected %q to be written, got %q", tt.wantOut, got)
+			}
+		})
+	}
+}
+
+func TestPartialWritesDoNotCountAsSlow(t *testing.T) {
+	t.Parallel()
+	conn := &partialConn{mockConn: newMockConn(), chunk: 8, stallAfter: 1000}
+	c := &client{conn: conn, name: "congested"}
+
+	for i := 0; i < maxSlowWrites+1; i++ {
+		if err := c.send("a line longer than one chunk"); err != nil {
+			t.Fatalf("Expected the line to be delivered, got %v", err)
+		}
+	}
+	if conn.closed {
+		t.Error("Expected a client that keeps draining not to be disconnected")
+	}
+}
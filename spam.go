@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// RepeatSettings controls the suppression of identical consecutive
+// messages, which catches spam that stays under the flood limit.
+type RepeatSettings struct {
+	Count  int `json:"count"`  // Identical messages allowed in a row, 0 disables the check
+	Window int `json:"window"` // Seconds after which a repeat counts as a new message
+}
+
+var errRepeatedMessage = errors.New("Repeated message suppressed, please don't spam")
+
+// repeatState tracks the last chat message of a client. It is only touched
+// by the client's own connection goroutine.
+type repeatState struct {
+	text  string
+	count int
+	first time.Time // When the current run of repeats started
+}
+
+// checkRepeat records a chat message from c received at now against the
+// configured repeat limit, see RepeatSettings.check.
+func checkRepeat(c *client, message string, now time.Time) error {
+	return config.Repeat.check(c, message, now)
+}
+
+// check records a chat message from c received at now and returns
+// errRepeatedMessage when it repeats the previous one more often than
+// limit allows.
+func (limit RepeatSettings) check(c *client, message string, now time.Time) error {
+	if limit.Count <= 0 {
+		return nil
+	}
+	s := &c.repeat
+	text := strings.ToLower(strings.Join(strings.Fields(message), " "))
+	window := time.Duration(limit.Window) * time.Second
+
+	if text != s.text || (window > 0 && now.Sub(s.first) >= window) {
+		*s = repeatState{text: text, count: 1, first: now}
+		return nil
+	}
+	s.count++
+	if s.count > limit.Count {
+		return errRepeatedMessage
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckRepeat(t *testing.T) {
+	limit := RepeatSettings{Count: 2, Window: 30}
+
+	c := &client{conn: newMockConn(), name: "spammer"}
+	start := time.Now()
+	steps := []struct {
+		message string
+		offset  time.Duration
+		wantErr bool
+	}{
+		{"buy now", 0, false},
+		{"Buy  NOW", time.Second, false},
+		{"buy now", 2 * time.Second, true},
+		{"buy now", 3 * time.Second, true},
+		{"something else", 4 * time.Second, false},
+		{"buy now", 5 * time.Second, false},
+		{"buy now", 6 * time.Second, false},
+		{"buy now", 40 * time.Second, false}, // the run started too long ago
+	}
+	for i, step := range steps {
+		err := limit.check(c, step.message, start.Add(step.offset))
+		if (err != nil) != step.wantErr {
+			t.Errorf("Step %d (%q): expected error %t, got %v", i+1, step.message, step.wantErr, err)
+		}
+	}
+
+	limit.Count = 0
+	for i := 0; i < 10; i++ {
+		if err := limit.check(c, "again", start); err != nil {
+			t.Fatalf("Expected no check when disabled, got %v", err)
+		}
+	}
+}
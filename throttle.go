@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxTarpitted bounds the refused connections held open at once, so the
+// tarpit itself cannot be used to exhaust the server.
+const maxTarpitted = 100
+
+var errServerBusy = errors.New("Server is busy. Please try again later.")
+
+// tokenBucket allows bursts of up to burst events and a sustained rate of
+// rate events per second.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow takes a token at now and reports whether one was available.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// AcceptSettings throttles how fast the listener takes new connections.
+type AcceptSettings struct {
+	Rate   float64 `json:"rate"`   // Connections accepted per second, 0 disables the throttle
+	Burst  int     `json:"burst"`  // Connections accepted at once after a quiet period
+	Tarpit int     `json:"tarpit"` // Milliseconds a throttled connection waits before it is refused
+}
+
+// acceptThrottle holds back connections beyond the configured accept rate
+// in a tarpit before refusing them.
+type acceptThrottle struct {
+	bucket *tokenBucket
+	tarpit time.Duration
+}
+
+var (
+	acceptLimiter *acceptThrottle                     // Nil when accept throttling is disabled
+	tarpitSlots   = make(chan struct{}, maxTarpitted) // Connections currently held in the tarpit
+)
+
+// newAcceptThrottle builds the throttle described by settings, or returns
+// nil when they disable it.
+func newAcceptThrottle(settings AcceptSettings) *acceptThrottle {
+	if settings.Rate <= 0 {
+		return nil
+	}
+	burst := max(settings.Burst, 1)
+	return &acceptThrottle{
+		bucket: newTokenBucket(settings.Rate, burst),
+		tarpit: time.Duration(settings.Tarpit) * time.Millisecond,
+	}
+}
+
+// setupAcceptThrottle builds the accept limiter from the configuration.
+func setupAcceptThrottle() {
+	acceptLimiter = newAcceptThrottle(config.Accept)
+}
+
+// throttleAccept reports whether conn may proceed to the handshake, see
+// acceptThrottle.admit.
+func throttleAccept(conn net.Conn) bool {
+	return acceptLimiter.admit(conn)
+}
+
+// admit reports whether conn may proceed to the handshake. When it may
+// not, conn is held for the tarpit delay and then refused, without
+// blocking the accept loop. A nil throttle admits every connection.
+func (t *acceptThrottle) admit(conn net.Conn) bool {
+	if t == nil || t.bucket.allow(time.Now()) {
+		return true
+	}
+	select {
+	case tarpitSlots <- struct{}{}:
+		go func() {
+			defer func() { <-tarpitSlots }()
+			time.Sleep(t.tarpit)
+			conn.Write([]byte(errServerBusy.Error() + "\n"))
+			conn.Close()
+		}()
+	default:
+		// Tarpit full, drop the connection right away
+		conn.Close()
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+	b := newTokenBucket(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !b.allow(now) {
+			t.Fatalf("Expected burst event %d to be allowed", i+1)
+		}
+	}
+	if b.allow(now) {
+		t.Fatal("Expected the bucket to be empty after the burst")
+	}
+	if !b.allow(now.Add(500 * time.Millisecond)) {
+		t.Error("Expected one token after half a second at 2/s")
+	}
+	if b.allow(now.Add(600 * time.Millisecond)) {
+		t.Error("Expected no token 100ms later")
+	}
+	if !b.allow(now.Add(time.Hour)) || !b.allow(now.Add(time.Hour)) || !b.allow(now.Add(time.Hour)) || b.allow(now.Add(time.Hour)) {
+		t.Error("Expected refill to stop at the burst size")
+	}
+}
+
+func TestThrottleAccept(t *testing.T) {
+	t.Parallel()
+	throttle := newAcceptThrottle(AcceptSettings{Rate: 0.001, Burst: 1, Tarpit: 10})
+
+	first, _ := net.Pipe()
+	if !throttle.admit(first) {
+		t.Fatal("Expected the first connection to pass")
+	}
+
+	server, peer := net.Pipe()
+	start := time.Now()
+	if throttle.admit(server) {
+		t.Fatal("Expected the second connection to be throttled")
+	}
+	if time.Since(start) > 5*time.Millisecond {
+		t.Error("Expected the tarpit not to block the caller")
+	}
+	buf := make([]byte, 128)
+	n, _ := peer.Read(buf)
+	if !strings.Contains(string(buf[:n]), "Server is busy") {
+		t.Errorf("Expected a busy reply, got %q", buf[:n])
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Error("Expected the reply to be delayed by the tarpit")
+	}
+}
+
+func TestAcceptThrottleDisabled(t *testing.T) {
+	t.Parallel()
+	throttle := newAcceptThrottle(AcceptSettings{Burst: 1})
+	if throttle != nil {
+		t.Fatal("Expected no throttle without a rate")
+	}
+	for i := 0; i < 3; i++ {
+		conn, _ := net.Pipe()
+		if !throttle.admit(conn) {
+			t.Fatal("Expected a disabled throttle to admit every connection")
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// timestampLayout is the format of the time, in UTC, in front of chat
+// messages: "[2026-03-01 12:30:00] alice: hi".
+const timestampLayout = "2006-01-02 15:04:05"
+
+// stampMessage puts the time at in front of a chat message. Messages
+// without a known time are left alone.
+func stampMessage(at time.Time, message string) string {
+	if at.IsZero() {
+		return message
+	}
+	return "[" + at.UTC().Format(timestampLayout) + "] " + message
+}
+
+// splitTimestamp separates the time from a line written by stampMessage.
+// Lines without one have the zero time.
+func splitTimestamp(line string) (time.Time, string) {
+	rest, ok := strings.CutPrefix(line, "[")
+	if !ok || len(rest) < len(timestampLayout)+2 || rest[len(timestampLayout):len(timestampLayout)+2] != "] " {
+		return time.Time{}, line
+	}
+	at, err := time.Parse(timestampLayout, rest[:len(timestampLayout)])
+	if err != nil {
+		return time.Time{}, line
+	}
+	return at, rest[len(timestampLayout)+2:]
+}
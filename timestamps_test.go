@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitTimestamp(t *testing.T) {
+	t.Parallel()
+	posted := time.Date(2026, 3, 1, 12, 30, 5, 0, time.UTC)
+	tests := []struct {
+		line     string
+		wantTime time.Time
+		want     string
+	}{
+		{stampMessage(posted, "alice: hi"), posted, "alice: hi"},
+		{stampMessage(posted.In(time.FixedZone("CET", 3600)), "alice: hi"), posted, "alice: hi"},
+		{stampMessage(time.Time{}, "alice: hi"), time.Time{}, "alice: hi"},
+		{"[PM from bob]: hi", time.Time{}, "[PM from bob]: hi"},
+		{"[2026-13-01 12:30:05] alice: hi", time.Time{}, "[2026-13-01 12:30:05] alice: hi"},
+		{"[2026-03-01 12:30:05]alice", time.Time{}, "[2026-03-01 12:30:05]alice"},
+	}
+	for _, tt := range tests {
+		at, line := splitTimestamp(tt.line)
+		if !at.Equal(tt.wantTime) || line != tt.want {
+			t.Errorf("splitTimestamp(%q) = %v, %q, want %v, %q", tt.line, at, line, tt.wantTime, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"time"
+)
+
+// typingInterval is the least time between two typing notices relayed for
+// the same client. Notices sent more often are dropped, so /typing is not
+// counted by the flood limit.
+const typingInterval = 3 * time.Second
+
+// typingCommand is the line clients send when their user starts typing.
+const typingCommand = "/typing"
+
+// handleTyping relays a typing notice from c, received at now, to the
+// members of its room that enabled the typing capability.
+func handleTyping(c *client, now time.Time) {
+	if now.Sub(c.lastTyping) < typingInterval {
+		return
+	}
+	c.lastTyping = now
+	if isShadowBanned(c) {
+		return
+	}
+
+	for _, member := range roomMembers(c.room, c.conn) {
+		if member.caps.has(capTyping) {
+			member.send("TYPING " + c.name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// supportedVersions lists the protocol versions the server speaks, oldest
+// first. Sessions that do not negotiate use protocolVersion.
+var supportedVersions = []string{protocolVersion}
+
+// Handshake tokens carrying lists, as in "CHAT/1.0 VERSIONS=1.0,1.1".
+const (
+	versionsToken = "VERSIONS=" // Versions the client speaks
+	capsToken     = "CAPS="     // Capabilities to enable before the session starts
+	encodingToken = "ENCODING=" // Wire encoding of the session, see jsonlines.go
+)
+
+var errNoCommonVersion = errors.New("No common protocol version")
+
+// handshake is what a client asked for in its first line.
+type handshake struct {
+	guest      bool     // The client asked for a generated guest name
+	version    string   // Protocol version of the session
+	negotiated bool     // The client offered versions and expects the server's choice
+	caps       []string // Known capabilities requested in the handshake
+	encoding   string   // Wire encoding of the session
+	resume     string   // Token of the session to resume, see resume.go
+	lastID     uint64   // Latest message ID the resuming client saw, 0 if it did not say
+	compress   string   // Stream compression picked from the client's offer, empty without one
+}
+
+// parseHandshake reads the first line of a connection, which already
+// starts with the CHAT/1.0 prefix. Tokens after it select guest mode,
+// offer protocol versions, request capabilities, pick the encoding and
+// compression and resume a session; unknown tokens and capabilities are
+// ignored so later clients can add their own. Without an offer the
+// session stays on the base version, as older clients expect.
+func parseHandshake(line string) (handshake, error) {
+	line, _, _ = strings.Cut(line, "\n")
+	hs := handshake{version: protocolVersion, encoding: encodingText}
+	for _, token := range strings.Fields(line)[1:] {
+		switch {
+		case token == "GUEST":
+			hs.guest = true
+		case strings.HasPrefix(token, versionsToken):
+			version, ok := negotiateVersion(strings.Split(strings.TrimPrefix(token, versionsToken), ","), supportedVersions)
+			if !ok {
+				return hs, errNoCommonVersion
+			}
+			hs.version, hs.negotiated = version, true
+		case strings.HasPrefix(token, capsToken):
+			for _, name := range strings.Split(strings.TrimPrefix(token, capsToken), ",") {
+				if isCapability(name) {
+					hs.caps = append(hs.caps, name)
+				}
+			}
+		case strings.HasPrefix(token, resumeToken):
+			if session, lastID, ok := parseResume(strings.TrimPrefix(token, resumeToken)); ok {
+				hs.resume, hs.lastID = session, lastID
+			}
+		case strings.HasPrefix(token, compressToken):
+			hs.compress = pickCompression(strings.Split(strings.TrimPrefix(token, compressToken), ","))
+		case strings.HasPrefix(token, encodingToken):
+			hs.encoding = strings.TrimPrefix(token, encodingToken)
+			if !slices.Contains(encodings, hs.encoding) {
+				return hs, errUnsupportedEncoding
+			}
+		}
+	}
+	return hs, nil
+}
+
+// negotiateVersion picks the highest version in offered that is also in
+// supported.
+func negotiateVersion(offered, supported []string) (string, bool) {
+	best := ""
+	for _, version := range offered {
+		if !slices.Contains(supported, version) {
+			continue
+		}
+		if best == "" || compareVersions(version, best) > 0 {
+			best = version
+		}
+	}
+	return best, best != ""
+}
+
+// compareVersions compares "major.minor" versions numerically, so that
+// 1.10 is newer than 1.9.
+func compareVersions(a, b string) int {
+	aMajor, aMinor := splitVersion(a)
+	bMajor, bMinor := splitVersion(b)
+	if aMajor != bMajor {
+		return aMajor - bMajor
+	}
+	return aMinor - bMinor
+}
+
+func splitVersion(version string) (int, int) {
+	major, minor, _ := strings.Cut(version, ".")
+	m, _ := strconv.Atoi(major)
+	n, _ := strconv.Atoi(minor)
+	return m, n
+}
+
+// versionReply is the line telling a negotiating client the version of
+// the session and every version the server speaks.
+func versionReply(version string) string {
+	return fmt.Sprintf("%s/%s SUPPORTED %s", protocolName, version, strings.Join(supportedVersions, ","))
+}
+
+// unsupportedVersionReply refuses a client that offered no version the
+// server speaks.
+func unsupportedVersionReply() string {
+	return fmt.Sprintf("%s. Server supports: %s", errNoCommonVersion, strings.Join(supportedVersions, ","))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHandshake(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line    string
+		want    handshake
+		wantErr bool
+	}{
+		{"CHAT/1.0\n", handshake{version: "1.0", encoding: "text"}, false},
+		{"CHAT/1.0 GUEST\n", handshake{guest: true, version: "1.0", encoding: "text"}, false},
+		{"CHAT/1.0 VERSIONS=1.0\n", handshake{version: "1.0", encoding: "text", negotiated: true}, false},
+		{"CHAT/1.0 GUEST VERSIONS=2.0,1.0\nalice\n", handshake{guest: true, version: "1.0", encoding: "text", negotiated: true}, false},
+		{"CHAT/1.0 FUTURE-FLAG\n", handshake{version: "1.0", encoding: "text"}, false},
+		{"CHAT/1.0 VERSIONS=1.0 CAPS=replay,bogus,typing\n", handshake{version: "1.0", encoding: "text", negotiated: true, caps: []string{"replay", "typing"}}, false},
+		{"CHAT/1.0 VERSIONS=1.0 ENCODING=json\n", handshake{version: "1.0", encoding: "json", negotiated: true}, false},
+		{"CHAT/1.0 CAPS=resume RESUME=abc:42\n", handshake{version: "1.0", encoding: "text", caps: []string{"resume"}, resume: "abc", lastID: 42}, false},
+		{"CHAT/1.0 RESUME=abc:soon\n", handshake{version: "1.0", encoding: "text"}, false},
+		{"CHAT/1.0 COMPRESS=zstd,GZIP\n", handshake{version: "1.0", encoding: "text", compress: "gzip"}, false},
+		{"CHAT/1.0 COMPRESS=zstd\n", handshake{version: "1.0", encoding: "text", compress: "none"}, false},
+		{"CHAT/1.0 ENCODING=xml\n", handshake{}, true},
+		{"CHAT/1.0 VERSIONS=2.0,3.1\n", handshake{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseHandshake(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHandshake(%q) error = %v, want error %t", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseHandshake(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	t.Parallel()
+	supported := []string{"1.0", "1.2", "1.10"}
+
+	tests := []struct {
+		offered []string
+		want    string
+		wantOK  bool
+	}{
+		{[]string{"1.0"}, "1.0", true},
+		{[]string{"1.0", "1.2"}, "1.2", true},
+		{[]string{"1.10", "1.2", "1.0"}, "1.10", true},
+		{[]string{"1.1", "1.2", "2.0"}, "1.2", true},
+		{[]string{"2.0"}, "", false},
+		{[]string{""}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := negotiateVersion(tt.offered, supported)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("negotiateVersion(%v) = %q, %t, want %q, %t", tt.offered, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	if got := versionReply("1.0"); got != "CHAT/1.0 SUPPORTED 1.0" {
+		t.Errorf("Unexpected reply %q", got)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchInterval = time.Hour       // Digest interval when /watch names none
+	minWatchInterval     = 5 * time.Minute // Shortest digest interval
+	maxWatches           = 10              // Rooms a client may watch at once
+	digestMessages       = 5               // Latest messages quoted in a digest
+)
+
+// watch is a subscription of a client to the digest of a room.
+type watch struct {
+	c     *client
+	room  string
+	every time.Duration
+	seen  int // Value of the room's posted counter at the last digest
+}
+
+// watches holds the digest subscriptions by scheduler job key. They last
+// for the session of the watching client.
+var watches = struct {
+	sync.Mutex
+	byKey map[string]*watch
+}{byKey: make(map[string]*watch)}
+
+// watchJobKey names the scheduler job sending the digest of room to c.
+func watchJobKey(c *client, room string) string {
+	return fmt.Sprintf("watch:%p:%s", c, room)
+}
+
+// buildDigest renders the digest of room for count new messages, quoting
+// the latest ones from history.
+func buildDigest(loc locale, room string, history []string, count int, every time.Duration) []string {
+	noun := "messages"
+	if count == 1 {
+		noun = "message"
+	}
+	lines := []string{fmt.Sprintf("%s new %s in the last %s", loc.number(count), noun, every)}
+	quoted := min(count, digestMessages, len(history))
+	if quoted > 0 {
+		lines[0] += ", latest:"
+	}
+	for _, msg := range history[len(history)-quoted:] {
+		lines = append(lines, "  "+msg)
+	}
+	for i, line := range lines {
+		lines[i] = fmt.Sprintf("[PM from %s]: %s", room, line)
+	}
+	return lines
+}
+
+// sendDigest delivers the digest watched under key and schedules the next
+// one. The subscription ends when its client has disconnected or the room
+// is gone.
+func sendDigest(key string) {
+	watches.Lock()
+	w, ok := watches.byKey[key]
+	watches.Unlock()
+	if !ok {
+		return
+	}
+
+	mutex.Lock()
+	r, roomExists := rooms[w.room]
+	_, online := clients[w.c.conn]
+	var history []string
+	var posted int
+	inRoom := w.c.room == w.room
+	if roomExists {
+		history, posted = append([]string(nil), r.history...), r.posted
+	}
+	mutex.Unlock()
+	if !roomExists || !online {
+		watches.Lock()
+		delete(watches.byKey, key)
+		watches.Unlock()
+		return
+	}
+
+	// Members already saw the activity as it happened
+	if count := posted - w.seen; count > 0 && !inRoom {
+		for _, line := range buildDigest(w.c.locale(), w.room, history, count, w.every) {
+			w.c.send(line)
+		}
+	}
+	w.seen = posted
+	jobs.schedule(key, time.Now().Add(w.every), func() { sendDigest(key) })
+}
+
+// handleWatchCommand processes "/watch [#room [interval] | <user>]" and
+// "/unwatch #room|<user>". Watching a room sends a periodic private digest
+// of its activity, watching a user a notice when their presence changes;
+// the '#' tells the two apart.
+func handleWatchCommand(c *client, call commandCall) {
+	if call.name == "/unwatch" {
+		if len(call.args) != 1 {
+			c.send("Usage: /unwatch #room|<user>")
+			return
+		}
+		if !strings.HasPrefix(call.args[0], "#") {
+			unwatchUser(c, call.args[0])
+			return
+		}
+		key := watchJobKey(c, call.args[0])
+		watches.Lock()
+		_, ok := watches.byKey[key]
+		delete(watches.byKey, key)
+		watches.Unlock()
+		if !ok {
+			c.send(fmt.Sprintf("You are not watching %s", call.args[0]))
+			return
+		}
+		jobs.cancel(key)
+		c.send(fmt.Sprintf("Stopped watching %s", call.args[0]))
+		return
+	}
+
+	if len(call.args) == 0 {
+		watched := append(watchedRooms(c), watchedUsers(c)...)
+		if len(watched) == 0 {
+			c.send("You are not watching any rooms or users")
+		} else {
+			c.send("Watching: " + strings.Join(watched, ", "))
+		}
+		return
+	}
+	if len(call.args) > 2 || (len(call.args) == 2 && !strings.HasPrefix(call.args[0], "#")) {
+		c.send("Usage: /watch [#room [interval] | <user>]")
+		return
+	}
+	if !strings.HasPrefix(call.args[0], "#") {
+		watchUser(c, call.args[0])
+		return
+	}
+
+	every := defaultWatchInterval
+	if len(call.args) == 2 {
+		d, err := parseDuration(call.args[1])
+		if err != nil || d < minWatchInterval {
+			c.send(fmt.Sprintf("Digest interval must be a duration of at least %s", minWatchInterval))
+			return
+		}
+		every = d
+	}
+
+	name := call.args[0]
+	mutex.Lock()
+	r, ok := rooms[name]
+	var posted int
+	if ok {
+		posted = r.posted
+	}
+	mutex.Unlock()
+	if !ok {
+		c.send(errUnknownRoom.Error())
+		return
+	}
+	if !guestMayEnter(c, name) {
+		c.send(errGuestRoom.Error())
+		return
+	}
+
+	key := watchJobKey(c, name)
+	watches.Lock()
+	_, renewing := watches.byKey[key]
+	if !renewing && len(watchedRoomsLocked(c)) >= maxWatches {
+		watches.Unlock()
+		c.send(fmt.Sprintf("You can watch at most %d rooms", maxWatches))
+		return
+	}
+	watches.byKey[key] = &watch{c: c, room: name, every: every, seen: posted}
+	watches.Unlock()
+
+	jobs.schedule(key, time.Now().Add(every), func() { sendDigest(key) })
+	log.Printf("%s watches %s every %s", c.name, name, every)
+	c.send(fmt.Sprintf("Watching %s, digest every %s", name, every))
+}
+
+// watchedRooms lists the rooms c watches, sorted by name.
+func watchedRooms(c *client) []string {
+	watches.Lock()
+	defer watches.Unlock()
+	return watchedRoomsLocked(c)
+}
+
+// watchedRoomsLocked is watchedRooms for callers holding watches.
+func watchedRoomsLocked(c *client) []string {
+	var names []string
+	for _, w := range watches.byKey {
+		if w.c == c {
+			names = append(names, w.room)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
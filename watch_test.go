@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDigest(t *testing.T) {
+	t.Parallel()
+	history := []string{"a: 1", "b: 2", "a: 3", "b: 4", "a: 5", "b: 6"}
+
+	got := buildDigest(defaultLocale, "#releases", history, 2, time.Hour)
+	want := []string{
+		"[PM from #releases]: 2 new messages in the last 1h0m0s, latest:",
+		"[PM from #releases]:   a: 5",
+		"[PM from #releases]:   b: 6",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got := buildDigest(defaultLocale, "#releases", history, 40, time.Hour); len(got) != 1+digestMessages {
+		t.Errorf("Expected %d quoted messages, got %q", digestMessages, got)
+	}
+	if got := buildDigest(defaultLocale, "#releases", nil, 3, time.Hour); len(got) != 1 || strings.Contains(got[0], "latest") {
+		t.Errorf("Expected only the count without history, got %q", got)
+	}
+}
+
+func TestHandleWatchCommand(t *testing.T) {
+	r, err := createRoom("#watch-test", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newMockConn()
+	c := &client{conn: conn, name: "watcher", room: defaultRoom}
+	mutex.Lock()
+	clients[conn] = c
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(rooms, r.name)
+		delete(clients, conn)
+		mutex.Unlock()
+		jobs.cancel(watchJobKey(c, r.name))
+	}()
+
+	handleWatchCommand(c, callOf("/watch #watch-test 1m"))
+	if got := conn.writeBuffer.String(); !strings.Contains(got, "at least 5m0s") {
+		t.Errorf("Expected short intervals to be refused, got %q", got)
+	}
+	handleWatchCommand(c, callOf("/watch #watch-test 10m"))
+	if !jobs.pending(watchJobKey(c, r.name)) {
+		t.Fatal("Expected a digest to be scheduled")
+	}
+	handleWatchCommand(c, callOf("/watch"))
+	if got := conn.writeBuffer.String(); !strings.Contains(got, "Watching: #watch-test") {
+		t.Errorf("Expected the watched rooms to be listed, got %q", got)
+	}
+
+	t.Run("Digest", func(t *testing.T) {
+		conn.writeBuffer.Reset()
+		sendDigest(watchJobKey(c, r.name))
+		if got := conn.writeBuffer.String(); got != "" {
+			t.Errorf("Expected no digest without activity, got %q", got)
+		}
+		mutex.Lock()
+		r.addToHistory("bob: v1.2 is out")
+		mutex.Unlock()
+		sendDigest(watchJobKey(c, r.name))
+		if got := conn.writeBuffer.String(); !strings.Contains(got, "1 new message in") || !strings.Contains(got, "bob: v1.2 is out") {
+			t.Errorf("Expected a digest of the new message, got %q", got)
+		}
+	})
+
+	handleWatchCommand(c, callOf("/unwatch #watch-test"))
+	if jobs.pending(watchJobKey(c, r.name)) {
+		t.Error("Expected /unwatch to cancel the digest")
+	}
+}
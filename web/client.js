@@ -0,0 +1,165 @@
+// Browser client of the chat, speaking the JSON messages of the WebSocket
+// gateway at /ws of the server that serves this page.
+"use strict";
+
+const log = document.getElementById("log");
+const login = document.getElementById("login");
+const input = document.getElementById("input");
+const line = document.getElementById("line");
+const prompt = document.getElementById("prompt");
+const roomLabel = document.getElementById("room");
+const stateLabel = document.getElementById("state");
+
+let socket = null;
+let pendingName = ""; // Answer to the server's first prompt
+let answering = false; // The next line answers a prompt
+let me = ""; // Name logged in with, empty for guests
+
+// Messages that only matter to the protocol
+const quiet = new Set(["version", "cap_ls", "cap_ack", "cap_nak", "session", "typing", "pong", "replay_start", "replay_end"]);
+
+function connect(name, guest) {
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  const query = guest ? "?guest=1" : "";
+  socket = new WebSocket(scheme + "//" + location.host + "/ws" + query);
+  pendingName = name;
+  me = name;
+  setState("Connecting…");
+
+  socket.onopen = () => {
+    setState("Connected");
+    login.hidden = true;
+    input.hidden = false;
+    line.focus();
+  };
+  socket.onmessage = (event) => {
+    let msg;
+    try {
+      msg = JSON.parse(event.data);
+    } catch {
+      show({type: "notice", body: event.data});
+      return;
+    }
+    receive(msg);
+  };
+  socket.onclose = () => {
+    setState("Disconnected");
+    show({type: "error", body: "Connection closed."});
+    socket = null;
+    answering = false;
+    prompt.textContent = "";
+    input.hidden = true;
+    login.hidden = false;
+  };
+}
+
+function receive(msg) {
+  switch (msg.type) {
+  case "ping":
+    send({type: "pong", body: msg.body});
+    return;
+  case "prompt":
+    if (pendingName) {
+      send({type: "name", body: pendingName});
+      pendingName = "";
+      return;
+    }
+    answering = true;
+    prompt.textContent = msg.body;
+    show({type: "notice", body: msg.body});
+    return;
+  case "notice":
+    if (msg.body.startsWith("Now talking in ")) {
+      roomLabel.textContent = msg.body.slice("Now talking in ".length);
+    }
+    break;
+  case "chat":
+  case "action":
+  case "system":
+    if (msg.room && !roomLabel.textContent) {
+      roomLabel.textContent = msg.room;
+    }
+    break;
+  }
+  if (!quiet.has(msg.type)) {
+    show(msg);
+  }
+}
+
+function show(msg) {
+  const atBottom = log.scrollTop + log.clientHeight >= log.scrollHeight - 4;
+  const row = document.createElement("div");
+  row.className = msg.type + (msg.mention ? " mention" : "");
+
+  const time = document.createElement("span");
+  time.className = "time";
+  const when = msg.timestamp ? new Date(msg.timestamp) : new Date();
+  time.textContent = when.toLocaleTimeString([], {hour: "2-digit", minute: "2-digit"}) + " ";
+  row.append(time);
+
+  switch (msg.type) {
+  case "chat":
+    row.append(sender(msg.sender + ": "), msg.body);
+    break;
+  case "action":
+    row.append("* " + msg.sender + " " + msg.body);
+    break;
+  case "private":
+    row.append(sender("[PM from " + msg.sender + "] "), msg.body);
+    break;
+  case "private_echo":
+    row.append(sender("[PM to " + msg.to + "] "), msg.body);
+    break;
+  default:
+    row.append(msg.body);
+  }
+  log.append(row);
+  if (atBottom) {
+    log.scrollTop = log.scrollHeight;
+  }
+}
+
+function sender(text) {
+  const span = document.createElement("span");
+  span.className = "sender";
+  span.textContent = text;
+  return span;
+}
+
+function send(msg) {
+  if (socket && socket.readyState === WebSocket.OPEN) {
+    socket.send(JSON.stringify(msg));
+  }
+}
+
+function setState(text) {
+  stateLabel.textContent = text;
+}
+
+login.addEventListener("submit", (event) => {
+  event.preventDefault();
+  const name = document.getElementById("name").value.trim();
+  if (name) {
+    connect(name, false);
+  }
+});
+
+document.getElementById("guest").addEventListener("click", () => connect("", true));
+
+input.addEventListener("submit", (event) => {
+  event.preventDefault();
+  const text = line.value;
+  line.value = "";
+  if (answering) {
+    answering = false;
+    prompt.textContent = "";
+    me = text.trim() || me;
+    send({type: "name", body: text.trim()});
+  } else if (text.startsWith("/")) {
+    send({type: "command", body: text});
+  } else if (text.trim()) {
+    // The server does not echo the sender's own messages
+    send({type: "chat", body: text});
+    show({type: "chat", sender: me || "you", body: text});
+  }
+});
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles is the browser client, served by the WebSocket gateway when
+// web_client is set.
+//
+//go:embed web
+var webFiles embed.FS
+
+// webClientPolicy keeps the page to its own scripts and to the gateway it
+// came from.
+const webClientPolicy = "default-src 'self'; connect-src 'self'; frame-ancestors 'none'"
+
+// webClientHandler serves the browser client.
+func webClientHandler() http.Handler {
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err) // The directory is embedded above
+	}
+	static := http.FileServerFS(files)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", webClientPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		static.ServeHTTP(w, r)
+	})
+}
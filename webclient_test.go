@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebClientServed(t *testing.T) {
+	saved := config.WebClient
+	defer func() { config.WebClient = saved }()
+
+	config.WebClient = true
+	gateway := httptest.NewServer(websocketHandler())
+	defer gateway.Close()
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/", "text/html", `src="client.js"`},
+		{"/client.js", "javascript", `"/ws"`},
+		{"/client.css", "text/css", "#log"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(gateway.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), tt.contentType) {
+			t.Errorf("GET %s: %s, %s", tt.path, resp.Status, resp.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(string(body), tt.contains) {
+			t.Errorf("GET %s: expected %q in the body", tt.path, tt.contains)
+		}
+		if resp.Header.Get("Content-Security-Policy") != webClientPolicy {
+			t.Errorf("GET %s: expected the content security policy, got %q", tt.path, resp.Header.Get("Content-Security-Policy"))
+		}
+	}
+}
+
+func TestWebClientOffByDefault(t *testing.T) {
+	saved := config.WebClient
+	defer func() { config.WebClient = saved }()
+
+	config.WebClient = false
+	gateway := httptest.NewServer(websocketHandler())
+	defer gateway.Close()
+
+	resp, err := http.Get(gateway.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected no page without web_client, got %s", resp.Status)
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// websocketPath is where the gateway accepts WebSocket connections.
+const websocketPath = "/ws"
+
+// websocketGUID is appended to the client's key to compute the accept
+// key of the opening handshake (RFC 6455, section 4.2.2).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// Status codes of close frames.
+const (
+	wsCloseNormal      = 1000
+	wsCloseProtocol    = 1002
+	wsCloseUnsupported = 1003 // Binary messages, the gateway speaks JSON text only
+	wsCloseTooBig      = 1009
+)
+
+// wsCloseTimeout is how long the closing frame may take to go out.
+const wsCloseTimeout = time.Second
+
+var (
+	errWebSocketProtocol = errors.New("WebSocket protocol error")
+	errWebSocketBinary   = errors.New("WebSocket binary messages are not supported")
+	errWebSocketTooBig   = errors.New("WebSocket message too long")
+)
+
+// startWebSocketGateway accepts browser clients on addr in the background.
+// They speak the JSON encoding, one message per text frame, and share the
+// rooms and broadcasts of the TCP clients.
+func startWebSocketGateway(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: websocketHandler(), ReadHeaderTimeout: handshakeTimeout}
+	go func() {
+		log.Printf("WebSocket gateway listening on %s", addr)
+		if err := srv.Serve(ln); err != nil {
+			log.Printf("Error serving WebSocket gateway: %v", err)
+		}
+	}()
+	return nil
+}
+
+// websocketHandler serves the gateway's endpoints, and the browser
+// client when web_client is set.
+func websocketHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(websocketPath, serveWebSocket)
+	if config.WebClient {
+		mux.Handle("/", webClientHandler())
+	}
+	return mux
+}
+
+// serveWebSocket upgrades the request and runs a chat session over it.
+// The query stands in for the handshake line of TCP clients: guest=1,
+// versions=, caps= and resume= ask for what GUEST, VERSIONS=, CAPS= and
+// RESUME= do; the encoding is always JSON.
+func serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	line, ok := websocketHandshake(r)
+	if !ok {
+		http.Error(w, "Invalid handshake parameters", http.StatusBadRequest)
+		return
+	}
+	raw, rw, ok := upgradeWebSocket(w, r)
+	if !ok {
+		return
+	}
+	conn := newWSConn(raw, rw.Reader)
+	if !admissible(conn) || refuseBanned(conn) {
+		return
+	}
+	openSession(conn, line)
+}
+
+// websocketHandshake builds the handshake line of a TCP client from the
+// query of r. Values with spaces would add tokens of their own and are
+// refused.
+func websocketHandshake(r *http.Request) (string, bool) {
+	query := r.URL.Query()
+	tokens := []string{"CHAT/1.0", encodingToken + encodingJSON}
+	if query.Get("guest") == "1" {
+		tokens = append(tokens, "GUEST")
+	}
+	for _, param := range []struct{ name, token string }{
+		{"versions", versionsToken},
+		{"caps", capsToken},
+		{"resume", resumeToken},
+	} {
+		value := query.Get(param.name)
+		if strings.ContainsFunc(value, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+			return "", false
+		}
+		if value != "" {
+			tokens = append(tokens, param.token+value)
+		}
+	}
+	return strings.Join(tokens, " "), true
+}
+
+// upgradeWebSocket answers the opening handshake of r and takes over its
+// connection. Failed handshakes get an HTTP error and false.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, nil, false
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, nil, false
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, nil, false
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if !websocketOriginAllowed(r.Header.Get("Origin"), r.Host) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return nil, nil, false
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error taking over WebSocket connection: %v", err)
+		return nil, nil, false
+	}
+	conn.SetDeadline(time.Time{})
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n"
+	if err := writeFull(conn, []byte(response)); err != nil {
+		conn.Close()
+		return nil, nil, false
+	}
+	return conn, rw, true
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept value for key.
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHasToken reports whether the comma-separated header name of h
+// lists token, ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// websocketOriginAllowed reports whether pages from origin may connect to
+// the gateway at host: pages of the gateway itself, such as the browser
+// client, and of the origins listed in websocket_origins. Requests without
+// an Origin header do not come from a browser page and are let through.
+// Other sites could otherwise open sessions from their visitors' browsers.
+func websocketOriginAllowed(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, host) {
+		return true
+	}
+	return slices.ContainsFunc(config.WebSocketOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
+}
+
+// wsConn carries the session's lines over a WebSocket: every line written
+// goes out as a text frame, and every text message read comes in as a
+// line. Pings are answered and a close frame ends the stream.
+type wsConn struct {
+	net.Conn
+	r       *bufio.Reader
+	buf     []byte // Bytes read but not parsed into a frame yet
+	tmp     [512]byte
+	message []byte     // Fragments of the message being read
+	started bool       // Whether the first fragment of message has come
+	pending []byte     // Rest of the message being returned by Read
+	writeMu sync.Mutex // Keeps the frames of the two sides whole
+	closing atomic.Bool
+}
+
+func newWSConn(conn net.Conn, r *bufio.Reader) *wsConn {
+	return &wsConn{Conn: conn, r: r}
+}
+
+// Read returns the text messages of the client, each followed by a
+// newline. Messages longer than maxLineBytes end the connection.
+func (c *wsConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		message, err := c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = append(message, '\n')
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readMessage reads frames up to the end of the next text message,
+// handling the control frames in between. A partial frame and the
+// fragments of the message survive read timeouts, so the session's read
+// deadline cannot leave the stream in the middle of a frame.
+func (c *wsConn) readMessage() ([]byte, error) {
+	for {
+		fin, op, payload, n, err := parseFrame(c.buf, maxLineBytes)
+		if err != nil {
+			switch err {
+			case errWebSocketProtocol:
+				c.closeWith(wsCloseProtocol)
+			case errWebSocketTooBig:
+				c.closeWith(wsCloseTooBig)
+			}
+			return nil, err
+		}
+		if n == 0 {
+			read, err := c.r.Read(c.tmp[:])
+			c.buf = append(c.buf, c.tmp[:read]...)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		c.buf = append(c.buf[:0], c.buf[n:]...)
+
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.closeWith(wsCloseNormal)
+			return nil, io.EOF
+		case wsBinary:
+			c.closeWith(wsCloseUnsupported)
+			return nil, errWebSocketBinary
+		case wsText:
+			if c.started {
+				c.closeWith(wsCloseProtocol)
+				return nil, errWebSocketProtocol
+			}
+			c.started = true
+		case wsContinuation:
+			if !c.started {
+				c.closeWith(wsCloseProtocol)
+				return nil, errWebSocketProtocol
+			}
+		}
+		if len(c.message)+len(payload) > maxLineBytes {
+			c.closeWith(wsCloseTooBig)
+			return nil, errWebSocketTooBig
+		}
+		c.message = append(c.message, payload...)
+		if fin {
+			message := c.message
+			c.message, c.started = nil, false
+			return message, nil
+		}
+	}
+}
+
+// parseFrame parses the frame at the start of buf, which a client must
+// mask, and returns it unmasked along with its size in buf. The size is 0
+// while the frame is incomplete. Payloads over limit are refused as soon
+// as their length is known.
+func parseFrame(buf []byte, limit int) (fin bool, op byte, payload []byte, n int, err error) {
+	if len(buf) < 2 {
+		return false, 0, nil, 0, nil
+	}
+	fin, op = buf[0]&0x80 != 0, buf[0]&0x0f
+	masked, length := buf[1]&0x80 != 0, uint64(buf[1]&0x7f)
+	if buf[0]&0x70 != 0 || !masked {
+		return false, 0, nil, 0, errWebSocketProtocol
+	}
+	switch op {
+	case wsContinuation, wsText, wsBinary:
+	case wsClose, wsPing, wsPong:
+		if !fin || length > 125 {
+			return false, 0, nil, 0, errWebSocketProtocol
+		}
+	default:
+		return false, 0, nil, 0, errWebSocketProtocol
+	}
+	head := 2
+	switch length {
+	case 126:
+		if len(buf) < 4 {
+			return false, 0, nil, 0, nil
+		}
+		length, head = uint64(binary.BigEndian.Uint16(buf[2:4])), 4
+	case 127:
+		if len(buf) < 10 {
+			return false, 0, nil, 0, nil
+		}
+		length, head = binary.BigEndian.Uint64(buf[2:10]), 10
+	}
+	if length > uint64(limit) {
+		return false, 0, nil, 0, errWebSocketTooBig
+	}
+	size := head + 4 + int(length)
+	if len(buf) < size {
+		return false, 0, nil, 0, nil
+	}
+	mask := buf[head : head+4]
+	payload = append([]byte(nil), buf[head+4:size]...)
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, size, nil
+}
+
+// appendFrame appends an unmasked frame, as servers send them, with a
+// single fragment of payload.
+func appendFrame(out []byte, op byte, payload []byte) []byte {
+	out = append(out, 0x80|op)
+	switch n := len(payload); {
+	case n <= 125:
+		out = append(out, byte(n))
+	case n <= 0xffff:
+		out = append(out, 126)
+		out = binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, 127)
+		out = binary.BigEndian.AppendUint64(out, uint64(n))
+	}
+	return append(out, payload...)
+}
+
+// Write sends every line in p as a text frame, without its newline. Text
+// after the last newline, such as a prompt, goes out as a frame too.
+func (c *wsConn) Write(p []byte) (int, error) {
+	var out []byte
+	for text := string(p); text != ""; {
+		var line string
+		line, text, _ = strings.Cut(text, "\n")
+		out = appendFrame(out, wsText, []byte(line))
+	}
+	if err := c.send(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	return c.send(appendFrame(nil, op, payload))
+}
+
+func (c *wsConn) send(frames []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeFull(c.Conn, frames)
+}
+
+// closeWith sends the close frame with status code, once.
+func (c *wsConn) closeWith(code uint16) {
+	if c.closing.Swap(true) {
+		return
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.Conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+	c.Conn.Write(appendFrame(nil, wsClose, binary.BigEndian.AppendUint16(nil, code)))
+}
+
+// Close ends the session with a normal close frame and closes the
+// connection.
+func (c *wsConn) Close() error {
+	c.closeWith(wsCloseNormal)
+	return c.Conn.Close()
+}
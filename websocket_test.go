@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestClient is the browser side of a gateway connection.
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialWebSocket opens a WebSocket to the gateway at addr with query.
+func dialWebSocket(t *testing.T, addr, query string) *wsTestClient {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	request := "GET " + websocketPath + query + " HTTP/1.1\r\n" +
+		"Host: " + addr + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101 Switching Protocols, got %s", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected the accept key of the RFC example, got %q", got)
+	}
+	return &wsTestClient{t: t, conn: conn, r: r}
+}
+
+// maskedFrame returns a frame as clients send it.
+func maskedFrame(fin bool, op byte, payload []byte) []byte {
+	frame := appendFrame(nil, op, payload)
+	header := frame[:len(frame)-len(payload)]
+	if !fin {
+		header[0] &^= 0x80
+	}
+	header[1] |= 0x80
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	out := append(header, mask[:]...)
+	for i, b := range payload {
+		out = append(out, b^mask[i%4])
+	}
+	return out
+}
+
+func (c *wsTestClient) send(msg wireMessage) {
+	c.t.Helper()
+	payload, _ := json.Marshal(msg)
+	if _, err := c.conn.Write(maskedFrame(true, wsText, payload)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readFrame reads an unmasked frame of the server.
+func (c *wsTestClient) readFrame() (byte, []byte) {
+	c.t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if head[0]&0x80 == 0 || head[1]&0x80 != 0 {
+		c.t.Fatalf("Expected a final unmasked frame, got header % x", head)
+	}
+	length := int(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.r, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.r, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+// expect reads messages until one of type kind whose body contains substr.
+func (c *wsTestClient) expect(kind, substr string) wireMessage {
+	c.t.Helper()
+	for {
+		op, payload := c.readFrame()
+		if op != wsText {
+			c.t.Fatalf("Expected a text frame, got opcode %d", op)
+		}
+		var msg wireMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			c.t.Fatalf("Expected one JSON message per frame, got %q", payload)
+		}
+		if msg.Type == kind && strings.Contains(msg.Body, substr) {
+			return msg
+		}
+	}
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	t.Parallel()
+	if got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("websocketAccept() = %q", got)
+	}
+}
+
+func TestWebSocketHandshakeLine(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{"", "CHAT/1.0 ENCODING=json", true},
+		{"?guest=1", "CHAT/1.0 ENCODING=json GUEST", true},
+		{"?versions=1.0&caps=replay,ids", "CHAT/1.0 ENCODING=json VERSIONS=1.0 CAPS=replay,ids", true},
+		{"?resume=abc:42", "CHAT/1.0 ENCODING=json RESUME=abc:42", true},
+		{"?caps=replay%20ENCODING=text", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, websocketPath+tt.query, nil)
+		got, ok := websocketHandshake(r)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("websocketHandshake(%q) = %q, %t, want %q, %t", tt.query, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestWebSocketOriginAllowed(t *testing.T) {
+	saved := config.WebSocketOrigins
+	defer func() { config.WebSocketOrigins = saved }()
+
+	config.WebSocketOrigins = []string{"https://chat.example.com/"}
+	tests := []struct {
+		origin, host string
+		want         bool
+	}{
+		{"https://chat.example.com", "gateway.example.com:8992", true},
+		{"", "gateway.example.com:8992", true},
+		{"http://gateway.example.com:8992", "gateway.example.com:8992", true},
+		{"https://evil.example.com", "gateway.example.com:8992", false},
+	}
+	for _, tt := range tests {
+		if got := websocketOriginAllowed(tt.origin, tt.host); got != tt.want {
+			t.Errorf("websocketOriginAllowed(%q, %q) = %t, want %t", tt.origin, tt.host, got, tt.want)
+		}
+	}
+
+	config.WebSocketOrigins = nil
+	if websocketOriginAllowed("https://evil.example.com", "gateway.example.com:8992") {
+		t.Error("Expected other sites refused without websocket_origins")
+	}
+	if !websocketOriginAllowed("http://gateway.example.com:8992", "gateway.example.com:8992") {
+		t.Error("Expected the gateway's own pages allowed without websocket_origins")
+	}
+}
+
+func TestParseFrame(t *testing.T) {
+	t.Parallel()
+	long := bytes.Repeat([]byte("x"), 300)
+	tests := []struct {
+		name    string
+		frame   []byte
+		wantOp  byte
+		wantFin bool
+		wantErr error
+	}{
+		{"text", maskedFrame(true, wsText, []byte("hello")), wsText, true, nil},
+		{"extended length", maskedFrame(true, wsText, long), wsText, true, nil},
+		{"fragment", maskedFrame(false, wsText, []byte("hel")), wsText, false, nil},
+		{"unmasked", appendFrame(nil, wsText, []byte("hello")), 0, false, errWebSocketProtocol},
+		{"too long", maskedFrame(true, wsText, long), 0, false, errWebSocketTooBig},
+		{"fragmented ping", maskedFrame(false, wsPing, nil), 0, false, errWebSocketProtocol},
+		{"reserved opcode", maskedFrame(true, 0x3, nil), 0, false, errWebSocketProtocol},
+	}
+	for _, tt := range tests {
+		limit := 1024
+		if tt.name == "too long" {
+			limit = 100
+		}
+		fin, op, payload, n, err := parseFrame(tt.frame, limit)
+		if err != tt.wantErr {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (n != len(tt.frame) || fin != tt.wantFin || op != tt.wantOp || bytes.Contains(payload, []byte{0x12})) {
+			t.Errorf("%s: got size %d, fin %t, op %d, payload %q", tt.name, n, fin, op, payload)
+		}
+		if err == nil {
+			// Any prefix of the frame is incomplete rather than an error
+			for i := range tt.frame {
+				if _, _, _, n, err := parseFrame(tt.frame[:i], limit); n != 0 || err != nil {
+					t.Errorf("%s: %d of %d bytes gave size %d, %v", tt.name, i, len(tt.frame), n, err)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestWSConnReassemblesMessages(t *testing.T) {
+	t.Parallel()
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	conn := newWSConn(server, bufio.NewReader(server))
+
+	go func() {
+		client.Write(maskedFrame(false, wsText, []byte("hel")))
+		client.Write(maskedFrame(true, wsPing, []byte("beat")))
+		client.Write(maskedFrame(true, wsContinuation, []byte("lo")))
+	}()
+	pong := make(chan []byte, 1)
+	go func() {
+		r := bufio.NewReader(client)
+		head := make([]byte, 2)
+		io.ReadFull(r, head)
+		payload := make([]byte, head[1])
+		io.ReadFull(r, payload)
+		pong <- append(head, payload...)
+	}()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "hello\n" {
+		t.Fatalf("Expected the fragments as one line, got %q, %v", line, err)
+	}
+	if got, want := <-pong, appendFrame(nil, wsPong, []byte("beat")); !bytes.Equal(got, want) {
+		t.Errorf("Expected the ping answered with % x, got % x", want, got)
+	}
+}
+
+func TestWSConnSurvivesReadTimeouts(t *testing.T) {
+	t.Parallel()
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	conn := newWSConn(server, bufio.NewReader(server))
+
+	first := maskedFrame(false, wsText, []byte("hel"))
+	last := maskedFrame(true, wsContinuation, []byte("lo"))
+	// A whole fragment, then half of the next, before the deadline
+	go client.Write(append(first, last[:3]...))
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 16)); err == nil {
+		t.Fatal("Expected a read timeout")
+	} else if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	go client.Write(last[3:])
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "hello\n" {
+		t.Fatalf("Expected the message whole after the timeout, got %q, %v", line, err)
+	}
+}
+
+func TestWebSocketRefusesBadUpgrades(t *testing.T) {
+	gateway := httptest.NewServer(websocketHandler())
+	defer gateway.Close()
+
+	saved := config.WebSocketOrigins
+	config.WebSocketOrigins = []string{"https://chat.example.com"}
+	defer func() { config.WebSocketOrigins = saved }()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"plain request", nil, http.StatusUpgradeRequired},
+		{"old version", map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"bad key", map[string]string{"Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+		{"foreign origin", map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, gateway.URL+websocketPath, nil)
+		if tt.name != "plain request" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		}
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestWebSocketGatewayBridgesRooms(t *testing.T) {
+	srv, _ := startTestServer(t)
+	gateway := httptest.NewServer(websocketHandler())
+	defer gateway.Close()
+
+	alice := srv.Connect(t, "alice")
+	browser := dialWebSocket(t, strings.TrimPrefix(gateway.URL, "http://"), "")
+	browser.expect("prompt", "ENTER YOUR NAME")
+	browser.send(wireMessage{Type: "name", Body: "webby"})
+	browser.expect("notice", "Welcome, webby")
+	alice.Expect("webby has joined our chat")
+
+	browser.send(wireMessage{Type: "chat", Body: "hello from the browser"})
+	alice.Expect("webby: hello from the browser")
+
+	alice.Send("hello webby")
+	if msg := browser.expect("chat", "hello webby"); msg.Sender != "alice" || msg.Room != defaultRoom {
+		t.Errorf("Expected alice's message in %s, got %+v", defaultRoom, msg)
+	}
+
+	browser.conn.Write(maskedFrame(true, wsClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal)))
+	for op, _ := browser.readFrame(); op != wsClose; op, _ = browser.readFrame() {
+		if op != wsText {
+			t.Fatalf("Expected the close frame echoed, got opcode %d", op)
+		}
+	}
+	alice.Expect("webby has left our chat")
+}